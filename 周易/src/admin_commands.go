@@ -0,0 +1,497 @@
+// admin_commands.go 实现命令行管理命令
+// 以子命令形式运行，如 `./Yijing.exe prewarm -from 2025-01-01 -to 2025-12-31`，
+// 执行完毕后程序直接退出，不启动HTTP服务
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// adminCommand 管理命令定义
+type adminCommand struct {
+	Usage string                    // 命令用途说明
+	Run   func(args []string) error // 命令执行函数，args为子命令之后的参数
+}
+
+// adminCommands 管理命令注册表，键为子命令名称
+var adminCommands = map[string]adminCommand{
+	"prewarm": {
+		Usage: "预热指定日期范围的万年历缓存",
+		Run:   runPrewarmCommand,
+	},
+	"verify-calendar": {
+		Usage: "比对日期范围内万年历API与本地历法的三柱并输出差异报告",
+		Run:   runVerifyCalendarCommand,
+	},
+	"check-fonts": {
+		Usage: "检查各主题的字体回退链，列出卦象图中无法显示的字符",
+		Run:   runCheckFontsCommand,
+	},
+	"convert-font": {
+		Usage: "把CFF轮廓的OpenType字体转换为可嵌入PDF报告的TrueType字体",
+		Run:   runConvertFontCommand,
+	},
+	"bench-render": {
+		Usage: "按不同并发数渲染固定盘面，输出吞吐量和相对单线程的加速比",
+		Run:   runBenchRenderCommand,
+	},
+	"check-golden": {
+		Usage: "渲染固定盘面并与golden目录中的基准图比对，失败时生成HTML差异报告",
+		Run:   runCheckGoldenCommand,
+	},
+	"update-golden": {
+		Usage: "重新渲染固定盘面并覆盖golden目录中的基准图",
+		Run:   runUpdateGoldenCommand,
+	},
+	"check-api-docs": {
+		Usage: "检查API接口文档是否覆盖OpenAPI文档中的全部接口、参数和错误码",
+		Run:   runCheckAPIDocsCommand,
+	},
+	"preview-template": {
+		Usage: "用固定盘面渲染版式模板文件或已注册的模板，输出有动爻和无动爻两张预览图",
+		Run:   runPreviewTemplateCommand,
+	},
+}
+
+// runAdminCommand 执行指定名称的管理命令
+//
+// 参数：
+//   - name: 子命令名称
+//   - args: 子命令参数
+//
+// 返回值：命令不存在或执行失败时返回错误
+func runAdminCommand(name string, args []string) error {
+	command, exists := adminCommands[name]
+	if !exists {
+		return fmt.Errorf("未知的管理命令: %s\n%s", name, adminCommandsUsage())
+	}
+	return command.Run(args)
+}
+
+// adminCommandsUsage 生成所有管理命令的帮助信息
+func adminCommandsUsage() string {
+	names := make([]string, 0, len(adminCommands))
+	for name := range adminCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	usage := "可用的管理命令:"
+	for _, name := range names {
+		usage += fmt.Sprintf("\n  %-16s %s", name, adminCommands[name].Usage)
+	}
+	return usage
+}
+
+// parseDateArg 解析YYYY-MM-DD格式的日期参数，使用北京时区
+func parseDateArg(value string) (time.Time, error) {
+	location, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		location = time.FixedZone("CST", 8*3600)
+	}
+	return time.ParseInLocation("2006-01-02", value, location)
+}
+
+// runPrewarmCommand 预热万年历缓存
+// 用法：prewarm -from 2025-01-01 -to 2025-12-31 [-interval 1s]
+func runPrewarmCommand(args []string) error {
+	flags := flag.NewFlagSet("prewarm", flag.ContinueOnError)
+	fromArg := flags.String("from", "", "起始日期，格式YYYY-MM-DD")
+	toArg := flags.String("to", "", "结束日期（包含），格式YYYY-MM-DD")
+	interval := flags.Duration("interval", time.Duration(GetConfig().Calendar.PrewarmIntervalMs)*time.Millisecond, "两次API调用的间隔")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *fromArg == "" || *toArg == "" {
+		return fmt.Errorf("必须同时指定 -from 和 -to")
+	}
+	from, err := parseDateArg(*fromArg)
+	if err != nil {
+		return fmt.Errorf("起始日期格式错误: %v", err)
+	}
+	to, err := parseDateArg(*toArg)
+	if err != nil {
+		return fmt.Errorf("结束日期格式错误: %v", err)
+	}
+	if to.Before(from) {
+		return fmt.Errorf("结束日期不能早于起始日期")
+	}
+
+	fetched, failed := prewarmCalendarCache(from, to, *interval)
+	log.Printf("缓存状态: %v", getCalendarCache().Stats())
+	if failed > 0 {
+		return fmt.Errorf("有 %d 天预热失败（新增 %d 天），可稍后重新执行", failed, fetched)
+	}
+	return nil
+}
+
+// runVerifyCalendarCommand 交叉校验万年历数据
+// 用法：verify-calendar -from 2025-01-01 -to 2025-12-31 [-out report.json] [-fresh] [-interval 1s]
+func runVerifyCalendarCommand(args []string) error {
+	flags := flag.NewFlagSet("verify-calendar", flag.ContinueOnError)
+	fromArg := flags.String("from", "", "起始日期，格式YYYY-MM-DD")
+	toArg := flags.String("to", "", "结束日期（包含），格式YYYY-MM-DD")
+	out := flags.String("out", "", "差异报告输出路径，默认 output/calendar_verify_<起始>_<结束>.json")
+	fresh := flags.Bool("fresh", false, "绕过缓存，直接调用万年历API")
+	interval := flags.Duration("interval", time.Duration(GetConfig().Calendar.PrewarmIntervalMs)*time.Millisecond, "两次API调用的间隔")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *fromArg == "" || *toArg == "" {
+		return fmt.Errorf("必须同时指定 -from 和 -to")
+	}
+	from, err := parseDateArg(*fromArg)
+	if err != nil {
+		return fmt.Errorf("起始日期格式错误: %v", err)
+	}
+	to, err := parseDateArg(*toArg)
+	if err != nil {
+		return fmt.Errorf("结束日期格式错误: %v", err)
+	}
+	if to.Before(from) {
+		return fmt.Errorf("结束日期不能早于起始日期")
+	}
+
+	report := sweepCalendarRange(from, to, *fresh, *interval)
+
+	reportPath := *out
+	if reportPath == "" {
+		reportPath = filepath.Join("output", fmt.Sprintf("calendar_verify_%s_%s.json", from.Format("20060102"), to.Format("20060102")))
+	}
+	if err := writeCalendarVerifyReport(report, reportPath); err != nil {
+		return err
+	}
+
+	log.Printf("校验完成: 比对 %d 天，失败 %d 天，存在差异 %d 天，报告已写入 %s",
+		report.Checked, report.Failed, report.Mismatched, reportPath)
+	return nil
+}
+
+// runBenchRenderCommand 渲染基准测试
+// 用法：bench-render [-n 200] [-workers 1,2,4,8] [-format png] [-quality 90] [-layout landscape] [-theme classic]
+// 每个并发数渲染n张图，渲染槽位按最大并发数创建，输出每秒渲染张数
+func runBenchRenderCommand(args []string) error {
+	flags := flag.NewFlagSet("bench-render", flag.ContinueOnError)
+	total := flags.Int("n", 200, "每个并发数下渲染的图片数")
+	workersArg := flags.String("workers", fmt.Sprintf("1,%d", runtime.NumCPU()), "逗号分隔的并发数列表")
+	formatArg := flags.String("format", ImageFormatPNG, "图片格式：png、jpeg、svg、gif、apng")
+	quality := flags.Int("quality", 0, "JPEG的编码质量，0表示使用配置的默认值")
+	layoutArg := flags.String("layout", "", "版式，默认使用配置的默认版式")
+	themeArg := flags.String("theme", "", "主题，默认使用配置的默认主题")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var workerCounts []int
+	for _, field := range strings.Split(*workersArg, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || n <= 0 {
+			return fmt.Errorf("并发数无效: %s", field)
+		}
+		workerCounts = append(workerCounts, n)
+	}
+	if *total <= 0 {
+		return fmt.Errorf("渲染数量必须大于0")
+	}
+	format, err := normalizeImageFormat(*formatArg)
+	if err != nil {
+		return err
+	}
+	if err := validateImageQuality(*quality); err != nil {
+		return err
+	}
+	layoutName, err := normalizeLayoutName(*layoutArg)
+	if err != nil {
+		return err
+	}
+	theme, err := resolveTheme(*themeArg, 0)
+	if err != nil {
+		return err
+	}
+
+	// 渲染槽位只创建一次，按最大并发数配置，避免被槽位限制住
+	maxWorkers := 0
+	for _, n := range workerCounts {
+		maxWorkers = max(maxWorkers, n)
+	}
+	GetConfig().Render.MaxConcurrency = maxWorkers
+
+	// 预热字体和背景，不计入耗时
+	chart := benchmarkChart()
+	if _, err := benchmarkRender(chart, theme, format, *quality, layoutName, 1, 1); err != nil {
+		return err
+	}
+
+	log.Printf("渲染基准: %s %s %s，每组 %d 张，CPU核数 %d", theme.Name, layoutName, format, *total, runtime.NumCPU())
+	var baseline float64
+	for _, workers := range workerCounts {
+		elapsed, err := benchmarkRender(chart, theme, format, *quality, layoutName, workers, *total)
+		if err != nil {
+			return err
+		}
+		throughput := float64(*total) / elapsed.Seconds()
+		if baseline == 0 {
+			baseline = throughput
+		}
+		log.Printf("并发 %3d: 耗时 %-12s 每张 %-12s 吞吐 %7.1f 张/秒  加速比 %.2fx",
+			workers, elapsed.Round(time.Millisecond), (elapsed / time.Duration(*total)).Round(time.Microsecond),
+			throughput, throughput/baseline)
+	}
+	return nil
+}
+
+// runCheckFontsCommand 检查字体回退链的字形覆盖
+// 用法：check-fonts [-theme classic]，不指定主题时检查全部主题
+// 有主题缺少字形时返回错误，便于在部署脚本中使用
+func runCheckFontsCommand(args []string) error {
+	flags := flag.NewFlagSet("check-fonts", flag.ContinueOnError)
+	themeArg := flags.String("theme", "", "只检查指定主题")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	names := themeNames()
+	if *themeArg != "" {
+		names = []string{*themeArg}
+	}
+
+	incomplete := 0
+	for _, name := range names {
+		theme, err := resolveTheme(name, 0)
+		if err != nil {
+			return err
+		}
+		missing, err := reportMissingGlyphs(theme)
+		if err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			incomplete++
+		}
+	}
+	if incomplete > 0 {
+		return fmt.Errorf("有 %d 个主题的字体缺少字形", incomplete)
+	}
+	return nil
+}
+
+// runConvertFontCommand 转换字体轮廓
+// 用法：convert-font -in NotoSansCJK.otc [-index 0] -family "Zhouyi Sans" -out fonts/10-ZhouyiSans-Regular.ttf [-tolerance 1]
+// 只保留fontConvertRanges中的字符；新字体名不能沿用原字体的保留名称，许可证文件需另行放入fonts目录
+func runConvertFontCommand(args []string) error {
+	flags := flag.NewFlagSet("convert-font", flag.ContinueOnError)
+	in := flags.String("in", "", "原字体文件，.otf或.ttc/.otc字体集合")
+	index := flags.Int("index", 0, "字体集合中的字体序号")
+	family := flags.String("family", "", "新的字体家族名")
+	out := flags.String("out", "", "输出的.ttf文件")
+	tolerance := flags.Float64("tolerance", 1, "二次曲线近似的最大误差，单位为字体设计单位")
+	notice := flags.String("notice", "", "追加在版权信息之后的修改说明，默认注明由原字体转换")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *in == "" || *out == "" || *family == "" {
+		return fmt.Errorf("必须指定 -in、-out 和 -family")
+	}
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		return err
+	}
+	if *notice == "" {
+		*notice = fmt.Sprintf("Modified: converted to TrueType outlines and subset by the Zhouyi project as %q.", *family)
+	}
+	converted, err := convertFontToTrueType(data, fontConvertOptions{
+		Index:     *index,
+		Family:    *family,
+		Tolerance: *tolerance,
+		Notice:    *notice,
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, converted, 0644); err != nil {
+		return err
+	}
+	log.Printf("已生成 %s（%.1f MB），重新编译后执行 check-fonts 和 update-golden", *out, float64(len(converted))/(1<<20))
+	return nil
+}
+
+// runCheckGoldenCommand 金图比对
+// 用法：check-golden [-dir golden] [-case classic-landscape] [-threshold 0.1] [-tolerance 0.001] [-report output/golden_report]
+// 有用例失败时返回错误，便于在构建脚本中使用
+func runCheckGoldenCommand(args []string) error {
+	flags := flag.NewFlagSet("check-golden", flag.ContinueOnError)
+	dir := flags.String("dir", "golden", "基准图目录")
+	caseName := flags.String("case", "", "只比对指定用例")
+	threshold := flags.Float64("threshold", defaultGoldenThreshold, "单个像素的色差阈值，0-1，越小越严格")
+	tolerance := flags.Float64("tolerance", defaultGoldenTolerance, "允许的差异像素占比")
+	report := flags.String("report", filepath.Join("output", "golden_report"), "失败时HTML报告的输出目录")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *threshold < 0 || *threshold > 1 || *tolerance < 0 || *tolerance > 1 {
+		return fmt.Errorf("色差阈值和容差必须在0到1之间")
+	}
+
+	cases, err := selectGoldenCases(*caseName)
+	if err != nil {
+		return err
+	}
+	results, err := checkGoldenImages(*dir, cases, goldenOptions{Threshold: *threshold, Tolerance: *tolerance, ReportDir: *report})
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, result := range results {
+		status := "通过"
+		if !result.Passed {
+			status = "失败"
+			failed++
+		}
+		log.Printf("%-30s %s  %s", result.Name, status, result.Message)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d/%d 个用例与基准图不一致，差异报告: %s", failed, len(results), filepath.Join(*report, "index.html"))
+	}
+	log.Printf("全部 %d 个用例与基准图一致", len(results))
+	return nil
+}
+
+// runUpdateGoldenCommand 重新生成基准图
+// 用法：update-golden [-dir golden] [-case classic-landscape]
+// 修改排版并确认效果无误后执行，再将golden目录的变更一并提交
+func runUpdateGoldenCommand(args []string) error {
+	flags := flag.NewFlagSet("update-golden", flag.ContinueOnError)
+	dir := flags.String("dir", "golden", "基准图目录")
+	caseName := flags.String("case", "", "只更新指定用例")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cases, err := selectGoldenCases(*caseName)
+	if err != nil {
+		return err
+	}
+	written, err := updateGoldenImages(*dir, cases)
+	for _, path := range written {
+		log.Printf("已更新基准图: %s", path)
+	}
+	return err
+}
+
+// runPreviewTemplateCommand 预览版式模板
+// 用法：preview-template [-file templates/spring.json | -name portrait] [-theme classic] [-format png] [-locale zh-Hans] [-out output/template_preview]
+// 模板文件不需要放进模板目录、也不需要重启服务，修改后重新执行即可查看效果
+func runPreviewTemplateCommand(args []string) error {
+	flags := flag.NewFlagSet("preview-template", flag.ContinueOnError)
+	file := flags.String("file", "", "模板文件路径")
+	name := flags.String("name", "", "已注册的模板名称，未指定file时使用，默认为配置的默认版式")
+	themeArg := flags.String("theme", "", "主题名称，默认为配置的默认主题")
+	formatArg := flags.String("format", ImageFormatPNG, "图片格式")
+	localeArg := flags.String("locale", "", "输出语言，默认为配置的默认语言")
+	out := flags.String("out", filepath.Join("output", "template_preview"), "预览图输出目录")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var tpl *ChartTemplate
+	var err error
+	if *file != "" {
+		tpl, err = loadTemplateFile(*file)
+	} else {
+		var layoutName string
+		if layoutName, err = normalizeLayoutName(*name); err == nil {
+			tpl, err = getChartTemplate(layoutName)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	theme, err := resolveTheme(*themeArg, 0)
+	if err != nil {
+		return err
+	}
+	format, err := normalizeImageFormat(*formatArg)
+	if err != nil {
+		return err
+	}
+	locale, err := normalizeLocale(*localeArg)
+	if err != nil {
+		return err
+	}
+	if err := ensureDir(*out); err != nil {
+		return fmt.Errorf("创建预览目录失败: %v", err)
+	}
+
+	charts := []struct {
+		suffix string
+		chart  *GuaChart
+	}{
+		{"moving", benchmarkChart()},
+		{"static", goldenStaticChart()},
+	}
+	for _, item := range charts {
+		item.chart.Locale = locale
+		rendered, err := renderChartWithTemplate(format, 0, tpl, item.chart, theme, nil)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(*out, fmt.Sprintf("%s-%s.%s", tpl.Name, item.suffix, imageFileExt(format)))
+		if err := os.WriteFile(path, rendered.Data, 0644); err != nil {
+			return fmt.Errorf("写入预览图失败: %v", err)
+		}
+		log.Printf("已生成预览图: %s", path)
+	}
+	return nil
+}
+
+// runCheckAPIDocsCommand 检查手写的接口文档与代码是否一致
+// 用法：check-api-docs [-doc ../../API接口文档.md] [-out openapi.json]
+// 文档缺少接口、参数或错误码时返回错误，便于在构建脚本中使用；指定-out时同时导出OpenAPI文档
+func runCheckAPIDocsCommand(args []string) error {
+	flags := flag.NewFlagSet("check-api-docs", flag.ContinueOnError)
+	docPath := flags.String("doc", filepath.Join("..", "..", "API接口文档.md"), "API接口文档路径")
+	out := flags.String("out", "", "导出OpenAPI文档的路径，为空时不导出")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	text, err := os.ReadFile(*docPath)
+	if err != nil {
+		return fmt.Errorf("读取接口文档失败: %v", err)
+	}
+	doc := getOpenAPIDocument()
+	if *out != "" {
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return fmt.Errorf("序列化OpenAPI文档失败: %v", err)
+		}
+		if err := os.WriteFile(*out, data, 0644); err != nil {
+			return fmt.Errorf("写入OpenAPI文档失败: %v", err)
+		}
+		log.Printf("已导出OpenAPI文档: %s", *out)
+	}
+
+	missing := doc.missingFromAPIDocs(string(text))
+	for _, item := range missing {
+		log.Printf("接口文档未提及: %s", item)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s 缺少 %d 项，请与 /api/v1/openapi.json 对照补充", *docPath, len(missing))
+	}
+	log.Printf("接口文档覆盖了OpenAPI文档中的全部 %d 个路径及其参数和错误码", len(doc.Paths))
+	return nil
+}
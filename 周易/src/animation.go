@@ -0,0 +1,466 @@
+// animation.go 实现起卦过程的动画输出
+// 动画从初爻到上爻依次展示六次摇卦：三枚铜钱落下、画出该爻、动爻以强调色标出，
+// 最后停在与静态图完全相同的完整盘面上。帧时长和文件大小上限可配置，
+// 超出大小上限时按比例缩小画面重新编码，适合在群聊中发送
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"sort"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+// animationFrame 动画中的一帧
+type animationFrame struct {
+	Image *image.NRGBA
+	Delay time.Duration // 本帧停留时间
+}
+
+// 三枚铜钱的绘制参数
+const (
+	coinRadius  = 34  // 铜钱半径
+	coinSpacing = 100 // 相邻铜钱圆心的间距
+)
+
+// coinHeads 由爻的阴阳和是否为动爻推出三枚铜钱中正面的个数
+// 与yaoQian的规则一致：0个正面为老阴，3个为老阳，1个为少阳，2个为少阴
+func coinHeads(yang, moving bool) int {
+	switch {
+	case moving && yang:
+		return 3
+	case moving:
+		return 0
+	case yang:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// yaoKindName 返回四象名称，如"老阳"、"少阴"
+func yaoKindName(yang, moving bool) string {
+	name := "少"
+	if moving {
+		name = "老"
+	}
+	if yang {
+		return name + "阳"
+	}
+	return name + "阴"
+}
+
+// renderCastingFrames 生成起卦动画的全部帧
+// 依次为：只有标题的起始帧，每一爻的铜钱帧和画爻帧，最后的完整盘面帧
+func renderCastingFrames(layout *Layout, chart *GuaChart, faces *chartFaces, theme *Theme) ([]animationFrame, error) {
+	config := GetConfig().Render.Animation
+	coinDelay := time.Duration(config.CoinMs) * time.Millisecond
+	lineDelay := time.Duration(config.LineMs) * time.Millisecond
+	finalDelay := time.Duration(config.FinalMs) * time.Millisecond
+
+	newFrame := func() (*image.NRGBA, *rasterCanvas) {
+		img := getBackground(theme, layout.宽度, layout.高度)
+		return img, newRasterCanvas(img, faces, theme)
+	}
+
+	// 起始帧：标题和公历时间
+	img, canvas := newFrame()
+	drawCastingHeader(canvas, layout, chart)
+	frames := []animationFrame{{Image: img, Delay: coinDelay}}
+
+	// 从初爻到上爻，每一爻先摇铜钱，再画出该爻
+	for 爻位 := 1; 爻位 <= 6; 爻位++ {
+		img, canvas = newFrame()
+		drawCastingHeader(canvas, layout, chart)
+		drawCastingLines(canvas, layout, chart, 爻位-1)
+		drawCastingCoins(img, canvas, layout, chart, theme, 爻位)
+		frames = append(frames, animationFrame{Image: img, Delay: coinDelay})
+
+		img, canvas = newFrame()
+		drawCastingHeader(canvas, layout, chart)
+		drawCastingLines(canvas, layout, chart, 爻位)
+		frames = append(frames, animationFrame{Image: img, Delay: lineDelay})
+	}
+
+	// 结束帧：与静态图相同的完整盘面
+	img, canvas = newFrame()
+	if err := drawGuaImage(canvas, layout, chart); err != nil {
+		return nil, fmt.Errorf("绘制卦象图像失败: %v", err)
+	}
+	frames = append(frames, animationFrame{Image: img, Delay: finalDelay})
+	return frames, nil
+}
+
+// drawCastingHeader 绘制动画各帧共用的标题和公历时间，位置和是否显示与完整盘面一致
+func drawCastingHeader(canvas chartCanvas, layout *Layout, chart *GuaChart) {
+	loc := newLocalizer(chart.Locale)
+	if layout.栏目.标题 {
+		canvas.DrawCenteredText(loc.pillars(chart), layout.宽度/2, layout.标题Y, textTitle)
+	}
+	if layout.栏目.副标题 {
+		canvas.DrawCenteredText(loc.divineTime(chart), layout.宽度/2, layout.副标题Y, textSmall)
+	}
+}
+
+// drawCastingLines 绘制已摇出的前count爻，位置与完整盘面中的本卦一致，动爻以强调色标出
+func drawCastingLines(canvas chartCanvas, layout *Layout, chart *GuaChart, count int) {
+	loc := newLocalizer(chart.Locale)
+	for 爻位 := 1; 爻位 <= count; 爻位++ {
+		i := 6 - 爻位 // 盘面从上爻画起，第i行对应第6-i爻
+		rowY := layout.基础Y + i*layout.爻间距
+		文字Y := rowY + layout.文字基线偏移
+		yang := chart.BenGua[爻位-1] == 1
+
+		drawRowLabel(canvas, layout, loc, loc.yaoShortName(爻位, chart.BenGua[爻位-1]), layout.六神X, 文字Y)
+		canvas.DrawYao(layout.左卦中心X-layout.爻宽度/2, rowY-layout.爻高度/2, layout.爻宽度, layout.爻高度, yang)
+		if chart.DongYao[爻位-1] {
+			canvas.DrawText("● "+loc.yaoKind(yang, true), layout.左卦中心X+layout.爻宽度/2+10, 文字Y, textAccent)
+		} else {
+			canvas.DrawText(loc.yaoKind(yang, false), layout.左卦中心X+layout.爻宽度/2+10, 文字Y, textSmall)
+		}
+	}
+}
+
+// drawCastingCoins 在爻辞区域绘制第爻位次摇出的三枚铜钱和结果说明
+// 正面写"字"，反面写"背"，动爻的说明使用强调色
+func drawCastingCoins(img *image.NRGBA, canvas chartCanvas, layout *Layout, chart *GuaChart, theme *Theme, 爻位 int) {
+	yang := chart.BenGua[爻位-1] == 1
+	moving := chart.DongYao[爻位-1]
+	heads := coinHeads(yang, moving)
+	loc := newLocalizer(chart.Locale)
+
+	centerX := layout.单卦爻辞中心X
+	centerY := layout.爻辞Y + coinRadius + 20
+	for i := 0; i < 3; i++ {
+		x := centerX + (i-1)*coinSpacing
+		label := loc.text("背")
+		if i < heads {
+			label = loc.text("字")
+		}
+		drawCoin(img, x, centerY, coinRadius, theme.colors.yao)
+		canvas.DrawCenteredText(label, x, centerY+10, textNormal)
+	}
+
+	caption := loc.castCaption(爻位, chart.BenGua[爻位-1], yang, moving)
+	style := textSmall
+	if moving {
+		style = textAccent
+	}
+	canvas.DrawCenteredText(caption, centerX, centerY+coinRadius+40, style)
+}
+
+// drawCoin 绘制一枚铜钱的外圈圆环，正反面文字另行绘制
+func drawCoin(img *image.NRGBA, cx, cy, radius int, col color.RGBA) {
+	c := color.NRGBA{col.R, col.G, col.B, col.A}
+	inner := radius - 4
+	for y := -radius; y <= radius; y++ {
+		for x := -radius; x <= radius; x++ {
+			if d := x*x + y*y; d <= radius*radius && d >= inner*inner {
+				img.SetNRGBA(cx+x, cy+y, c)
+			}
+		}
+	}
+}
+
+// renderChartAnimation 渲染起卦过程动画，最后一帧与静态图相同
+// 品牌叠加层绘制在每一帧上，播放过程中位置保持不变
+func renderChartAnimation(format string, layout *Layout, chart *GuaChart, faces *chartFaces, theme *Theme, overlay *brandOverlay) (*renderedImage, error) {
+	frames, err := renderCastingFrames(layout, chart, faces, theme)
+	if err != nil {
+		return nil, err
+	}
+	if overlay != nil {
+		for _, frame := range frames {
+			overlay.drawRaster(frame.Image, faces, theme)
+		}
+	}
+	data, err := encodeAnimation(format, frames)
+	if err != nil {
+		return nil, err
+	}
+	return &renderedImage{Data: data, ContentType: imageContentType(format), CreatedAt: time.Now()}, nil
+}
+
+// encodeAnimation 按格式编码动画，超出大小上限时逐步缩小画面
+//
+// 参数：
+//   - format: ImageFormatGIF或ImageFormatAPNG
+//   - frames: 动画帧，尺寸相同
+//
+// 返回值：编码后的动画数据；缩小到最小比例仍超出上限时返回错误
+func encodeAnimation(format string, frames []animationFrame) ([]byte, error) {
+	config := GetConfig().Render.Animation
+	scale := 1.0
+	for {
+		scaled := scaleFrames(frames, scale)
+
+		var data []byte
+		var err error
+		if format == ImageFormatGIF {
+			data, err = encodeGIF(scaled, config.LoopCount)
+		} else {
+			data, err = encodeAPNG(scaled, config.LoopCount)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if config.MaxBytes <= 0 || len(data) <= config.MaxBytes {
+			return data, nil
+		}
+		if scale*0.8 < config.MinScale {
+			return nil, fmt.Errorf("动画大小 %d bytes 超过上限 %d bytes（已缩小到 %.0f%%）", len(data), config.MaxBytes, scale*100)
+		}
+		scale *= 0.8
+	}
+}
+
+// scaleFrames 按比例缩放全部帧，比例为1时原样返回
+func scaleFrames(frames []animationFrame, scale float64) []animationFrame {
+	if scale >= 1 {
+		return frames
+	}
+	scaled := make([]animationFrame, len(frames))
+	for i, frame := range frames {
+		width := int(float64(frame.Image.Bounds().Dx()) * scale)
+		scaled[i] = animationFrame{
+			Image: imaging.Resize(frame.Image, width, 0, imaging.Lanczos),
+			Delay: frame.Delay,
+		}
+	}
+	return scaled
+}
+
+// encodeGIF 将帧编码为GIF动画
+// 所有帧共用一张从结束帧中位切分出的调色板，用Floyd-Steinberg抖动减轻渐变背景的色带；
+// 后续帧只对与上一帧不同的矩形区域抖动和编码，其余像素沿用上一帧，减小文件
+func encodeGIF(frames []animationFrame, loopCount int) ([]byte, error) {
+	palette := buildPalette(frames[len(frames)-1].Image, 256)
+	mapper := newPaletteMapper(palette)
+
+	anim := &gif.GIF{LoopCount: loopCount}
+	var previous *image.Paletted
+	var previousSrc *image.NRGBA
+	for _, frame := range frames {
+		changed := frame.Image.Rect
+		if previousSrc != nil {
+			changed = changedNRGBARect(previousSrc, frame.Image)
+			if changed.Empty() {
+				// 与上一帧相同，只延长上一帧的停留时间
+				anim.Delay[len(anim.Delay)-1] += delayCentiseconds(frame.Delay)
+				continue
+			}
+		}
+
+		current := image.NewPaletted(frame.Image.Rect, palette)
+		if previous != nil {
+			copy(current.Pix, previous.Pix)
+		}
+		draw.FloydSteinberg.Draw(mappedPaletted{current, mapper}, changed, frame.Image, changed.Min)
+
+		out := current
+		if previous != nil {
+			out = current.SubImage(changed).(*image.Paletted)
+		}
+		anim.Image = append(anim.Image, out)
+		anim.Delay = append(anim.Delay, delayCentiseconds(frame.Delay))
+		anim.Disposal = append(anim.Disposal, gif.DisposalNone)
+		previous, previousSrc = current, frame.Image
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		return nil, fmt.Errorf("编码GIF失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// delayCentiseconds 将停留时间换算为GIF使用的百分之一秒
+func delayCentiseconds(d time.Duration) int {
+	return int(d / (10 * time.Millisecond))
+}
+
+// colorBin 颜色直方图中按每通道5位归并的一格
+type colorBin struct {
+	key   uint16
+	count int
+	sum   [3]int // 落入该格的像素各通道之和，用于求平均色
+}
+
+// channel 格的5位颜色在某个通道上的值，0、1、2分别为红、绿、蓝
+func (b *colorBin) channel(c int) int {
+	return int(b.key>>(10-5*c)) & 31
+}
+
+// buildPalette 用中位切分法从图像中生成调色板
+// 颜色先按每通道5位归并成直方图，反复把像素数乘颜色跨度最大的一组沿跨度最大的通道
+// 按像素数从中位处一分为二，直到分出size组，每组取像素的平均色
+func buildPalette(img *image.NRGBA, size int) color.Palette {
+	bins := make([]colorBin, 1<<15)
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(img.Rect.Min.X, y):]
+		for x := 0; x < img.Rect.Dx(); x++ {
+			r, g, b := row[x*4], row[x*4+1], row[x*4+2]
+			bin := &bins[rgb555(r, g, b)]
+			bin.count++
+			bin.sum[0] += int(r)
+			bin.sum[1] += int(g)
+			bin.sum[2] += int(b)
+		}
+	}
+	used := make([]colorBin, 0, 1024)
+	for key := range bins {
+		if bins[key].count > 0 {
+			bins[key].key = uint16(key)
+			used = append(used, bins[key])
+		}
+	}
+
+	boxes := [][]colorBin{used}
+	for len(boxes) < size {
+		best, bestScore, bestChannel := -1, 0, 0
+		for i, box := range boxes {
+			channel, span := widestChannel(box)
+			if score := boxPixels(box) * span; span > 0 && score > bestScore {
+				best, bestScore, bestChannel = i, score, channel
+			}
+		}
+		if best < 0 {
+			break // 每组都只剩一种颜色
+		}
+		low, high := splitBox(boxes[best], bestChannel)
+		boxes[best] = low
+		boxes = append(boxes, high)
+	}
+
+	palette := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		var count int
+		var sum [3]int
+		for _, bin := range box {
+			count += bin.count
+			for c := range sum {
+				sum[c] += bin.sum[c]
+			}
+		}
+		if count > 0 {
+			palette = append(palette, color.RGBA{uint8(sum[0] / count), uint8(sum[1] / count), uint8(sum[2] / count), 255})
+		}
+	}
+	return palette
+}
+
+// widestChannel 返回一组颜色中跨度最大的通道及其跨度
+func widestChannel(box []colorBin) (int, int) {
+	channel, span := 0, -1
+	for c := 0; c < 3; c++ {
+		low, high := 31, 0
+		for i := range box {
+			v := box[i].channel(c)
+			low, high = min(low, v), max(high, v)
+		}
+		if high-low > span {
+			channel, span = c, high-low
+		}
+	}
+	return channel, span
+}
+
+// boxPixels 一组颜色的像素总数
+func boxPixels(box []colorBin) int {
+	total := 0
+	for i := range box {
+		total += box[i].count
+	}
+	return total
+}
+
+// splitBox 把一组颜色沿指定通道排序后按像素数从中位处分为两组，两组都不为空
+func splitBox(box []colorBin, channel int) ([]colorBin, []colorBin) {
+	sort.Slice(box, func(i, j int) bool {
+		if vi, vj := box[i].channel(channel), box[j].channel(channel); vi != vj {
+			return vi < vj
+		}
+		return box[i].key < box[j].key
+	})
+	half, seen := boxPixels(box)/2, 0
+	for i := 0; i < len(box)-1; i++ {
+		seen += box[i].count
+		if seen >= half {
+			return box[:i+1], box[i+1:]
+		}
+	}
+	return box[:len(box)-1], box[len(box)-1:]
+}
+
+// rgb555 将颜色按每通道5位归并
+func rgb555(r, g, b uint8) uint16 {
+	return uint16(r>>3)<<10 | uint16(g>>3)<<5 | uint16(b>>3)
+}
+
+// paletteMapper 把真彩色快速映射到调色板，按5位归并后的颜色缓存最近色
+type paletteMapper struct {
+	palette color.Palette
+	lookup  [1 << 15]int16
+}
+
+func newPaletteMapper(palette color.Palette) *paletteMapper {
+	m := &paletteMapper{palette: palette}
+	for i := range m.lookup {
+		m.lookup[i] = -1
+	}
+	return m
+}
+
+// index 返回颜色在调色板中的最近色序号
+func (m *paletteMapper) index(r, g, b uint8) uint8 {
+	key := rgb555(r, g, b)
+	index := m.lookup[key]
+	if index < 0 {
+		index = int16(m.palette.Index(color.RGBA{r, g, b, 255}))
+		m.lookup[key] = index
+	}
+	return uint8(index)
+}
+
+// mappedPaletted 经paletteMapper写入像素的调色板图像
+// draw.FloydSteinberg对*image.Paletted逐像素遍历整张调色板找最近色，比渲染整个动画还慢；
+// 包装后按通用图像处理，写入时查缓存，抖动结果只在同一5位格内的最近色上有差别
+type mappedPaletted struct {
+	*image.Paletted
+	mapper *paletteMapper
+}
+
+// Set 写入颜色在调色板中的最近色
+func (p mappedPaletted) Set(x, y int, c color.Color) {
+	r, g, b, _ := c.RGBA()
+	p.SetColorIndex(x, y, p.mapper.index(uint8(r>>8), uint8(g>>8), uint8(b>>8)))
+}
+
+// changedNRGBARect 返回两帧之间像素不同的最小矩形
+func changedNRGBARect(a, b *image.NRGBA) image.Rectangle {
+	changed := image.Rectangle{}
+	for y := b.Rect.Min.Y; y < b.Rect.Max.Y; y++ {
+		rowA := a.Pix[a.PixOffset(b.Rect.Min.X, y):]
+		rowB := b.Pix[b.PixOffset(b.Rect.Min.X, y):]
+		first, last := -1, -1
+		for x := 0; x < b.Rect.Dx()*4; x++ {
+			if rowA[x] != rowB[x] {
+				if first < 0 {
+					first = x / 4
+				}
+				last = x / 4
+			}
+		}
+		if first >= 0 {
+			changed = changed.Union(image.Rect(b.Rect.Min.X+first, y, b.Rect.Min.X+last+1, y+1))
+		}
+	}
+	return changed
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"math"
+	"testing"
+	"time"
+)
+
+// TestEncodeGIFDither 渐变背景抖动后每行的平均色与原图一致，后续帧只编码变化的区域
+func TestEncodeGIFDither(t *testing.T) {
+	background := generateGradientBackground(200, 300, color.RGBA{20, 20, 30, 255}, color.RGBA{60, 40, 80, 255})
+	final := image.NewNRGBA(background.Rect)
+	copy(final.Pix, background.Pix)
+	changed := image.Rect(50, 60, 70, 70)
+	draw.Draw(final, changed, image.NewUniform(color.NRGBA{200, 60, 40, 255}), image.Point{}, draw.Src)
+
+	data, err := encodeGIF([]animationFrame{
+		{Image: background, Delay: 100 * time.Millisecond},
+		{Image: final, Delay: 100 * time.Millisecond},
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 2 || anim.Image[1].Rect != changed {
+		t.Fatalf("第二帧应只编码 %v，实际 %d 帧", changed, len(anim.Image))
+	}
+
+	// 每10行的平均色与原图比较：不抖动时同一5位格内的各行映射到同一种颜色，平均色相差约2级
+	// 最后两组的颜色超出调色板的范围，不参与比较
+	first := anim.Image[0]
+	const band = 10
+	for y0 := 0; y0+band <= first.Rect.Dy()-2*band; y0 += band {
+		var got, want [3]float64
+		for y := y0; y < y0+band; y++ {
+			for x := 0; x < first.Rect.Dx(); x++ {
+				r, g, b, _ := first.At(x, y).RGBA()
+				pixel := background.NRGBAAt(x, y)
+				for c, v := range [3]uint32{r >> 8, g >> 8, b >> 8} {
+					got[c] += float64(v)
+				}
+				for c, v := range [3]uint8{pixel.R, pixel.G, pixel.B} {
+					want[c] += float64(v)
+				}
+			}
+		}
+		for c := range got {
+			if diff := math.Abs(got[c]-want[c]) / float64(band*first.Rect.Dx()); diff > 1.2 {
+				t.Errorf("第%d-%d行通道%d的平均色相差 %.2f", y0, y0+band-1, c, diff)
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// api_router.go 实现版本化的REST API路由和统一的错误响应
+// 所有接口注册在/api/v1下，同时保留旧的/api路径作为别名，两者按请求方法区分路由。
+// 出错时统一返回ApiResponse，error字段给出机器可读的错误码和出错的参数，
+// 路径不存在、请求方法不支持时同样返回JSON而不是纯文本
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// API路径前缀
+const (
+	apiV1Prefix     = "/api/v1" // 当前版本
+	apiLegacyPrefix = "/api"    // 旧路径，作为当前版本的别名保留
+)
+
+// 机器可读的错误码
+const (
+	ErrCodeInvalidRequest   = "invalid_request"    // 请求无效，未细分的400错误
+	ErrCodeInvalidJSON      = "invalid_json"       // 请求体不是合法的JSON
+	ErrCodeEmptyBody        = "empty_body"         // 请求体为空
+	ErrCodeTypeMismatch     = "type_mismatch"      // JSON字段的类型不符
+	ErrCodeInvalidParameter = "invalid_parameter"  // 参数取值无效，field给出参数名
+	ErrCodeUnauthorized     = "unauthorized"       // 缺少访问令牌或令牌错误，如查询占卜历史
+	ErrCodeInvalidSignature = "invalid_signature"  // 签名链接缺少签名或签名无效
+	ErrCodeLinkExpired      = "link_expired"       // 签名链接已过期
+	ErrCodeNotFound         = "not_found"          // 资源或接口不存在
+	ErrCodeMethodNotAllowed = "method_not_allowed" // 接口不支持该请求方法
+	ErrCodePayloadTooLarge  = "payload_too_large"  // 请求体过大
+	ErrCodeRateLimited      = "rate_limited"       // 请求过于频繁，如短时间内生成过多高清图
+	ErrCodeUnprocessable    = "unprocessable"      // 请求格式正确但无法处理，如图片中没有盘面元数据
+	ErrCodeInternal         = "internal_error"     // 服务器内部错误
+	ErrCodeUpstream         = "upstream_error"     // 依赖的外部服务出错，如万年历API
+)
+
+// apiErrorCodes 所有错误码，写入接口文档中ErrorDetail.code的枚举
+var apiErrorCodes = []string{
+	ErrCodeInvalidRequest, ErrCodeInvalidJSON, ErrCodeEmptyBody, ErrCodeTypeMismatch, ErrCodeInvalidParameter,
+	ErrCodeUnauthorized, ErrCodeInvalidSignature, ErrCodeLinkExpired, ErrCodeNotFound, ErrCodeMethodNotAllowed, ErrCodePayloadTooLarge,
+	ErrCodeRateLimited, ErrCodeUnprocessable, ErrCodeInternal, ErrCodeUpstream,
+}
+
+// errorCodeForStatus 按HTTP状态码给出默认的错误码
+func errorCodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrCodeInvalidRequest
+	case http.StatusUnauthorized:
+		return ErrCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrCodeInvalidSignature
+	case http.StatusNotFound:
+		return ErrCodeNotFound
+	case http.StatusMethodNotAllowed:
+		return ErrCodeMethodNotAllowed
+	case http.StatusRequestEntityTooLarge:
+		return ErrCodePayloadTooLarge
+	case http.StatusUnprocessableEntity:
+		return ErrCodeUnprocessable
+	case http.StatusTooManyRequests:
+		return ErrCodeRateLimited
+	case http.StatusBadGateway:
+		return ErrCodeUpstream
+	}
+	return ErrCodeInternal
+}
+
+// apiError 接口错误，由writeError写出
+type apiError struct {
+	Status  int    // HTTP状态码
+	Code    string // 机器可读的错误码
+	Field   string // 出错的参数名，与参数无关时为空
+	Message string // 错误描述
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+// invalidParameter 返回参数取值无效的错误
+func invalidParameter(field string, err error) *apiError {
+	return &apiError{Status: http.StatusBadRequest, Code: ErrCodeInvalidParameter, Field: field, Message: err.Error()}
+}
+
+// writeError 以统一的ApiResponse格式返回错误
+func writeError(w http.ResponseWriter, e *apiError) {
+	writeErrorResponse(w, e.Status, e.Message, &ErrorDetail{Code: e.Code, Field: e.Field})
+}
+
+// writeFieldError 返回参数取值无效的错误
+func writeFieldError(w http.ResponseWriter, field, message string) {
+	writeErrorResponse(w, http.StatusBadRequest, message, &ErrorDetail{Code: ErrCodeInvalidParameter, Field: field})
+}
+
+// handleAPI 注册API路由，pattern形如"GET /divine/{id}"，同时注册在/api/v1和旧的/api下
+// 接口必须已在OpenAPI文档中描述，请求先按文档校验再交给处理器
+func handleAPI(pattern string, handler http.HandlerFunc) {
+	method, path, _ := strings.Cut(pattern, " ")
+	op := getOpenAPIDocument().markRegistered(method + " " + apiV1Prefix + path)
+	handler = validateRequest(op, handler)
+	http.Handle(method+" "+apiV1Prefix+path, handler)
+	http.Handle(method+" "+apiLegacyPrefix+path, handler)
+}
+
+// apiPath 返回当前版本的接口路径，如apiPath("/divine")为"/api/v1/divine"
+func apiPath(path string) string {
+	return apiV1Prefix + path
+}
+
+// routeMethods 检测接口支持的请求方法时逐一尝试的方法
+var routeMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// apiRouter 包装路由表，/api下的路径不存在或请求方法不支持时返回JSON错误
+type apiRouter struct {
+	mux *http.ServeMux
+}
+
+// newAPIRouter 包装路由表
+func newAPIRouter(mux *http.ServeMux) *apiRouter {
+	return &apiRouter{mux: mux}
+}
+
+func (a *apiRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, apiLegacyPrefix+"/") {
+		a.mux.ServeHTTP(w, r)
+		return
+	}
+	if _, pattern := a.mux.Handler(r); pattern != "" {
+		a.mux.ServeHTTP(w, r)
+		return
+	}
+
+	if allowed := a.allowedMethods(r); len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeErrorResponse(w, http.StatusMethodNotAllowed,
+			"接口不支持 "+r.Method+" 请求，可用: "+strings.Join(allowed, ", "),
+			&ErrorDetail{Code: ErrCodeMethodNotAllowed})
+		return
+	}
+	writeErrorResponse(w, http.StatusNotFound, "接口不存在: "+r.URL.Path, &ErrorDetail{Code: ErrCodeNotFound})
+}
+
+// allowedMethods 返回该路径支持的请求方法，路径不存在时为空
+func (a *apiRouter) allowedMethods(r *http.Request) []string {
+	var allowed []string
+	probe := r.Clone(r.Context())
+	for _, method := range routeMethods {
+		probe.Method = method
+		if _, pattern := a.mux.Handler(probe); pattern != "" {
+			allowed = append(allowed, method)
+			if method == http.MethodGet {
+				allowed = append(allowed, http.MethodHead)
+			}
+		}
+	}
+	sort.Strings(allowed)
+	return allowed
+}
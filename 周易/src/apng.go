@@ -0,0 +1,169 @@
+// apng.go 实现APNG动画编码
+// 标准库只能编码静态PNG，这里先用image/png把每一帧编码成普通PNG，再取出其中的压缩数据，
+// 按APNG规范重新组装：IHDR、acTL，首帧为fcTL+IDAT，后续帧为fcTL+fdAT，最后是IEND。
+// 不支持APNG的查看器会把它当作普通PNG，只显示第一帧
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"time"
+)
+
+// pngSignature PNG文件头
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// apngFrame 一帧待写入的APNG数据
+type apngFrame struct {
+	rect  image.Rectangle // 本帧在画布中的区域
+	data  []byte          // 合并后的IDAT压缩数据
+	delay time.Duration   // 停留时间
+}
+
+// encodeAPNG 将帧编码为APNG动画
+// 后续帧只编码与上一帧不同的矩形区域，与上一帧相同的帧合并为更长的停留时间
+//
+// 参数：
+//   - frames: 动画帧，尺寸相同
+//   - loopCount: 与GIF相同的约定，0表示无限循环，-1表示只播放一次，n表示额外循环n次
+//
+// 返回值：APNG文件数据
+func encodeAPNG(frames []animationFrame, loopCount int) ([]byte, error) {
+	var (
+		header   []byte // 首帧的IHDR内容，所有帧的位深和颜色类型必须与之一致
+		encoded  []apngFrame
+		previous *image.NRGBA
+	)
+	for _, frame := range frames {
+		rect := frame.Image.Rect
+		if previous != nil {
+			rect = changedNRGBARect(previous, frame.Image)
+			if rect.Empty() {
+				encoded[len(encoded)-1].delay += frame.Delay
+				continue
+			}
+		}
+
+		ihdr, data, err := encodePNGFrame(frame.Image.SubImage(rect))
+		if err != nil {
+			return nil, err
+		}
+		if header == nil {
+			header = ihdr
+		} else if !bytes.Equal(ihdr[8:], header[8:]) {
+			// IHDR前8字节是宽高，其余为位深、颜色类型等，必须与首帧一致
+			return nil, fmt.Errorf("编码APNG失败: 第%d帧的颜色类型与首帧不同", len(encoded)+1)
+		}
+		encoded = append(encoded, apngFrame{rect: rect, data: data, delay: frame.Delay})
+		previous = frame.Image
+	}
+
+	plays := 0
+	if loopCount < 0 {
+		plays = 1
+	} else if loopCount > 0 {
+		plays = loopCount + 1
+	}
+
+	var buf bytes.Buffer
+	buf.Write(pngSignature)
+	writePNGChunk(&buf, "IHDR", header)
+
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl[0:], uint32(len(encoded)))
+	binary.BigEndian.PutUint32(actl[4:], uint32(plays))
+	writePNGChunk(&buf, "acTL", actl)
+
+	// fcTL和fdAT共用一个从0开始递增的序号
+	var sequence uint32
+	for i, frame := range encoded {
+		writePNGChunk(&buf, "fcTL", frameControl(sequence, frame, frames[0].Image.Rect.Min))
+		sequence++
+
+		if i == 0 {
+			writePNGChunk(&buf, "IDAT", frame.data)
+			continue
+		}
+		fdat := make([]byte, 4+len(frame.data))
+		binary.BigEndian.PutUint32(fdat, sequence)
+		copy(fdat[4:], frame.data)
+		writePNGChunk(&buf, "fdAT", fdat)
+		sequence++
+	}
+
+	writePNGChunk(&buf, "IEND", nil)
+	return buf.Bytes(), nil
+}
+
+// isAPNG 判断PNG数据是否为APNG动画：acTL块必须出现在首个IDAT块之前
+func isAPNG(data []byte) bool {
+	idat := bytes.Index(data, []byte("IDAT"))
+	if idat < 0 {
+		return false
+	}
+	return bytes.Contains(data[:idat], []byte("acTL"))
+}
+
+// frameControl 构造fcTL块内容：序号、区域、停留时间（毫秒），不处置、直接覆盖
+func frameControl(sequence uint32, frame apngFrame, origin image.Point) []byte {
+	fctl := make([]byte, 26)
+	binary.BigEndian.PutUint32(fctl[0:], sequence)
+	binary.BigEndian.PutUint32(fctl[4:], uint32(frame.rect.Dx()))
+	binary.BigEndian.PutUint32(fctl[8:], uint32(frame.rect.Dy()))
+	binary.BigEndian.PutUint32(fctl[12:], uint32(frame.rect.Min.X-origin.X))
+	binary.BigEndian.PutUint32(fctl[16:], uint32(frame.rect.Min.Y-origin.Y))
+	binary.BigEndian.PutUint16(fctl[20:], uint16(min(frame.delay/time.Millisecond, 65535)))
+	binary.BigEndian.PutUint16(fctl[22:], 1000)
+	// fctl[24] dispose_op=0（APNG_DISPOSE_OP_NONE），fctl[25] blend_op=0（APNG_BLEND_OP_SOURCE）
+	return fctl
+}
+
+// encodePNGFrame 用标准库编码一帧，返回IHDR内容和合并后的IDAT数据
+func encodePNGFrame(img image.Image) (ihdr, data []byte, err error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, nil, fmt.Errorf("编码APNG帧失败: %v", err)
+	}
+
+	raw := buf.Bytes()[len(pngSignature):]
+	for len(raw) >= 12 {
+		length := binary.BigEndian.Uint32(raw)
+		chunkType := string(raw[4:8])
+		if int(length)+12 > len(raw) {
+			break
+		}
+		content := raw[8 : 8+length]
+		switch chunkType {
+		case "IHDR":
+			ihdr = content
+		case "IDAT":
+			data = append(data, content...)
+		}
+		raw = raw[12+length:]
+	}
+	if ihdr == nil || data == nil {
+		return nil, nil, fmt.Errorf("编码APNG帧失败: PNG数据不完整")
+	}
+	return ihdr, data, nil
+}
+
+// writePNGChunk 写入一个PNG数据块：长度、类型、内容和CRC
+func writePNGChunk(buf *bytes.Buffer, chunkType string, content []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(content)))
+	buf.Write(length[:])
+
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(chunkType))
+	crc.Write(content)
+	buf.WriteString(chunkType)
+	buf.Write(content)
+
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	buf.Write(sum[:])
+}
@@ -0,0 +1,486 @@
+// branding.go 实现卦象图的品牌叠加层
+// 在drawGuaImage画完盘面之后叠加徽标图片、文字和指向结果页的二维码，
+// 位置和不透明度可配置；按请求指定的品牌、OneBot群配置或默认品牌选择使用哪一套
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// 品牌元素在画布上的位置
+const (
+	BrandPositionTopLeft     = "top-left"
+	BrandPositionTopRight    = "top-right"
+	BrandPositionBottomLeft  = "bottom-left"
+	BrandPositionBottomRight = "bottom-right"
+	BrandPositionTop         = "top"    // 顶部居中
+	BrandPositionBottom      = "bottom" // 底部居中
+)
+
+const (
+	BrandingNone = "none" // 请求或群配置中使用此名称表示不叠加品牌
+
+	defaultBrandMargin = 16  // 品牌元素与画布边缘的默认距离（像素）
+	defaultBrandQRSize = 128 // 二维码的默认边长（像素），含留白
+	brandQRQuietZone   = 4   // 二维码四周的留白模块数
+	brandItemSpacing   = 8   // 同一位置多个元素之间的距离（像素）
+)
+
+// Branding 一套品牌叠加配置，徽标、文字和二维码均可省略
+type Branding struct {
+	Name   string        `json:"-"`                // 品牌名称，即配置中的键
+	Logo   *BrandingLogo `json:"logo,omitempty"`   // 徽标图片
+	Text   *BrandingText `json:"text,omitempty"`   // 文字，如网站名或版权声明
+	QRCode *BrandingQR   `json:"qrcode,omitempty"` // 指向结果页的二维码
+}
+
+// BrandingPlacement 品牌元素的位置、边距和不透明度
+type BrandingPlacement struct {
+	Position string   `json:"position,omitempty"` // 位置：top-left、top-right、bottom-left、bottom-right、top、bottom
+	Margin   *int     `json:"margin,omitempty"`   // 与画布边缘的距离（像素），默认16
+	Opacity  *float64 `json:"opacity,omitempty"`  // 不透明度（0到1），默认1
+}
+
+// BrandingLogo 徽标图片
+type BrandingLogo struct {
+	BrandingPlacement
+	File  string `json:"file"`            // 图片文件路径，支持PNG和JPEG，透明背景建议使用PNG
+	Width int    `json:"width,omitempty"` // 绘制宽度（像素），高度按比例缩放，0表示使用原图尺寸
+}
+
+// BrandingText 品牌文字
+type BrandingText struct {
+	BrandingPlacement
+	Content string `json:"content"`         // 文字内容，{id}替换为占卜ID
+	Style   string `json:"style,omitempty"` // 字号和默认颜色沿用主题的文字样式：title、normal、small（默认）、accent
+	Color   string `json:"color,omitempty"` // 文字颜色#rrggbb，为空时使用主题中该样式的颜色
+}
+
+// BrandingQR 指向结果页的二维码
+type BrandingQR struct {
+	BrandingPlacement
+	URL  string `json:"url,omitempty"`  // 二维码内容，{id}替换为占卜ID，为空时指向本服务的结果图片地址
+	Size int    `json:"size,omitempty"` // 边长（像素），含四周留白，默认128
+}
+
+// brandTextStyles 品牌文字可用的样式名称
+var brandTextStyles = map[string]textStyle{
+	"title":  textTitle,
+	"normal": textNormal,
+	"small":  textSmall,
+	"accent": textAccent,
+}
+
+// validate 检查品牌配置中的位置、不透明度、尺寸和颜色
+func (b *Branding) validate() error {
+	if b.Logo != nil {
+		if b.Logo.File == "" {
+			return fmt.Errorf("徽标缺少图片文件")
+		}
+		if b.Logo.Width < 0 {
+			return fmt.Errorf("徽标宽度不能为负数")
+		}
+		if err := b.Logo.validate(); err != nil {
+			return fmt.Errorf("徽标%v", err)
+		}
+	}
+	if b.Text != nil {
+		if b.Text.Content == "" {
+			return fmt.Errorf("品牌文字不能为空")
+		}
+		if _, ok := brandTextStyles[b.Text.textStyleName()]; !ok {
+			return fmt.Errorf("品牌文字样式无效: %s（可选 title、normal、small、accent）", b.Text.Style)
+		}
+		if b.Text.Color != "" {
+			if _, err := parseHexColor(b.Text.Color); err != nil {
+				return fmt.Errorf("品牌文字%v", err)
+			}
+		}
+		if err := b.Text.validate(); err != nil {
+			return fmt.Errorf("品牌文字%v", err)
+		}
+	}
+	if b.QRCode != nil {
+		if b.QRCode.Size < 0 {
+			return fmt.Errorf("二维码边长不能为负数")
+		}
+		if err := b.QRCode.validate(); err != nil {
+			return fmt.Errorf("二维码%v", err)
+		}
+	}
+	return nil
+}
+
+// validate 检查位置名称、边距和不透明度
+func (p *BrandingPlacement) validate() error {
+	switch p.Position {
+	case "", BrandPositionTopLeft, BrandPositionTopRight, BrandPositionBottomLeft,
+		BrandPositionBottomRight, BrandPositionTop, BrandPositionBottom:
+	default:
+		return fmt.Errorf("位置无效: %s（可选 top-left、top-right、bottom-left、bottom-right、top、bottom）", p.Position)
+	}
+	if p.Margin != nil && *p.Margin < 0 {
+		return fmt.Errorf("边距不能为负数")
+	}
+	if p.Opacity != nil && (*p.Opacity < 0 || *p.Opacity > 1) {
+		return fmt.Errorf("不透明度必须在0到1之间: %g", *p.Opacity)
+	}
+	return nil
+}
+
+// position 返回元素的位置，未配置时使用给定的默认位置
+func (p *BrandingPlacement) position(fallback string) string {
+	if p.Position == "" {
+		return fallback
+	}
+	return p.Position
+}
+
+// margin 返回与画布边缘的距离
+func (p *BrandingPlacement) margin() int {
+	if p.Margin == nil {
+		return defaultBrandMargin
+	}
+	return *p.Margin
+}
+
+// opacity 返回不透明度
+func (p *BrandingPlacement) opacity() float64 {
+	if p.Opacity == nil {
+		return 1
+	}
+	return *p.Opacity
+}
+
+// textStyleName 返回品牌文字的样式名称，默认为small
+func (t *BrandingText) textStyleName() string {
+	if t.Style == "" {
+		return "small"
+	}
+	return t.Style
+}
+
+// resolveBranding 按请求指定的品牌、群配置和默认配置确定使用的品牌
+// 优先级：请求中的品牌 > 群配置的品牌 > 默认品牌；名称为none表示不叠加
+//
+// 返回值：品牌配置，不叠加品牌时为nil；请求指定的品牌不存在时返回错误
+func resolveBranding(name string, groupID int64) (*Branding, error) {
+	config := GetConfig().Render
+
+	if name != "" {
+		if name == BrandingNone {
+			return nil, nil
+		}
+		branding, ok := config.Brandings[name]
+		if !ok {
+			return nil, fmt.Errorf("品牌不存在: %s（可选 %s）", name, strings.Join(brandingNames(), "、"))
+		}
+		return namedBranding(name, branding), nil
+	}
+
+	if groupID != 0 {
+		if groupBranding, ok := config.GroupBrandings[strconv.FormatInt(groupID, 10)]; ok {
+			if groupBranding == BrandingNone {
+				return nil, nil
+			}
+			if branding, ok := config.Brandings[groupBranding]; ok {
+				return namedBranding(groupBranding, branding), nil
+			}
+			log.Printf("群 %d 配置的品牌 %s 不存在，使用默认品牌", groupID, groupBranding)
+		}
+	}
+
+	if branding, ok := config.Brandings[config.DefaultBranding]; ok {
+		return namedBranding(config.DefaultBranding, branding), nil
+	}
+	return nil, nil
+}
+
+// namedBranding 返回带名称的品牌配置副本
+func namedBranding(name string, branding *Branding) *Branding {
+	named := *branding
+	named.Name = name
+	return &named
+}
+
+// brandingNames 返回所有品牌名称，按字母排序，末尾附加none
+func brandingNames() []string {
+	names := make([]string, 0, len(GetConfig().Render.Brandings)+1)
+	for name := range GetConfig().Render.Brandings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append(names, BrandingNone)
+}
+
+// brandOverlay 一次渲染使用的品牌叠加层
+type brandOverlay struct {
+	branding *Branding
+	id       string  // 占卜ID，替换文字和二维码地址中的{id}
+	scale    float64 // 边距和尺寸的缩放比例，与缩略图、高清图的画布一致，0表示不缩放
+}
+
+// newBrandOverlay 创建品牌叠加层，品牌为nil时返回nil
+func newBrandOverlay(branding *Branding, id string) *brandOverlay {
+	if branding == nil {
+		return nil
+	}
+	return &brandOverlay{branding: branding, id: id}
+}
+
+// brandItem 排好位置的品牌元素
+type brandItem struct {
+	rect    image.Rectangle
+	opacity float64
+}
+
+// scaled 返回按比例缩放的叠加层副本
+func (o *brandOverlay) scaled(scale float64) *brandOverlay {
+	if o == nil {
+		return nil
+	}
+	scaled := *o
+	scaled.scale = scale
+	return &scaled
+}
+
+// px 将配置中的像素尺寸按缩放比例换算
+func (o *brandOverlay) px(v int) int {
+	if o.scale == 0 || o.scale == 1 {
+		return v
+	}
+	return max(int(math.Round(float64(v)*o.scale)), 1)
+}
+
+// brandStack 记录每个位置已占用的高度，同一位置的多个元素依次向画布内侧排列
+type brandStack struct {
+	overlay       *brandOverlay
+	width, height int
+	used          map[string]int
+}
+
+// newBrandStack 创建画布尺寸为width×height的排列状态
+func (o *brandOverlay) newBrandStack(width, height int) *brandStack {
+	return &brandStack{overlay: o, width: width, height: height, used: make(map[string]int)}
+}
+
+// place 计算元素左上角坐标
+func (s *brandStack) place(p BrandingPlacement, fallback string, w, h int) brandItem {
+	position, margin := p.position(fallback), s.overlay.px(p.margin())
+	x := margin
+	switch position {
+	case BrandPositionTopRight, BrandPositionBottomRight:
+		x = s.width - margin - w
+	case BrandPositionTop, BrandPositionBottom:
+		x = (s.width - w) / 2
+	}
+
+	offset := s.used[position]
+	s.used[position] = offset + h + s.overlay.px(brandItemSpacing)
+	y := margin + offset
+	switch position {
+	case BrandPositionBottomLeft, BrandPositionBottomRight, BrandPositionBottom:
+		y = s.height - margin - offset - h
+	}
+	return brandItem{rect: image.Rect(x, y, x+w, y+h), opacity: p.opacity()}
+}
+
+// qrURL 返回二维码内容
+func (o *brandOverlay) qrURL() string {
+	if o.branding.QRCode.URL == "" {
+		return resultPageURL(o.id)
+	}
+	return strings.ReplaceAll(o.branding.QRCode.URL, "{id}", o.id)
+}
+
+// text 返回替换占位符后的品牌文字
+func (o *brandOverlay) text() string {
+	return strings.ReplaceAll(o.branding.Text.Content, "{id}", o.id)
+}
+
+// textColor 返回品牌文字的颜色
+func (o *brandOverlay) textColor(theme *Theme) color.RGBA {
+	if o.branding.Text.Color != "" {
+		if col, err := parseHexColor(o.branding.Text.Color); err == nil {
+			return col
+		}
+	}
+	return theme.textColor(brandTextStyles[o.branding.Text.textStyleName()])
+}
+
+// qrCode 编码二维码并计算每个模块的像素大小，内容过长时记录日志并返回nil
+func (o *brandOverlay) qrCode() (*qrCode, int) {
+	qr, err := encodeQRCode([]byte(o.qrURL()))
+	if err != nil {
+		log.Printf("品牌 %s 的二维码生成失败: %v", o.branding.Name, err)
+		return nil, 0
+	}
+	size := o.branding.QRCode.Size
+	if size == 0 {
+		size = defaultBrandQRSize
+	}
+	return qr, max(o.px(size)/(qr.Size+brandQRQuietZone*2), 1)
+}
+
+// drawRaster 在画完盘面的位图上叠加品牌元素
+// 依次放置徽标、二维码和文字，同一位置的元素从画布边缘向内排列
+func (o *brandOverlay) drawRaster(dst *image.NRGBA, faces *chartFaces, theme *Theme) {
+	bounds := dst.Bounds()
+	stack := o.newBrandStack(bounds.Dx(), bounds.Dy())
+	composite := func(item brandItem, src image.Image) {
+		mask := image.NewUniform(color.Alpha{A: uint8(item.opacity*255 + 0.5)})
+		draw.DrawMask(dst, item.rect, src, src.Bounds().Min, mask, image.Point{}, draw.Over)
+	}
+
+	if logo := o.branding.Logo; logo != nil {
+		if img := o.logo(); img != nil {
+			composite(stack.place(logo.BrandingPlacement, BrandPositionBottomRight, img.Bounds().Dx(), img.Bounds().Dy()), img)
+		}
+	}
+
+	if qrc := o.branding.QRCode; qrc != nil {
+		if qr, module := o.qrCode(); qr != nil {
+			side := (qr.Size + brandQRQuietZone*2) * module
+			img := image.NewNRGBA(image.Rect(0, 0, side, side))
+			draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+			for y, row := range qr.Modules {
+				for x, dark := range row {
+					if dark {
+						px, py := (x+brandQRQuietZone)*module, (y+brandQRQuietZone)*module
+						draw.Draw(img, image.Rect(px, py, px+module, py+module), image.Black, image.Point{}, draw.Src)
+					}
+				}
+			}
+			composite(stack.place(qrc.BrandingPlacement, BrandPositionBottomRight, side, side), img)
+		}
+	}
+
+	if text := o.branding.Text; text != nil {
+		face := faces.face(brandTextStyles[text.textStyleName()])
+		content := o.text()
+		metrics := face.Metrics()
+		w := font.MeasureString(face, content).Ceil()
+		h := (metrics.Ascent + metrics.Descent).Ceil()
+		img := image.NewNRGBA(image.Rect(0, 0, w, h))
+		drawer := &font.Drawer{
+			Dst:  img,
+			Src:  image.NewUniform(o.textColor(theme)),
+			Face: face,
+			Dot:  fixed.Point26_6{Y: metrics.Ascent},
+		}
+		drawer.DrawString(content)
+		composite(stack.place(text.BrandingPlacement, BrandPositionBottom, w, h), img)
+	}
+}
+
+// writeSVG 将品牌元素写为SVG元素，位置与位图渲染一致
+// 文字和二维码为矢量元素，徽标以PNG数据内嵌
+func (o *brandOverlay) writeSVG(buf *bytes.Buffer, faces *chartFaces, theme *Theme, width, height int) {
+	stack := o.newBrandStack(width, height)
+
+	if logo := o.branding.Logo; logo != nil {
+		if img := o.logo(); img != nil {
+			var data bytes.Buffer
+			if err := png.Encode(&data, img); err == nil {
+				item := stack.place(logo.BrandingPlacement, BrandPositionBottomRight, img.Bounds().Dx(), img.Bounds().Dy())
+				fmt.Fprintf(buf, `<image class="gua-brand gua-brand-logo" x="%d" y="%d" width="%d" height="%d" opacity="%g" href="data:image/png;base64,%s"/>`+"\n",
+					item.rect.Min.X, item.rect.Min.Y, item.rect.Dx(), item.rect.Dy(), item.opacity,
+					base64.StdEncoding.EncodeToString(data.Bytes()))
+			}
+		}
+	}
+
+	if qrc := o.branding.QRCode; qrc != nil {
+		if qr, module := o.qrCode(); qr != nil {
+			side := (qr.Size + brandQRQuietZone*2) * module
+			item := stack.place(qrc.BrandingPlacement, BrandPositionBottomRight, side, side)
+			fmt.Fprintf(buf, `<g class="gua-brand gua-brand-qrcode" opacity="%g" transform="translate(%d %d)">`,
+				item.opacity, item.rect.Min.X, item.rect.Min.Y)
+			fmt.Fprintf(buf, `<rect width="%d" height="%d" fill="#ffffff"/><path fill="#000000" d="`, side, side)
+			for y, row := range qr.Modules {
+				for x, dark := range row {
+					if dark {
+						fmt.Fprintf(buf, "M%d %dh%dv%dh-%dz", (x+brandQRQuietZone)*module, (y+brandQRQuietZone)*module, module, module, module)
+					}
+				}
+			}
+			buf.WriteString(`"/></g>` + "\n")
+		}
+	}
+
+	if text := o.branding.Text; text != nil {
+		style := brandTextStyles[text.textStyleName()]
+		face := faces.face(style)
+		content := o.text()
+		metrics := face.Metrics()
+		w := font.MeasureString(face, content).Ceil()
+		h := (metrics.Ascent + metrics.Descent).Ceil()
+		item := stack.place(text.BrandingPlacement, BrandPositionBottom, w, h)
+		col := o.textColor(theme)
+		fmt.Fprintf(buf, `<text x="%d" y="%d" class="%s gua-brand gua-brand-text" fill="#%02x%02x%02x" opacity="%g">`,
+			item.rect.Min.X, item.rect.Min.Y+metrics.Ascent.Round(), svgTextClass[style], col.R, col.G, col.B, item.opacity)
+		xml.EscapeText(buf, []byte(content))
+		buf.WriteString("</text>\n")
+	}
+}
+
+// logo 返回按缩放比例调整宽度后的徽标
+func (o *brandOverlay) logo() *image.NRGBA {
+	logo := o.branding.Logo
+	img := loadBrandLogo(logo.File, logo.Width)
+	if img == nil || o.scale == 0 || o.scale == 1 {
+		return img
+	}
+	return loadBrandLogo(logo.File, o.px(img.Bounds().Dx()))
+}
+
+// brandLogoCache 按文件路径和宽度缓存缩放好的徽标，读取失败也缓存为nil，避免每次渲染重复记录日志
+var brandLogoCache = struct {
+	sync.Mutex
+	images map[string]*image.NRGBA
+}{images: make(map[string]*image.NRGBA)}
+
+// loadBrandLogo 读取并缩放徽标图片，失败时记录日志并返回nil
+func loadBrandLogo(path string, width int) *image.NRGBA {
+	key := fmt.Sprintf("%s@%d", path, width)
+	brandLogoCache.Lock()
+	defer brandLogoCache.Unlock()
+	if img, ok := brandLogoCache.images[key]; ok {
+		return img
+	}
+
+	var logo *image.NRGBA
+	src, err := imaging.Open(path)
+	if err != nil {
+		log.Printf("读取品牌徽标失败，跳过徽标: %v", err)
+	} else if width > 0 {
+		logo = imaging.Resize(src, width, 0, imaging.Lanczos)
+	} else {
+		logo = imaging.Clone(src)
+	}
+	brandLogoCache.images[key] = logo
+	return logo
+}
+
+// resultPageURL 返回二维码默认指向的结果图片地址
+// 二维码印在图中，没有请求可供推断地址，对外访问需配置server.public_base_url
+func resultPageURL(id string) string {
+	return buildPublicURL(publicBaseURL(nil), divineImagePath(id), nil)
+}
@@ -10,8 +10,12 @@ import (
 	"net/http"
 	"strings"
 	"time"
+	_ "time/tzdata" // 内置时区数据库，保证在没有系统时区数据的环境中也能解析IANA时区
 )
 
+// defaultTimezone 占卜默认使用的时区
+const defaultTimezone = "Asia/Shanghai"
+
 // getChinaCurrentTime 获取中国北京时间
 // 优先使用系统时区设置，如果系统时区不正确则通过网络时间服务获取
 //
@@ -79,57 +83,129 @@ func getNetworkTime() (time.Time, error) {
 	return networkTime, nil
 }
 
-// getRiGanAndCalendarInfo 获取当前日期的干支信息和万年历数据
-// 包含智能缓存机制和超时控制，避免重复调用外部API
-// 现在使用准确的中国北京时间进行查询
+// getRiGanAndCalendarInfoAt 获取指定时刻所在日期的干支信息和万年历数据
+// 按时刻自身所带时区的公历日期查询，适用于当前起卦、补录之前的起卦或海外用户的当地时间
+// 查询结果经过万年历缓存，避免重复调用外部API
+// 按子初换日排时，23点之后的日柱取次日，年柱和月柱仍取当日
 //
-// 功能说明：
-// 1. 获取准确的中国北京时间
-// 2. 检查缓存中是否已有当日数据
-// 3. 如无缓存则调用外部万年历API
-// 4. 解析API响应并缓存结果
-// 5. 返回干支纪年、纪月、纪日信息
+// 参数：
+//   - moment: 起卦时刻，日期以其所在时区为准
+//   - ziHour: 子时排法，ZiHourLate或ZiHourEarly，为空时按晚子时
 //
 // 返回值：
 //   - string: 干支日，如"乙巳日"
 //   - string: 干支年，如"甲辰年"
 //   - string: 干支月，如"丙寅月"
 //   - error: 错误信息，成功时为nil
-func getRiGanAndCalendarInfo() (string, string, string, error) {
-	// 获取准确的中国北京时间
-	beijingTime, err := getChinaCurrentTime()
+func getRiGanAndCalendarInfoAt(moment time.Time, ziHour string) (string, string, string, error) {
+	year := moment.Year()
+	month := int(moment.Month())
+	day := moment.Day()
+
+	log.Printf("使用的查询时间: %d年%d月%d日", year, month, day)
+
+	apiResponse, err := getCalendarInfoForDate(year, month, day)
 	if err != nil {
-		log.Printf("获取北京时间失败，尝试网络时间服务: %v", err)
-		// 如果本地时区获取失败，尝试网络时间服务
-		beijingTime, err = getNetworkTime()
+		return "", "", "", err
+	}
+
+	ganzhiri := apiResponse.Ganzhiri
+	if dayDate := dayPillarDate(moment, ziHour); !dayDate.Equal(moment) {
+		next, err := getCalendarInfoForDate(dayDate.Year(), int(dayDate.Month()), dayDate.Day())
 		if err != nil {
-			log.Printf("网络时间服务也失败，使用系统时间: %v", err)
-			// 最后备选方案：使用系统时间（可能不准确）
-			beijingTime = time.Now()
+			return "", "", "", err
 		}
+		ganzhiri = next.Ganzhiri
 	}
 
-	year := beijingTime.Year()
-	month := int(beijingTime.Month())
-	day := beijingTime.Day()
+	// 返回成功获取的干支纪年、纪月、纪日信息
+	return ganzhiri, apiResponse.Ganzhinian, apiResponse.Ganzhiyue, nil
+}
 
-	log.Printf("使用的查询时间: %d年%d月%d日", year, month, day)
+// resolveDivineTime 解析占卜请求中的起卦时刻
+// 未指定时间时使用请求时区的当前时间；未指定时区时使用北京时间
+//
+// 支持的时间格式：
+//   - RFC3339，如"2025-01-01T14:30:00+08:00"（自带偏移时以偏移为准换算到请求时区）
+//   - "2006-01-02 15:04:05"、"2006-01-02 15:04"、"2006-01-02T15:04"
+//   - "2006-01-02"，按当日正午处理
+//
+// 参数：
+//   - datetime: 起卦时间字符串，可为空
+//   - timezone: IANA时区名称，如"America/New_York"，可为空
+//
+// 返回值：
+//   - time.Time: 位于请求时区的起卦时刻
+//   - error: 时区或时间格式无效时返回错误
+func resolveDivineTime(datetime, timezone string) (time.Time, error) {
+	if timezone == "" {
+		timezone = defaultTimezone
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("无效的时区: %s", timezone)
+	}
+
+	datetime = strings.TrimSpace(datetime)
+	if datetime == "" {
+		return time.Now().In(location), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, datetime); err == nil {
+		return t.In(location), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, datetime, location); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02", datetime, location); err == nil {
+		return t.Add(12 * time.Hour), nil
+	}
 
-	// 创建基于日期的缓存键，格式：YYYY-MM-DD
-	cacheKey := fmt.Sprintf("%d-%02d-%02d", year, month, day)
+	return time.Time{}, fmt.Errorf("无效的时间格式: %s", datetime)
+}
 
-	// 检查缓存中是否已存在当日数据（使用读锁保证并发安全）
-	calendarCacheMutex.RLock()
-	cachedResponse, found := calendarCache[cacheKey]
-	calendarCacheMutex.RUnlock()
+// getCalendarInfoForDate 获取指定日期的万年历数据
+// 优先读取持久化缓存，未命中时调用外部API并将结果写入缓存
+// 开启校验模式时会与本地历法计算的三柱交叉比对
+//
+// 参数：
+//   - year, month, day: 查询的公历年月日
+//
+// 返回值：
+//   - CalendarAPIResponse: 包含干支纪年、纪月、纪日的万年历数据
+//   - error: 错误信息，成功时为nil
+func getCalendarInfoForDate(year, month, day int) (CalendarAPIResponse, error) {
+	cache := getCalendarCache()
+	cacheKey := calendarCacheKey(year, month, day)
 
-	if found {
+	if cachedResponse, found := cache.Get(cacheKey); found {
 		// 缓存命中，直接返回缓存的数据
 		log.Printf("使用缓存的万年历数据: %s", cacheKey)
-		return cachedResponse.Ganzhiri, cachedResponse.Ganzhinian, cachedResponse.Ganzhiyue, nil
+		return verifyCalendarResponse(year, month, day, cachedResponse, false), nil
+	}
+
+	apiResponse, err := fetchCalendarFromAPI(year, month, day)
+	if err != nil {
+		return CalendarAPIResponse{}, err
 	}
 
-	// 缓存未命中，需要调用外部万年历API获取数据
+	// 缓存保存API原始响应，按校验模式与本地历法交叉比对后再返回
+	cache.Put(cacheKey, apiResponse)
+	return verifyCalendarResponse(year, month, day, apiResponse, true), nil
+}
+
+// fetchCalendarFromAPI 调用外部万年历API获取指定日期的干支信息
+// 不读写缓存，调用方负责缓存结果
+//
+// 参数：
+//   - year, month, day: 查询的公历年月日
+//
+// 返回值：
+//   - CalendarAPIResponse: 经过完整性校验的API响应
+//   - error: 错误信息，成功时为nil
+func fetchCalendarFromAPI(year, month, day int) (CalendarAPIResponse, error) {
 	// 创建带超时控制的HTTP客户端，避免长时间等待
 	client := &http.Client{
 		Timeout: 10 * time.Second, // 增加超时时间到10秒，提高成功率
@@ -148,14 +224,14 @@ func getRiGanAndCalendarInfo() (string, string, string, error) {
 	// 发送HTTP GET请求到万年历API
 	resp, err := client.Get(apiURL)
 	if err != nil {
-		return "", "", "", fmt.Errorf("调用万年历API失败: %w", err)
+		return CalendarAPIResponse{}, fmt.Errorf("调用万年历API失败: %w", err)
 	}
 	defer resp.Body.Close() // 确保响应体被正确关闭
 
 	// 读取API响应的原始数据
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return "", "", "", fmt.Errorf("读取API响应数据失败: %w", err)
+		return CalendarAPIResponse{}, fmt.Errorf("读取API响应数据失败: %w", err)
 	}
 
 	log.Printf("万年历API响应: %s", string(body))
@@ -164,28 +240,21 @@ func getRiGanAndCalendarInfo() (string, string, string, error) {
 	var apiResponse CalendarAPIResponse
 	err = json.Unmarshal(body, &apiResponse)
 	if err != nil {
-		return "", "", "", fmt.Errorf("解析万年历API响应JSON失败: %w", err)
+		return CalendarAPIResponse{}, fmt.Errorf("解析万年历API响应JSON失败: %w", err)
 	}
 
 	// 验证API响应的状态码
 	if apiResponse.Code != 200 {
-		return "", "", "", fmt.Errorf("万年历API返回错误状态码: %d", apiResponse.Code)
+		return CalendarAPIResponse{}, fmt.Errorf("万年历API返回错误状态码: %d", apiResponse.Code)
 	}
 
 	// 验证响应数据的完整性
 	if strings.TrimSpace(apiResponse.Ganzhiri) == "" ||
 		strings.TrimSpace(apiResponse.Ganzhinian) == "" ||
 		strings.TrimSpace(apiResponse.Ganzhiyue) == "" {
-		return "", "", "", fmt.Errorf("万年历API返回的数据不完整")
+		return CalendarAPIResponse{}, fmt.Errorf("万年历API返回的数据不完整")
 	}
 
-	// 将获取的数据保存到缓存中（使用写锁保证并发安全）
-	calendarCacheMutex.Lock()
-	calendarCache[cacheKey] = apiResponse
-	calendarCacheMutex.Unlock()
-
 	log.Printf("万年历数据获取成功: %s %s %s", apiResponse.Ganzhinian, apiResponse.Ganzhiyue, apiResponse.Ganzhiri)
-
-	// 返回成功获取的干支纪年、纪月、纪日信息
-	return apiResponse.Ganzhiri, apiResponse.Ganzhinian, apiResponse.Ganzhiyue, nil
+	return apiResponse, nil
 }
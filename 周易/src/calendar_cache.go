@@ -0,0 +1,342 @@
+// calendar_cache.go 实现万年历数据的有界持久化缓存
+// 缓存按日期保存万年历API的干支结果，超出容量时淘汰最久未使用的条目，
+// 并以JSON文件形式落盘，程序重启后自动恢复，同时统计命中与未命中次数。
+// 写入只标记有未落盘的内容，由定时器合并为一次写盘；预热结束和程序退出前立即落盘
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// calendarCacheFlushDelay 写入后延迟落盘的时间，期间的多次写入合并为一次写盘
+const calendarCacheFlushDelay = 30 * time.Second
+
+// calendarCacheEntry 单条万年历缓存记录
+type calendarCacheEntry struct {
+	Response  CalendarAPIResponse `json:"response"`   // 万年历API返回的干支数据
+	FetchedAt int64               `json:"fetched_at"` // 从API获取数据的时间戳
+	LastUsed  int64               `json:"last_used"`  // 最近一次被读取的时间戳，用于淘汰
+}
+
+// CalendarCache 有界的万年历持久化缓存
+// 键为日期字符串（YYYY-MM-DD），所有方法均可并发调用
+type CalendarCache struct {
+	mu         sync.Mutex
+	entries    map[string]*calendarCacheEntry
+	maxEntries int           // 最大缓存条目数，<=0 表示不限制
+	filePath   string        // 持久化文件路径，为空时仅在内存中缓存
+	version    uint64        // 缓存内容的版本，每次写入加一，由mu保护
+	flushDelay time.Duration // 写入后延迟落盘的时间
+	flushTimer *time.Timer   // 等待中的落盘定时器，没有未落盘的内容时为nil，由mu保护
+
+	writeMu      sync.Mutex // 串行化落盘，保证文件只会被更新的内容覆盖
+	savedVersion uint64     // 已落盘的版本，由writeMu保护
+
+	hits      atomic.Int64 // 缓存命中次数
+	misses    atomic.Int64 // 缓存未命中次数
+	evictions atomic.Int64 // 因超出容量被淘汰的条目数
+}
+
+// 万年历缓存单例
+var (
+	calendarCache     *CalendarCache
+	calendarCacheOnce sync.Once
+)
+
+// getCalendarCache 获取全局万年历缓存
+// 首次调用时根据配置创建缓存并从磁盘加载历史数据
+func getCalendarCache() *CalendarCache {
+	calendarCacheOnce.Do(func() {
+		config := GetConfig()
+		calendarCache = NewCalendarCache(config.Calendar.CacheFile, config.Calendar.CacheMaxEntries)
+		if err := calendarCache.Load(); err != nil {
+			log.Printf("加载万年历缓存文件失败，将使用空缓存: %v", err)
+		}
+	})
+	return calendarCache
+}
+
+// NewCalendarCache 创建万年历缓存
+//
+// 参数：
+//   - filePath: 持久化文件路径，为空则不落盘
+//   - maxEntries: 最大条目数，<=0 表示不限制
+//
+// 返回值：新建的缓存实例
+func NewCalendarCache(filePath string, maxEntries int) *CalendarCache {
+	return &CalendarCache{
+		entries:    make(map[string]*calendarCacheEntry),
+		maxEntries: maxEntries,
+		filePath:   filePath,
+		flushDelay: calendarCacheFlushDelay,
+	}
+}
+
+// calendarCacheKey 根据年月日生成缓存键，格式：YYYY-MM-DD
+func calendarCacheKey(year, month, day int) string {
+	return fmt.Sprintf("%d-%02d-%02d", year, month, day)
+}
+
+// Get 查询指定日期的缓存数据，并更新命中统计
+//
+// 返回值：
+//   - CalendarAPIResponse: 缓存的干支数据
+//   - bool: 是否命中
+func (c *CalendarCache) Get(key string) (CalendarAPIResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, found := c.entries[key]
+	if !found {
+		c.misses.Add(1)
+		return CalendarAPIResponse{}, false
+	}
+
+	c.hits.Add(1)
+	entry.LastUsed = time.Now().Unix()
+	return entry.Response, true
+}
+
+// Contains 判断指定日期是否已缓存，不影响命中统计和淘汰顺序
+func (c *CalendarCache) Contains(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, found := c.entries[key]
+	return found
+}
+
+// Put 写入一条缓存数据，必要时淘汰旧条目
+// 不立即写盘，而是在flushDelay后由定时器落盘，连续写入只重写一次文件
+func (c *CalendarCache) Put(key string, response CalendarAPIResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now().Unix()
+	c.entries[key] = &calendarCacheEntry{
+		Response:  response,
+		FetchedAt: now,
+		LastUsed:  now,
+	}
+	c.evictLocked()
+	c.version++
+	if c.filePath != "" && c.flushTimer == nil {
+		c.flushTimer = time.AfterFunc(c.flushDelay, func() {
+			if err := c.Flush(); err != nil {
+				log.Printf("保存万年历缓存失败: %v", err)
+			}
+		})
+	}
+}
+
+// Flush 将未落盘的内容立即写入磁盘，没有未落盘的内容时直接返回
+// 在写盘锁内取快照，并发调用时排在后面的一次发现版本已落盘即返回，文件不会被较旧的快照覆盖；
+// 写盘失败时内容仍标记为未落盘，下次写入后重新安排落盘
+func (c *CalendarCache) Flush() error {
+	if c.filePath == "" {
+		return nil
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.mu.Lock()
+	if c.flushTimer != nil {
+		c.flushTimer.Stop()
+		c.flushTimer = nil
+	}
+	if c.version == c.savedVersion {
+		c.mu.Unlock()
+		return nil
+	}
+	version := c.version
+	data, err := c.snapshotLocked()
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("序列化万年历缓存失败: %v", err)
+	}
+
+	if err := c.writeFile(data); err != nil {
+		return err
+	}
+	c.savedVersion = version
+	return nil
+}
+
+// evictLocked 淘汰超出容量的条目，调用方必须持有锁
+// 按最近使用时间排序，优先淘汰最久未被读取的日期
+func (c *CalendarCache) evictLocked() {
+	if c.maxEntries <= 0 || len(c.entries) <= c.maxEntries {
+		return
+	}
+
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := c.entries[keys[i]], c.entries[keys[j]]
+		if a.LastUsed != b.LastUsed {
+			return a.LastUsed < b.LastUsed
+		}
+		return keys[i] < keys[j] // 使用时间相同时先淘汰较早的日期
+	})
+
+	overflow := len(c.entries) - c.maxEntries
+	for _, key := range keys[:overflow] {
+		delete(c.entries, key)
+	}
+	c.evictions.Add(int64(overflow))
+}
+
+// snapshotLocked 序列化当前缓存内容，调用方必须持有锁
+func (c *CalendarCache) snapshotLocked() ([]byte, error) {
+	if c.filePath == "" {
+		return nil, nil
+	}
+	return json.Marshal(c.entries)
+}
+
+// writeFile 将序列化后的缓存写入磁盘，调用方必须持有writeMu
+// 先写临时文件再重命名，避免写入中途崩溃导致缓存文件损坏
+func (c *CalendarCache) writeFile(data []byte) error {
+	if c.filePath == "" || data == nil {
+		return nil
+	}
+	if err := ensureDir(filepath.Dir(c.filePath)); err != nil {
+		return fmt.Errorf("创建缓存目录失败: %v", err)
+	}
+
+	tmpPath := c.filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("写入缓存临时文件失败: %v", err)
+	}
+	if err := os.Rename(tmpPath, c.filePath); err != nil {
+		return fmt.Errorf("替换缓存文件失败: %v", err)
+	}
+	return nil
+}
+
+// Load 从磁盘加载缓存文件，文件不存在时视为空缓存
+func (c *CalendarCache) Load() error {
+	if c.filePath == "" || !fileExists(c.filePath) {
+		return nil
+	}
+
+	data, err := os.ReadFile(c.filePath)
+	if err != nil {
+		return fmt.Errorf("读取缓存文件失败: %v", err)
+	}
+
+	entries := make(map[string]*calendarCacheEntry)
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("解析缓存文件失败: %v", err)
+	}
+
+	c.mu.Lock()
+	c.entries = entries
+	c.evictLocked()
+	count := len(c.entries)
+	c.mu.Unlock()
+
+	log.Printf("已从 %s 加载 %d 条万年历缓存", c.filePath, count)
+	return nil
+}
+
+// Stats 返回缓存的统计信息，用于状态查询接口
+func (c *CalendarCache) Stats() map[string]interface{} {
+	c.mu.Lock()
+	size := len(c.entries)
+	c.mu.Unlock()
+
+	hits := c.hits.Load()
+	misses := c.misses.Load()
+	hitRate := 0.0
+	if total := hits + misses; total > 0 {
+		hitRate = float64(hits) / float64(total)
+	}
+
+	return map[string]interface{}{
+		"entries":     size,
+		"max_entries": c.maxEntries,
+		"hits":        hits,
+		"misses":      misses,
+		"hit_rate":    hitRate,
+		"evictions":   c.evictions.Load(),
+		"persistent":  c.filePath != "",
+	}
+}
+
+// prewarmCalendarCache 预热指定日期范围内的万年历缓存
+// 已缓存的日期会被跳过，每次调用外部API之间间隔interval，避免触发调用频次限制
+//
+// 参数：
+//   - from: 起始日期（包含）
+//   - to: 结束日期（包含）
+//   - interval: 两次API调用之间的等待时间
+//
+// 返回值：新获取的天数、失败的天数
+func prewarmCalendarCache(from, to time.Time, interval time.Duration) (int, int) {
+	cache := getCalendarCache()
+	fetched, failed := 0, 0
+
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		key := calendarCacheKey(day.Year(), int(day.Month()), day.Day())
+		if cache.Contains(key) {
+			continue
+		}
+
+		if fetched+failed > 0 && interval > 0 {
+			time.Sleep(interval)
+		}
+
+		response, err := fetchCalendarFromAPI(day.Year(), int(day.Month()), day.Day())
+		if err != nil {
+			log.Printf("预热万年历缓存失败 %s: %v", key, err)
+			failed++
+			continue
+		}
+		cache.Put(key, response)
+		verifyCalendarResponse(day.Year(), int(day.Month()), day.Day(), response, true)
+		fetched++
+	}
+	if err := cache.Flush(); err != nil {
+		log.Printf("保存万年历缓存失败: %v", err)
+	}
+
+	log.Printf("万年历缓存预热完成: %s 至 %s，新增 %d 天，失败 %d 天",
+		from.Format("2006-01-02"), to.Format("2006-01-02"), fetched, failed)
+	return fetched, failed
+}
+
+// startCalendarPrewarm 根据配置在后台预热从今天开始的若干天万年历数据
+func startCalendarPrewarm() {
+	config := GetConfig()
+	days := config.Calendar.PrewarmDays
+	if days <= 0 {
+		return
+	}
+
+	go func() {
+		today, _ := getChinaCurrentTime()
+		from := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
+		to := from.AddDate(0, 0, days-1)
+		log.Printf("开始后台预热万年历缓存，共 %d 天", days)
+		prewarmCalendarCache(from, to, time.Duration(config.Calendar.PrewarmIntervalMs)*time.Millisecond)
+	}()
+}
+
+// flushCalendarCache 程序退出前将全局万年历缓存中未落盘的内容写入磁盘，缓存未创建时什么也不做
+func flushCalendarCache() {
+	if calendarCache == nil {
+		return
+	}
+	if err := calendarCache.Flush(); err != nil {
+		log.Printf("保存万年历缓存失败: %v", err)
+	}
+}
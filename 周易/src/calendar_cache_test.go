@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testCalendarResponse 按序号生成一条万年历数据，内容只用于比对
+func testCalendarResponse(n int) CalendarAPIResponse {
+	return CalendarAPIResponse{Code: 200, Ganzhinian: "甲辰年", Ganzhiyue: "丙寅月", Ganzhiri: fmt.Sprintf("第%d日", n)}
+}
+
+// TestCalendarCacheHitMiss 命中和未命中计入统计，超出容量时淘汰最久未读取的日期
+func TestCalendarCacheHitMiss(t *testing.T) {
+	cache := NewCalendarCache("", 2)
+	if _, found := cache.Get("2025-01-01"); found {
+		t.Fatal("空缓存不应命中")
+	}
+	cache.Put("2025-01-01", testCalendarResponse(1))
+	cache.Put("2025-01-02", testCalendarResponse(2))
+	got, found := cache.Get("2025-01-01")
+	if !found || got != testCalendarResponse(1) {
+		t.Fatalf("命中结果为 %v, %v", got, found)
+	}
+
+	// 1日刚被读取，2日最久未用，写入3日时淘汰2日
+	cache.mu.Lock()
+	cache.entries["2025-01-02"].LastUsed--
+	cache.mu.Unlock()
+	cache.Put("2025-01-03", testCalendarResponse(3))
+	if cache.Contains("2025-01-02") || !cache.Contains("2025-01-01") || !cache.Contains("2025-01-03") {
+		t.Error("应淘汰最久未读取的2025-01-02")
+	}
+
+	stats := cache.Stats()
+	if stats["hits"] != int64(1) || stats["misses"] != int64(1) || stats["evictions"] != int64(1) || stats["entries"] != 2 {
+		t.Errorf("统计为 %v", stats)
+	}
+}
+
+// TestCalendarCachePersistReload 写入后延迟落盘，Flush立即落盘，重新加载后内容一致
+func TestCalendarCachePersistReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calendar_cache.json")
+	cache := NewCalendarCache(path, 0)
+	cache.flushDelay = time.Hour
+	cache.Put("2025-01-01", testCalendarResponse(1))
+	if fileExists(path) {
+		t.Fatal("写入后不应立即落盘")
+	}
+	if err := cache.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := NewCalendarCache(path, 0)
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got, found := reloaded.Get("2025-01-01"); !found || got != testCalendarResponse(1) {
+		t.Fatalf("重新加载后为 %v, %v", got, found)
+	}
+
+	// 定时落盘
+	cache.flushDelay = 10 * time.Millisecond
+	cache.Put("2025-01-02", testCalendarResponse(2))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		reloaded = NewCalendarCache(path, 0)
+		if err := reloaded.Load(); err != nil {
+			t.Fatal(err)
+		}
+		if reloaded.Contains("2025-01-02") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("定时器未将写入落盘")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestCalendarCacheConcurrentPut 并发写入和落盘后，文件中包含全部条目
+func TestCalendarCacheConcurrentPut(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calendar_cache.json")
+	cache := NewCalendarCache(path, 0)
+	cache.flushDelay = time.Millisecond
+
+	const writers, perWriter = 8, 50
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				n := w*perWriter + i
+				cache.Put(fmt.Sprintf("key-%d", n), testCalendarResponse(n))
+				if i%10 == 0 {
+					if err := cache.Flush(); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	if err := cache.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := NewCalendarCache(path, 0)
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < writers*perWriter; n++ {
+		if got, found := reloaded.Get(fmt.Sprintf("key-%d", n)); !found || got != testCalendarResponse(n) {
+			t.Fatalf("key-%d 重新加载后为 %v, %v", n, got, found)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// calendar_info.go 汇总指定时刻的完整历法信息
+// 年月日三柱来自万年历API（经过缓存），时柱按五鼠遁推算，
+// 纳音、旬空、农历日期和节气由本地历法库计算
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/6tail/lunar-go/LunarUtil"
+	"github.com/6tail/lunar-go/calendar"
+)
+
+// newCalendarPillar 根据干支构建一柱的完整信息
+//
+// 参数：
+//   - ganzhi: 干支，可带"年/月/日/时"后缀，如"甲辰年"
+//
+// 返回值：包含干支、纳音和旬空的柱信息
+func newCalendarPillar(ganzhi string) CalendarPillar {
+	ganzhi = trimPillarSuffix(ganzhi)
+	return CalendarPillar{
+		GanZhi:  ganzhi,
+		NaYin:   LunarUtil.NAYIN[ganzhi],
+		XunKong: LunarUtil.GetXunKong(ganzhi),
+	}
+}
+
+// trimPillarSuffix 去掉干支字符串末尾的"年/月/日/时"后缀
+func trimPillarSuffix(ganzhi string) string {
+	ganzhi = strings.TrimSpace(ganzhi)
+	for _, suffix := range []string{"年", "月", "日", "时"} {
+		ganzhi = strings.TrimSuffix(ganzhi, suffix)
+	}
+	return ganzhi
+}
+
+// buildCalendarInfo 构建指定时刻的历法信息
+//
+// 参数：
+//   - moment: 查询时刻，日期和钟点以其所在时区为准
+//   - withHour: 是否计算时柱，查询未指定钟点时为false
+//   - longitude: 查询地经度，可为nil；换算真太阳时后四柱按真太阳时排定
+//
+// 返回值：
+//   - *CalendarInfo: 四柱、纳音、空亡、农历和节气信息
+//   - error: 万年历API调用失败时返回错误
+func buildCalendarInfo(moment time.Time, withHour bool, longitude *float64) (*CalendarInfo, error) {
+	clock := moment
+	if withHour {
+		var err error
+		moment, longitude, err = resolvePillarTime(clock, longitude)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ziHour := GetConfig().Calendar.ZiHour
+	ganzhiri, ganzhinian, ganzhiyue, err := getRiGanAndCalendarInfoAt(moment, ziHour)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &CalendarInfo{
+		Date:     clock.Format("2006-01-02"),
+		Timezone: moment.Location().String(),
+		Year:     newCalendarPillar(ganzhinian),
+		Month:    newCalendarPillar(ganzhiyue),
+		Day:      newCalendarPillar(ganzhiri),
+	}
+	info.KongWang = info.Day.XunKong
+
+	if withHour {
+		hour := newCalendarPillar(hourPillar(extractRiGan(ganzhiri), moment.Hour(), ziHour))
+		info.Time = clock.Format("15:04")
+		info.Hour = &hour
+		info.ZiHour = ziHourUsed(moment, ziHour)
+		if longitude != nil {
+			info.SolarTime = moment.Format("15:04")
+			info.Longitude = longitude
+		}
+	}
+
+	// 农历和节气按当地钟点计算，与四柱使用同一时刻
+	solar := calendar.NewSolar(moment.Year(), int(moment.Month()), moment.Day(), moment.Hour(), moment.Minute(), moment.Second())
+	lunar := solar.GetLunar()
+	info.Lunar = LunarDate{
+		Year:   lunar.GetYear(),
+		Month:  abs(lunar.GetMonth()),
+		Day:    lunar.GetDay(),
+		IsLeap: lunar.GetMonth() < 0,
+		Text:   lunar.String(),
+	}
+	info.SolarTerm = SolarTermInfo{
+		Current: lunar.GetJieQi(),
+		Prev:    newSolarTermPoint(lunar.GetPrevJieQi()),
+		Next:    newSolarTermPoint(lunar.GetNextJieQi()),
+	}
+
+	return info, nil
+}
+
+// newSolarTermPoint 将历法库的节气对象转换为接口输出格式
+func newSolarTermPoint(jieQi *calendar.JieQi) *SolarTermPoint {
+	if jieQi == nil {
+		return nil
+	}
+	return &SolarTermPoint{
+		Name: jieQi.GetName(),
+		Time: jieQi.GetSolar().ToYmdHms(),
+	}
+}
+
+// abs 返回整数的绝对值
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// parseCalendarQuery 解析历法查询参数
+//
+// 参数：
+//   - date: 日期，格式YYYY-MM-DD，必填
+//   - clock: 钟点，格式HH:MM，可为空
+//   - timezone: IANA时区名称，可为空
+//
+// 返回值：
+//   - time.Time: 查询时刻
+//   - bool: 是否指定了钟点
+//   - error: 参数无效时返回错误
+func parseCalendarQuery(date, clock, timezone string) (time.Time, bool, error) {
+	if date == "" {
+		return time.Time{}, false, fmt.Errorf("缺少参数 date")
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return time.Time{}, false, fmt.Errorf("date 格式应为 YYYY-MM-DD: %s", date)
+	}
+
+	if clock == "" {
+		moment, err := resolveDivineTime(date, timezone)
+		return moment, false, err
+	}
+	if _, err := time.Parse("15:04", clock); err != nil {
+		return time.Time{}, false, fmt.Errorf("time 格式应为 HH:MM: %s", clock)
+	}
+	moment, err := resolveDivineTime(date+" "+clock, timezone)
+	return moment, true, err
+}
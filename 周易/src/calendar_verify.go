@@ -0,0 +1,219 @@
+// calendar_verify.go 实现万年历数据的交叉校验
+// 对同一日期分别用本地历法库和远程万年历API计算年月日三柱，
+// 记录并统计不一致的情况，必要时以本地计算结果为准
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/6tail/lunar-go/calendar"
+)
+
+// 万年历校验模式
+const (
+	CalendarVerifyOff         = "off"          // 不校验
+	CalendarVerifyLog         = "log"          // 校验并记录差异，仍使用API结果
+	CalendarVerifyPreferLocal = "prefer_local" // 校验并记录差异，出现差异时使用本地结果
+)
+
+// CalendarMismatch 一条三柱不一致记录
+type CalendarMismatch struct {
+	Date   string `json:"date"`   // 公历日期，YYYY-MM-DD
+	Pillar string `json:"pillar"` // 不一致的柱：year、month、day
+	Remote string `json:"remote"` // 万年历API返回的干支
+	Local  string `json:"local"`  // 本地历法库计算的干支
+}
+
+// CalendarVerifyReport 日期范围校验报告
+type CalendarVerifyReport struct {
+	From        string             `json:"from"`         // 起始日期
+	To          string             `json:"to"`           // 结束日期
+	GeneratedAt string             `json:"generated_at"` // 报告生成时间
+	Checked     int                `json:"checked"`      // 成功比对的天数
+	Failed      int                `json:"failed"`       // 获取API数据失败的天数
+	Mismatched  int                `json:"mismatched"`   // 存在差异的天数
+	Mismatches  []CalendarMismatch `json:"mismatches"`   // 差异明细
+}
+
+// 校验统计，供状态接口展示
+var (
+	calendarVerifyChecked    atomic.Int64 // 已校验的API响应数
+	calendarVerifyMismatched atomic.Int64 // 存在差异的API响应数
+
+	calendarVerifyLastMu       sync.Mutex
+	calendarVerifyLastMismatch *CalendarMismatch // 最近一次发现的差异
+)
+
+// localCalendarPillars 用本地历法库计算指定日期的年月日三柱
+// 与万年历API一样按整日计算：年以立春当天为界，月以节气交接当天为界
+//
+// 返回值：与API响应格式相同、带"年/月/日"后缀的干支数据
+func localCalendarPillars(year, month, day int) CalendarAPIResponse {
+	lunar := calendar.NewSolar(year, month, day, 12, 0, 0).GetLunar()
+	return CalendarAPIResponse{
+		Code:       200,
+		Ganzhinian: lunar.GetYearInGanZhiByLiChun() + "年",
+		Ganzhiyue:  lunar.GetMonthInGanZhi() + "月",
+		Ganzhiri:   lunar.GetDayInGanZhi() + "日",
+	}
+}
+
+// compareCalendarPillars 比较远程和本地的三柱，返回所有不一致项
+func compareCalendarPillars(date string, remote, local CalendarAPIResponse) []CalendarMismatch {
+	var mismatches []CalendarMismatch
+	pairs := []struct {
+		pillar        string
+		remote, local string
+	}{
+		{"year", remote.Ganzhinian, local.Ganzhinian},
+		{"month", remote.Ganzhiyue, local.Ganzhiyue},
+		{"day", remote.Ganzhiri, local.Ganzhiri},
+	}
+	for _, pair := range pairs {
+		if trimPillarSuffix(pair.remote) != trimPillarSuffix(pair.local) {
+			mismatches = append(mismatches, CalendarMismatch{
+				Date:   date,
+				Pillar: pair.pillar,
+				Remote: pair.remote,
+				Local:  pair.local,
+			})
+		}
+	}
+	return mismatches
+}
+
+// verifyCalendarResponse 按配置的校验模式检查万年历API数据
+// 缓存中始终保存API的原始响应，校验在每次读取时进行；
+// 只有新从API获取的数据才会记录日志和计入统计，避免缓存命中时重复报告
+//
+// 参数：
+//   - year, month, day: 数据对应的公历日期
+//   - remote: API原始响应（来自API或缓存）
+//   - fresh: 是否为刚从API获取的数据
+//
+// 返回值：最终采用的万年历数据
+func verifyCalendarResponse(year, month, day int, remote CalendarAPIResponse, fresh bool) CalendarAPIResponse {
+	mode := GetConfig().Calendar.VerifyMode
+	if mode == "" || mode == CalendarVerifyOff {
+		return remote
+	}
+
+	local := localCalendarPillars(year, month, day)
+	mismatches := compareCalendarPillars(calendarCacheKey(year, month, day), remote, local)
+	if fresh {
+		calendarVerifyChecked.Add(1)
+	}
+	if len(mismatches) == 0 {
+		return remote
+	}
+	if !fresh {
+		if mode == CalendarVerifyPreferLocal {
+			return local
+		}
+		return remote
+	}
+
+	calendarVerifyMismatched.Add(1)
+	for _, mismatch := range mismatches {
+		log.Printf("万年历校验发现差异 %s %s柱: API=%s 本地=%s", mismatch.Date, mismatch.Pillar, mismatch.Remote, mismatch.Local)
+	}
+	calendarVerifyLastMu.Lock()
+	calendarVerifyLastMismatch = &mismatches[0]
+	calendarVerifyLastMu.Unlock()
+
+	if mode == CalendarVerifyPreferLocal {
+		log.Printf("万年历校验模式为 %s，采用本地计算结果", mode)
+		return local
+	}
+	return remote
+}
+
+// calendarVerifyStats 返回校验统计信息，用于状态查询接口
+func calendarVerifyStats() map[string]interface{} {
+	calendarVerifyLastMu.Lock()
+	last := calendarVerifyLastMismatch
+	calendarVerifyLastMu.Unlock()
+
+	mode := GetConfig().Calendar.VerifyMode
+	if mode == "" {
+		mode = CalendarVerifyOff
+	}
+	return map[string]interface{}{
+		"mode":          mode,
+		"checked":       calendarVerifyChecked.Load(),
+		"mismatched":    calendarVerifyMismatched.Load(),
+		"last_mismatch": last,
+	}
+}
+
+// sweepCalendarRange 逐日比对日期范围内的远程与本地三柱
+// 远程数据优先读取缓存（缓存保存的是API原始响应），fresh为true时绕过缓存直接调用API
+//
+// 参数：
+//   - from, to: 日期范围（包含两端）
+//   - fresh: 是否绕过缓存
+//   - interval: 两次API调用的间隔
+//
+// 返回值：校验报告
+func sweepCalendarRange(from, to time.Time, fresh bool, interval time.Duration) *CalendarVerifyReport {
+	report := &CalendarVerifyReport{
+		From:        from.Format("2006-01-02"),
+		To:          to.Format("2006-01-02"),
+		GeneratedAt: time.Now().Format(time.RFC3339),
+		Mismatches:  []CalendarMismatch{},
+	}
+	cache := getCalendarCache()
+	calls := 0
+
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		year, month, date := day.Year(), int(day.Month()), day.Day()
+		key := calendarCacheKey(year, month, date)
+
+		remote, cached := CalendarAPIResponse{}, false
+		if !fresh {
+			remote, cached = cache.Get(key)
+		}
+		if !cached {
+			if calls > 0 && interval > 0 {
+				time.Sleep(interval)
+			}
+			calls++
+			var err error
+			remote, err = fetchCalendarFromAPI(year, month, date)
+			if err != nil {
+				log.Printf("校验 %s 时获取万年历数据失败: %v", key, err)
+				report.Failed++
+				continue
+			}
+			cache.Put(key, remote)
+		}
+
+		report.Checked++
+		mismatches := compareCalendarPillars(key, remote, localCalendarPillars(year, month, date))
+		if len(mismatches) > 0 {
+			report.Mismatched++
+			report.Mismatches = append(report.Mismatches, mismatches...)
+		}
+	}
+
+	return report
+}
+
+// writeCalendarVerifyReport 将校验报告写入JSON文件
+func writeCalendarVerifyReport(report *CalendarVerifyReport, path string) error {
+	data, err := json.MarshalIndent(report, "", "    ")
+	if err != nil {
+		return fmt.Errorf("序列化校验报告失败: %v", err)
+	}
+	if err := ensureDir(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("创建报告目录失败: %v", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
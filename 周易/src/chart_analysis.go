@@ -0,0 +1,291 @@
+// chart_analysis.go 实现盘面的排盘分析
+// 按卦宫、纳甲、六亲、六神和世应为六爻逐一排盘，并结合月建、日辰和动爻变化给出要点，
+// 供PDF报告的分析一节使用，英文报告另有对应的英文要点。世爻、纳甲和六亲的取法与卦象图一致
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 六亲的固定排列顺序
+var 六亲顺序 = []string{"父母", "兄弟", "子孙", "妻财", "官鬼"}
+
+// LineAnalysis 一爻的排盘结果
+type LineAnalysis struct {
+	Position    int    `json:"position"`              // 爻位，1为初爻
+	Name        string `json:"name"`                  // 爻位名称，如"初九"
+	LiuShen     string `json:"liushen"`               // 六神
+	LiuQin      string `json:"liuqin"`                // 六亲
+	GanZhi      string `json:"ganzhi"`                // 纳甲干支
+	WuXing      string `json:"wuxing"`                // 五行
+	Shi         bool   `json:"shi"`                   // 是否为世爻
+	Ying        bool   `json:"ying"`                  // 是否为应爻
+	Moving      bool   `json:"moving"`                // 是否为动爻
+	BianLiuQin  string `json:"bian_liuqin,omitempty"` // 动爻化出之爻的六亲
+	BianGanZhi  string `json:"bian_ganzhi,omitempty"` // 动爻化出之爻的干支
+	BianWuXing  string `json:"bian_wuxing,omitempty"` // 动爻化出之爻的五行
+	Transformed string `json:"transformed,omitempty"` // 化出之爻对本爻的作用，如"回头生"
+}
+
+// ChartAnalysis 一次起卦的排盘分析
+type ChartAnalysis struct {
+	GuaGong   string         `json:"guagong"`   // 本卦所属卦宫
+	GuaWuXing string         `json:"guawuxing"` // 卦宫五行
+	ShiYao    int            `json:"shiyao"`    // 世爻爻位
+	YingYao   int            `json:"yingyao"`   // 应爻爻位
+	Lines     []LineAnalysis `json:"lines"`     // 六爻，从初爻到上爻
+	Missing   []string       `json:"missing"`   // 本卦中未出现的六亲
+	Findings  []string       `json:"findings"`  // 分析要点
+}
+
+// analyzeChart 为盘面排盘并给出分析要点
+func analyzeChart(chart *GuaChart) *ChartAnalysis {
+	gong := guaXiang[chart.BenGuaName].GuaGong
+	bianGong := guaXiang[chart.BianGuaName].GuaGong
+	analysis := &ChartAnalysis{
+		GuaGong:   gong,
+		GuaWuXing: 卦宫五行[gong],
+		ShiYao:    dingShiYao(gong),
+	}
+	analysis.YingYao = dingYingYao(analysis.ShiYao)
+
+	present := make(map[string]bool)
+	for 爻位 := 1; 爻位 <= 6; 爻位++ {
+		干支, 五行 := naJia干支五行(gong, 爻位)
+		line := LineAnalysis{
+			Position: 爻位,
+			Name:     getYaoWeiName(爻位, chart.BenGua[爻位-1]),
+			LiuShen:  anLiuShen(chart.RiGan, 爻位),
+			LiuQin:   getLiuQin(gong, 五行),
+			GanZhi:   干支,
+			WuXing:   五行,
+			Shi:      爻位 == analysis.ShiYao,
+			Ying:     爻位 == analysis.YingYao,
+			Moving:   chart.DongYao[爻位-1],
+		}
+		present[line.LiuQin] = true
+
+		// 动爻化出之爻，六亲按变卦卦宫取，与卦象图一致
+		if line.Moving {
+			line.BianGanZhi, line.BianWuXing = naJia干支五行(bianGong, 爻位)
+			line.BianLiuQin = getLiuQin(bianGong, line.BianWuXing)
+			line.Transformed = transformEffect(line.BianWuXing, line.WuXing)
+		}
+		analysis.Lines = append(analysis.Lines, line)
+	}
+	for _, 六亲 := range 六亲顺序 {
+		if !present[六亲] {
+			analysis.Missing = append(analysis.Missing, 六亲)
+		}
+	}
+
+	analysis.Findings = analysisFindings(chart, analysis)
+	return analysis
+}
+
+// FuShen 伏神：本卦不见的六亲，伏于本卦同一爻位之下
+type FuShen struct {
+	Position int    // 爻位，1为初爻
+	LiuQin   string // 六亲
+	GanZhi   string // 纳甲干支
+	WuXing   string // 五行
+}
+
+// fuShenLines 取本卦的伏神
+// 本卦六亲不全时，所缺的六亲取本宫首卦（八纯卦）中同类之爻，伏于本卦同一爻位。
+// 纳甲按卦宫取，与本宫首卦相同，因此六亲通常齐全、没有伏神
+func fuShenLines(卦名 string) []FuShen {
+	gong := guaXiang[卦名].GuaGong
+	present := make(map[string]bool)
+	for 爻位 := 1; 爻位 <= 6; 爻位++ {
+		_, 五行 := naJia干支五行(gong, 爻位)
+		present[getLiuQin(gong, 五行)] = true
+	}
+
+	var lines []FuShen
+	for 爻位 := 1; 爻位 <= 6; 爻位++ {
+		干支, 五行 := naJia干支五行(gong, 爻位)
+		六亲 := getLiuQin(gong, 五行)
+		if present[六亲] {
+			continue
+		}
+		// 首卦中同一六亲出现两次时只取第一爻
+		present[六亲] = true
+		lines = append(lines, FuShen{Position: 爻位, LiuQin: 六亲, GanZhi: 干支, WuXing: 五行})
+	}
+	return lines
+}
+
+// analysisFindings 生成分析要点
+func analysisFindings(chart *GuaChart, analysis *ChartAnalysis) []string {
+	shi := analysis.Lines[analysis.ShiYao-1]
+	ying := analysis.Lines[analysis.YingYao-1]
+	findings := []string{
+		fmt.Sprintf("本卦%s属%s，五行属%s。世爻在%s，为%s%s%s，临%s；应爻在%s，为%s%s%s。",
+			guaXiang[chart.BenGuaName].FullName, analysis.GuaGong, analysis.GuaWuXing,
+			shi.Name, shi.LiuQin, shi.GanZhi, shi.WuXing, shi.LiuShen,
+			ying.Name, ying.LiuQin, ying.GanZhi, ying.WuXing),
+	}
+
+	// 月建和日辰对世爻的作用
+	for _, item := range []struct{ label, pillar string }{{"月建", chart.Ganzhiyue}, {"日辰", chart.Ganzhiri}} {
+		branch := pillarBranch(item.pillar)
+		五行, ok := 地支五行[branch]
+		if !ok {
+			continue
+		}
+		findings = append(findings, fmt.Sprintf("%s%s属%s，%s。", item.label, branch, 五行, describeEffectOnShi(五行, shi.WuXing)))
+	}
+
+	// 动爻
+	var moving []LineAnalysis
+	for _, line := range analysis.Lines {
+		if line.Moving {
+			moving = append(moving, line)
+		}
+	}
+	switch len(moving) {
+	case 0:
+		findings = append(findings, "六爻安静，无动爻，以本卦爻象和世应为断。")
+	case 6:
+		findings = append(findings, fmt.Sprintf("六爻皆动，变为%s，事多变化，宜参看变卦。", guaXiang[chart.BianGuaName].FullName))
+	default:
+		findings = append(findings, fmt.Sprintf("有%d个动爻，变为%s。", len(moving), guaXiang[chart.BianGuaName].FullName))
+	}
+	for _, line := range moving {
+		findings = append(findings, fmt.Sprintf("%s%s%s%s发动，化出%s%s%s，%s。",
+			line.Name, line.LiuQin, line.GanZhi, line.WuXing, line.BianLiuQin, line.BianGanZhi, line.BianWuXing, line.Transformed))
+	}
+
+	if len(analysis.Missing) > 0 {
+		findings = append(findings, fmt.Sprintf("卦中不见%s，所问若涉及此类，可查伏神。", strings.Join(analysis.Missing, "、")))
+	}
+	return findings
+}
+
+// englishFindings 生成英文的分析要点，内容与analysisFindings一一对应
+func englishFindings(chart *GuaChart, analysis *ChartAnalysis, loc *localizer) []string {
+	shi := analysis.Lines[analysis.ShiYao-1]
+	ying := analysis.Lines[analysis.YingYao-1]
+	findings := []string{
+		fmt.Sprintf("The primary hexagram %s belongs to the %s, element %s. The Shi line is %s, %s, with the %s; the Ying line is %s, %s.",
+			loc.guaDesc(chart.BenGuaName), loc.guaGong(analysis.GuaGong), wuXingEnglish[analysis.GuaWuXing],
+			loc.yaoName(shi.Position, chart.BenGua[shi.Position-1]), loc.naJiaText(shi.LiuQin, shi.GanZhi, shi.WuXing), loc.liuShen(shi.LiuShen),
+			loc.yaoName(ying.Position, chart.BenGua[ying.Position-1]), loc.naJiaText(ying.LiuQin, ying.GanZhi, ying.WuXing)),
+	}
+
+	for _, item := range []struct{ label, pillar string }{{"The month branch", chart.Ganzhiyue}, {"The day branch", chart.Ganzhiri}} {
+		branch := pillarBranch(item.pillar)
+		五行, ok := 地支五行[branch]
+		if !ok {
+			continue
+		}
+		findings = append(findings, fmt.Sprintf("%s %s (%s) %s.", item.label, pinyinGanZhi(branch), wuXingEnglish[五行], englishEffectOnShi(五行, shi.WuXing)))
+	}
+
+	var moving []LineAnalysis
+	for _, line := range analysis.Lines {
+		if line.Moving {
+			moving = append(moving, line)
+		}
+	}
+	switch len(moving) {
+	case 0:
+		findings = append(findings, "All six lines are at rest; judge by the lines of the primary hexagram and the Shi and Ying lines.")
+	case 1:
+		findings = append(findings, fmt.Sprintf("One line is moving; the hexagram changes to %s.", loc.guaDesc(chart.BianGuaName)))
+	case 6:
+		findings = append(findings, fmt.Sprintf("All six lines are moving and the hexagram changes to %s; expect many changes and consult the changed hexagram.", loc.guaDesc(chart.BianGuaName)))
+	default:
+		findings = append(findings, fmt.Sprintf("%d lines are moving; the hexagram changes to %s.", len(moving), loc.guaDesc(chart.BianGuaName)))
+	}
+	for _, line := range moving {
+		findings = append(findings, fmt.Sprintf("%s, %s, moves and changes to %s: %s.",
+			loc.yaoName(line.Position, chart.BenGua[line.Position-1]), loc.naJiaText(line.LiuQin, line.GanZhi, line.WuXing),
+			loc.naJiaText(line.BianLiuQin, line.BianGanZhi, line.BianWuXing), transformEnglish[line.Transformed]))
+	}
+
+	if len(analysis.Missing) > 0 {
+		missing := make([]string, len(analysis.Missing))
+		for i, 六亲 := range analysis.Missing {
+			missing[i] = liuQinEnglish[六亲]
+		}
+		findings = append(findings, fmt.Sprintf("%s do not appear in the hexagram; if the question concerns them, look for the hidden lines.", strings.Join(missing, ", ")))
+	}
+	return findings
+}
+
+// wuXingRelation 返回五行a对b的关系：生、克、被生、被克或比和
+// 借用六亲关系表：以b为我，a为生我者即a生b，以此类推
+func wuXingRelation(a, b string) string {
+	switch 六亲关系[b][a] {
+	case "父母":
+		return "生"
+	case "子孙":
+		return "被生"
+	case "官鬼":
+		return "克"
+	case "妻财":
+		return "被克"
+	default:
+		return "比和"
+	}
+}
+
+// transformEffect 返回动爻化出之爻对本爻的作用
+func transformEffect(变五行, 本五行 string) string {
+	switch wuXingRelation(变五行, 本五行) {
+	case "生":
+		return "回头生"
+	case "克":
+		return "回头克"
+	case "被生":
+		return "化泄"
+	case "被克":
+		return "化耗"
+	default:
+		return "化比和"
+	}
+}
+
+// describeEffectOnShi 描述月建或日辰对世爻的作用
+func describeEffectOnShi(五行, 世五行 string) string {
+	switch wuXingRelation(五行, 世五行) {
+	case "生":
+		return "生世爻"
+	case "克":
+		return "克世爻"
+	case "被生":
+		return "世爻生之，主耗泄"
+	case "被克":
+		return "世爻克之，主劳碌而有得"
+	default:
+		return "与世爻比和"
+	}
+}
+
+// englishEffectOnShi 月建或日辰对世爻作用的英文，与describeEffectOnShi对应
+func englishEffectOnShi(五行, 世五行 string) string {
+	switch wuXingRelation(五行, 世五行) {
+	case "生":
+		return "nourishes the Shi line"
+	case "克":
+		return "controls the Shi line"
+	case "被生":
+		return "is nourished by the Shi line, a sign of drain"
+	case "被克":
+		return "is controlled by the Shi line, a sign of toil that brings gain"
+	default:
+		return "shares the element of the Shi line"
+	}
+}
+
+// pillarBranch 取干支的地支，如"丙寅月"取"寅"
+func pillarBranch(pillar string) string {
+	runes := []rune(pillar)
+	if len(runes) < 2 {
+		return ""
+	}
+	return string(runes[1])
+}
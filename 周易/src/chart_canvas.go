@@ -0,0 +1,132 @@
+// chart_canvas.go 定义卦象图的绘制目标
+// 卦象的排版逻辑只依赖chartCanvas接口，由不同的实现输出位图（PNG）或矢量图（SVG），
+// 保证两种格式的内容和位置完全一致
+package main
+
+import (
+	"image"
+	"math"
+
+	"golang.org/x/image/font"
+)
+
+// textStyle 文字样式，决定字号和主题中的颜色
+type textStyle int
+
+const (
+	textTitle  textStyle = iota // 标题：四柱
+	textNormal                  // 正文：卦名、六神
+	textSmall                   // 小字：公历时间、六亲纳甲、爻辞
+	textAccent                  // 强调：动爻标记，字号同正文
+)
+
+// chartCanvas 卦象图绘制目标
+// 坐标以像素为单位，文字的y坐标为基线位置
+type chartCanvas interface {
+	// DrawText 以(x, y)为起点绘制文字
+	DrawText(text string, x, y int, style textStyle)
+	// DrawCenteredText 以centerX为中心水平居中绘制文字
+	DrawCenteredText(text string, centerX, y int, style textStyle)
+	// DrawYao 在(x, y)处按主题的爻线样式绘制一爻，yang为true时画阳爻，否则画阴爻
+	DrawYao(x, y, width, height int, yang bool)
+	// MeasureText 返回文字渲染后的宽度，用于换行和居中
+	MeasureText(text string, style textStyle) int
+}
+
+// chartFaces 一次渲染使用的三种字号字体，按主题字号创建
+type chartFaces struct {
+	Title  font.Face
+	Normal font.Face
+	Small  font.Face
+}
+
+// face 返回样式对应的字体
+func (f *chartFaces) face(style textStyle) font.Face {
+	switch style {
+	case textTitle:
+		return f.Title
+	case textNormal, textAccent:
+		return f.Normal
+	default:
+		return f.Small
+	}
+}
+
+// Close 释放字体资源
+func (f *chartFaces) Close() {
+	f.Title.Close()
+	f.Normal.Close()
+	f.Small.Close()
+}
+
+// rasterCanvas 绘制到NRGBA位图上的画布
+// 每次渲染创建一个，文本缓存只在本次渲染内有效
+type rasterCanvas struct {
+	img   *image.NRGBA
+	faces *chartFaces
+	theme *Theme
+	texts map[string]*TextCache // 本画布的文本缓存
+	scale float64               // 坐标缩放比例，0或1表示按布局原尺寸绘制
+}
+
+// newRasterCanvas 创建位图画布，img通常是主题背景图的副本
+func newRasterCanvas(img *image.NRGBA, faces *chartFaces, theme *Theme) *rasterCanvas {
+	return &rasterCanvas{img: img, faces: faces, theme: theme, texts: make(map[string]*TextCache)}
+}
+
+// newScaledRasterCanvas 创建按比例缩放的位图画布，用于缩略图和高清图
+// 调用方仍按原尺寸的布局坐标绘制，画布将坐标乘以scale；faces和theme须为已按scale放大字号和描边的版本，
+// 文字以目标字号直接绘制而不是缩放位图，MeasureText返回折算回原尺寸的宽度，排版与原图一致
+func newScaledRasterCanvas(img *image.NRGBA, faces *chartFaces, theme *Theme, scale float64) *rasterCanvas {
+	canvas := newRasterCanvas(img, faces, theme)
+	canvas.scale = scale
+	return canvas
+}
+
+// px 将布局坐标换算为画布像素
+func (c *rasterCanvas) px(v int) int {
+	if c.scale == 0 || c.scale == 1 {
+		return v
+	}
+	return int(math.Round(float64(v) * c.scale))
+}
+
+func (c *rasterCanvas) DrawText(text string, x, y int, style textStyle) {
+	drawCachedText(c.texts, c.img, text, c.px(x), c.px(y), c.faces.face(style), c.theme.textColor(style))
+}
+
+func (c *rasterCanvas) DrawCenteredText(text string, centerX, y int, style textStyle) {
+	drawCenteredText(c.texts, c.img, text, c.px(centerX), c.px(y), c.faces.face(style), c.theme.textColor(style))
+}
+
+func (c *rasterCanvas) DrawYao(x, y, width, height int, yang bool) {
+	// 两端分别换算，相邻元素之间不会因取整出现缝隙
+	x, y, width, height = c.px(x), c.px(y), c.px(x+width)-c.px(x), c.px(y+height)-c.px(y)
+	line, col := c.theme.Line, c.theme.colors.yao
+	gap := int(float64(width) * line.Gap)
+
+	if line.Style == YaoLineStroke {
+		if yang {
+			drawRectOutline(c.img, x, y, width, height, line.Stroke, col)
+			return
+		}
+		leftWidth := (width - gap) / 2
+		drawRectOutline(c.img, x, y, leftWidth, height, line.Stroke, col)
+		drawRectOutline(c.img, x+leftWidth+gap, y, width-gap-leftWidth, height, line.Stroke, col)
+		return
+	}
+
+	if yang {
+		drawYangYao(c.img, x, y, width, height, col)
+	} else {
+		drawYinYao(c.img, x, y, width, height, gap, col)
+	}
+}
+
+func (c *rasterCanvas) MeasureText(text string, style textStyle) int {
+	width := font.MeasureString(c.faces.face(style), text)
+	if c.scale == 0 || c.scale == 1 {
+		return width.Round()
+	}
+	return int(math.Round(float64(width) / 64 / c.scale))
+}
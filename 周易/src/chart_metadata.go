@@ -0,0 +1,344 @@
+// chart_metadata.go 实现卦象图中的盘面元数据
+// 图片生成时把完整的盘面（四柱、六爻、动爻、起卦方法和种子、所问之事）以JSON写入图片：
+// PNG和APNG写在iTXt块中，JPEG写在XMP（APP1段）中，SVG写在<metadata>元素中。
+// 图片被转发后仍可通过 POST /api/v1/divine/extract 上传图片取回盘面，重新渲染或分析
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// CastMethodCoins 起卦方法：三枚铜钱摇六次
+const CastMethodCoins = "coins"
+
+// 元数据在各格式中的标识
+const (
+	chartMetadataKeyword = "zhouyi:chart"              // PNG iTXt块的关键字
+	chartMetadataNS      = "urn:zhouyi-divination:1.0" // XMP中自定义属性的命名空间
+	chartMetadataVersion = 1                           // 元数据格式版本
+	xmpHeader            = "http://ns.adobe.com/xap/1.0/\x00"
+	softwareName         = "zhouyi-divination"
+	maxQuestionLength    = 200     // 所问之事的最大字数
+	maxInflatedMetadata  = 1 << 20 // PNG中压缩的元数据解压后的最大字节数，防止解压炸弹
+)
+
+// ChartMetadata 写入图片的盘面元数据
+type ChartMetadata struct {
+	Version  int       `json:"version"`            // 元数据格式版本
+	ID       string    `json:"id"`                 // 占卜ID
+	Type     string    `json:"type,omitempty"`     // 占卜类型，如"today"
+	Question string    `json:"question,omitempty"` // 所问之事
+	Theme    string    `json:"theme,omitempty"`    // 渲染主题
+	Layout   string    `json:"layout,omitempty"`   // 渲染版式
+	Brand    string    `json:"brand,omitempty"`    // 叠加的品牌
+	Chart    *GuaChart `json:"chart"`              // 完整盘面，含起卦方法和种子
+}
+
+// embedChartMetadata 将盘面元数据写入编码好的图片
+// GIF和其他格式不支持写入，原样返回
+//
+// 返回值：写入元数据后的图片数据
+func embedChartMetadata(format string, data []byte, meta *ChartMetadata) ([]byte, error) {
+	payload, err := json.Marshal(meta)
+	if err != nil {
+		return nil, fmt.Errorf("序列化盘面元数据失败: %v", err)
+	}
+
+	switch format {
+	case ImageFormatPNG, ImageFormatAPNG:
+		return embedPNGText(data, payload)
+	case ImageFormatJPEG:
+		return embedJPEGXMP(data, payload)
+	case ImageFormatSVG:
+		return embedSVGMetadata(data, payload)
+	default:
+		return data, nil
+	}
+}
+
+// extractChartMetadata 从上传的图片中读取盘面元数据，按文件头识别PNG、JPEG和SVG
+//
+// 返回值：盘面元数据；图片格式不支持或不含元数据时返回错误
+func extractChartMetadata(data []byte) (*ChartMetadata, error) {
+	var payload []byte
+	var err error
+	switch {
+	case bytes.HasPrefix(data, pngSignature):
+		payload, err = extractPNGText(data)
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		payload, err = extractJPEGXMP(data)
+	case bytes.Contains(data[:min(len(data), 1024)], []byte("<svg")):
+		payload, err = extractSVGMetadata(data)
+	default:
+		return nil, fmt.Errorf("无法识别的图片格式，仅支持PNG、JPEG和SVG")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var meta ChartMetadata
+	if err := json.Unmarshal(payload, &meta); err != nil {
+		return nil, fmt.Errorf("解析盘面元数据失败: %v", err)
+	}
+	if err := validateChart(meta.Chart); err != nil {
+		return nil, err
+	}
+	if err := validateQuestion(meta.Question); err != nil {
+		return nil, fmt.Errorf("盘面元数据中的%v", err)
+	}
+	return &meta, nil
+}
+
+// validateChart 检查从元数据还原的盘面能否用于渲染
+// 元数据可能被改动，变卦、卦名和是否有动爻不采信图中的值，一律按本卦和动爻重新推出；
+// 语言规范化为支持的语言名称
+func validateChart(chart *GuaChart) error {
+	if chart == nil || len(chart.BenGua) != 6 || len(chart.DongYao) != 6 {
+		return fmt.Errorf("盘面元数据不完整")
+	}
+	for i := 0; i < 6; i++ {
+		if !isYaoValue(chart.BenGua[i]) {
+			return fmt.Errorf("盘面元数据中的第%d爻无效，爻只能为0或1", i+1)
+		}
+	}
+	if _, ok := 日干六神[chart.RiGan]; !ok {
+		return fmt.Errorf("盘面元数据中的日干无效: %s", chart.RiGan)
+	}
+	if chart.Locale != "" {
+		locale, err := normalizeLocale(chart.Locale)
+		if err != nil {
+			return fmt.Errorf("盘面元数据中的语言无效: %v", err)
+		}
+		chart.Locale = locale
+	}
+
+	chart.BianGua = make([]int, 6)
+	for i, yao := range chart.BenGua {
+		chart.BianGua[i] = yao
+		if chart.DongYao[i] {
+			chart.BianGua[i] = 1 - yao
+		}
+	}
+	chart.HasDongYao = hasChangingYao(chart.DongYao)
+	chart.BenGuaName = guaToName(chart.BenGua)
+	chart.BianGuaName = guaToName(chart.BianGua)
+	return nil
+}
+
+// isYaoValue 判断是否为有效的爻：0为阴爻，1为阳爻
+func isYaoValue(yao int) bool {
+	return yao == 0 || yao == 1
+}
+
+// verifyChartSeed 用盘面记录的种子重新摇卦，检查本卦和动爻是否一致
+// 不一致说明元数据被改动过，或盘面不是由本服务摇出的
+func verifyChartSeed(chart *GuaChart) bool {
+	if chart.Method != CastMethodCoins {
+		return false
+	}
+	本卦, _, 变爻标记 := generateGua(chart.Seed)
+	for i := range 本卦 {
+		if 本卦[i] != chart.BenGua[i] || 变爻标记[i] != chart.DongYao[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// validateQuestion 检查所问之事的长度
+func validateQuestion(question string) error {
+	if n := utf8.RuneCountInString(question); n > maxQuestionLength {
+		return fmt.Errorf("所问之事过长: %d 字（最多 %d 字）", n, maxQuestionLength)
+	}
+	return nil
+}
+
+// embedPNGText 在IHDR之后插入tEXt（软件名）和iTXt（盘面JSON）块
+// 盘面含中文，必须使用UTF-8的iTXt；tEXt只能存Latin-1文本
+func embedPNGText(data, payload []byte) ([]byte, error) {
+	// 文件头8字节 + IHDR块（长度4 + 类型4 + 内容13 + CRC4）
+	const ihdrEnd = 8 + 25
+	if len(data) < ihdrEnd || !bytes.HasPrefix(data, pngSignature) || string(data[12:16]) != "IHDR" {
+		return nil, fmt.Errorf("写入元数据失败: PNG数据无效")
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data[:ihdrEnd])
+	writePNGChunk(&buf, "tEXt", []byte("Software\x00"+softwareName))
+
+	// iTXt: 关键字\0 压缩标志 压缩方法 语言\0 翻译关键字\0 文本
+	itxt := append([]byte(chartMetadataKeyword), 0, 0, 0, 0, 0)
+	writePNGChunk(&buf, "iTXt", append(itxt, payload...))
+
+	buf.Write(data[ihdrEnd:])
+	return buf.Bytes(), nil
+}
+
+// extractPNGText 查找关键字为chartMetadataKeyword的iTXt、zTXt或tEXt块
+func extractPNGText(data []byte) ([]byte, error) {
+	raw := data[len(pngSignature):]
+	for len(raw) >= 12 {
+		length := binary.BigEndian.Uint32(raw)
+		if uint64(length)+12 > uint64(len(raw)) {
+			break
+		}
+		chunkType, content := string(raw[4:8]), raw[8:8+length]
+		raw = raw[12+length:]
+
+		keyword, rest, found := bytes.Cut(content, []byte{0})
+		if !found || string(keyword) != chartMetadataKeyword {
+			if chunkType == "IEND" {
+				break
+			}
+			continue
+		}
+
+		switch chunkType {
+		case "tEXt":
+			return rest, nil
+		case "zTXt":
+			if len(rest) < 1 {
+				continue
+			}
+			return inflate(rest[1:])
+		case "iTXt":
+			if len(rest) < 2 {
+				continue
+			}
+			compressed := rest[0] == 1
+			// 跳过语言标签和翻译关键字
+			_, rest, _ = bytes.Cut(rest[2:], []byte{0})
+			_, text, _ := bytes.Cut(rest, []byte{0})
+			if compressed {
+				return inflate(text)
+			}
+			return text, nil
+		}
+	}
+	return nil, fmt.Errorf("图片中没有盘面元数据")
+}
+
+// inflate 解压zlib数据，解压后超过maxInflatedMetadata字节时返回错误
+func inflate(data []byte) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解压盘面元数据失败: %v", err)
+	}
+	defer reader.Close()
+
+	// 多读一个字节，用来区分恰好达到上限和超过上限
+	inflated, err := io.ReadAll(io.LimitReader(reader, maxInflatedMetadata+1))
+	if err != nil {
+		return nil, fmt.Errorf("解压盘面元数据失败: %v", err)
+	}
+	if len(inflated) > maxInflatedMetadata {
+		return nil, fmt.Errorf("盘面元数据解压后超过 %d 字节", maxInflatedMetadata)
+	}
+	return inflated, nil
+}
+
+// embedJPEGXMP 在SOI之后插入携带盘面JSON的XMP段（APP1）
+func embedJPEGXMP(data, payload []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, fmt.Errorf("写入元数据失败: JPEG数据无效")
+	}
+
+	packet := `<?xpacket begin="` + "\uFEFF" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>` +
+		`<x:xmpmeta xmlns:x="adobe:ns:meta/">` +
+		`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+		`<rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmlns:zhouyi="` + chartMetadataNS + `">` +
+		`<xmp:CreatorTool>` + softwareName + `</xmp:CreatorTool>` +
+		`<zhouyi:chart>` + html.EscapeString(string(payload)) + `</zhouyi:chart>` +
+		`</rdf:Description></rdf:RDF></x:xmpmeta><?xpacket end="w"?>`
+
+	segment := append([]byte(xmpHeader), packet...)
+	// 段长度含长度字段本身的2字节，上限65535
+	if len(segment)+2 > 0xFFFF {
+		return nil, fmt.Errorf("盘面元数据过大，无法写入JPEG: %d bytes", len(segment))
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data[:2])
+	buf.Write([]byte{0xFF, 0xE1, byte((len(segment) + 2) >> 8), byte(len(segment) + 2)})
+	buf.Write(segment)
+	buf.Write(data[2:])
+	return buf.Bytes(), nil
+}
+
+// extractJPEGXMP 遍历JPEG的段，从XMP中取出zhouyi:chart属性
+func extractJPEGXMP(data []byte) ([]byte, error) {
+	pos := 2
+	for pos+4 <= len(data) && data[pos] == 0xFF {
+		marker := data[pos+1]
+		// SOS之后是图像数据，元数据段不会出现在其后
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			break
+		}
+		segment := data[pos+4 : pos+2+length]
+		pos += 2 + length
+
+		if marker != 0xE1 || !bytes.HasPrefix(segment, []byte(xmpHeader)) {
+			continue
+		}
+		if text, ok := xmlElementText(string(segment[len(xmpHeader):]), "zhouyi:chart"); ok {
+			return []byte(text), nil
+		}
+	}
+	return nil, fmt.Errorf("图片中没有盘面元数据")
+}
+
+// embedSVGMetadata 在<svg>开始标签之后插入<metadata>元素
+func embedSVGMetadata(data, payload []byte) ([]byte, error) {
+	start := bytes.Index(data, []byte("<svg"))
+	end := -1
+	if start >= 0 {
+		end = bytes.IndexByte(data[start:], '>')
+	}
+	if end < 0 {
+		return nil, fmt.Errorf("写入元数据失败: SVG数据无效")
+	}
+	insertAt := start + end + 1
+
+	var buf bytes.Buffer
+	buf.Write(data[:insertAt])
+	buf.WriteString(`<metadata id="zhouyi-chart">` + html.EscapeString(string(payload)) + `</metadata>`)
+	buf.Write(data[insertAt:])
+	return buf.Bytes(), nil
+}
+
+// extractSVGMetadata 取出<metadata id="zhouyi-chart">元素的内容
+func extractSVGMetadata(data []byte) ([]byte, error) {
+	text, ok := xmlElementText(string(data), `metadata id="zhouyi-chart"`)
+	if !ok {
+		return nil, fmt.Errorf("图片中没有盘面元数据")
+	}
+	return []byte(text), nil
+}
+
+// xmlElementText 取出第一个匹配的XML元素的文本并反转义
+// openTag为开始标签中<之后、>之前的内容，结束标签取其中的元素名
+func xmlElementText(doc, openTag string) (string, bool) {
+	name, _, _ := strings.Cut(openTag, " ")
+	start := strings.Index(doc, "<"+openTag+">")
+	if start < 0 {
+		return "", false
+	}
+	start += len(openTag) + 2
+	end := strings.Index(doc[start:], "</"+name+">")
+	if end < 0 {
+		return "", false
+	}
+	return html.UnescapeString(doc[start : start+end]), true
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// tamperedChartPNG 生成写入了指定元数据的1×1 PNG，模拟被改动过元数据的上传图片
+func tamperedChartPNG(t *testing.T, meta *ChartMetadata) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	data, err := embedChartMetadata(ImageFormatPNG, buf.Bytes(), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// TestExtractChartMetadataRebuildsNames 元数据中的变卦和卦名被改动时，按本卦和动爻重新推出
+func TestExtractChartMetadataRebuildsNames(t *testing.T) {
+	chart := benchmarkChart()
+	tampered := *chart
+	tampered.BenGuaName = "乾"
+	tampered.BianGuaName = "坤"
+	tampered.BianGua = []int{1, 1, 1}
+	tampered.HasDongYao = false
+
+	meta, err := extractChartMetadata(tamperedChartPNG(t, &ChartMetadata{Version: chartMetadataVersion, ID: "divine_test", Chart: &tampered}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := meta.Chart
+	if got.BenGuaName != chart.BenGuaName || got.BianGuaName != chart.BianGuaName {
+		t.Errorf("卦名为 %s→%s，期望 %s→%s", got.BenGuaName, got.BianGuaName, chart.BenGuaName, chart.BianGuaName)
+	}
+	for i := range chart.BianGua {
+		if got.BianGua[i] != chart.BianGua[i] {
+			t.Fatalf("变卦为 %v，期望 %v", got.BianGua, chart.BianGua)
+		}
+	}
+	if got.HasDongYao != chart.HasDongYao {
+		t.Errorf("是否有动爻为 %v，期望 %v", got.HasDongYao, chart.HasDongYao)
+	}
+}
+
+// TestExtractChartMetadataRejectsInvalid 所问之事过长或语言无效的元数据不能用于重新渲染
+func TestExtractChartMetadataRejectsInvalid(t *testing.T) {
+	cases := map[string]func(meta *ChartMetadata){
+		"所问之事过长": func(meta *ChartMetadata) { meta.Question = strings.Repeat("问", maxQuestionLength+1) },
+		"语言无效":   func(meta *ChartMetadata) { meta.Chart.Locale = "xx-YY" },
+		"爻无效":    func(meta *ChartMetadata) { meta.Chart.BenGua = []int{1, 2, 1, 0, 1, 0} },
+		"日干无效":   func(meta *ChartMetadata) { meta.Chart.RiGan = "王" },
+	}
+	for name, tamper := range cases {
+		t.Run(name, func(t *testing.T) {
+			chart := *benchmarkChart()
+			meta := &ChartMetadata{Version: chartMetadataVersion, ID: "divine_test", Chart: &chart}
+			tamper(meta)
+			if _, err := extractChartMetadata(tamperedChartPNG(t, meta)); err == nil {
+				t.Error("期望返回错误")
+			}
+		})
+	}
+}
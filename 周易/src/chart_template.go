@@ -0,0 +1,441 @@
+// chart_template.go 实现卦象图的版式模板
+// 模板以JSON声明画布尺寸、各元素的位置、字体以及显示哪些栏目（六神、六亲、动爻、伏神、爻辞、互卦等），
+// 渲染时由computeLayout解释为布局，不需要修改代码即可调整排版。
+// 内置横版、竖版、方形、宽屏四个模板，也可以通过模板目录中的JSON文件或配置文件自定义，
+// 自定义模板可继承已有模板，只写需要改动的字段
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 模板尺寸的上限，避免误写的模板生成过大的图片
+const maxTemplateSize = 4096
+
+// TemplateCanvas 画布尺寸
+type TemplateCanvas struct {
+	Width        int `json:"width"`         // 画布宽度
+	MinHeight    int `json:"min_height"`    // 最小画布高度，爻辞更长时自动加高
+	BottomMargin int `json:"bottom_margin"` // 内容底部到画布底边的最小留白
+}
+
+// TemplateHeader 标题区域
+type TemplateHeader struct {
+	TitleY    int `json:"title_y"`    // 四柱标题的基线Y
+	SubtitleY int `json:"subtitle_y"` // 公历时间的基线Y
+}
+
+// TemplateChart 卦象区域，X偏移均相对于卦象中心或爻的右端
+type TemplateChart struct {
+	LeftCenterX         int `json:"left_center_x"`         // 有动爻时本卦中心X
+	RightCenterX        int `json:"right_center_x"`        // 有动爻时变卦中心X
+	SingleCenterX       int `json:"single_center_x"`       // 无动爻时单卦中心X
+	NameY               int `json:"name_y"`                // 卦名的基线Y
+	GongY               int `json:"gong_y"`                // 卦宫的基线Y
+	BaseY               int `json:"base_y"`                // 上爻中心Y
+	LineSpacing         int `json:"line_spacing"`          // 相邻两爻的间距
+	LiuShenOffset       int `json:"liushen_offset"`        // 有动爻时六神在本卦中心左侧的距离
+	SingleLiuShenOffset int `json:"single_liushen_offset"` // 无动爻时六神在卦象中心左侧的距离
+	NaJiaOffset         int `json:"najia_offset"`          // 六亲纳甲在爻右端之后的距离
+	MarkerOffset        int `json:"marker_offset"`         // 动爻标记在爻右端之后的距离
+	FuShenOffset        int `json:"fushen_offset"`         // 伏神在爻右端之后的距离，与动爻标记同行时排在标记之后
+}
+
+// TemplateYaoCi 爻辞区域
+type TemplateYaoCi struct {
+	Y             int   `json:"y"`                 // 爻辞区域起始Y
+	X             int   `json:"x"`                 // 有动爻时本卦爻辞X
+	X2            int   `json:"x2"`                // 有动爻时变卦爻辞X，堆叠排列时与x相同
+	Width         int   `json:"width"`             // 有动爻时每段爻辞的宽度
+	Stacked       *bool `json:"stacked,omitempty"` // 本卦和变卦爻辞上下堆叠而不是左右并排
+	SingleCenterX int   `json:"single_center_x"`   // 无动爻时爻辞区域中心X
+	SingleWidth   int   `json:"single_width"`      // 无动爻时爻辞宽度
+	LineHeight    int   `json:"line_height"`       // 行高
+}
+
+// TemplateHuGua 互卦栏的位置，为0时自动放置：双卦时在两卦标签之间，单卦时在主卦标签右侧
+type TemplateHuGua struct {
+	X int `json:"x"` // 文字中心X
+	Y int `json:"y"` // 文字基线Y
+}
+
+// TemplatePanels 各栏目是否显示，未填写的取自继承的模板
+type TemplatePanels struct {
+	Title    *bool `json:"title,omitempty"`    // 四柱标题
+	Subtitle *bool `json:"subtitle,omitempty"` // 公历时间
+	Names    *bool `json:"names,omitempty"`    // 卦名和卦宫
+	LiuShen  *bool `json:"liushen,omitempty"`  // 六神
+	LiuQin   *bool `json:"liuqin,omitempty"`   // 六亲纳甲
+	Moving   *bool `json:"moving,omitempty"`   // 动爻标记
+	Labels   *bool `json:"labels,omitempty"`   // 卦象下方的"主卦""变卦"标签
+	FuShen   *bool `json:"fushen,omitempty"`   // 伏神，本卦不见的六亲
+	YaoCi    *bool `json:"yaoci,omitempty"`    // 卦辞和爻辞
+	HuGua    *bool `json:"hugua,omitempty"`    // 互卦
+}
+
+// ChartTemplate 一个卦象图版式模板
+type ChartTemplate struct {
+	Name   string         `json:"name"`           // 模板名称，即请求中的layout
+	Base   string         `json:"base,omitempty"` // 继承的模板，未填写的字段取自该模板
+	Title  string         `json:"title"`          // 模板显示名称
+	Canvas TemplateCanvas `json:"canvas"`
+	Fonts  ThemeFont      `json:"fonts"` // 覆盖主题的字体和字号，未填写的沿用主题
+	Header TemplateHeader `json:"header"`
+	Chart  TemplateChart  `json:"chart"`
+	YaoCi  TemplateYaoCi  `json:"yaoci"`
+	HuGua  TemplateHuGua  `json:"hugua"`
+	Panels TemplatePanels `json:"panels"`
+}
+
+// boolPtr 返回布尔值的指针，用于模板中的可选开关
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+// builtinTemplates 返回内置模板
+// 横版与最初的固定布局一致，其余版式继承横版；伏神和互卦默认不显示
+func builtinTemplates() []ChartTemplate {
+	return []ChartTemplate{
+		{
+			Name:   LayoutLandscape,
+			Title:  "横版",
+			Canvas: TemplateCanvas{Width: 1200, MinHeight: 900, BottomMargin: 40},
+			Header: TemplateHeader{TitleY: 70, SubtitleY: 115},
+			Chart: TemplateChart{
+				LeftCenterX: 300, RightCenterX: 800, SingleCenterX: 600,
+				NameY: 210, GongY: 250, BaseY: 300, LineSpacing: 40,
+				LiuShenOffset: 160, SingleLiuShenOffset: 180,
+				NaJiaOffset: 10, MarkerOffset: 150, FuShenOffset: 150,
+			},
+			YaoCi: TemplateYaoCi{
+				Y: 620, X: 80, X2: 620, Width: 480, Stacked: boolPtr(false),
+				SingleCenterX: 600, SingleWidth: 600, LineHeight: 25,
+			},
+			Panels: TemplatePanels{
+				Title: boolPtr(true), Subtitle: boolPtr(true), Names: boolPtr(true),
+				LiuShen: boolPtr(true), LiuQin: boolPtr(true), Moving: boolPtr(true), Labels: boolPtr(true),
+				FuShen: boolPtr(false), YaoCi: boolPtr(true), HuGua: boolPtr(false),
+			},
+		},
+		{
+			Name:   LayoutSquare,
+			Base:   LayoutLandscape,
+			Title:  "方形",
+			Canvas: TemplateCanvas{Width: 1080, MinHeight: 1080},
+			Chart:  TemplateChart{LeftCenterX: 270, RightCenterX: 720, SingleCenterX: 540},
+			YaoCi:  TemplateYaoCi{X: 50, X2: 560, Width: 470, SingleCenterX: 540, SingleWidth: 700},
+		},
+		{
+			Name:   LayoutPortrait,
+			Base:   LayoutLandscape,
+			Title:  "竖版",
+			Canvas: TemplateCanvas{Width: 1080, MinHeight: 1620},
+			Chart:  TemplateChart{LeftCenterX: 270, RightCenterX: 720, SingleCenterX: 540},
+			YaoCi: TemplateYaoCi{
+				X: 80, X2: 80, Width: 920, Stacked: boolPtr(true),
+				SingleCenterX: 540, SingleWidth: 920,
+			},
+		},
+		{
+			Name:   LayoutWide,
+			Base:   LayoutLandscape,
+			Title:  "宽屏",
+			Canvas: TemplateCanvas{Width: 1920, MinHeight: 1080},
+			Chart:  TemplateChart{SingleCenterX: 550},
+			YaoCi: TemplateYaoCi{
+				Y: 210, X: 1180, X2: 1180, Width: 660, Stacked: boolPtr(true),
+				SingleCenterX: 1510, SingleWidth: 660,
+			},
+		},
+	}
+}
+
+// templateRegistry 已加载的模板
+var (
+	templateRegistry     map[string]*ChartTemplate
+	templateRegistryOnce sync.Once
+)
+
+// getChartTemplates 获取模板表，首次调用时加载内置模板、模板目录和配置中的模板
+// 同名模板后加载的覆盖先加载的
+func getChartTemplates() map[string]*ChartTemplate {
+	templateRegistryOnce.Do(func() {
+		templateRegistry = make(map[string]*ChartTemplate)
+		for _, tpl := range builtinTemplates() {
+			registerTemplate(tpl, "内置")
+		}
+
+		config := GetConfig().Render
+		if config.TemplateDir != "" {
+			files, _ := filepath.Glob(filepath.Join(config.TemplateDir, "*.json"))
+			sort.Strings(files)
+			for _, file := range files {
+				data, err := os.ReadFile(file)
+				if err != nil {
+					log.Printf("读取模板文件失败 %s: %v", file, err)
+					continue
+				}
+				if err := registerTemplateJSON(data, file); err != nil {
+					log.Printf("加载模板文件失败 %s: %v", file, err)
+				}
+			}
+		}
+		for i, raw := range config.Templates {
+			if err := registerTemplateJSON(raw, fmt.Sprintf("配置templates[%d]", i)); err != nil {
+				log.Printf("加载配置中的模板失败: %v", err)
+			}
+		}
+
+		if _, ok := templateRegistry[config.DefaultLayout]; !ok && config.DefaultLayout != "" {
+			log.Printf("默认版式 %s 不存在，使用 %s", config.DefaultLayout, LayoutLandscape)
+		}
+		log.Printf("已加载 %d 个版式模板", len(templateRegistry))
+	})
+	return templateRegistry
+}
+
+// registerTemplateJSON 解析JSON模板定义并注册
+// 定义中未出现的字段取自base指定的模板，未指定base时取自横版
+func registerTemplateJSON(data []byte, source string) error {
+	tpl, err := parseTemplateJSON(data, templateRegistry)
+	if err != nil {
+		return err
+	}
+	return registerTemplate(*tpl, source)
+}
+
+// parseTemplateJSON 解析JSON模板定义，base在templates中查找
+func parseTemplateJSON(data []byte, templates map[string]*ChartTemplate) (*ChartTemplate, error) {
+	var tpl ChartTemplate
+	if err := json.Unmarshal(data, &tpl); err != nil {
+		return nil, fmt.Errorf("解析模板失败: %v", err)
+	}
+	tpl.Name = strings.ToLower(strings.TrimSpace(tpl.Name))
+	if tpl.Name == "" {
+		return nil, fmt.Errorf("模板缺少name字段")
+	}
+	if tpl.Base == "" {
+		tpl.Base = LayoutLandscape
+	}
+	if _, ok := templates[tpl.Base]; !ok {
+		return nil, fmt.Errorf("模板 %s 继承的模板 %s 不存在", tpl.Name, tpl.Base)
+	}
+	return &tpl, nil
+}
+
+// registerTemplate 补全继承字段、校验并注册模板
+func registerTemplate(tpl ChartTemplate, source string) error {
+	if base, ok := templateRegistry[tpl.Base]; ok && tpl.Base != "" {
+		mergeTemplate(&tpl, base)
+	}
+	if tpl.Title == "" {
+		tpl.Title = tpl.Name
+	}
+	if err := tpl.validate(); err != nil {
+		return fmt.Errorf("模板 %s 无效: %v", tpl.Name, err)
+	}
+	templateRegistry[tpl.Name] = &tpl
+	log.Printf("已注册版式模板 %s（%s，来源：%s）", tpl.Name, tpl.Title, source)
+	return nil
+}
+
+// loadTemplateFile 读取模板文件并补全继承字段、校验，但不注册，用于预览尚未部署的模板
+func loadTemplateFile(path string) (*ChartTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取模板文件失败: %v", err)
+	}
+	templates := getChartTemplates()
+	tpl, err := parseTemplateJSON(data, templates)
+	if err != nil {
+		return nil, err
+	}
+	mergeTemplate(tpl, templates[tpl.Base])
+	if tpl.Title == "" {
+		tpl.Title = tpl.Name
+	}
+	if err := tpl.validate(); err != nil {
+		return nil, fmt.Errorf("模板 %s 无效: %v", tpl.Name, err)
+	}
+	return tpl, nil
+}
+
+// mergeTemplate 用base的字段补全tpl中的零值字段
+func mergeTemplate(tpl *ChartTemplate, base *ChartTemplate) {
+	fillInt := func(dst *int, src int) {
+		if *dst == 0 {
+			*dst = src
+		}
+	}
+	fillBool := func(dst **bool, src *bool) {
+		if *dst == nil && src != nil {
+			*dst = boolPtr(*src)
+		}
+	}
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	fillFloat := func(dst *float64, src float64) {
+		if *dst == 0 {
+			*dst = src
+		}
+	}
+
+	fillInt(&tpl.Canvas.Width, base.Canvas.Width)
+	fillInt(&tpl.Canvas.MinHeight, base.Canvas.MinHeight)
+	fillInt(&tpl.Canvas.BottomMargin, base.Canvas.BottomMargin)
+
+	fill(&tpl.Fonts.File, base.Fonts.File)
+	fill(&tpl.Fonts.Family, base.Fonts.Family)
+	fillFloat(&tpl.Fonts.TitleSize, base.Fonts.TitleSize)
+	fillFloat(&tpl.Fonts.NormalSize, base.Fonts.NormalSize)
+	fillFloat(&tpl.Fonts.SmallSize, base.Fonts.SmallSize)
+
+	fillInt(&tpl.Header.TitleY, base.Header.TitleY)
+	fillInt(&tpl.Header.SubtitleY, base.Header.SubtitleY)
+
+	fillInt(&tpl.Chart.LeftCenterX, base.Chart.LeftCenterX)
+	fillInt(&tpl.Chart.RightCenterX, base.Chart.RightCenterX)
+	fillInt(&tpl.Chart.SingleCenterX, base.Chart.SingleCenterX)
+	fillInt(&tpl.Chart.NameY, base.Chart.NameY)
+	fillInt(&tpl.Chart.GongY, base.Chart.GongY)
+	fillInt(&tpl.Chart.BaseY, base.Chart.BaseY)
+	fillInt(&tpl.Chart.LineSpacing, base.Chart.LineSpacing)
+	fillInt(&tpl.Chart.LiuShenOffset, base.Chart.LiuShenOffset)
+	fillInt(&tpl.Chart.SingleLiuShenOffset, base.Chart.SingleLiuShenOffset)
+	fillInt(&tpl.Chart.NaJiaOffset, base.Chart.NaJiaOffset)
+	fillInt(&tpl.Chart.MarkerOffset, base.Chart.MarkerOffset)
+	fillInt(&tpl.Chart.FuShenOffset, base.Chart.FuShenOffset)
+
+	fillInt(&tpl.YaoCi.Y, base.YaoCi.Y)
+	fillInt(&tpl.YaoCi.X, base.YaoCi.X)
+	fillInt(&tpl.YaoCi.X2, base.YaoCi.X2)
+	fillInt(&tpl.YaoCi.Width, base.YaoCi.Width)
+	fillBool(&tpl.YaoCi.Stacked, base.YaoCi.Stacked)
+	fillInt(&tpl.YaoCi.SingleCenterX, base.YaoCi.SingleCenterX)
+	fillInt(&tpl.YaoCi.SingleWidth, base.YaoCi.SingleWidth)
+	fillInt(&tpl.YaoCi.LineHeight, base.YaoCi.LineHeight)
+
+	fillInt(&tpl.HuGua.X, base.HuGua.X)
+	fillInt(&tpl.HuGua.Y, base.HuGua.Y)
+
+	fillBool(&tpl.Panels.Title, base.Panels.Title)
+	fillBool(&tpl.Panels.Subtitle, base.Panels.Subtitle)
+	fillBool(&tpl.Panels.Names, base.Panels.Names)
+	fillBool(&tpl.Panels.LiuShen, base.Panels.LiuShen)
+	fillBool(&tpl.Panels.LiuQin, base.Panels.LiuQin)
+	fillBool(&tpl.Panels.Moving, base.Panels.Moving)
+	fillBool(&tpl.Panels.Labels, base.Panels.Labels)
+	fillBool(&tpl.Panels.FuShen, base.Panels.FuShen)
+	fillBool(&tpl.Panels.YaoCi, base.Panels.YaoCi)
+	fillBool(&tpl.Panels.HuGua, base.Panels.HuGua)
+}
+
+// validate 校验模板的尺寸和位置
+func (t *ChartTemplate) validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("模板缺少name字段")
+	}
+	if t.Canvas.Width <= 0 || t.Canvas.MinHeight <= 0 ||
+		t.Canvas.Width > maxTemplateSize || t.Canvas.MinHeight > maxTemplateSize {
+		return fmt.Errorf("画布尺寸应在1到%d之间: %dx%d", maxTemplateSize, t.Canvas.Width, t.Canvas.MinHeight)
+	}
+	for field, x := range map[string]int{
+		"chart.left_center_x":   t.Chart.LeftCenterX,
+		"chart.right_center_x":  t.Chart.RightCenterX,
+		"chart.single_center_x": t.Chart.SingleCenterX,
+		"yaoci.x":               t.YaoCi.X,
+		"yaoci.x2":              t.YaoCi.X2,
+		"yaoci.single_center_x": t.YaoCi.SingleCenterX,
+		"hugua.x":               t.HuGua.X,
+	} {
+		if x < 0 || x >= t.Canvas.Width {
+			return fmt.Errorf("%s超出画布宽度: %d", field, x)
+		}
+	}
+	if t.Chart.LineSpacing <= 0 || t.YaoCi.LineHeight <= 0 {
+		return fmt.Errorf("爻间距和爻辞行高必须大于0")
+	}
+	if t.YaoCi.Width <= 0 || t.YaoCi.SingleWidth <= 0 {
+		return fmt.Errorf("爻辞宽度必须大于0")
+	}
+	if t.Fonts.TitleSize < 0 || t.Fonts.NormalSize < 0 || t.Fonts.SmallSize < 0 {
+		return fmt.Errorf("字号不能为负数")
+	}
+	return nil
+}
+
+// show 返回栏目开关的值，未设置时显示
+func show(panel *bool) bool {
+	return panel == nil || *panel
+}
+
+// applyFonts 返回应用了模板字体的主题，模板没有覆盖字体时返回原主题
+// 主题名称不变，背景缓存仍按主题共用；字体面对象池按字体和字号区分
+func (t *ChartTemplate) applyFonts(theme *Theme) *Theme {
+	fonts := t.Fonts
+	if fonts.File == "" && fonts.Family == "" && fonts.TitleSize == 0 && fonts.NormalSize == 0 && fonts.SmallSize == 0 {
+		return theme
+	}
+	merged := *theme
+	if fonts.File != "" {
+		merged.Font.File = fonts.File
+	}
+	if fonts.Family != "" {
+		merged.Font.Family = fonts.Family
+	}
+	if fonts.TitleSize > 0 {
+		merged.Font.TitleSize = fonts.TitleSize
+	}
+	if fonts.NormalSize > 0 {
+		merged.Font.NormalSize = fonts.NormalSize
+	}
+	if fonts.SmallSize > 0 {
+		merged.Font.SmallSize = fonts.SmallSize
+	}
+	return &merged
+}
+
+// layoutNames 返回所有版式模板名称，按字母排序
+func layoutNames() []string {
+	names := make([]string, 0, len(getChartTemplates()))
+	for name := range getChartTemplates() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// normalizeLayoutName 规范化请求中的版式名称，为空时使用配置的默认版式
+func normalizeLayoutName(name string) (string, error) {
+	templates := getChartTemplates()
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = strings.ToLower(GetConfig().Render.DefaultLayout)
+		if _, ok := templates[name]; !ok {
+			name = LayoutLandscape
+		}
+	}
+	if _, ok := templates[name]; !ok {
+		return "", fmt.Errorf("不支持的版式: %s（可选 %s）", name, strings.Join(layoutNames(), "、"))
+	}
+	return name, nil
+}
+
+// getChartTemplate 返回指定名称的版式模板
+func getChartTemplate(name string) (*ChartTemplate, error) {
+	tpl, ok := getChartTemplates()[name]
+	if !ok {
+		return nil, fmt.Errorf("不支持的版式: %s", name)
+	}
+	return tpl, nil
+}
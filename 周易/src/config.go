@@ -7,21 +7,48 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/url"
 	"os"
+	"strings"
 )
 
 // Config 应用程序主配置结构体
-// 包含服务器配置、万年历API配置和文件清理配置三个主要部分
+// 包含服务器配置、万年历API配置、文件清理配置和渲染配置几个主要部分
 type Config struct {
 	Server   ServerConfig   `json:"server"`   // HTTP服务器相关配置
 	Calendar CalendarConfig `json:"calendar"` // 万年历API相关配置
 	Cleanup  CleanupConfig  `json:"cleanup"`  // 文件清理相关配置
+	Render   RenderConfig   `json:"render"`   // 卦象图渲染相关配置
+	History  HistoryConfig  `json:"history"`  // 占卜历史相关配置
 }
 
+// HistoryConfig 占卜历史配置
+type HistoryConfig struct {
+	Enabled     bool   `json:"enabled"`      // 是否记录占卜历史
+	File        string `json:"file"`         // 历史文件路径，JSON Lines格式，每行一次起卦
+	MaxRecords  int    `json:"max_records"`  // 最多保留的记录数，超出时淘汰最早的记录，记录全部保存在内存中，上限maxHistoryRecords
+	AccessToken string `json:"access_token"` // 查询历史所需的访问令牌，为空时历史接口不返回所问之事
+}
+
+// maxHistoryRecords 占卜历史最多保留的记录数上限
+// 记录全部保存在内存中，每条连同盘面约2KB，两万条约40MB
+const maxHistoryRecords = 20000
+
 // ServerConfig HTTP服务器配置结构体
 // 定义服务器运行的基本参数
 type ServerConfig struct {
-	Port string `json:"port"` // HTTP服务器监听端口，默认为8090
+	Port                  string           `json:"port"`                    // HTTP服务器监听端口，默认为8090
+	PublicBaseURL         string           `json:"public_base_url"`         // 对外访问的基础地址，如"https://zhouyi.example.com"，为空时按请求推断
+	TrustForwardedHeaders bool             `json:"trust_forwarded_headers"` // 未配置基础地址时是否采信反向代理的X-Forwarded-Proto/Host
+	SignedLinks           SignedLinkConfig `json:"signed_links"`            // 图片和报告的签名链接
+}
+
+// SignedLinkConfig 签名链接配置
+// 开启后图片和报告链接附带过期时间和签名，无签名或已过期的请求被拒绝
+type SignedLinkConfig struct {
+	Enabled    bool   `json:"enabled"`     // 是否只允许通过签名链接访问图片和报告
+	Secret     string `json:"secret"`      // 签名密钥，为空时每次启动随机生成，重启后旧链接失效
+	TTLMinutes int    `json:"ttl_minutes"` // 链接有效期（分钟）
 }
 
 // CalendarConfig 万年历API配置结构体
@@ -30,6 +57,19 @@ type CalendarConfig struct {
 	APIHost string `json:"api_host"` // 万年历API服务器地址
 	ID      string `json:"id"`       // API访问ID，用于身份认证
 	Key     string `json:"key"`      // API访问密钥，用于身份认证
+
+	CacheFile         string `json:"cache_file"`          // 万年历缓存持久化文件路径，为空则仅缓存在内存中
+	CacheMaxEntries   int    `json:"cache_max_entries"`   // 缓存最大条目数，超出时淘汰最久未使用的日期
+	PrewarmDays       int    `json:"prewarm_days"`        // 启动时从今天起预热的天数，0表示不预热
+	PrewarmIntervalMs int    `json:"prewarm_interval_ms"` // 预热时两次API调用的间隔（毫秒），避免触发频次限制
+
+	VerifyMode string `json:"verify_mode"` // 与本地历法交叉校验的模式：off、log、prefer_local
+
+	TrueSolarTime    bool    `json:"true_solar_time"`   // 请求未指定经度时是否也按默认经度换算真太阳时
+	DefaultLongitude float64 `json:"default_longitude"` // 默认起卦地经度，东经为正
+	EquationOfTime   bool    `json:"equation_of_time"`  // 换算真太阳时是否计入均时差，关闭时仅做经度修正
+
+	ZiHour string `json:"zi_hour"` // 子时排法：late为晚子时（零点换日），early为子初换日（23点起日柱取次日）
 }
 
 // CleanupConfig 文件清理配置结构体
@@ -40,6 +80,58 @@ type CleanupConfig struct {
 	CleanOnStart bool `json:"clean_on_start"` // 是否在程序启动时执行一次清理
 }
 
+// RenderConfig 卦象图渲染配置结构体
+// 用于选择和自定义图片主题，以及图片的保存方式
+type RenderConfig struct {
+	DefaultTheme    string            `json:"default_theme"`          // 默认主题名称
+	DefaultLayout   string            `json:"default_layout"`         // 默认版式模板：landscape、portrait、square、wide或自定义模板
+	DefaultLocale   string            `json:"default_locale"`         // 默认语言：zh-Hans、zh-Hant、en
+	NegotiateLocale bool              `json:"negotiate_locale"`       // 请求未指定语言时是否按Accept-Language请求头协商，关闭时使用默认语言
+	ThemeDir        string            `json:"theme_dir"`              // 自定义主题文件目录，目录下每个.json文件定义一个主题
+	Themes          []json.RawMessage `json:"themes,omitempty"`       // 直接写在配置中的自定义主题
+	GroupThemes     map[string]string `json:"group_themes,omitempty"` // OneBot群号到主题名称的映射
+	TemplateDir     string            `json:"template_dir"`           // 自定义版式模板目录，目录下每个.json文件定义一个模板
+	Templates       []json.RawMessage `json:"templates,omitempty"`    // 直接写在配置中的自定义版式模板
+
+	Brandings       map[string]*Branding `json:"brandings,omitempty"`       // 品牌叠加配置，键为品牌名称
+	DefaultBranding string               `json:"default_branding"`          // 默认品牌名称，为空表示不叠加品牌
+	GroupBrandings  map[string]string    `json:"group_brandings,omitempty"` // OneBot群号到品牌名称的映射，none表示该群不叠加
+
+	SaveToDisk        bool `json:"save_to_disk"`        // 是否将图片写入photos目录，关闭时仅保存在内存中
+	ImageCacheSize    int  `json:"image_cache_size"`    // 内存中最多保存的图片数，超出时淘汰最早生成的图片
+	ImageCacheMinutes int  `json:"image_cache_minutes"` // 图片在内存中的保留时间（分钟），0表示不过期
+	MaxConcurrency    int  `json:"max_concurrency"`     // 同时渲染的最大图片数，0表示等于CPU核数
+
+	Fonts []string `json:"fonts,omitempty"` // 按顺序加入回退链的字体文件，排在内嵌字体之前，用于补充生僻字
+
+	Quality        int    `json:"quality"`         // JPEG的默认编码质量（1-100）
+	PNGCompression string `json:"png_compression"` // PNG压缩级别：default、speed、best、none
+
+	Animation AnimationConfig `json:"animation"` // 起卦动画（gif、apng）的帧时长和大小限制
+	Variants  VariantConfig   `json:"variants"`  // 缩略图和高清图
+}
+
+// VariantConfig 缩略图和高清图配置
+type VariantConfig struct {
+	ThumbWidth  int      `json:"thumb_width"` // 缩略图宽度（像素），高度按原图比例
+	HiResDPI    int      `json:"hires_dpi"`   // 高清图的分辨率，原图按96 DPI计算放大倍数
+	Pregenerate []string `json:"pregenerate"` // 起卦时随原图一起生成的尺寸，其余尺寸在首次请求时生成
+
+	CacheSize      int `json:"cache_size"`       // 内存中最多保存的变体数，与原图的image_cache_size分开计数
+	CacheMB        int `json:"cache_mb"`         // 内存中变体的总大小上限（MB），超出时淘汰最久未用的变体
+	HiResPerMinute int `json:"hires_per_minute"` // 每个客户端每分钟最多新渲染的高清图数，0表示不限制
+}
+
+// AnimationConfig 起卦动画配置
+type AnimationConfig struct {
+	CoinMs    int     `json:"coin_ms"`    // 铜钱落下帧的停留时间（毫秒）
+	LineMs    int     `json:"line_ms"`    // 画出一爻后的停留时间（毫秒）
+	FinalMs   int     `json:"final_ms"`   // 最终完整盘面的停留时间（毫秒）
+	LoopCount int     `json:"loop_count"` // 循环次数：0表示无限循环，-1表示只播放一次
+	MaxBytes  int     `json:"max_bytes"`  // 动画文件大小上限（字节），超出时缩小画面重新编码，0表示不限制
+	MinScale  float64 `json:"min_scale"`  // 为满足大小上限允许缩小到的最小比例
+}
+
 // appConfig 全局配置变量，存储当前应用程序的配置信息
 // 通过initConfig()函数初始化，通过GetConfig()函数获取
 var appConfig *Config
@@ -49,25 +141,82 @@ var appConfig *Config
 //
 // 默认配置说明：
 // - 服务器端口：8090
-// - 万年历API：使用测试API地址和默认密钥
+// - 万年历API：使用测试API地址和默认密钥，缓存最多1000天并持久化到cache目录
 // - 文件清理：默认启用，保存24小时，启动时清理
+// - 渲染：默认使用古典主题，从themes目录加载自定义主题，图片落盘并在内存中保留一小时
 //
 // 返回值：包含默认设置的Config结构体指针
 func getDefaultConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Port: "8090", // 默认HTTP服务端口
+			Port:                  "8090", // 默认HTTP服务端口
+			TrustForwardedHeaders: false,  // 默认不采信X-Forwarded-*请求头，确认前面有反向代理时再开启
+			SignedLinks: SignedLinkConfig{
+				Enabled:    false, // 默认不要求签名
+				TTLMinutes: 1440,  // 与图片默认保存时间一致，24小时
+			},
 		},
 		Calendar: CalendarConfig{
 			APIHost: "https://cn.apihz.cn", // 万年历API服务地址
 			ID:      "88888888",            // 测试用API ID
 			Key:     "88888888",            // 测试用API密钥
+
+			CacheFile:         "cache/calendar_cache.json", // 缓存文件保存在cache目录
+			CacheMaxEntries:   1000,                        // 约覆盖近三年的日期
+			PrewarmDays:       0,                           // 默认不预热
+			PrewarmIntervalMs: 1000,                        // 每秒最多调用一次API
+			VerifyMode:        CalendarVerifyLog,           // 默认记录与本地历法的差异
+
+			TrueSolarTime:    false, // 默认按钟表时间排时柱
+			DefaultLongitude: 120,   // 东经120度，即北京时间的中央经线
+			EquationOfTime:   true,  // 默认计入均时差
+
+			ZiHour: ZiHourLate, // 默认零点换日，与万年历的日期一致
 		},
 		Cleanup: CleanupConfig{
 			Enabled:      true, // 默认启用自动清理
 			MaxAge:       24,   // 默认保存24小时
 			CleanOnStart: true, // 默认启动时执行清理
 		},
+		History: HistoryConfig{
+			Enabled:    true,                        // 默认记录占卜历史
+			File:       "history/divinations.jsonl", // 历史保存在history目录
+			MaxRecords: 10000,                       // 每条约2KB，一万条约20MB
+		},
+		Render: RenderConfig{
+			DefaultTheme:    ThemeClassic,    // 默认使用古典主题
+			DefaultLayout:   LayoutLandscape, // 默认横版1200×900
+			DefaultLocale:   LocaleZhHans,    // 默认简体中文
+			NegotiateLocale: false,           // 浏览器总会带上Accept-Language，默认不协商，以免英文系统的浏览器得到英文图片
+			ThemeDir:        "themes",        // 自定义主题放在themes目录
+			TemplateDir:     "templates",     // 自定义版式模板放在templates目录
+
+			SaveToDisk:        true, // 默认同时落盘，兼容通过imagepath取图的客户端
+			ImageCacheSize:    200,  // 内存中保留最近200张图片
+			ImageCacheMinutes: 60,   // 一小时后从内存淘汰
+			MaxConcurrency:    0,    // 按CPU核数并发渲染
+
+			Quality:        90,                    // JPEG质量90，文字边缘清晰
+			PNGCompression: PNGCompressionDefault, // 标准库默认压缩级别
+
+			Animation: AnimationConfig{
+				CoinMs:    500,             // 铜钱停留半秒
+				LineMs:    600,             // 画爻后停留0.6秒
+				FinalMs:   4000,            // 最终盘面停留4秒
+				LoopCount: 0,               // 无限循环
+				MaxBytes:  2 * 1024 * 1024, // 2MB，低于常见聊天平台的动图限制
+				MinScale:  0.5,             // 最多缩小到一半
+			},
+			Variants: VariantConfig{
+				ThumbWidth:  360,                      // 聊天预览和历史列表用的小图
+				HiResDPI:    300,                      // 打印常用分辨率，横版原图放大到3750像素宽
+				Pregenerate: []string{ImageSizeThumb}, // 缩略图渲染很快，起卦时一并生成
+
+				CacheSize:      100, // 缩略图约40KB，高清图PNG约2-4MB
+				CacheMB:        64,  // 约可容纳二十张高清图
+				HiResPerMinute: 6,   // 高清图每张渲染约1秒，够正常浏览和下载
+			},
+		},
 	}
 }
 
@@ -147,7 +296,8 @@ func loadConfig(configPath string) error {
 	}
 
 	// 解析JSON格式的配置数据到Config结构体
-	var config Config
+	// 以默认配置为基础解析，旧版配置文件中缺少的新增字段保持默认值
+	config := *getDefaultConfig()
 	if err := json.Unmarshal(configData, &config); err != nil {
 		return fmt.Errorf("解析配置文件失败: %v", err)
 	}
@@ -174,6 +324,18 @@ func validateConfig(config *Config) error {
 	if config.Server.Port == "" {
 		return fmt.Errorf("服务器端口不能为空")
 	}
+	if base := config.Server.PublicBaseURL; base != "" {
+		parsed, err := url.Parse(base)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("对外访问地址无效，应为http或https开头的完整地址: %s", base)
+		}
+		if parsed.RawQuery != "" || parsed.Fragment != "" {
+			return fmt.Errorf("对外访问地址不能包含查询参数: %s", base)
+		}
+	}
+	if config.Server.SignedLinks.Enabled && config.Server.SignedLinks.TTLMinutes <= 0 {
+		return fmt.Errorf("签名链接有效期必须大于0")
+	}
 
 	// 验证万年历API服务器地址
 	if config.Calendar.APIHost == "" {
@@ -190,6 +352,96 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("万年历API密钥不能为空")
 	}
 
+	// 验证万年历缓存配置
+	if config.Calendar.CacheMaxEntries < 0 {
+		return fmt.Errorf("万年历缓存最大条目数不能为负数")
+	}
+	if config.Calendar.PrewarmDays < 0 || config.Calendar.PrewarmIntervalMs < 0 {
+		return fmt.Errorf("万年历预热天数和调用间隔不能为负数")
+	}
+	switch config.Calendar.VerifyMode {
+	case "", CalendarVerifyOff, CalendarVerifyLog, CalendarVerifyPreferLocal:
+	default:
+		return fmt.Errorf("万年历校验模式无效: %s（可选 off、log、prefer_local）", config.Calendar.VerifyMode)
+	}
+	switch config.Calendar.ZiHour {
+	case "", ZiHourLate, ZiHourEarly:
+	default:
+		return fmt.Errorf("子时排法无效: %s（可选 late、early）", config.Calendar.ZiHour)
+	}
+	if err := validateLongitude(config.Calendar.DefaultLongitude); err != nil {
+		return fmt.Errorf("默认经度配置错误: %v", err)
+	}
+
+	// 验证渲染配置
+	if _, ok := matchLocale(config.Render.DefaultLocale); !ok {
+		return fmt.Errorf("默认语言无效: %s（可选 %s）", config.Render.DefaultLocale, strings.Join(localeNames(), "、"))
+	}
+	for name, branding := range config.Render.Brandings {
+		if name == BrandingNone || branding == nil {
+			return fmt.Errorf("品牌配置无效: %s", name)
+		}
+		if err := branding.validate(); err != nil {
+			return fmt.Errorf("品牌 %s 配置错误: %v", name, err)
+		}
+	}
+	if name := config.Render.DefaultBranding; name != "" {
+		if _, ok := config.Render.Brandings[name]; !ok {
+			return fmt.Errorf("默认品牌不存在: %s", name)
+		}
+	}
+	if config.Render.ImageCacheSize < 0 || config.Render.ImageCacheMinutes < 0 {
+		return fmt.Errorf("图片缓存数量和保留时间不能为负数")
+	}
+	if config.Render.MaxConcurrency < 0 {
+		return fmt.Errorf("最大渲染并发数不能为负数")
+	}
+	if config.Render.Quality < 1 || config.Render.Quality > 100 {
+		return fmt.Errorf("图片编码质量必须在1到100之间: %d", config.Render.Quality)
+	}
+	switch config.Render.PNGCompression {
+	case PNGCompressionDefault, PNGCompressionSpeed, PNGCompressionBest, PNGCompressionNone:
+	default:
+		return fmt.Errorf("PNG压缩级别无效: %s（可选 default、speed、best、none）", config.Render.PNGCompression)
+	}
+	animation := config.Render.Animation
+	if animation.CoinMs < 0 || animation.LineMs < 0 || animation.FinalMs < 0 || animation.MaxBytes < 0 {
+		return fmt.Errorf("动画帧时长和大小上限不能为负数")
+	}
+	if animation.LoopCount < -1 {
+		return fmt.Errorf("动画循环次数无效: %d（0表示无限循环，-1表示只播放一次）", animation.LoopCount)
+	}
+	if animation.MinScale <= 0 || animation.MinScale > 1 {
+		return fmt.Errorf("动画最小缩放比例必须在0到1之间: %g", animation.MinScale)
+	}
+
+	variants := config.Render.Variants
+	if variants.ThumbWidth < 16 || variants.ThumbWidth > maxTemplateSize {
+		return fmt.Errorf("缩略图宽度必须在16到%d之间: %d", maxTemplateSize, variants.ThumbWidth)
+	}
+	if variants.HiResDPI < baseImageDPI || variants.HiResDPI > 600 {
+		return fmt.Errorf("高清图分辨率必须在%d到600 DPI之间: %d", baseImageDPI, variants.HiResDPI)
+	}
+	if variants.CacheSize < 1 {
+		return fmt.Errorf("变体缓存张数必须大于0: %d", variants.CacheSize)
+	}
+	if variants.CacheMB < 1 {
+		return fmt.Errorf("变体缓存大小必须大于0 MB: %d", variants.CacheMB)
+	}
+	if variants.HiResPerMinute < 0 {
+		return fmt.Errorf("高清图限流不能为负数: %d", variants.HiResPerMinute)
+	}
+	for _, size := range variants.Pregenerate {
+		if size != ImageSizeThumb && size != ImageSizeHiRes {
+			return fmt.Errorf("预生成的图片尺寸无效: %s（可选 thumb、hires）", size)
+		}
+	}
+
+	// 验证占卜历史配置
+	if config.History.MaxRecords < 1 || config.History.MaxRecords > maxHistoryRecords {
+		return fmt.Errorf("占卜历史最大记录数无效: %d（1-%d）", config.History.MaxRecords, maxHistoryRecords)
+	}
+
 	// 验证文件清理配置
 	if config.Cleanup.MaxAge < 0 {
 		return fmt.Errorf("文件最大保存时间不能为负数")
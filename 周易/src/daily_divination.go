@@ -0,0 +1,253 @@
+// daily_divination.go 实现"今日卦象"
+// 同一用户在北京时间的同一天内请求今日卦象，得到同一个盘面和同一张图：
+// 当日第一次请求时起卦，之后的请求沿用该盘面，渲染参数相同时直接返回同一个结果。
+// 起卦种子由北京日期和用户标识散列得到，起卦的时辰也由种子决定，与第一次请求的时刻无关，
+// 即使历史未启用、服务重启，同一用户当日的卦象也不变。
+// HTTP和WebSocket请求未带用户标识时，以X-User-ID请求头或客户端地址区分用户，见requestUserID。
+// 针对具体问题的临时起卦使用cast类型，每次重新摇卦
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 占卜类型
+const (
+	DivineTypeToday = "today" // 今日卦象，同一用户每个北京日一卦，带用户标识且未指定类型时的默认值
+	DivineTypeCast  = "cast"  // 临时起卦，每次重新摇卦，用于针对具体问题的占问
+)
+
+// userIDHeader 调用方自报用户标识的请求头，请求体未带user_id的今日卦象优先使用
+const userIDHeader = "X-User-ID"
+
+// errTodayWithoutUser 指定了今日卦象但没有用户标识，无法确定是谁的今日卦象
+// HTTP和WebSocket请求会先以requestUserID补上标识，只有内部调用缺少标识时才会出现
+var errTodayWithoutUser = errors.New("今日卦象需要提供 user_id，不区分用户时请使用 type=cast")
+
+// normalizeDivineType 校验占卜类型
+// 未指定类型时，带用户标识按今日卦象，否则按临时起卦；指定今日卦象时必须带用户标识
+//
+// 返回值：规范化的类型名称；名称无效或今日卦象缺少用户标识时返回错误
+func normalizeDivineType(divineType, userID string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(divineType)) {
+	case "":
+		if userID == "" {
+			return DivineTypeCast, nil
+		}
+		return DivineTypeToday, nil
+	case DivineTypeToday:
+		if userID == "" {
+			return "", errTodayWithoutUser
+		}
+		return DivineTypeToday, nil
+	case DivineTypeCast:
+		return DivineTypeCast, nil
+	}
+	return "", fmt.Errorf("占卜类型无效: %s（可选 today、cast）", divineType)
+}
+
+// isTodayType 判断请求是否明确指定了今日卦象
+func isTodayType(divineType string) bool {
+	return strings.EqualFold(strings.TrimSpace(divineType), DivineTypeToday)
+}
+
+// requestUserID 为未带user_id的今日卦象确定调用方的用户标识
+// 优先取X-User-ID请求头；没有时按客户端地址散列为"anon-"开头的标识，同一地址当日得到同一卦，
+// 历史中不保存原始地址
+func requestUserID(r *http.Request) string {
+	if userID := strings.TrimSpace(r.Header.Get(userIDHeader)); userID != "" {
+		return userID
+	}
+	sum := sha256.Sum256([]byte(clientAddress(r)))
+	return "anon-" + hex.EncodeToString(sum[:8])
+}
+
+// beijingDate 返回时刻对应的北京日期，格式YYYY-MM-DD
+func beijingDate(t time.Time) string {
+	location, err := time.LoadLocation(defaultTimezone)
+	if err != nil {
+		location = time.FixedZone("CST", 8*3600)
+	}
+	return t.In(location).Format("2006-01-02")
+}
+
+// dailySeed 用户当日的起卦种子，由北京日期和用户标识散列得到
+func dailySeed(date, userID string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(date))
+	h.Write([]byte{0})
+	h.Write([]byte(userID))
+	return int64(h.Sum64())
+}
+
+// dailyDivineTime 用户当日的起卦时刻：北京日期当天由种子选定的一个时辰
+// 子时取当日0点，其余时辰取该时辰正中的整点（丑时2点、寅时4点……亥时22点），不跨入前后两日
+func dailyDivineTime(date string, seed int64) (time.Time, error) {
+	day, err := parseDateArg(date)
+	if err != nil {
+		return time.Time{}, err
+	}
+	branch := (uint64(seed) >> 32) % 12
+	return day.Add(time.Duration(branch*2) * time.Hour), nil
+}
+
+// dailyRenderKey 今日卦象按渲染参数和所问之事缓存结果的键
+// 所问之事写入图片元数据并随结果返回，不同时需要重新渲染
+func dailyRenderKey(format string, quality int, theme, layout, locale, brand, question string) string {
+	return fmt.Sprintf("%s:%d:%s:%s:%s:%s:%q", format, quality, theme, layout, locale, brand, question)
+}
+
+// dailyCast 一个用户当日的今日卦象
+type dailyCast struct {
+	mu      sync.Mutex               // 同一用户的并发请求依次处理，保证只起一次卦
+	chart   *GuaChart                // 当日第一次起卦的盘面
+	results map[string]*DivineResult // 按渲染参数缓存的结果，不含Base64图片
+}
+
+// dailyCastCache 按用户缓存当日的今日卦象，进入北京时间新的一天时清空
+type dailyCastCache struct {
+	mu    sync.Mutex
+	date  string                // 缓存所属的北京日期
+	casts map[string]*dailyCast // 按用户标识索引
+}
+
+// dailyCasts 今日卦象缓存
+var dailyCasts = &dailyCastCache{}
+
+// entry 取得用户当日的今日卦象，不存在时创建空条目
+// 每天第一次调用时从占卜历史中恢复当日已起的卦，服务重启后仍沿用
+func (c *dailyCastCache) entry(date, userID string) *dailyCast {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.date != date {
+		c.date = date
+		c.casts = loadDailyCasts(date)
+	}
+	cast, found := c.casts[userID]
+	if !found {
+		cast = &dailyCast{results: make(map[string]*DivineResult)}
+		c.casts[userID] = cast
+	}
+	return cast
+}
+
+// loadDailyCasts 从占卜历史中取出指定北京日期内各用户的今日卦象，同一用户取最早的一条
+func loadDailyCasts(date string) map[string]*dailyCast {
+	casts := make(map[string]*dailyCast)
+	store := getHistoryStore()
+	if store == nil {
+		return casts
+	}
+	start, err := parseDateArg(date)
+	if err != nil {
+		return casts
+	}
+	for _, record := range store.Since(start) {
+		if record.Type != DivineTypeToday || record.UserID == "" || record.Chart == nil {
+			continue
+		}
+		if beijingDate(time.Unix(record.CreatedAt, 0)) != date {
+			continue
+		}
+		if _, found := casts[record.UserID]; found {
+			continue
+		}
+		result := *record.DivineResult
+		key := dailyRenderKey(imageFormatFromContentType(result.ImageType), 0, record.Theme, record.Layout, result.Locale, record.Brand, result.Question)
+		casts[record.UserID] = &dailyCast{
+			chart:   record.Chart,
+			results: map[string]*DivineResult{key: &result},
+		}
+	}
+	return casts
+}
+
+// generateDailyDivination 生成用户的今日卦象
+// 当日尚未起卦时以当日种子起卦并记入历史；已起卦时沿用盘面，渲染参数和所问之事相同且图片仍在时返回同一个结果，
+// 否则按请求的参数重新渲染该盘面。重新渲染不是新的起卦，不记入历史。
+// 盘面只由北京日期和用户标识决定：起卦时刻见dailyDivineTime，请求的时区和经度不参与排盘
+//
+// 参数：
+//   - req: 占卜请求，UserID不能为空
+//   - format, theme, layoutName, locale, brandName: 已校验的渲染参数
+//
+// 返回值：占卜结果的副本，调用方可以修改
+func generateDailyDivination(req *DivineRequest, format string, theme *Theme, layoutName, locale, brandName string) (*DivineResult, error) {
+	date := beijingDate(time.Now())
+	cast := dailyCasts.entry(date, req.UserID)
+	cast.mu.Lock()
+	defer cast.mu.Unlock()
+
+	key := dailyRenderKey(format, req.Quality, theme.Name, layoutName, locale, brandName, req.Question)
+	if cast.chart == nil {
+		seed := dailySeed(date, req.UserID)
+		divineTime, err := dailyDivineTime(date, seed)
+		if err != nil {
+			return nil, err
+		}
+		chart, err := castChart(divineTime, nil, locale, seed)
+		if err != nil {
+			return nil, err
+		}
+		result, err := renderDivination(req, chart, format, theme, layoutName)
+		if err != nil {
+			return nil, err
+		}
+		recordDivination(req, result, chart, theme.Name, layoutName, brandName)
+		cast.chart = chart
+		return cast.store(key, result), nil
+	}
+
+	if cached, found := cast.results[key]; found {
+		if img, ok := loadDivineImage(cached.ID); ok {
+			log.Printf("沿用今日卦象: %s %s", req.UserID, cached.ID)
+			result := *cached
+			if req.Inline {
+				result.ImageData = base64.StdEncoding.EncodeToString(img.Data)
+			}
+			return &result, nil
+		}
+	}
+
+	// 盘面不变，只换语言
+	chart := *cast.chart
+	chart.Locale = locale
+	result, err := renderDivination(req, &chart, format, theme, layoutName)
+	if err != nil {
+		return nil, err
+	}
+	return cast.store(key, result), nil
+}
+
+// store 缓存渲染结果，缓存中不保存Base64图片
+//
+// 返回值：结果的副本，调用方可以修改
+func (c *dailyCast) store(key string, result *DivineResult) *DivineResult {
+	cached := *result
+	cached.ImageData = ""
+	c.results[key] = &cached
+	copied := *result
+	return &copied
+}
+
+// loadDivineImage 按占卜ID取得图片，内存存储中已淘汰时从图片目录读取并放回存储
+func loadDivineImage(id string) (*renderedImage, bool) {
+	if img, found := getImageStore().Get(id); found {
+		return img, true
+	}
+	img, found := loadStoredImageFile(id)
+	if found {
+		getImageStore().Put(id, img)
+	}
+	return img, found
+}
@@ -1,30 +1,100 @@
 package main
 
 import (
+	"encoding/base64"
 	"fmt"
-	"image"
-	"image/color"
 	"log"
 	"strings"
 	"time"
-
-	"golang.org/x/image/font"
 )
 
-// 新增：生成今日卦象并返回图像路径
-func generateTodayGua() (string, error) {
-	// 获取信号量，限制并发图片生成数量
-	imageGenerationSem <- struct{}{}
-	defer func() { <-imageGenerationSem }()
+// generateDivination 按请求起卦并生成卦象图片
+// 起卦时刻由请求中的时间和时区决定，未指定时使用当前北京时间；
+// 今日卦象按用户每天一卦，见generateDailyDivination
+//
+// 返回值：填充了干支、卦象和图片地址的占卜结果，图片同时保存在内存存储中
+func generateDivination(req *DivineRequest) (*DivineResult, error) {
+	divineType, err := normalizeDivineType(req.Type, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	req.Type = divineType
+	divineTime, err := resolveDivineTime(req.DateTime, req.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	format, err := normalizeImageFormat(req.Format)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateImageQuality(req.Quality); err != nil {
+		return nil, err
+	}
+	theme, err := resolveTheme(req.Theme, req.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	layoutName, err := normalizeLayoutName(req.Layout)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateQuestion(req.Question); err != nil {
+		return nil, err
+	}
+	locale, err := normalizeLocale(req.Locale)
+	if err != nil {
+		return nil, err
+	}
+	branding, err := resolveBranding(req.Brand, req.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	brandName := ""
+	if branding != nil {
+		brandName = branding.Name
+	}
 
-	// 加锁保证图片生成过程的串行化，防止并发冲突
-	imageGenerationMutex.Lock()
-	defer imageGenerationMutex.Unlock()
+	// 今日卦象按用户每天一卦；补录指定时刻的起卦时按临时起卦处理
+	if req.Type == DivineTypeToday && req.DateTime == "" {
+		return generateDailyDivination(req, format, theme, layoutName, locale, brandName)
+	}
 
-	log.Printf("开始生成卦象图片...")
+	// 生成卦象，种子随盘面记录在图片元数据中
+	chart, err := castChart(divineTime, req.Longitude, locale, newCastSeed())
+	if err != nil {
+		return nil, err
+	}
+	result, err := renderDivination(req, chart, format, theme, layoutName)
+	if err != nil {
+		return nil, err
+	}
+
+	// 记入占卜历史，图片被清理后仍可按ID查询盘面
+	recordDivination(req, result, chart, theme.Name, layoutName, brandName)
+	return result, nil
+}
+
+// castChart 在指定时刻以种子摇卦，排出四柱和卦象
+//
+// 参数：
+//   - divineTime: 起卦时刻，位于请求时区
+//   - requestLongitude: 请求中的起卦地经度，为nil时按配置决定是否换算真太阳时
+//   - locale: 已校验的输出语言
+//   - seed: 摇卦的随机种子
+//
+// 返回值：完整盘面
+func castChart(divineTime time.Time, requestLongitude *float64, locale string, seed int64) (*GuaChart, error) {
+	// 指定经度或开启真太阳时后，四柱按真太阳时排定
+	pillarTime, longitude, err := resolvePillarTime(divineTime, requestLongitude)
+	if err != nil {
+		return nil, err
+	}
 
-	// 获取日干和万年历信息
-	ganzhiri, ganzhinian, ganzhiyue, err := getRiGanAndCalendarInfo()
+	log.Printf("开始生成卦象图片，起卦时间: %s", divineTime.Format("2006-01-02 15:04:05 MST"))
+
+	// 获取起卦时刻的日干和万年历信息
+	ziHour := GetConfig().Calendar.ZiHour
+	ganzhiri, ganzhinian, ganzhiyue, err := getRiGanAndCalendarInfoAt(pillarTime, ziHour)
 	if err != nil {
 		log.Printf("获取日干和万年历信息失败: %v\n", err)
 		// 使用默认值以便测试
@@ -33,107 +103,315 @@ func generateTodayGua() (string, error) {
 		ganzhiyue = "王中月"
 	}
 
-	// 提取日干
-	日干 := extractRiGan(ganzhiri)
+	本卦, 变卦, 变爻标记 := generateGua(seed)
+
+	chart := &GuaChart{
+		DivineTime: divineTime,
+		Timezone:   divineTime.Location().String(),
+		Longitude:  longitude,
+		Ganzhinian: ganzhinian,
+		Ganzhiyue:  ganzhiyue,
+		Ganzhiri:   ganzhiri,
+		RiGan:      extractRiGan(ganzhiri),
+		BenGua:     本卦,
+		BianGua:    变卦,
+		DongYao:    变爻标记,
+		HasDongYao: hasChangingYao(变爻标记),
+		Method:     CastMethodCoins,
+		Seed:       seed,
+		Locale:     locale,
+	}
+	if longitude != nil {
+		chart.SolarTime = &pillarTime
+	}
+	chart.Ganzhishi = hourPillar(chart.RiGan, pillarTime.Hour(), ziHour)
+	chart.ZiHour = ziHourUsed(pillarTime, ziHour)
+	chart.BenGuaName = guaToName(本卦)
+	chart.BianGuaName = guaToName(变卦)
+	return chart, nil
+}
 
-	// 生成卦象
-	本卦, 变卦, 变爻标记 := generateGua()
+// renderDivination 渲染盘面、写入盘面元数据并保存图片
+// 起卦和从图片元数据还原的盘面都经此生成新的占卜结果
+//
+// 参数：
+//   - req: 占卜请求，使用其中的编码质量、类型、所问之事、品牌和inline选项
+//   - chart: 盘面数据，图中文字和结果中的卦名描述按其语言输出
+//   - format, theme, layoutName: 已校验的图片格式、主题和版式
+//
+// 返回值：填充了干支、卦象和图片地址的占卜结果，图片同时保存在内存存储中
+func renderDivination(req *DivineRequest, chart *GuaChart, format string, theme *Theme, layoutName string) (*DivineResult, error) {
+	// 占用渲染槽位后排版并渲染到内存，万年历查询等网络请求不占用槽位
+	now := time.Now()
+	id := newDivineID(now)
+	branding, err := resolveBranding(req.Brand, req.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := renderChartWithLayout(format, req.Quality, layoutName, chart, theme, newBrandOverlay(branding, id))
+	if err != nil {
+		return nil, err
+	}
 
-	// 检测是否有动爻
-	有动爻 := hasChangingYao(变爻标记)
+	// 写入盘面元数据，图片被转发后仍可取回盘面
+	rendered.Meta = &ChartMetadata{
+		Version:  chartMetadataVersion,
+		ID:       id,
+		Type:     req.Type,
+		Question: req.Question,
+		Theme:    theme.Name,
+		Layout:   layoutName,
+		Chart:    chart,
+	}
+	if branding != nil {
+		rendered.Meta.Brand = branding.Name
+	}
+	rendered.Data, err = embedChartMetadata(format, rendered.Data, rendered.Meta)
+	if err != nil {
+		return nil, err
+	}
 
-	// 根据卦象确定卦名
-	本卦名 := guaToName(本卦)
-	变卦名 := guaToName(变卦)
+	baseName, err := imageBaseName(id)
+	if err != nil {
+		return nil, err
+	}
+	rendered.FileName = baseName + "." + imageFileExt(format)
+	getImageStore().Put(id, rendered)
+
+	// 按配置落盘，供/photos/静态路径访问
+	savePath := ""
+	if GetConfig().Render.SaveToDisk {
+		if savePath, err = saveImageData(rendered.Data, rendered.FileName); err != nil {
+			return nil, err
+		}
+	}
 
-	// 初始化布局
-	layout := initLayout(ImageWidth, 有动爻)
+	// 缩略图等变体与原图一起生成，历史列表和聊天预览可直接取用
+	pregenerateImageVariants(id, rendered)
 
-	// 获取背景
-	dst := getBackground(ImageWidth, ImageHeight, "images/background.png")
+	// 输出卦象信息到日志
+	log.Printf("%s，%s，%s，%s", chart.Ganzhinian, chart.Ganzhiyue, chart.Ganzhiri, chart.Ganzhishi)
+	log.Printf("本卦：%s %s", chart.BenGuaName, guaXiang[chart.BenGuaName].FullName)
+	if chart.HasDongYao {
+		log.Printf("变卦：%s %s", chart.BianGuaName, guaXiang[chart.BianGuaName].FullName)
+	} else {
+		log.Printf("无动爻，无变卦")
+	}
 
-	// 加载字体文件
-	fontBytes, err := loadFontFile()
-	if err != nil {
-		return "", fmt.Errorf("加载字体文件失败: %v", err)
+	log.Printf("卦象图片生成完成: %s（%d bytes）", id, len(rendered.Data))
+
+	// 卦名描述和卦辞按盘面的语言输出
+	loc := newLocalizer(chart.Locale)
+	result := &DivineResult{
+		ID:         id,
+		Date:       chart.DivineTime.Format("2006-01-02"),
+		DivineTime: chart.DivineTime.Format(time.RFC3339),
+		Timezone:   chart.Timezone,
+		Longitude:  chart.Longitude,
+		Ganzhinian: chart.Ganzhinian,
+		Ganzhiyue:  chart.Ganzhiyue,
+		Ganzhiri:   chart.Ganzhiri,
+		Ganzhishi:  chart.Ganzhishi,
+		ZiHour:     chart.ZiHour,
+		BenGua:     chart.BenGuaName,
+		BenGuaDesc: loc.guaDesc(chart.BenGuaName),
+		Judgment:   loc.judgment(chart.BenGuaName),
+		HasDongYao: chart.HasDongYao,
+		ImagePath:  savePath,
+		ImageURL:   divineImagePath(id),
+		ThumbURL:   divineImagePath(id) + "?size=" + ImageSizeThumb,
+		ImageType:  rendered.ContentType,
+		Question:   req.Question,
+		Locale:     loc.locale,
+		CreatedAt:  now.Unix(),
+	}
+	if req.Inline {
+		result.ImageData = base64.StdEncoding.EncodeToString(rendered.Data)
+	}
+	if chart.SolarTime != nil {
+		result.SolarTime = chart.SolarTime.Format("2006-01-02 15:04:05")
 	}
+	if chart.HasDongYao {
+		result.BianGua = chart.BianGuaName
+		result.BianGuaDesc = loc.guaDesc(chart.BianGuaName)
+		result.BianJudgment = loc.judgment(chart.BianGuaName)
+	}
+	return result, nil
+}
 
-	// 创建字体面
-	titleFace, normalFace, smallFace, err := createFontFaces(fontBytes)
-	if err != nil {
-		return "", fmt.Errorf("创建字体失败: %v", err)
+// renderChart 按格式将卦象盘面渲染为编码好的图片数据，不写磁盘
+//
+// 参数：
+//   - format: 图片格式，位图png、jpeg，矢量图svg，或动画格式gif、apng
+//   - quality: JPEG的编码质量，0表示使用配置的默认值
+//   - layout, chart: 布局和盘面数据
+//   - faces: 渲染用字体
+//   - theme: 渲染主题
+//   - overlay: 画完盘面后叠加的品牌元素，nil表示不叠加
+//
+// 返回值：编码后的图片及其MIME类型
+func renderChart(format string, quality int, layout *Layout, chart *GuaChart, faces *chartFaces, theme *Theme, overlay *brandOverlay) (*renderedImage, error) {
+	if format == ImageFormatSVG {
+		data, err := renderChartSVG(layout, chart, faces, theme, overlay)
+		if err != nil {
+			return nil, fmt.Errorf("绘制卦象图像失败: %v", err)
+		}
+		return &renderedImage{Data: data, ContentType: imageContentType(format), CreatedAt: time.Now()}, nil
+	}
+	if isAnimatedFormat(format) {
+		return renderChartAnimation(format, layout, chart, faces, theme, overlay)
 	}
-	defer titleFace.Close()
-	defer normalFace.Close()
-	defer smallFace.Close()
 
-	// 清空文本缓存
-	textCacheMap = make(map[string]*TextCache)
+	// 获取背景
+	dst := getBackground(theme, layout.宽度, layout.高度)
 
 	// 绘制图像内容
-	err = drawGuaImage(dst, layout, 日干, 本卦名, 变卦名, 本卦, 变卦, 变爻标记, 有动爻, ganzhinian, ganzhiyue, ganzhiri, titleFace, normalFace, smallFace)
+	if err := drawGuaImage(newRasterCanvas(dst, faces, theme), layout, chart); err != nil {
+		return nil, fmt.Errorf("绘制卦象图像失败: %v", err)
+	}
+	if overlay != nil {
+		overlay.drawRaster(dst, faces, theme)
+	}
+
+	// 按格式编码图像
+	data, err := encodeRaster(format, dst, quality)
 	if err != nil {
-		return "", fmt.Errorf("绘制卦象图像失败: %v", err)
+		return nil, err
 	}
+	return &renderedImage{Data: data, ContentType: imageContentType(format), CreatedAt: time.Now()}, nil
+}
 
-	// 保存图像
-	fileName := fmt.Sprintf("卜卦_%s.png", time.Now().Format("20060102150405"))
-	savePath, err := saveImageToPathFixed(dst, fileName)
+// renderChartWithLayout 借出字体面、按版式模板排版后渲染卦象盘面
+// 同时进行的渲染数量受渲染槽位限制，各次渲染之间不共享可变状态
+//
+// 参数：
+//   - format, quality: 图片格式和编码质量
+//   - layoutName: 版式名称
+//   - chart: 盘面数据
+//   - theme: 渲染主题
+//   - overlay: 品牌叠加层，nil表示不叠加
+//
+// 返回值：编码后的图片
+func renderChartWithLayout(format string, quality int, layoutName string, chart *GuaChart, theme *Theme, overlay *brandOverlay) (*renderedImage, error) {
+	tpl, err := getChartTemplate(layoutName)
 	if err != nil {
-		return "", fmt.Errorf("保存图像失败: %v", err)
+		return nil, err
 	}
+	return renderChartWithTemplate(format, quality, tpl, chart, theme, overlay)
+}
 
-	// 输出卦象信息到日志
-	log.Printf("%s，%s，%s", ganzhinian, ganzhiyue, ganzhiri)
-	log.Printf("本卦：%s %s", 本卦名, guaXiang[本卦名].FullName)
-	if 有动爻 {
-		log.Printf("变卦：%s %s", 变卦名, guaXiang[变卦名].FullName)
-	} else {
-		log.Printf("无动爻，无变卦")
+// renderChartWithTemplate 按给定的版式模板渲染卦象盘面，模板可以未注册（如预览中的模板文件）
+func renderChartWithTemplate(format string, quality int, tpl *ChartTemplate, chart *GuaChart, theme *Theme, overlay *brandOverlay) (*renderedImage, error) {
+	release := acquireRenderSlot()
+	defer release()
+
+	// 版式模板可覆盖主题的字体和字号
+	theme = tpl.applyFonts(theme)
+
+	// 借出字体面，渲染结束后归还对象池
+	faces, err := acquireFaces(theme)
+	if err != nil {
+		return nil, err
 	}
+	defer releaseFaces(theme, faces)
 
-	log.Printf("卦象图片生成完成: %s", savePath)
-	return savePath, nil
+	// 按版式模板计算布局，爻辞过长时自动加高画布
+	layout := computeLayout(tpl, chart, theme, faces)
+	return renderChart(format, quality, layout, chart, faces, theme, overlay)
+}
+
+// divineImagePath 返回占卜结果图片的接口路径
+func divineImagePath(id string) string {
+	return apiPath("/divine/" + id + "/image")
 }
 
 // 绘制卦象图像
-func drawGuaImage(dst interface{}, layout *Layout, 日干, 本卦名, 变卦名 string, 本卦, 变卦 []int, 变爻标记 []bool, 有动爻 bool, ganzhinian, ganzhiyue, ganzhiri string, titleFace, normalFace, smallFace interface{}) error {
-	img := dst.(*image.NRGBA)
+// 图中文字按盘面的语言转换，简体中文的排版与最初的固定布局一致
+func drawGuaImage(canvas chartCanvas, layout *Layout, chart *GuaChart) error {
+	本卦名, 变卦名 := chart.BenGuaName, chart.BianGuaName
+	有动爻 := chart.HasDongYao
+	loc := newLocalizer(chart.Locale)
+
+	// 绘制标题（年月日时）- 使用优化的居中文本绘制
+	if layout.栏目.标题 {
+		canvas.DrawCenteredText(loc.pillars(chart), layout.宽度/2, layout.标题Y, textTitle)
+	}
 
-	// 绘制标题（年月日）- 使用优化的居中文本绘制
-	titleText := ganzhinian + " " + ganzhiyue + " " + ganzhiri
-	drawCenteredText(img, titleText, ImageWidth/2, 70, titleFace.(font.Face))
+	// 绘制起卦的公历时间和时区
+	if layout.栏目.副标题 {
+		canvas.DrawCenteredText(loc.divineTime(chart), layout.宽度/2, layout.副标题Y, textSmall)
+	}
 
 	// 绘制本卦和变卦信息
-	if 有动爻 {
-		// 有动爻，显示双卦标题
-		leftInfoX := layout.左卦中心X - 60
-		rightInfoX := layout.右卦中心X - 45
-		drawCachedText(img, guaXiang[本卦名].FullName, leftInfoX, 210, normalFace.(font.Face))
-		drawCachedText(img, "("+guaXiang[本卦名].GuaGong+")", leftInfoX, 250, normalFace.(font.Face))
-		drawCachedText(img, guaXiang[变卦名].FullName, rightInfoX, 210, normalFace.(font.Face))
-		drawCachedText(img, "("+guaXiang[变卦名].GuaGong+")", rightInfoX, 250, normalFace.(font.Face))
-	} else {
-		// 无动爻，只显示单卦标题并居中
-		titleX := layout.左卦中心X
-		drawCenteredText(img, guaXiang[本卦名].FullName, titleX, 210, normalFace.(font.Face))
-		drawCenteredText(img, "("+guaXiang[本卦名].GuaGong+")", titleX, 250, normalFace.(font.Face))
+	if layout.栏目.卦名 {
+		drawGuaNames(canvas, layout, loc, 本卦名, 变卦名, 有动爻)
 	}
 
 	// 绘制卦象主体
-	err := drawGuaBody(img, layout, 日干, 本卦名, 变卦名, 本卦, 变卦, 变爻标记, 有动爻, normalFace.(font.Face), smallFace.(font.Face))
+	err := drawGuaBody(canvas, layout, loc, chart.RiGan, 本卦名, 变卦名, chart.BenGua, chart.BianGua, chart.DongYao, 有动爻)
 	if err != nil {
 		return err
 	}
 
+	// 绘制互卦
+	if layout.栏目.互卦 {
+		drawHuGua(canvas, layout, loc, chart.BenGua)
+	}
+
 	// 绘制爻辞
-	drawYaoCi(img, layout, 本卦名, 变卦名, 本卦, 变卦, 有动爻, smallFace.(font.Face))
+	if layout.栏目.爻辞 {
+		drawYaoCi(canvas, layout, loc, 本卦名, 变卦名, chart.BenGua, chart.BianGua, 有动爻)
+	}
 
 	return nil
 }
 
+// drawGuaNames 绘制卦象上方的卦名和卦宫
+func drawGuaNames(canvas chartCanvas, layout *Layout, loc *localizer, 本卦名, 变卦名 string, 有动爻 bool) {
+	if 有动爻 && !loc.latin() {
+		// 有动爻，显示双卦标题
+		leftInfoX := layout.左卦中心X - 60
+		rightInfoX := layout.右卦中心X - 45
+		canvas.DrawText(loc.guaTitle(本卦名), leftInfoX, layout.卦名Y, textNormal)
+		canvas.DrawText(loc.guaSubtitle(本卦名), leftInfoX, layout.卦宫Y, textNormal)
+		canvas.DrawText(loc.guaTitle(变卦名), rightInfoX, layout.卦名Y, textNormal)
+		canvas.DrawText(loc.guaSubtitle(变卦名), rightInfoX, layout.卦宫Y, textNormal)
+	} else {
+		// 无动爻时只显示单卦标题；英文卦名较长，双卦时也各自以卦象为中心居中
+		subtitleStyle := textNormal
+		if loc.latin() {
+			subtitleStyle = textSmall
+		}
+		canvas.DrawCenteredText(loc.guaTitle(本卦名), layout.左卦中心X, layout.卦名Y, textNormal)
+		canvas.DrawCenteredText(loc.guaSubtitle(本卦名), layout.左卦中心X, layout.卦宫Y, subtitleStyle)
+		if 有动爻 {
+			canvas.DrawCenteredText(loc.guaTitle(变卦名), layout.右卦中心X, layout.卦名Y, textNormal)
+			canvas.DrawCenteredText(loc.guaSubtitle(变卦名), layout.右卦中心X, layout.卦宫Y, subtitleStyle)
+		}
+	}
+}
+
+// formatDivineTime 格式化起卦的公历时间，如"公历 2025-01-01 14:30 Asia/Shanghai"
+// 换算了真太阳时的盘面在其后附上真太阳时，如"真太阳时 14:05"
+func formatDivineTime(chart *GuaChart) string {
+	text := fmt.Sprintf("公历 %s %s", chart.DivineTime.Format("2006-01-02 15:04"), chart.Timezone)
+	if chart.SolarTime != nil {
+		text += "  真太阳时 " + chart.SolarTime.Format("15:04")
+	}
+	return text
+}
+
+// drawRowLabel 绘制与爻并排的标签（六神、爻位）
+// 中文从labelX起排；英文文字宽度不定，右对齐到爻的左侧
+func drawRowLabel(canvas chartCanvas, layout *Layout, loc *localizer, text string, labelX, y int) {
+	if loc.latin() {
+		labelX = layout.左卦中心X - layout.爻宽度/2 - 16 - canvas.MeasureText(text, textNormal)
+	}
+	canvas.DrawText(text, labelX, y, textNormal)
+}
+
 // 绘制卦象主体
-func drawGuaBody(img *image.NRGBA, layout *Layout, 日干, 本卦名, 变卦名 string, 本卦, 变卦 []int, 变爻标记 []bool, 有动爻 bool, normalFace, smallFace font.Face) error {
+func drawGuaBody(canvas chartCanvas, layout *Layout, loc *localizer, 日干, 本卦名, 变卦名 string, 本卦, 变卦 []int, 变爻标记 []bool, 有动爻 bool) error {
 	// 预先计算六神排序
 	起始位置 := 日干六神[日干]
 	六神排序 := make([]string, 6)
@@ -142,8 +420,13 @@ func drawGuaBody(img *image.NRGBA, layout *Layout, 日干, 本卦名, 变卦名
 		六神排序[i] = 六神[索引]
 	}
 
-	// 绘制基本布局
-	yaoColor := color.RGBA{139, 69, 19, 255} // 棕色
+	// 伏神按爻位索引
+	伏神 := make(map[int]FuShen)
+	if layout.栏目.伏神 {
+		for _, fu := range fuShenLines(本卦名) {
+			伏神[fu.Position] = fu
+		}
+	}
 
 	// 绘制六神和爻循环
 	for i := 0; i < 6; i++ {
@@ -151,142 +434,205 @@ func drawGuaBody(img *image.NRGBA, layout *Layout, 日干, 本卦名, 变卦名
 		文字Y := rowY + layout.文字基线偏移 // 文字Y位置，基线对齐
 
 		// 六神
-		drawCachedText(img, 六神排序[i], layout.六神X, 文字Y, normalFace)
+		if layout.栏目.六神 {
+			drawRowLabel(canvas, layout, loc, loc.liuShen(六神排序[i]), layout.六神X, 文字Y)
+		}
 
-		// 本卦爻
+		// 本卦爻（1为阳爻，0为阴爻）
 		爻Y := rowY - layout.爻高度/2
-		if 本卦[5-i] == 1 { // 阳爻
-			drawYangYao(img, layout.左卦中心X-layout.爻宽度/2, 爻Y, layout.爻宽度, layout.爻高度, yaoColor)
-		} else { // 阴爻
-			drawYinYao(img, layout.左卦中心X-layout.爻宽度/2, 爻Y, layout.爻宽度, layout.爻高度, yaoColor)
+		canvas.DrawYao(layout.左卦中心X-layout.爻宽度/2, 爻Y, layout.爻宽度, layout.爻高度, 本卦[5-i] == 1)
+
+		// 本卦六亲信息，行尾X记录本行文字已排到的位置，动爻标记和伏神顺延其后
+		爻右端X := layout.左卦中心X + layout.爻宽度/2
+		行尾X := 爻右端X
+		if layout.栏目.六亲 {
+			_, 干支五行 := naJia(guaXiang[本卦名].GuaGong, 6-i, 本卦)
+			五行部分 := strings.Split(干支五行, "(")[1]
+			五行部分 = strings.TrimSuffix(五行部分, ")")
+			六亲 := getLiuQin(guaXiang[本卦名].GuaGong, 五行部分)
+			干支部分 := strings.Split(干支五行, " ")[0]
+			六亲X := 爻右端X + layout.六亲偏移
+			本卦六亲 := loc.naJiaText(六亲, 干支部分, 五行部分)
+			canvas.DrawText(本卦六亲, 六亲X, 文字Y, textSmall)
+			行尾X = 六亲X + canvas.MeasureText(本卦六亲, textSmall)
 		}
 
-		// 本卦六亲信息
-		_, 干支五行 := naJia(guaXiang[本卦名].GuaGong, 6-i, 本卦)
-		五行部分 := strings.Split(干支五行, "(")[1]
-		五行部分 = strings.TrimSuffix(五行部分, ")")
-		六亲 := getLiuQin(guaXiang[本卦名].GuaGong, 五行部分)
-		干支部分 := strings.Split(干支五行, " ")[0]
-		drawCachedText(img, 六亲+干支部分+五行部分, layout.左卦中心X+layout.爻宽度/2+10, 文字Y, smallFace)
-
 		// 只在有动爻情况下绘制变卦
 		if 有动爻 {
 			// 变卦爻
-			if 变卦[5-i] == 1 { // 阳爻
-				drawYangYao(img, layout.右卦中心X-layout.爻宽度/2, 爻Y, layout.爻宽度, layout.爻高度, yaoColor)
-			} else { // 阴爻
-				drawYinYao(img, layout.右卦中心X-layout.爻宽度/2, 爻Y, layout.爻宽度, layout.爻高度, yaoColor)
-			}
+			canvas.DrawYao(layout.右卦中心X-layout.爻宽度/2, 爻Y, layout.爻宽度, layout.爻高度, 变卦[5-i] == 1)
 
 			// 变卦六亲信息
-			_, 干支五行 = naJia(guaXiang[变卦名].GuaGong, 6-i, 变卦)
-			五行部分 = strings.Split(干支五行, "(")[1]
-			五行部分 = strings.TrimSuffix(五行部分, ")")
-			六亲 = getLiuQin(guaXiang[变卦名].GuaGong, 五行部分)
-			干支部分 = strings.Split(干支五行, " ")[0]
-			drawCachedText(img, 六亲+干支部分+五行部分, layout.右卦中心X+layout.爻宽度/2+10, 文字Y, smallFace)
+			if layout.栏目.六亲 {
+				_, 干支五行 := naJia(guaXiang[变卦名].GuaGong, 6-i, 变卦)
+				五行部分 := strings.Split(干支五行, "(")[1]
+				五行部分 = strings.TrimSuffix(五行部分, ")")
+				六亲 := getLiuQin(guaXiang[变卦名].GuaGong, 五行部分)
+				干支部分 := strings.Split(干支五行, " ")[0]
+				canvas.DrawText(loc.naJiaText(六亲, 干支部分, 五行部分), layout.右卦中心X+layout.爻宽度/2+layout.六亲偏移, 文字Y, textSmall)
+			}
 		}
 
-		// 动爻判定
-		if 变爻标记[5-i] {
-			动爻X := layout.左卦中心X + layout.爻宽度/2 + 150
-			drawCachedText(img, "● 动爻", 动爻X, 文字Y, normalFace)
+		// 动爻判定，六亲纳甲的文字较长（英文）时标记顺延到其后
+		if 变爻标记[5-i] && layout.栏目.动爻 {
+			动爻X := 爻右端X + layout.动爻偏移
+			if 行尾X > 动爻X {
+				动爻X = 行尾X + 12
+			}
+			标记 := loc.text("● 动爻")
+			canvas.DrawText(标记, 动爻X, 文字Y, textAccent)
+			行尾X = 动爻X + canvas.MeasureText(标记, textAccent)
+		}
+
+		// 伏神，与动爻标记同行时排在标记之后
+		if fu, ok := 伏神[6-i]; ok {
+			伏神X := 爻右端X + layout.伏神偏移
+			if 行尾X > 伏神X {
+				伏神X = 行尾X + 12
+			}
+			canvas.DrawText(loc.fuShenText(fu), 伏神X, 文字Y, textSmall)
 		}
 	}
 
 	// 绘制底部标签
-	if 有动爻 {
-		drawCachedText(img, "主卦", layout.左卦中心X-20, layout.基础Y+6*layout.爻间距+10, normalFace)
-		drawCachedText(img, "变卦", layout.右卦中心X-20, layout.基础Y+6*layout.爻间距+10, normalFace)
-	} else {
-		drawCachedText(img, "主卦", layout.左卦中心X-20, layout.基础Y+6*layout.爻间距+10, normalFace)
+	if layout.栏目.标签 {
+		labelY := guaLabelY(layout)
+		drawGuaLabel(canvas, loc, loc.text("主卦"), layout.左卦中心X, labelY)
+		if 有动爻 {
+			drawGuaLabel(canvas, loc, loc.text("变卦"), layout.右卦中心X, labelY)
+		}
 	}
 
 	return nil
 }
 
-// 绘制爻辞
-func drawYaoCi(img *image.NRGBA, layout *Layout, 本卦名, 变卦名 string, 本卦, 变卦 []int, 有动爻 bool, smallFace font.Face) {
-	if 有动爻 {
-		// 双卦爻辞显示
-		const (
-			本卦爻辞X = 80
-			变卦爻辞X = 620
-			爻辞宽度  = 480
-			行间距   = 25
-		)
+// guaLabelY 卦象下方"主卦""变卦"标签的基线Y
+func guaLabelY(layout *Layout) int {
+	return layout.基础Y + 6*layout.爻间距 + 10
+}
 
-		nextY := layout.爻辞Y
+// drawHuGua 绘制互卦名称
+// 模板未指定位置时与卦象下方的标签同行：双卦时居中于两卦之间，单卦时排在主卦标签右侧
+func drawHuGua(canvas chartCanvas, layout *Layout, loc *localizer, 本卦 []int) {
+	text := loc.huGuaText(guaToName(huGua(本卦)))
+	y := layout.互卦Y
+	if y == 0 {
+		y = guaLabelY(layout)
+	}
+	switch {
+	case layout.互卦X != 0:
+		canvas.DrawCenteredText(text, layout.互卦X, y, textSmall)
+	case layout.右卦中心X >= 0:
+		canvas.DrawCenteredText(text, (layout.左卦中心X+layout.右卦中心X)/2, y, textSmall)
+	default:
+		canvas.DrawText(text, layout.左卦中心X+layout.爻宽度/2+layout.六亲偏移, y, textSmall)
+	}
+}
 
-		// 绘制爻辞标题
-		drawCachedText(img, "爻辞：", 本卦爻辞X, nextY, smallFace)
-		drawCachedText(img, "爻辞：", 变卦爻辞X, nextY, smallFace)
-		nextY += 行间距
+// drawGuaLabel 在卦象下方绘制"主卦"、"变卦"标签，中文两字从中心左移20像素起排，英文居中
+func drawGuaLabel(canvas chartCanvas, loc *localizer, text string, centerX, y int) {
+	if loc.latin() {
+		canvas.DrawCenteredText(text, centerX, y, textNormal)
+		return
+	}
+	canvas.DrawText(text, centerX-20, y, textNormal)
+}
 
-		// 双卦爻辞绘制
-		for i := 0; i < 6; i++ {
-			爻位 := 6 - i    // 从上爻到初爻
-			爻辞索引 := 爻位 - 1 // 数组索引从0开始
+// 绘制爻辞，返回爻辞区域下方的Y坐标
+// 有动爻时本卦和变卦爻辞按版式左右并排或上下堆叠，无动爻时居中显示本卦爻辞
+// 有卦辞译文的语言（英文）在每卦的爻辞之前先排卦辞
+func drawYaoCi(canvas chartCanvas, layout *Layout, loc *localizer, 本卦名, 变卦名 string, 本卦, 变卦 []int, 有动爻 bool) int {
+	行间距 := layout.爻辞行间距
+	nextY := layout.爻辞Y
+
+	if 有动爻 && layout.爻辞堆叠 {
+		// 本卦爻辞在上，变卦爻辞在下
+		nextY = drawYaoCiBlock(canvas, loc, loc.text("本卦爻辞："), 本卦名, 本卦, layout.本卦爻辞X, nextY, layout.爻辞宽度, 行间距)
+		nextY += 行间距 / 2
+		return drawYaoCiBlock(canvas, loc, loc.text("变卦爻辞："), 变卦名, 变卦, layout.变卦爻辞X, nextY, layout.爻辞宽度, 行间距)
+	}
 
-			// 获取爻位名称
-			爻位名称 := getYaoWeiName(爻位, 本卦[爻辞索引])
+	if 有动爻 {
+		// 双卦爻辞左右并排
+		本卦爻辞X, 变卦爻辞X, 爻辞宽度 := layout.本卦爻辞X, layout.变卦爻辞X, layout.爻辞宽度
 
-			// 获取对应的爻辞
-			爻辞 := "无爻辞" // 默认值
-			if gua, exists := guaXiang[本卦名]; exists && 爻辞索引 < len(gua.YaoCi) {
-				爻辞 = gua.YaoCi[爻辞索引]
-			}
+		// 绘制爻辞标题
+		canvas.DrawText(loc.text("爻辞："), 本卦爻辞X, nextY, textSmall)
+		canvas.DrawText(loc.text("爻辞："), 变卦爻辞X, nextY, textSmall)
+		nextY += 行间距
 
-			// 绘制本卦爻辞（带换行）
-			完整爻辞 := fmt.Sprintf("%s: %s", 爻位名称, 爻辞)
-			本爻辞Y := drawWrappedText(img, 完整爻辞, 本卦爻辞X, nextY, 爻辞宽度, 行间距, smallFace)
+		// 卦辞
+		if judgment := loc.judgment(本卦名); judgment != "" {
+			本卦辞Y := drawWrappedText(canvas, judgment, 本卦爻辞X, nextY, 爻辞宽度, 行间距, textSmall)
+			变卦辞Y := drawWrappedText(canvas, loc.judgment(变卦名), 变卦爻辞X, nextY, 爻辞宽度, 行间距, textSmall)
+			nextY = max(本卦辞Y, 变卦辞Y) + 10
+		}
 
-			// 获取变卦爻辞
-			变爻位名称 := getYaoWeiName(爻位, 变卦[爻辞索引])
-			变爻辞 := "无爻辞" // 默认值
-			if gua, exists := guaXiang[变卦名]; exists && 爻辞索引 < len(gua.YaoCi) {
-				变爻辞 = gua.YaoCi[爻辞索引]
-			}
+		// 双卦爻辞绘制
+		for i := 0; i < 6; i++ {
+			爻位 := 6 - i // 从上爻到初爻
 
-			// 绘制变卦爻辞（带换行）
-			完整变爻辞 := fmt.Sprintf("%s: %s", 变爻位名称, 变爻辞)
-			变爻辞Y := drawWrappedText(img, 完整变爻辞, 变卦爻辞X, nextY, 爻辞宽度, 行间距, smallFace)
+			// 绘制本卦和变卦爻辞（带换行）
+			本爻辞Y := drawWrappedText(canvas, loc.yaoCiLine(本卦名, 本卦, 爻位), 本卦爻辞X, nextY, 爻辞宽度, 行间距, textSmall)
+			变爻辞Y := drawWrappedText(canvas, loc.yaoCiLine(变卦名, 变卦, 爻位), 变卦爻辞X, nextY, 爻辞宽度, 行间距, textSmall)
 
 			// 取两侧爻辞高度的较大值作为下一个爻辞的起始 Y 坐标
 			nextY = max(本爻辞Y, 变爻辞Y) + 10 // 添加额外的10像素间距
 		}
-	} else {
-		// 单卦爻辞显示（居中）
-		const (
-			爻辞宽度 = 600 // 单卦爻辞更宽一些
-			行间距  = 25
-		)
+		return nextY
+	}
 
-		本卦爻辞X := (ImageWidth - 爻辞宽度) / 2 // 居中显示爻辞
-		nextY := layout.爻辞Y
+	// 单卦爻辞显示（居中）
+	爻辞宽度 := layout.单卦爻辞宽度
+	本卦爻辞X := layout.单卦爻辞中心X - 爻辞宽度/2 // 居中显示爻辞
 
-		// 绘制爻辞标题
-		drawCenteredText(img, "爻辞", ImageWidth/2, nextY, smallFace)
-		nextY += 行间距
+	// 绘制爻辞标题
+	canvas.DrawCenteredText(loc.text("爻辞"), layout.单卦爻辞中心X, nextY, textSmall)
+	nextY += 行间距
 
-		// 单卦爻辞绘制
-		for i := 0; i < 6; i++ {
-			爻位 := 6 - i    // 从上爻到初爻
-			爻辞索引 := 爻位 - 1 // 数组索引从0开始
+	// 卦辞
+	if judgment := loc.judgment(本卦名); judgment != "" {
+		nextY = drawWrappedText(canvas, judgment, 本卦爻辞X, nextY, 爻辞宽度, 行间距, textSmall) + 10
+	}
 
-			// 获取爻位名称
-			爻位名称 := getYaoWeiName(爻位, 本卦[爻辞索引])
+	// 单卦爻辞绘制
+	for i := 0; i < 6; i++ {
+		nextY = drawWrappedText(canvas, loc.yaoCiLine(本卦名, 本卦, 6-i), 本卦爻辞X, nextY, 爻辞宽度, 行间距, textSmall)
+		nextY += 10 // 添加额外的间距
+	}
+	return nextY
+}
 
-			// 获取对应的爻辞
-			爻辞 := "无爻辞" // 默认值
-			if gua, exists := guaXiang[本卦名]; exists && 爻辞索引 < len(gua.YaoCi) {
-				爻辞 = gua.YaoCi[爻辞索引]
-			}
+// drawYaoCiBlock 绘制一卦带标题的卦辞和六条爻辞，从上爻到初爻，返回下方的Y坐标
+func drawYaoCiBlock(canvas chartCanvas, loc *localizer, title, 卦名 string, 卦 []int, x, y, width, lineHeight int) int {
+	canvas.DrawText(title, x, y, textSmall)
+	nextY := y + lineHeight
+	if judgment := loc.judgment(卦名); judgment != "" {
+		nextY = drawWrappedText(canvas, judgment, x, nextY, width, lineHeight, textSmall) + 10
+	}
+	for 爻位 := 6; 爻位 >= 1; 爻位-- {
+		nextY = drawWrappedText(canvas, loc.yaoCiLine(卦名, 卦, 爻位), x, nextY, width, lineHeight, textSmall)
+		nextY += 10
+	}
+	return nextY
+}
 
-			// 绘制本卦爻辞（带换行）
-			完整爻辞 := fmt.Sprintf("%s: %s", 爻位名称, 爻辞)
-			nextY = drawWrappedText(img, 完整爻辞, 本卦爻辞X, nextY, 爻辞宽度, 行间距, smallFace)
-			nextY += 10 // 添加额外的间距
-		}
+// yaoCiLine 返回一爻的爻位名称和爻辞，如"初九: 潜龙勿用"
+//
+// 参数：
+//   - 卦名: 卦名
+//   - 卦: 六爻数组，从初爻到上爻
+//   - 爻位: 1-6，1为初爻
+func yaoCiLine(卦名 string, 卦 []int, 爻位 int) string {
+	爻辞索引 := 爻位 - 1 // 数组索引从0开始
+
+	// 获取爻位名称
+	爻位名称 := getYaoWeiName(爻位, 卦[爻辞索引])
+
+	// 获取对应的爻辞
+	爻辞 := "无爻辞" // 默认值
+	if gua, exists := guaXiang[卦名]; exists && 爻辞索引 < len(gua.YaoCi) {
+		爻辞 = gua.YaoCi[爻辞索引]
 	}
+	return fmt.Sprintf("%s: %s", 爻位名称, 爻辞)
 }
@@ -0,0 +1,386 @@
+// divine_report.go 实现占卜结果的PDF报告
+// 报告为A4多页文档，依次包含起卦信息和四柱、卦象图、本卦和变卦的卦辞、彖传、象传和全部爻辞、动爻爻辞、
+// 排盘分析和供解卦者填写的备注页，由 GET /api/v1/divine/{id}/report.pdf 输出。
+// 报告文字的语言与盘面的语言一致，经localizer转换
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"log"
+	"sort"
+	"strings"
+)
+
+// 报告版面，单位为点
+const (
+	reportMargin       = 56.0
+	reportContentWidth = pdfPageWidth - 2*reportMargin
+	reportBottom       = pdfPageHeight - 64 // 正文下边界，其下为页脚
+	reportNoteSpacing  = 28.0               // 备注横线的间距
+	reportMinNoteLines = 6                  // 当前页剩余空间不足此行数时备注另起一页
+)
+
+// 报告配色
+var (
+	reportInk    = pdfColor{0.12, 0.12, 0.12}
+	reportMuted  = pdfColor{0.45, 0.45, 0.45}
+	reportAccent = pdfColor{0.70, 0.12, 0.10}
+	reportRule   = pdfColor{0.78, 0.78, 0.78}
+)
+
+// 行首不宜出现的标点，换行时随前一行
+const reportNoBreakBefore = "，。、；：！？）」』》"
+
+// reportWriter 按从上到下的顺序排版报告，空间不足时自动换页
+type reportWriter struct {
+	doc  *pdfDocument
+	page *pdfPage
+	y    float64 // 当前位置，距页面上边缘
+}
+
+// generateDivineReport 生成占卜结果的PDF报告
+//
+// 参数：
+//   - meta: 盘面元数据，来自图片存储或图片中内嵌的元数据
+//   - theme: 卦象图的主题，报告文字使用该主题的字体回退链
+//   - layoutName: 卦象图的版式
+//
+// 返回值：PDF文件数据
+func generateDivineReport(meta *ChartMetadata, theme *Theme, layoutName string) ([]byte, error) {
+	doc, err := buildDivineReport(meta, theme, layoutName)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.missing) > 0 {
+		items := make([]string, 0, len(doc.missing))
+		for r := range doc.missing {
+			items = append(items, fmt.Sprintf("%c(U+%04X)", r, r))
+		}
+		sort.Strings(items)
+		log.Printf("警告: 报告 %s 中有 %d 个字符缺少字形，将显示为方框: %s", meta.ID, len(items), strings.Join(items, "、"))
+	}
+	return doc.bytes()
+}
+
+// buildDivineReport 排版报告的全部页面，报告文字按盘面的语言输出
+func buildDivineReport(meta *ChartMetadata, theme *Theme, layoutName string) (*pdfDocument, error) {
+	chart := meta.Chart
+	loc := newLocalizer(chart.Locale)
+
+	// 卦象图按PNG渲染后嵌入，与接口输出的图片一致
+	rendered, err := renderChartWithLayout(ImageFormatPNG, 0, layoutName, chart, theme, nil)
+	if err != nil {
+		return nil, err
+	}
+	chartImage, _, err := image.Decode(bytes.NewReader(rendered.Data))
+	if err != nil {
+		return nil, fmt.Errorf("解码卦象图失败: %v", err)
+	}
+
+	chain, err := loadFontChain(theme)
+	if err != nil {
+		return nil, err
+	}
+	title := loc.text("周易占卜报告")
+	doc, err := newPDFDocument(title+" "+meta.ID, chain)
+	if err != nil {
+		return nil, err
+	}
+	w := &reportWriter{doc: doc}
+	w.newPage()
+
+	// 标题
+	w.centered(title, 24, reportInk)
+	w.y += 8
+	w.centered(loc.text("占卜编号 ")+meta.ID, 10, reportMuted)
+	w.y += 12
+	if meta.Question != "" {
+		w.paragraph(loc.text("所问之事：")+meta.Question, 12, reportInk, 0)
+		w.y += 6
+	}
+
+	// 起卦信息和四柱
+	w.heading(loc.text("起卦信息"))
+	w.paragraph(loc.text("起卦时间：")+loc.divineTime(chart), 11, reportInk, 0)
+	w.paragraph(loc.text("起卦方法：")+describeCastMethod(chart, loc), 11, reportInk, 0)
+	w.y += 6
+	w.pillars(chart, loc)
+
+	// 卦象图按内容宽度缩放，放不下时另起一页
+	w.heading(loc.text("卦象"))
+	bounds := chartImage.Bounds()
+	width := reportContentWidth
+	height := width * float64(bounds.Dy()) / float64(bounds.Dx())
+	if maxHeight := reportBottom - reportMargin - 40; height > maxHeight {
+		width, height = width*maxHeight/height, maxHeight
+	}
+	w.ensure(height)
+	doc.drawImage(w.page, chartImage, reportMargin+(reportContentWidth-width)/2, w.y, width, height)
+	w.y += height + 12
+
+	// 本卦和变卦的卦辞、彖传、象传和爻辞，爻辞从初爻到上爻，动爻以强调色标出
+	w.guaSection(loc, "本卦", chart.BenGuaName, chart.BenGua, chart.DongYao)
+	if chart.HasDongYao {
+		w.guaSection(loc, "变卦", chart.BianGuaName, chart.BianGua, chart.DongYao)
+	}
+
+	// 动爻爻辞
+	w.heading(loc.text("动爻"))
+	if !chart.HasDongYao {
+		w.paragraph(loc.text("六爻安静，无动爻。"), 11, reportInk, 0)
+	}
+	for 爻位 := 1; 爻位 <= 6; 爻位++ {
+		if !chart.DongYao[爻位-1] {
+			continue
+		}
+		w.paragraph(loc.yaoCiLine(chart.BenGuaName, chart.BenGua, 爻位), 11, reportAccent, 0)
+		w.paragraph(loc.text("变为 ")+loc.yaoCiLine(chart.BianGuaName, chart.BianGua, 爻位), 11, reportInk, 2)
+		w.y += 4
+	}
+
+	// 排盘分析
+	analysis := analyzeChart(chart)
+	w.heading(loc.text("排盘分析"))
+	w.analysisTable(chart, analysis, loc)
+	w.y += 8
+	for _, finding := range localizedFindings(chart, analysis, loc) {
+		w.paragraph("· "+finding, 11, reportInk, 1)
+	}
+
+	// 备注页，留出书写的横线
+	w.y += 12
+	if w.y+40+reportMinNoteLines*reportNoteSpacing > reportBottom {
+		w.newPage()
+	}
+	w.heading(loc.text("备注"))
+	for w.y+reportNoteSpacing <= reportBottom {
+		w.y += reportNoteSpacing
+		doc.drawLine(w.page, reportMargin, w.y, reportMargin+reportContentWidth, w.y, 0.5, reportRule)
+	}
+
+	// 页脚
+	for i, page := range doc.pages {
+		footerY := pdfPageHeight - 36
+		doc.drawText(page, title+" · "+meta.ID, reportMargin, footerY, 9, reportMuted)
+		pageText := fmt.Sprintf(loc.text("第 %d / %d 页"), i+1, len(doc.pages))
+		doc.drawText(page, pageText, reportMargin+reportContentWidth-doc.measureText(pageText, 9), footerY, 9, reportMuted)
+	}
+	return doc, nil
+}
+
+// describeCastMethod 描述起卦方法
+func describeCastMethod(chart *GuaChart, loc *localizer) string {
+	switch chart.Method {
+	case CastMethodCoins:
+		return fmt.Sprintf(loc.text("三枚铜钱摇六次（随机种子 %d）"), chart.Seed)
+	case "":
+		return loc.text("未记录")
+	default:
+		return chart.Method
+	}
+}
+
+// localizedFindings 按语言输出分析要点，英文按排盘结果另行生成
+func localizedFindings(chart *GuaChart, analysis *ChartAnalysis, loc *localizer) []string {
+	if loc.latin() {
+		return englishFindings(chart, analysis, loc)
+	}
+	findings := make([]string, len(analysis.Findings))
+	for i, finding := range analysis.Findings {
+		findings[i] = loc.text(finding)
+	}
+	return findings
+}
+
+// newPage 另起一页
+func (w *reportWriter) newPage() {
+	w.page = w.doc.addPage()
+	w.y = reportMargin
+}
+
+// ensure 当前页剩余空间不足height时另起一页
+func (w *reportWriter) ensure(height float64) {
+	if w.y+height > reportBottom {
+		w.newPage()
+	}
+}
+
+// centered 居中绘制一行文字
+func (w *reportWriter) centered(text string, size float64, color pdfColor) {
+	w.ensure(size * 1.4)
+	w.y += size
+	x := reportMargin + (reportContentWidth-w.doc.measureText(text, size))/2
+	w.doc.drawText(w.page, text, x, w.y, size, color)
+	w.y += size * 0.4
+}
+
+// heading 绘制节标题及其下方的细线，标题不单独留在页尾
+func (w *reportWriter) heading(text string) {
+	w.ensure(60)
+	w.y += 18
+	w.doc.drawText(w.page, text, reportMargin, w.y, 14, reportInk)
+	w.y += 6
+	w.doc.drawLine(w.page, reportMargin, w.y, reportMargin+reportContentWidth, w.y, 0.8, reportRule)
+	w.y += 8
+}
+
+// paragraph 绘制自动换行的段落，indent为续行缩进的字数
+func (w *reportWriter) paragraph(text string, size float64, color pdfColor, indent int) {
+	leading := size * 1.6
+	indentWidth := float64(indent) * size
+	for i, line := range w.wrap(text, size, reportContentWidth, indentWidth) {
+		w.ensure(leading)
+		x := reportMargin
+		if i > 0 {
+			x += indentWidth
+		}
+		w.y += leading
+		w.doc.drawText(w.page, line, x, w.y-size*0.4, size, color)
+	}
+}
+
+// wrap 按宽度断行，续行宽度扣除缩进；中文逐字断行，行首标点随前一行，西文单词退到行内最后一个空格处断开
+func (w *reportWriter) wrap(text string, size, width, indentWidth float64) []string {
+	var lines []string
+	var line []rune
+	lineWidth, limit := 0.0, width
+	for _, r := range text {
+		advance := w.doc.measureText(string(r), size)
+		if len(line) > 0 && lineWidth+advance > limit && !strings.ContainsRune(reportNoBreakBefore, r) {
+			cut := len(line)
+			if r != ' ' && !isReportCJK(r) {
+				if space := lastIndexRune(line, ' '); space > 0 {
+					cut = space
+				}
+			}
+			lines = append(lines, strings.TrimRight(string(line[:cut]), " "))
+			line = []rune(strings.TrimLeft(string(line[cut:]), " "))
+			lineWidth, limit = w.doc.measureText(string(line), size), width-indentWidth
+		}
+		if len(line) == 0 && r == ' ' {
+			continue // 行首不留空格
+		}
+		line = append(line, r)
+		lineWidth += advance
+	}
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+// isReportCJK 是否为可在任意两字之间断行的中文字符或全角标点
+func isReportCJK(r rune) bool {
+	return r >= 0x2E80
+}
+
+// lastIndexRune 返回r在runes中最后出现的位置，不存在时返回-1
+func lastIndexRune(runes []rune, r rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// pillars 绘制年月日时四柱，英文的干支为拼音
+func (w *reportWriter) pillars(chart *GuaChart, loc *localizer) {
+	w.ensure(56)
+	labels := []string{"年柱", "月柱", "日柱", "时柱"}
+	values := []string{chart.Ganzhinian, chart.Ganzhiyue, chart.Ganzhiri, chart.Ganzhishi}
+	columnWidth := reportContentWidth / 4
+	top := w.y
+	w.doc.fillRect(w.page, reportMargin, top, reportContentWidth, 50, pdfColor{0.96, 0.95, 0.92})
+	for i := range labels {
+		label, value := loc.text(labels[i]), loc.text(values[i])
+		if loc.latin() {
+			value = pinyinGanZhi(values[i])
+		}
+		center := reportMargin + columnWidth*(float64(i)+0.5)
+		w.doc.drawText(w.page, label, center-w.doc.measureText(label, 10)/2, top+16, 10, reportMuted)
+		w.doc.drawText(w.page, value, center-w.doc.measureText(value, 16)/2, top+40, 16, reportInk)
+	}
+	w.y = top + 56
+}
+
+// guaSection 绘制一卦的卦辞、彖传、象传和六条爻辞，爻辞从初爻到上爻
+// 英文的卦辞为理雅各译文，彖传、象传和爻辞保留原文
+func (w *reportWriter) guaSection(loc *localizer, title, 卦名 string, 卦 []int, 动爻 []bool) {
+	if loc.latin() {
+		w.heading(fmt.Sprintf("%s: %s (%s)", loc.text(title), loc.guaDesc(卦名), loc.guaGong(guaXiang[卦名].GuaGong)))
+	} else {
+		w.heading(fmt.Sprintf("%s　%s（%s）", loc.text(title), loc.guaDesc(卦名), loc.guaGong(guaXiang[卦名].GuaGong)))
+	}
+
+	text := guaTexts[卦名]
+	guaCi := loc.text(text.GuaCi + "。")
+	if loc.latin() {
+		guaCi = loc.judgment(卦名)
+	}
+	w.paragraph(loc.text("卦辞：")+guaCi, 11, reportInk, 2)
+	w.paragraph(loc.text("彖曰：")+loc.text(text.Tuan+"。"), 10, reportMuted, 2)
+	w.paragraph(loc.text("象曰：")+loc.text(text.Xiang+"。"), 10, reportMuted, 2)
+	w.y += 4
+
+	for 爻位 := 1; 爻位 <= 6; 爻位++ {
+		color := reportInk
+		line := loc.yaoCiLine(卦名, 卦, 爻位)
+		if 动爻[爻位-1] {
+			color = reportAccent
+			line += loc.text("　（动）")
+		}
+		w.paragraph(line, 11, color, 2)
+	}
+}
+
+// analysisTable 绘制六爻排盘表，从上爻到初爻，与卦象图的排列一致
+func (w *reportWriter) analysisTable(chart *GuaChart, analysis *ChartAnalysis, loc *localizer) {
+	headers := []string{"爻位", "六神", "六亲纳甲", "世应", "动变"}
+	columns := []float64{0, 56, 112, 230, 280} // 各列相对左边距的位置
+	if loc.latin() {
+		columns = []float64{0, 48, 100, 222, 266} // 英文的六亲纳甲和动变较长
+	}
+	rowHeight := 22.0
+
+	w.ensure(rowHeight * 7)
+	w.y += rowHeight
+	for i, header := range headers {
+		w.doc.drawText(w.page, loc.text(header), reportMargin+columns[i], w.y-7, 10, reportMuted)
+	}
+	w.doc.drawLine(w.page, reportMargin, w.y, reportMargin+reportContentWidth, w.y, 0.8, reportRule)
+
+	for i := 5; i >= 0; i-- {
+		line := analysis.Lines[i]
+		shiYing := ""
+		if line.Shi {
+			shiYing = loc.text("世")
+		} else if line.Ying {
+			shiYing = loc.text("应")
+		}
+		change, color := "", reportInk
+		if line.Moving {
+			if loc.latin() {
+				change = fmt.Sprintf("○ %s (%s)", loc.naJiaText(line.BianLiuQin, line.BianGanZhi, line.BianWuXing), transformEnglish[line.Transformed])
+			} else {
+				change = loc.text(fmt.Sprintf("○ 化%s%s%s（%s）", line.BianLiuQin, line.BianGanZhi, line.BianWuXing, line.Transformed))
+			}
+			color = reportAccent
+		}
+		cells := []string{
+			loc.yaoShortName(line.Position, chart.BenGua[i]),
+			loc.liuShen(line.LiuShen),
+			loc.naJiaText(line.LiuQin, line.GanZhi, line.WuXing),
+			shiYing,
+			change,
+		}
+
+		w.y += rowHeight
+		for j, cell := range cells {
+			w.doc.drawText(w.page, cell, reportMargin+columns[j], w.y-7, 11, color)
+		}
+		w.doc.drawLine(w.page, reportMargin, w.y, reportMargin+reportContentWidth, w.y, 0.4, reportRule)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// TestGuaTexts 64卦都有卦辞、彖传和象传，繁体输出中除词组表特意保留的字外不再留有简体字
+func TestGuaTexts(t *testing.T) {
+	kept := make(map[rune]bool) // 词组表中按原字保留的字，如"后以"的后
+	for i := 0; i+1 < len(hantPhrases); i += 2 {
+		from, to := []rune(hantPhrases[i]), []rune(hantPhrases[i+1])
+		for j := range from {
+			if j < len(to) && from[j] == to[j] {
+				kept[from[j]] = true
+			}
+		}
+	}
+
+	hant := newLocalizer(LocaleZhHant)
+	for 卦名 := range guaXiang {
+		text, ok := guaTexts[卦名]
+		if !ok || text.GuaCi == "" || text.Tuan == "" || text.Xiang == "" {
+			t.Errorf("%s 缺少卦辞或传文", 卦名)
+			continue
+		}
+		converted := hant.text(text.GuaCi + text.Tuan + text.Xiang)
+		for _, r := range converted {
+			if _, simplified := hantChars[r]; simplified && !kept[r] {
+				t.Errorf("%s 的繁体传文中仍有简体字 %c", 卦名, r)
+			}
+		}
+	}
+	if len(guaTexts) != len(guaXiang) {
+		t.Errorf("卦辞数据有 %d 卦，期望 %d 卦", len(guaTexts), len(guaXiang))
+	}
+}
+
+// TestDivineReportFontSubset 各语言的报告中每个字都有字形，子集字体中的字形轮廓与原字体一致
+func TestDivineReportFontSubset(t *testing.T) {
+	theme, err := goldenTheme(ThemeClassic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layoutName, err := normalizeLayoutName("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, locale := range localeNames() {
+		t.Run(locale, func(t *testing.T) {
+			chart := benchmarkChart()
+			chart.Locale = locale
+			meta := &ChartMetadata{Version: chartMetadataVersion, ID: "divine_1735704000000000000_3f9a1c2e", Question: "今年适合换工作吗？", Chart: chart}
+			doc, err := buildDivineReport(meta, theme, layoutName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for r := range doc.missing {
+				t.Errorf("报告中的 %c(U+%04X) 缺少字形", r, r)
+			}
+
+			var buf sfnt.Buffer
+			for _, f := range doc.fonts {
+				if len(f.glyphs) == 0 {
+					continue
+				}
+				glyphs := make(map[uint16]bool, len(f.glyphs))
+				for gid := range f.glyphs {
+					glyphs[gid] = true
+				}
+				data, err := subsetTrueType(f.source.Data, glyphs)
+				if err != nil {
+					t.Fatalf("子集化 %s 失败: %v", f.source.Name, err)
+				}
+				subset, err := sfnt.Parse(data)
+				if err != nil {
+					t.Fatalf("解析 %s 的子集失败: %v", f.source.Name, err)
+				}
+				ppem := fixed.I(f.unitsEm)
+				for gid, r := range f.glyphs {
+					want, err := f.source.Font.LoadGlyph(&buf, sfnt.GlyphIndex(gid), ppem, nil)
+					if err != nil {
+						t.Fatalf("%c: %v", r, err)
+					}
+					want = append(sfnt.Segments(nil), want...)
+					got, err := subset.LoadGlyph(&buf, sfnt.GlyphIndex(gid), ppem, nil)
+					if err != nil {
+						t.Errorf("子集中的 %c(U+%04X) 无法读取: %v", r, r, err)
+						continue
+					}
+					if !sameSegments(got, want) {
+						t.Errorf("子集中的 %c(U+%04X) 轮廓与 %s 不一致", r, r, f.source.Name)
+					}
+					if len(got) == 0 && !strings.ContainsRune(" 　", r) && r != 0 {
+						t.Errorf("子集中的 %c(U+%04X) 没有轮廓", r, r)
+					}
+					advance, err := subset.GlyphAdvance(&buf, sfnt.GlyphIndex(gid), ppem, font.HintingNone)
+					if err != nil || advance.Round() != f.advances[gid] {
+						t.Errorf("子集中的 %c(U+%04X) 前进宽度为 %v，期望 %d", r, r, advance, f.advances[gid])
+					}
+				}
+			}
+
+			pdf, err := doc.bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasPrefix(pdf, []byte("%PDF-")) || !bytes.Contains(pdf, []byte("/FontFile2")) {
+				t.Error("输出不是嵌入了字体的PDF")
+			}
+		})
+	}
+}
+
+// sameSegments 比较两组轮廓线段是否相同
+func sameSegments(a, b sfnt.Segments) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
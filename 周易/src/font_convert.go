@@ -0,0 +1,584 @@
+// font_convert.go 实现CFF轮廓字体到TrueType轮廓的转换
+// PDF报告只能嵌入TrueType轮廓的字体（见font_subset.go），而Noto Sans CJK等开源中文字体只以CFF轮廓发布。
+// convert-font命令读取这类字体，保留fontConvertRanges中的字符，把三次曲线近似为二次曲线后写成.ttf。
+// 按SIL Open Font License的要求，修改后的字体换用新的字体名，版权和许可证信息原样保留；
+// fonts目录中的内嵌中文字体即由该命令生成
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// fontConvertRanges 转换时保留的Unicode区段，按码位升序排列
+// 覆盖卦象图、爻辞、报告和用户问题中常见的中西文字符，其余区段（假名、谚文等）不保留以控制文件大小
+var fontConvertRanges = []struct{ From, To rune }{
+	{0x0020, 0x007E}, // 基本拉丁字母
+	{0x00A0, 0x00FF}, // 拉丁字母补充
+	{0x02C6, 0x02DD}, // 修饰符号
+	{0x0391, 0x03C9}, // 希腊字母
+	{0x2000, 0x206F}, // 通用标点
+	{0x2100, 0x21FF}, // 字母式符号、数字形式和箭头
+	{0x2460, 0x24FF}, // 带圈字母数字
+	{0x2500, 0x27BF}, // 制表符、几何图形和杂项符号，含☰☱等八卦符号
+	{0x2E80, 0x2FDF}, // 部首补充和康熙部首
+	{0x3000, 0x303F}, // 中日韩符号和标点
+	{0x3200, 0x33FF}, // 带圈中日韩字符和兼容字符
+	{0x3400, 0x4DBF}, // 扩展A区汉字
+	{0x4DC0, 0x4DFF}, // 易经六十四卦符号
+	{0x4E00, 0x9FFF}, // 基本区汉字
+	{0xF900, 0xFAFF}, // 兼容汉字
+	{0xFE10, 0xFE1F}, // 竖排形式
+	{0xFE30, 0xFE4F}, // 兼容形式
+	{0xFF00, 0xFFEF}, // 半角及全角形式
+}
+
+// fontConvertOptions 字体转换参数
+type fontConvertOptions struct {
+	Index     int     // 字体集合中的字体序号，单个字体文件为0
+	Family    string  // 新的字体家族名，不能沿用原字体的保留名称
+	Tolerance float64 // 二次曲线与原三次曲线的最大偏差，单位为字体设计单位
+	Notice    string  // 追加在原版权信息之后的修改说明
+}
+
+// ttPoint TrueType轮廓上的点，坐标为字体设计单位，y轴向上
+type ttPoint struct {
+	X, Y    int16
+	OnCurve bool
+}
+
+// ttGlyph 转换后的TrueType简单字形
+type ttGlyph struct {
+	Advance  uint16      // 前进宽度
+	Contours [][]ttPoint // 轮廓，外轮廓为顺时针方向
+}
+
+// curvePoint 转换过程中的浮点坐标点
+type curvePoint struct {
+	X, Y float64
+}
+
+func (p curvePoint) add(q curvePoint) curvePoint  { return curvePoint{p.X + q.X, p.Y + q.Y} }
+func (p curvePoint) sub(q curvePoint) curvePoint  { return curvePoint{p.X - q.X, p.Y - q.Y} }
+func (p curvePoint) scale(k float64) curvePoint   { return curvePoint{p.X * k, p.Y * k} }
+func (p curvePoint) mid(q curvePoint) curvePoint  { return curvePoint{(p.X + q.X) / 2, (p.Y + q.Y) / 2} }
+func (p curvePoint) length() float64              { return math.Hypot(p.X, p.Y) }
+func (p curvePoint) cross(q curvePoint) float64   { return p.X*q.Y - p.Y*q.X }
+func (p curvePoint) round() (int16, int16)        { return int16(math.Round(p.X)), int16(math.Round(p.Y)) }
+func (p curvePoint) ttPoint(onCurve bool) ttPoint { x, y := p.round(); return ttPoint{x, y, onCurve} }
+func (p curvePoint) distanceToLine(a, b curvePoint) float64 {
+	chord := b.sub(a)
+	if chord.length() == 0 {
+		return p.sub(a).length()
+	}
+	return math.Abs(chord.cross(p.sub(a))) / chord.length()
+}
+
+// convertFontToTrueType 把字体中fontConvertRanges内的字符转换为TrueType轮廓的字体文件
+// 字形按码位顺序重新编号，0号为.notdef；hhea、OS/2、post沿用原字体的度量，name表按新字体名重建
+//
+// 参数：
+//   - data: 原字体文件数据，可以是.otf或.ttc/.otc字体集合
+//   - opts: 转换参数
+//
+// 返回值：TrueType字体文件数据
+func convertFontToTrueType(data []byte, opts fontConvertOptions) ([]byte, error) {
+	if strings.TrimSpace(opts.Family) == "" {
+		return nil, fmt.Errorf("必须指定新的字体家族名")
+	}
+	if opts.Tolerance <= 0 {
+		return nil, fmt.Errorf("曲线误差必须大于0")
+	}
+
+	var f *sfnt.Font
+	var err error
+	if len(data) >= 4 && string(data[:4]) == "ttcf" {
+		var collection *sfnt.Collection
+		if collection, err = sfnt.ParseCollection(data); err == nil {
+			f, err = collection.Font(opts.Index)
+		}
+	} else {
+		f, err = sfnt.Parse(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析字体失败: %v", err)
+	}
+	tables, err := readSFNTTablesAt(data, opts.Index)
+	if err != nil {
+		return nil, err
+	}
+	if len(tables["head"]) < 54 || len(tables["hhea"]) < 36 || len(tables["OS/2"]) < 68 {
+		return nil, fmt.Errorf("字体缺少head、hhea或OS/2表")
+	}
+
+	var buf sfnt.Buffer
+	ppem := fixed.Int26_6(f.UnitsPerEm()) << 6 // 按每em一个设计单位取轮廓，坐标即设计单位
+
+	// 按码位顺序收集字符，字形按首次出现的顺序重新编号
+	newIndex := map[sfnt.GlyphIndex]uint16{0: 0}
+	order := []sfnt.GlyphIndex{0}
+	var runes []rune
+	var gids []uint16
+	for _, r := range fontConvertRanges {
+		for c := r.From; c <= r.To; c++ {
+			gid, err := f.GlyphIndex(&buf, c)
+			if err != nil {
+				return nil, err
+			}
+			if gid == 0 {
+				continue
+			}
+			n, exists := newIndex[gid]
+			if !exists {
+				if len(order) >= math.MaxUint16 {
+					return nil, fmt.Errorf("字形数超过TrueType上限")
+				}
+				n = uint16(len(order))
+				newIndex[gid] = n
+				order = append(order, gid)
+			}
+			runes = append(runes, c)
+			gids = append(gids, n)
+		}
+	}
+	if len(runes) == 0 {
+		return nil, fmt.Errorf("字体中没有需要保留的字符")
+	}
+
+	glyphs := make([]ttGlyph, len(order))
+	for i, gid := range order {
+		if glyphs[i], err = convertGlyph(f, &buf, gid, ppem, opts.Tolerance); err != nil {
+			return nil, fmt.Errorf("转换第 %d 号字形失败: %v", gid, err)
+		}
+	}
+
+	names, err := convertedFontNames(f, &buf, opts)
+	if err != nil {
+		return nil, err
+	}
+	cmap, err := buildCmapTable(runes, gids)
+	if err != nil {
+		return nil, err
+	}
+	out := buildGlyphTables(glyphs, tables)
+	out["cmap"] = cmap
+	out["name"] = buildNameTable(names)
+
+	os2 := append([]byte(nil), tables["OS/2"]...)
+	binary.BigEndian.PutUint16(os2[64:], uint16(min(runes[0], 0xFFFF)))
+	binary.BigEndian.PutUint16(os2[66:], uint16(min(runes[len(runes)-1], 0xFFFF)))
+	out["OS/2"] = os2
+	post := make([]byte, 32)
+	if len(tables["post"]) >= 32 {
+		copy(post, tables["post"])
+	}
+	binary.BigEndian.PutUint32(post, 0x00030000)
+	out["post"] = post
+	return writeSFNT(out), nil
+}
+
+// convertGlyph 取出一个字形的轮廓并转换为二次曲线
+// sfnt返回的轮廓y轴向下，翻转为字体坐标；CFF外轮廓为逆时针，TrueType要求顺时针，因此反转每条轮廓
+func convertGlyph(f *sfnt.Font, buf *sfnt.Buffer, gid sfnt.GlyphIndex, ppem fixed.Int26_6, tolerance float64) (ttGlyph, error) {
+	advance, err := f.GlyphAdvance(buf, gid, ppem, font.HintingNone)
+	if err != nil {
+		return ttGlyph{}, err
+	}
+	segments, err := f.LoadGlyph(buf, gid, ppem, nil)
+	if err != nil {
+		return ttGlyph{}, err
+	}
+
+	glyph := ttGlyph{Advance: uint16((advance + 32) >> 6)}
+	var contour []ttPoint
+	var last curvePoint
+	flush := func() {
+		if points := closeContour(contour); len(points) >= 3 {
+			glyph.Contours = append(glyph.Contours, points)
+		}
+		contour = nil
+	}
+	for _, segment := range segments {
+		arg := func(i int) curvePoint {
+			return curvePoint{float64(segment.Args[i].X) / 64, -float64(segment.Args[i].Y) / 64}
+		}
+		switch segment.Op {
+		case sfnt.SegmentOpMoveTo:
+			flush()
+			last = arg(0)
+			contour = append(contour, last.ttPoint(true))
+		case sfnt.SegmentOpLineTo:
+			last = arg(0)
+			contour = append(contour, last.ttPoint(true))
+		case sfnt.SegmentOpQuadTo:
+			contour = append(contour, arg(0).ttPoint(false), arg(1).ttPoint(true))
+			last = arg(1)
+		case sfnt.SegmentOpCubeTo:
+			for _, quad := range cubicToQuads(last, arg(0), arg(1), arg(2), tolerance, 0) {
+				if quad[0] != quad[1] {
+					contour = append(contour, quad[0].ttPoint(false))
+				}
+				contour = append(contour, quad[1].ttPoint(true))
+			}
+			last = arg(2)
+		}
+	}
+	flush()
+	return glyph, nil
+}
+
+// cubicToQuads 把三次曲线近似为若干段二次曲线，每段返回[控制点, 终点]，直线段的控制点与终点相同
+// 单段二次曲线与三次曲线的最大偏差为 √3/36·|p3-3p2+3p1-p0|，超过误差时在中点二分
+func cubicToQuads(p0, p1, p2, p3 curvePoint, tolerance float64, depth int) [][2]curvePoint {
+	if p1.distanceToLine(p0, p3) <= tolerance/2 && p2.distanceToLine(p0, p3) <= tolerance/2 {
+		return [][2]curvePoint{{p3, p3}}
+	}
+	deviation := p3.sub(p2.scale(3)).add(p1.scale(3)).sub(p0).length() * math.Sqrt(3) / 36
+	if deviation <= tolerance || depth >= 8 {
+		control := p1.scale(3).sub(p0).add(p2.scale(3)).sub(p3).scale(0.25)
+		return [][2]curvePoint{{control, p3}}
+	}
+	a, b, c := p0.mid(p1), p1.mid(p2), p2.mid(p3)
+	d, e := a.mid(b), b.mid(c)
+	m := d.mid(e)
+	return append(cubicToQuads(p0, a, d, m, tolerance, depth+1), cubicToQuads(m, e, c, p3, tolerance, depth+1)...)
+}
+
+// closeContour 整理一条闭合轮廓：去掉取整后重复的相邻点和与起点重合的终点，再反转方向
+func closeContour(points []ttPoint) []ttPoint {
+	cleaned := make([]ttPoint, 0, len(points))
+	for _, p := range points {
+		if n := len(cleaned); n > 0 && cleaned[n-1] == p {
+			continue
+		}
+		cleaned = append(cleaned, p)
+	}
+	for len(cleaned) > 1 && cleaned[len(cleaned)-1] == cleaned[0] {
+		cleaned = cleaned[:len(cleaned)-1]
+	}
+	if len(cleaned) < 3 {
+		return nil
+	}
+	reversed := make([]ttPoint, 0, len(cleaned))
+	reversed = append(reversed, cleaned[0])
+	for i := len(cleaned) - 1; i > 0; i-- {
+		reversed = append(reversed, cleaned[i])
+	}
+	return reversed
+}
+
+// bounds 计算字形所有点（含控制点）的包围盒，TrueType的包围盒按全部点计算
+func (g ttGlyph) bounds() (xMin, yMin, xMax, yMax int16) {
+	xMin, yMin, xMax, yMax = math.MaxInt16, math.MaxInt16, math.MinInt16, math.MinInt16
+	for _, contour := range g.Contours {
+		for _, p := range contour {
+			if p.X < xMin {
+				xMin = p.X
+			}
+			if p.X > xMax {
+				xMax = p.X
+			}
+			if p.Y < yMin {
+				yMin = p.Y
+			}
+			if p.Y > yMax {
+				yMax = p.Y
+			}
+		}
+	}
+	return
+}
+
+// encode 编码为glyf表中的简单字形，空字形（如空格）返回nil
+// 坐标按增量存储，能用一个字节表示的用短格式，相同的标志用重复标志压缩
+func (g ttGlyph) encode() []byte {
+	if len(g.Contours) == 0 {
+		return nil
+	}
+	var out bytes.Buffer
+	xMin, yMin, xMax, yMax := g.bounds()
+	binary.Write(&out, binary.BigEndian, []int16{int16(len(g.Contours)), xMin, yMin, xMax, yMax})
+	end := -1
+	for _, contour := range g.Contours {
+		end += len(contour)
+		binary.Write(&out, binary.BigEndian, uint16(end))
+	}
+	binary.Write(&out, binary.BigEndian, uint16(0)) // 无hinting指令
+
+	var flags []byte
+	var xs, ys bytes.Buffer
+	coordinate := func(delta int, short, same byte, data *bytes.Buffer) byte {
+		switch {
+		case delta == 0:
+			return same
+		case delta > -256 && delta < 256:
+			if delta > 0 {
+				data.WriteByte(byte(delta))
+				return short | same
+			}
+			data.WriteByte(byte(-delta))
+			return short
+		default:
+			binary.Write(data, binary.BigEndian, int16(delta))
+			return 0
+		}
+	}
+	var prevX, prevY int
+	for _, contour := range g.Contours {
+		for _, p := range contour {
+			var flag byte
+			if p.OnCurve {
+				flag = 0x01
+			}
+			flag |= coordinate(int(p.X)-prevX, 0x02, 0x10, &xs)
+			flag |= coordinate(int(p.Y)-prevY, 0x04, 0x20, &ys)
+			prevX, prevY = int(p.X), int(p.Y)
+			flags = append(flags, flag)
+		}
+	}
+	for i := 0; i < len(flags); {
+		repeat := 0
+		for i+repeat+1 < len(flags) && flags[i+repeat+1] == flags[i] && repeat < 255 {
+			repeat++
+		}
+		if repeat > 0 {
+			out.Write([]byte{flags[i] | 0x08, byte(repeat)})
+		} else {
+			out.WriteByte(flags[i])
+		}
+		i += repeat + 1
+	}
+	out.Write(xs.Bytes())
+	out.Write(ys.Bytes())
+	return out.Bytes()
+}
+
+// buildGlyphTables 生成glyf、loca、hmtx、maxp，并据字形度量更新head和hhea
+func buildGlyphTables(glyphs []ttGlyph, tables map[string][]byte) map[string][]byte {
+	var glyf bytes.Buffer
+	loca := make([]byte, 4*(len(glyphs)+1))
+	hmtx := make([]byte, 0, 4*len(glyphs))
+	var maxPoints, maxContours, advanceMax int
+	xMin, yMin, xMax, yMax := math.MaxInt16, math.MaxInt16, math.MinInt16, math.MinInt16
+	minLSB, minRSB, maxExtent := math.MaxInt16, math.MaxInt16, math.MinInt16
+
+	for i, glyph := range glyphs {
+		binary.BigEndian.PutUint32(loca[4*i:], uint32(glyf.Len()))
+		glyf.Write(glyph.encode())
+		for glyf.Len()%4 != 0 {
+			glyf.WriteByte(0)
+		}
+
+		advanceMax = max(advanceMax, int(glyph.Advance))
+		var lsb int16
+		if len(glyph.Contours) > 0 {
+			gxMin, gyMin, gxMax, gyMax := glyph.bounds()
+			lsb = gxMin
+			xMin, yMin = min(xMin, int(gxMin)), min(yMin, int(gyMin))
+			xMax, yMax = max(xMax, int(gxMax)), max(yMax, int(gyMax))
+			minLSB = min(minLSB, int(gxMin))
+			minRSB = min(minRSB, int(glyph.Advance)-int(gxMax))
+			maxExtent = max(maxExtent, int(gxMax))
+			points := 0
+			for _, contour := range glyph.Contours {
+				points += len(contour)
+			}
+			maxPoints = max(maxPoints, points)
+			maxContours = max(maxContours, len(glyph.Contours))
+		}
+		hmtx = binary.BigEndian.AppendUint16(hmtx, glyph.Advance)
+		hmtx = binary.BigEndian.AppendUint16(hmtx, uint16(lsb))
+	}
+	binary.BigEndian.PutUint32(loca[4*len(glyphs):], uint32(glyf.Len()))
+
+	// 末尾前进宽度相同的字形只需记录左侧间距
+	numberOfHMetrics := len(glyphs)
+	for numberOfHMetrics > 1 && glyphs[numberOfHMetrics-2].Advance == glyphs[len(glyphs)-1].Advance {
+		numberOfHMetrics--
+	}
+	compact := append([]byte(nil), hmtx[:4*numberOfHMetrics]...)
+	for i := numberOfHMetrics; i < len(glyphs); i++ {
+		compact = append(compact, hmtx[4*i+2:4*i+4]...)
+	}
+
+	head := append([]byte(nil), tables["head"]...)
+	binary.BigEndian.PutUint32(head[8:], 0)
+	binary.BigEndian.PutUint16(head[16:], binary.BigEndian.Uint16(head[16:])|0x0003) // 基线在y=0，左侧间距等于xMin
+	for i, v := range []int{xMin, yMin, xMax, yMax} {
+		binary.BigEndian.PutUint16(head[36+2*i:], uint16(int16(v)))
+	}
+	binary.BigEndian.PutUint16(head[50:], 1) // 长格式loca
+	binary.BigEndian.PutUint16(head[52:], 0)
+
+	hhea := append([]byte(nil), tables["hhea"]...)
+	binary.BigEndian.PutUint16(hhea[10:], uint16(advanceMax))
+	binary.BigEndian.PutUint16(hhea[12:], uint16(int16(minLSB)))
+	binary.BigEndian.PutUint16(hhea[14:], uint16(int16(minRSB)))
+	binary.BigEndian.PutUint16(hhea[16:], uint16(int16(maxExtent)))
+	binary.BigEndian.PutUint16(hhea[34:], uint16(numberOfHMetrics))
+
+	// maxp 1.0版，无hinting指令和复合字形
+	maxp := make([]byte, 32)
+	binary.BigEndian.PutUint32(maxp, 0x00010000)
+	binary.BigEndian.PutUint16(maxp[4:], uint16(len(glyphs)))
+	binary.BigEndian.PutUint16(maxp[6:], uint16(maxPoints))
+	binary.BigEndian.PutUint16(maxp[8:], uint16(maxContours))
+	binary.BigEndian.PutUint16(maxp[14:], 2) // maxZones
+
+	return map[string][]byte{"glyf": glyf.Bytes(), "loca": loca, "hmtx": compact, "head": head, "hhea": hhea, "maxp": maxp}
+}
+
+// buildCmapTable 生成cmap表，含基本平面的格式4子表和全平面的格式12子表
+// runes按码位升序排列，gids为对应的新字形编号；码位和字形编号都连续的一段合并为一个区段
+func buildCmapTable(runes []rune, gids []uint16) ([]byte, error) {
+	type group struct {
+		start, end rune
+		glyph      uint16
+	}
+	var groups []group
+	for i, r := range runes {
+		if n := len(groups); n > 0 && groups[n-1].end+1 == r && int(groups[n-1].glyph)+int(r-groups[n-1].start) == int(gids[i]) {
+			groups[n-1].end = r
+			continue
+		}
+		groups = append(groups, group{r, r, gids[i]})
+	}
+
+	// 格式4只能表示基本平面，末尾必须以0xFFFF区段结束
+	var bmp []group
+	for _, g := range groups {
+		if g.start <= 0xFFFE {
+			bmp = append(bmp, group{g.start, min(g.end, 0xFFFE), g.glyph})
+		}
+	}
+	segCount := len(bmp) + 1
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= segCount {
+		entrySelector++
+	}
+	searchRange := 2 << entrySelector
+	length := 16 + 8*segCount
+	if length > math.MaxUint16 {
+		return nil, fmt.Errorf("cmap格式4子表过大（%d 个区段）", segCount)
+	}
+	format4 := binary.BigEndian.AppendUint16(nil, 4)
+	for _, v := range []int{length, 0, 2 * segCount, searchRange, entrySelector, 2*segCount - searchRange} {
+		format4 = binary.BigEndian.AppendUint16(format4, uint16(v))
+	}
+	for _, g := range bmp {
+		format4 = binary.BigEndian.AppendUint16(format4, uint16(g.end))
+	}
+	format4 = binary.BigEndian.AppendUint16(format4, 0xFFFF)
+	format4 = binary.BigEndian.AppendUint16(format4, 0) // reservedPad
+	for _, g := range bmp {
+		format4 = binary.BigEndian.AppendUint16(format4, uint16(g.start))
+	}
+	format4 = binary.BigEndian.AppendUint16(format4, 0xFFFF)
+	for _, g := range bmp {
+		format4 = binary.BigEndian.AppendUint16(format4, g.glyph-uint16(g.start))
+	}
+	format4 = binary.BigEndian.AppendUint16(format4, 1)
+	format4 = append(format4, make([]byte, 2*segCount)...) // idRangeOffset全为0
+
+	format12 := binary.BigEndian.AppendUint16(nil, 12)
+	format12 = binary.BigEndian.AppendUint16(format12, 0)
+	for _, v := range []int{16 + 12*len(groups), 0, len(groups)} {
+		format12 = binary.BigEndian.AppendUint32(format12, uint32(v))
+	}
+	for _, g := range groups {
+		for _, v := range []uint32{uint32(g.start), uint32(g.end), uint32(g.glyph)} {
+			format12 = binary.BigEndian.AppendUint32(format12, v)
+		}
+	}
+
+	// 子表记录：Windows平台的Unicode BMP（3,1）和Unicode全平面（3,10）
+	cmap := binary.BigEndian.AppendUint16(nil, 0)
+	cmap = binary.BigEndian.AppendUint16(cmap, 2)
+	offset := 4 + 2*8
+	for _, record := range []struct {
+		encoding uint16
+		data     []byte
+	}{{1, format4}, {10, format12}} {
+		cmap = binary.BigEndian.AppendUint16(cmap, 3)
+		cmap = binary.BigEndian.AppendUint16(cmap, record.encoding)
+		cmap = binary.BigEndian.AppendUint32(cmap, uint32(offset))
+		offset += len(record.data)
+	}
+	cmap = append(cmap, format4...)
+	return append(cmap, format12...), nil
+}
+
+// convertedFontNames 生成新字体的name表记录
+// 家族名、全名和PostScript名改用新名称，版权、设计者和许可证沿用原字体，版权信息后追加修改说明
+func convertedFontNames(f *sfnt.Font, buf *sfnt.Buffer, opts fontConvertOptions) (map[sfnt.NameID]string, error) {
+	source := func(id sfnt.NameID) string {
+		value, _ := f.Name(buf, id)
+		return value
+	}
+	copyright := source(sfnt.NameIDCopyright)
+	if copyright == "" {
+		return nil, fmt.Errorf("原字体没有版权信息，无法确认许可")
+	}
+	if opts.Notice != "" {
+		copyright += " " + opts.Notice
+	}
+	subfamily := source(sfnt.NameIDSubfamily)
+	if subfamily == "" {
+		subfamily = "Regular"
+	}
+	version, _, _ := strings.Cut(source(sfnt.NameIDVersion), ";")
+	postScript := strings.ReplaceAll(opts.Family, " ", "") + "-" + strings.ReplaceAll(subfamily, " ", "")
+
+	names := map[sfnt.NameID]string{
+		sfnt.NameIDCopyright:        copyright,
+		sfnt.NameIDFamily:           opts.Family,
+		sfnt.NameIDSubfamily:        subfamily,
+		sfnt.NameIDUniqueIdentifier: strings.TrimPrefix(version, "Version ") + ";" + postScript,
+		sfnt.NameIDFull:             opts.Family + " " + subfamily,
+		sfnt.NameIDVersion:          version,
+		sfnt.NameIDPostScript:       postScript,
+		sfnt.NameIDManufacturer:     source(sfnt.NameIDManufacturer),
+		sfnt.NameIDDesigner:         source(sfnt.NameIDDesigner),
+		sfnt.NameIDLicense:          source(sfnt.NameIDLicense),
+		sfnt.NameIDLicenseURL:       source(sfnt.NameIDLicenseURL),
+	}
+	for id, value := range names {
+		if value == "" {
+			delete(names, id)
+		}
+	}
+	return names, nil
+}
+
+// buildNameTable 生成格式0的name表，只写Windows平台英语（美国）的UTF-16BE记录
+func buildNameTable(names map[sfnt.NameID]string) []byte {
+	ids := make([]int, 0, len(names))
+	for id := range names {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+
+	var storage []byte
+	table := binary.BigEndian.AppendUint16(nil, 0)
+	table = binary.BigEndian.AppendUint16(table, uint16(len(ids)))
+	table = binary.BigEndian.AppendUint16(table, uint16(6+12*len(ids)))
+	for _, id := range ids {
+		start := len(storage)
+		for _, unit := range utf16.Encode([]rune(names[sfnt.NameID(id)])) {
+			storage = binary.BigEndian.AppendUint16(storage, unit)
+		}
+		for _, v := range []int{3, 1, 0x0409, id, len(storage) - start, start} {
+			table = binary.BigEndian.AppendUint16(table, uint16(v))
+		}
+	}
+	return append(table, storage...)
+}
@@ -0,0 +1,258 @@
+// font_subset.go 实现PDF报告嵌入字体所需的TrueType子集化
+// 只保留报告中用到的字形，字形编号保持不变，PDF按Identity编码直接以字形编号引用；
+// 中文字体通常有数MB到十几MB，子集化后只有几十KB。
+// CFF轮廓的OpenType字体（.otf，以及Noto Sans CJK等.ttc）不做子集化，不用于PDF
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// pdfFontTables 嵌入PDF的TrueType字体保留的表
+// PDF只要求glyf、head、hhea、hmtx、loca、maxp以及存在时的cvt、fpgm、prep；
+// 同时保留cmap、OS/2、name和post，子集仍是完整的字体文件，部分阅读器会用到
+var pdfFontTables = []string{"OS/2", "cmap", "cvt ", "fpgm", "glyf", "head", "hhea", "hmtx", "loca", "maxp", "name", "post", "prep"}
+
+// 复合字形的组件标志
+const (
+	glyphArgsAreWords   = 0x0001
+	glyphHaveScale      = 0x0008
+	glyphMoreComponents = 0x0020
+	glyphHaveXYScale    = 0x0040
+	glyphHave2x2        = 0x0080
+)
+
+// readSFNTTables 读取字体文件中第一个字体的所有表
+// 字体集合取第一个字体，与parseFontData一致；表的偏移相对于整个文件，集合中的字体同样适用
+//
+// 返回值：按表标签索引的表数据
+func readSFNTTables(data []byte) (map[string][]byte, error) {
+	return readSFNTTablesAt(data, 0)
+}
+
+// readSFNTTablesAt 读取字体文件中指定序号字体的所有表，单个字体文件只有0号
+func readSFNTTablesAt(data []byte, index int) (map[string][]byte, error) {
+	offset := 0
+	if len(data) >= 12 && string(data[:4]) == "ttcf" {
+		count := int(binary.BigEndian.Uint32(data[8:]))
+		if index < 0 || index >= count || 16+4*index > len(data) {
+			return nil, fmt.Errorf("字体集合中没有第 %d 个字体（共 %d 个）", index, count)
+		}
+		offset = int(binary.BigEndian.Uint32(data[12+4*index:]))
+	} else if index != 0 {
+		return nil, fmt.Errorf("不是字体集合，只能读取第0个字体")
+	}
+	if offset+12 > len(data) {
+		return nil, fmt.Errorf("字体数据过短")
+	}
+
+	numTables := int(binary.BigEndian.Uint16(data[offset+4:]))
+	if offset+12+numTables*16 > len(data) {
+		return nil, fmt.Errorf("字体表目录不完整")
+	}
+	tables := make(map[string][]byte, numTables)
+	for i := 0; i < numTables; i++ {
+		record := data[offset+12+i*16:]
+		tag := string(record[:4])
+		start := int(binary.BigEndian.Uint32(record[8:]))
+		length := int(binary.BigEndian.Uint32(record[12:]))
+		if start < 0 || length < 0 || start+length > len(data) {
+			return nil, fmt.Errorf("字体表 %q 超出文件范围", tag)
+		}
+		tables[tag] = data[start : start+length]
+	}
+	return tables, nil
+}
+
+// isTrueTypeOutline 判断字体是否为TrueType轮廓，只有这类字体可以子集化后嵌入PDF
+func isTrueTypeOutline(data []byte) bool {
+	tables, err := readSFNTTables(data)
+	if err != nil {
+		return false
+	}
+	_, hasGlyf := tables["glyf"]
+	_, hasLoca := tables["loca"]
+	return hasGlyf && hasLoca && len(tables["head"]) >= 54 && len(tables["maxp"]) >= 6
+}
+
+// subsetTrueType 生成只含指定字形的TrueType字体
+// 未用到的字形清空为零长度，字形编号和hmtx不变；复合字形引用的组件字形一并保留
+//
+// 参数：
+//   - data: 字体文件数据，字体集合取第一个字体
+//   - glyphs: 需要保留的字形编号，0号字形（.notdef）总是保留
+//
+// 返回值：子集字体文件数据
+func subsetTrueType(data []byte, glyphs map[uint16]bool) ([]byte, error) {
+	tables, err := readSFNTTables(data)
+	if err != nil {
+		return nil, err
+	}
+	head, maxp, loca, glyf := tables["head"], tables["maxp"], tables["loca"], tables["glyf"]
+	if glyf == nil || loca == nil || len(head) < 54 || len(maxp) < 6 {
+		return nil, fmt.Errorf("不是TrueType轮廓字体")
+	}
+
+	// 读取各字形在glyf表中的位置
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	longLoca := binary.BigEndian.Uint16(head[50:]) == 1
+	offsets := make([]int, numGlyphs+1)
+	for i := range offsets {
+		if longLoca {
+			if 4*i+4 > len(loca) {
+				return nil, fmt.Errorf("loca表不完整")
+			}
+			offsets[i] = int(binary.BigEndian.Uint32(loca[4*i:]))
+		} else {
+			if 2*i+2 > len(loca) {
+				return nil, fmt.Errorf("loca表不完整")
+			}
+			offsets[i] = int(binary.BigEndian.Uint16(loca[2*i:])) * 2
+		}
+	}
+	glyphData := func(gid int) []byte {
+		start, end := offsets[gid], offsets[gid+1]
+		if start >= end || end > len(glyf) {
+			return nil
+		}
+		return glyf[start:end]
+	}
+
+	// 保留的字形及其引用的组件字形
+	keep := map[int]bool{0: true}
+	queue := []int{0}
+	for gid := range glyphs {
+		if int(gid) < numGlyphs && !keep[int(gid)] {
+			keep[int(gid)] = true
+			queue = append(queue, int(gid))
+		}
+	}
+	for len(queue) > 0 {
+		gid := queue[0]
+		queue = queue[1:]
+		for _, component := range glyphComponents(glyphData(gid)) {
+			if component < numGlyphs && !keep[component] {
+				keep[component] = true
+				queue = append(queue, component)
+			}
+		}
+	}
+
+	// 重建glyf和长格式的loca
+	var newGlyf bytes.Buffer
+	newLoca := make([]byte, 4*(numGlyphs+1))
+	for gid := 0; gid < numGlyphs; gid++ {
+		binary.BigEndian.PutUint32(newLoca[4*gid:], uint32(newGlyf.Len()))
+		if keep[gid] {
+			newGlyf.Write(glyphData(gid))
+			for newGlyf.Len()%4 != 0 {
+				newGlyf.WriteByte(0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(newLoca[4*numGlyphs:], uint32(newGlyf.Len()))
+
+	newHead := append([]byte(nil), head...)
+	binary.BigEndian.PutUint32(newHead[8:], 0) // checkSumAdjustment，写完整个文件后再计算
+	binary.BigEndian.PutUint16(newHead[50:], 1)
+
+	out := map[string][]byte{"head": newHead, "loca": newLoca, "glyf": newGlyf.Bytes()}
+	// post表只保留32字节的表头并改为3.0版，去掉全部字形名
+	if post := tables["post"]; len(post) >= 32 {
+		newPost := append([]byte(nil), post[:32]...)
+		binary.BigEndian.PutUint32(newPost, 0x00030000)
+		out["post"] = newPost
+	}
+	for _, tag := range pdfFontTables {
+		if _, done := out[tag]; !done && tables[tag] != nil {
+			out[tag] = tables[tag]
+		}
+	}
+	return writeSFNT(out), nil
+}
+
+// glyphComponents 返回复合字形引用的组件字形编号，简单字形返回nil
+func glyphComponents(glyph []byte) []int {
+	if len(glyph) < 10 || int16(binary.BigEndian.Uint16(glyph)) >= 0 {
+		return nil
+	}
+	var components []int
+	pos := 10
+	for pos+4 <= len(glyph) {
+		flags := binary.BigEndian.Uint16(glyph[pos:])
+		components = append(components, int(binary.BigEndian.Uint16(glyph[pos+2:])))
+		pos += 4
+		if flags&glyphArgsAreWords != 0 {
+			pos += 4
+		} else {
+			pos += 2
+		}
+		switch {
+		case flags&glyphHaveScale != 0:
+			pos += 2
+		case flags&glyphHaveXYScale != 0:
+			pos += 4
+		case flags&glyphHave2x2 != 0:
+			pos += 8
+		}
+		if flags&glyphMoreComponents == 0 {
+			break
+		}
+	}
+	return components
+}
+
+// writeSFNT 按表标签排序写出TrueType字体文件，计算各表校验和及head表的checkSumAdjustment
+func writeSFNT(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	numTables := len(tags)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= numTables {
+		entrySelector++
+	}
+	searchRange := (1 << entrySelector) * 16
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, []uint16{0x0001, 0x0000, uint16(numTables), uint16(searchRange), uint16(entrySelector), uint16(numTables*16 - searchRange)})
+
+	offset := 12 + numTables*16
+	headOffset := 0
+	for _, tag := range tags {
+		data := tables[tag]
+		if tag == "head" {
+			headOffset = offset
+		}
+		buf.WriteString(tag)
+		binary.Write(&buf, binary.BigEndian, []uint32{sfntChecksum(data), uint32(offset), uint32(len(data))})
+		offset += (len(data) + 3) &^ 3
+	}
+	for _, tag := range tags {
+		buf.Write(tables[tag])
+		for buf.Len()%4 != 0 {
+			buf.WriteByte(0)
+		}
+	}
+
+	font := buf.Bytes()
+	binary.BigEndian.PutUint32(font[headOffset+8:], 0xB1B0AFBA-sfntChecksum(font))
+	return font
+}
+
+// sfntChecksum 按大端32位整数求和，末尾不足4字节补零
+func sfntChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
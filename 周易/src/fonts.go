@@ -0,0 +1,406 @@
+// fonts.go 实现字体回退链
+// 字体按顺序组成回退链：主题字体、配置的render.fonts、随程序内嵌的fonts目录、
+// 系统字体中找到的第一个中文字体，最后是Go自带的西文字体，保证任何环境下都能启动渲染。
+// 绘制时逐字选择链中第一个包含该字形的字体，爻辞中的生僻字可由后面的字体补齐；
+// 启动时检查卦象图会用到的全部文字，整条链都缺少的字记录到日志，而不是渲染成方框
+package main
+
+import (
+	"embed"
+	"fmt"
+	"image"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// embeddedFontFS 随程序内嵌的字体，fonts目录下的.ttf/.otf/.ttc文件按文件名顺序加入回退链
+//
+//go:embed fonts
+var embeddedFontFS embed.FS
+
+// fontSource 回退链中的一个字体
+type fontSource struct {
+	Name string         // 来源说明，如"内嵌 fonts/10-ZhouyiSans-Regular.ttf"
+	Font *opentype.Font // 解析后的字体，只读，可并发共享
+	Data []byte         // 字体文件的原始数据，PDF报告嵌入字体时使用
+}
+
+// fontChain 有序的字体回退链
+type fontChain struct {
+	sources []fontSource
+}
+
+// 字体回退链缓存
+var (
+	sharedFontSources     []fontSource // 主题字体之后的公共部分：配置字体、内嵌字体、系统字体和西文字体
+	sharedFontSourcesOnce sync.Once
+	fontChainCache        sync.Map // 键为主题字体文件路径，值为*fontChain
+)
+
+// systemFontPaths 各平台常见的中文字体位置，只取找到的第一个
+var systemFontPaths = []string{
+	"simkai.ttf",
+	"ttf/simkai.ttf",
+	"../ttf/simkai.ttf",
+	filepath.Join(getCurrentDir(), "ttf", "simkai.ttf"),
+	// Windows系统字体
+	"C:\\Windows\\Fonts\\simkai.ttf", // 楷体
+	"C:\\Windows\\Fonts\\simhei.ttf", // 黑体
+	"C:\\Windows\\Fonts\\simsun.ttc", // 宋体
+	// Linux系统字体
+	"/usr/share/fonts/truetype/arphic/ukai.ttc",
+	"/usr/share/fonts/truetype/wqy/wqy-microhei.ttc",
+	"/usr/share/fonts/opentype/noto/NotoSansCJK-Regular.ttc",
+	// macOS系统字体
+	"/Library/Fonts/Arial Unicode.ttf",
+	"/System/Library/Fonts/PingFang.ttc",
+}
+
+// parseFontData 解析字体数据，字体集合（.ttc/.otc）取其中第一个字体
+func parseFontData(data []byte, name string) (*opentype.Font, error) {
+	ext := strings.ToLower(path.Ext(name))
+	if ext == ".ttc" || ext == ".otc" {
+		collection, err := opentype.ParseCollection(data)
+		if err != nil {
+			return nil, err
+		}
+		return collection.Font(0)
+	}
+	return opentype.Parse(data)
+}
+
+// loadFontFile 读取并解析字体文件
+//
+// 返回值：解析后的字体和文件的原始数据
+func loadFontFile(filePath string) (*opentype.Font, []byte, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := parseFontData(data, filePath)
+	return f, data, err
+}
+
+// isFontFile 判断文件名是否为支持的字体格式
+func isFontFile(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".ttf", ".otf", ".ttc", ".otc":
+		return true
+	}
+	return false
+}
+
+// goRegularFontName Go自带西文字体在回退链中的名称
+const goRegularFontName = "内置 Go Regular"
+
+// embeddedFontSources 按文件名顺序加载编译时内嵌的字体，解析失败的字体记录日志后跳过
+func embeddedFontSources() []fontSource {
+	var sources []fontSource
+	entries, _ := fs.ReadDir(embeddedFontFS, "fonts")
+	for _, entry := range entries {
+		if entry.IsDir() || !isFontFile(entry.Name()) {
+			continue
+		}
+		name := "fonts/" + entry.Name()
+		data, err := embeddedFontFS.ReadFile(name)
+		if err == nil {
+			var f *opentype.Font
+			if f, err = parseFontData(data, name); err == nil {
+				sources = append(sources, fontSource{Name: "内嵌 " + name, Font: f, Data: data})
+				continue
+			}
+		}
+		log.Printf("加载字体 内嵌 %s 失败，已跳过: %v", name, err)
+	}
+	return sources
+}
+
+// cjkProbeText 判断字体是否为中文字体时探测的文字
+const cjkProbeText = "乾坤震巽坎离艮兑"
+
+// hasEmbeddedCJKFont 判断内嵌字体中是否有中文字体
+// fonts目录中的中文字体被删除时返回false，此时卦象图的汉字依赖配置字体和系统字体
+func hasEmbeddedCJKFont() bool {
+	chain := &fontChain{sources: embeddedFontSources()}
+	return len(chain.missingRunes(cjkProbeText)) == 0
+}
+
+// embeddedFontChain 只由内嵌字体和Go Regular组成的回退链，不受配置和系统字体影响
+// 用于金图比对，保证不同机器上渲染结果一致
+func embeddedFontChain() (*fontChain, error) {
+	f, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("解析Go Regular字体失败: %v", err)
+	}
+	sources := append(embeddedFontSources(), fontSource{Name: goRegularFontName, Font: f, Data: goregular.TTF})
+	return &fontChain{sources: sources}, nil
+}
+
+// getSharedFontSources 加载回退链的公共部分，只加载一次
+func getSharedFontSources() []fontSource {
+	sharedFontSourcesOnce.Do(func() {
+		var sources []fontSource
+		add := func(name string, f *opentype.Font, data []byte, err error) {
+			if err != nil {
+				log.Printf("加载字体 %s 失败，已跳过: %v", name, err)
+				return
+			}
+			sources = append(sources, fontSource{Name: name, Font: f, Data: data})
+		}
+
+		// 配置中按顺序列出的字体
+		for _, file := range GetConfig().Render.Fonts {
+			f, data, err := loadFontFile(file)
+			add("配置 "+file, f, data, err)
+		}
+
+		// 内嵌字体
+		sources = append(sources, embeddedFontSources()...)
+
+		// 系统中找到的第一个中文字体
+		for _, file := range systemFontPaths {
+			if !fileExists(file) {
+				continue
+			}
+			f, data, err := loadFontFile(file)
+			add("系统 "+file, f, data, err)
+			if err == nil {
+				break
+			}
+		}
+
+		// Go自带的西文字体，保证数字和拉丁字母总能显示
+		f, err := opentype.Parse(goregular.TTF)
+		add(goRegularFontName, f, goregular.TTF, err)
+
+		names := make([]string, len(sources))
+		for i, source := range sources {
+			names[i] = source.Name
+		}
+		log.Printf("字体回退链: %s", strings.Join(names, " → "))
+		sharedFontSources = sources
+	})
+	return sharedFontSources
+}
+
+// loadFontChain 获取主题的字体回退链，主题指定了字体文件时排在最前
+func loadFontChain(theme *Theme) (*fontChain, error) {
+	if chain, ok := fontChainCache.Load(theme.Font.File); ok {
+		return chain.(*fontChain), nil
+	}
+
+	shared := getSharedFontSources()
+	sources := make([]fontSource, 0, len(shared)+1)
+	if theme.Font.File != "" {
+		f, data, err := loadFontFile(theme.Font.File)
+		if err != nil {
+			return nil, fmt.Errorf("读取主题 %s 的字体文件失败: %v", theme.Name, err)
+		}
+		sources = append(sources, fontSource{Name: "主题 " + theme.Font.File, Font: f, Data: data})
+	}
+	sources = append(sources, shared...)
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("找不到可用的字体文件")
+	}
+
+	actual, _ := fontChainCache.LoadOrStore(theme.Font.File, &fontChain{sources: sources})
+	return actual.(*fontChain), nil
+}
+
+// sourceFor 返回链中第一个包含该字的字体序号，都没有时返回-1
+func (c *fontChain) sourceFor(buf *sfnt.Buffer, r rune) int {
+	for i, source := range c.sources {
+		if index, err := source.Font.GlyphIndex(buf, r); err == nil && index != 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// missingRunes 返回文本中整条回退链都没有字形的字符，按码位排序去重
+// 空白和控制字符不需要字形，不计入
+func (c *fontChain) missingRunes(text string) []rune {
+	var buf sfnt.Buffer
+	seen := make(map[rune]bool)
+	var missing []rune
+	for _, r := range text {
+		if seen[r] || r <= ' ' || r == 0x3000 {
+			continue
+		}
+		seen[r] = true
+		if c.sourceFor(&buf, r) < 0 {
+			missing = append(missing, r)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+	return missing
+}
+
+// newFace 为链中每个字体创建指定字号的字体面，组合成逐字回退的字体面
+func (c *fontChain) newFace(size float64) (font.Face, error) {
+	faces := make([]font.Face, 0, len(c.sources))
+	for _, source := range c.sources {
+		face, err := opentype.NewFace(source.Font, &opentype.FaceOptions{
+			Size:    size,
+			DPI:     72,
+			Hinting: font.HintingFull,
+		})
+		if err != nil {
+			for _, created := range faces {
+				created.Close()
+			}
+			return nil, fmt.Errorf("创建字体 %s 失败: %v", source.Name, err)
+		}
+		faces = append(faces, face)
+	}
+	return &fallbackFace{chain: c, faces: faces, picks: make(map[rune]int)}, nil
+}
+
+// fallbackFace 逐字回退的字体面
+// 每个字使用回退链中第一个包含它的字体，行高等度量取自第一个字体；
+// 与opentype的字体面一样不能并发使用，由字体面对象池保证
+type fallbackFace struct {
+	chain *fontChain
+	faces []font.Face
+	buf   sfnt.Buffer
+	picks map[rune]int // 每个字选用的字体序号
+}
+
+// faceFor 返回绘制该字使用的字体面，整条链都没有时用第一个字体（显示为方框）
+func (f *fallbackFace) faceFor(r rune) font.Face {
+	i, ok := f.picks[r]
+	if !ok {
+		i = max(f.chain.sourceFor(&f.buf, r), 0)
+		f.picks[r] = i
+	}
+	return f.faces[i]
+}
+
+func (f *fallbackFace) Close() error {
+	for _, face := range f.faces {
+		face.Close()
+	}
+	return nil
+}
+
+func (f *fallbackFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	return f.faceFor(r).Glyph(dot, r)
+}
+
+func (f *fallbackFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	return f.faceFor(r).GlyphBounds(r)
+}
+
+func (f *fallbackFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	return f.faceFor(r).GlyphAdvance(r)
+}
+
+// Kern 只有相邻两字来自同一字体时才有字距调整
+func (f *fallbackFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	face := f.faceFor(r0)
+	if face != f.faceFor(r1) {
+		return 0
+	}
+	return face.Kern(r0, r1)
+}
+
+func (f *fallbackFace) Metrics() font.Metrics {
+	return f.faces[0].Metrics()
+}
+
+// textRecorder 只记录文字的画布，用于收集卦象图可能出现的全部文字
+type textRecorder struct {
+	measureCanvas
+	text strings.Builder
+}
+
+func (c *textRecorder) DrawText(text string, x, y int, style textStyle) {
+	c.text.WriteString(text)
+}
+
+func (c *textRecorder) DrawCenteredText(text string, centerX, y int, style textStyle) {
+	c.text.WriteString(text)
+}
+
+// chartGlyphText 收集卦象图可能用到的全部文字
+// 对64卦分别按全部动爻和无动爻排一遍盘面，覆盖卦名、六亲纳甲、爻辞和各种标签，
+// 再加上四柱用到的天干地支和伏神的标记
+func chartGlyphText(theme *Theme, faces *chartFaces) string {
+	recorder := &textRecorder{measureCanvas: measureCanvas{faces: faces}}
+	recorder.text.WriteString(strings.Join(天干列表, "") + strings.Join(地支, "") + "年月日时伏")
+
+	tpl, _ := getChartTemplate(LayoutLandscape)
+	chart := benchmarkChart()
+	solarTime := chart.DivineTime
+	chart.SolarTime = &solarTime
+	for bits := 0; bits < 64; bits++ {
+		本卦 := make([]int, 6)
+		变卦 := make([]int, 6)
+		for i := range 本卦 {
+			本卦[i] = bits >> i & 1
+			变卦[i] = 1 - 本卦[i]
+		}
+		chart.BenGua, chart.BianGua = 本卦, 变卦
+		chart.BenGuaName, chart.BianGuaName = guaToName(本卦), guaToName(变卦)
+		for _, moving := range []bool{true, false} {
+			chart.HasDongYao = moving
+			chart.DongYao = []bool{moving, moving, moving, moving, moving, moving}
+			// 打开全部栏目，伏神和互卦用到的字也一并检查
+			layout := computeLayout(tpl, chart, theme, faces)
+			layout.栏目 = layoutPanels{标题: true, 副标题: true, 卦名: true, 六神: true, 六亲: true, 动爻: true, 标签: true, 伏神: true, 爻辞: true, 互卦: true}
+			drawGuaImage(recorder, layout, chart)
+		}
+	}
+	return recorder.text.String()
+}
+
+// reportMissingGlyphs 检查主题字体回退链能否显示卦象图的全部文字，把缺少的字记录到日志
+//
+// 返回值：缺少字形的字符
+func reportMissingGlyphs(theme *Theme) ([]rune, error) {
+	chain, err := loadFontChain(theme)
+	if err != nil {
+		return nil, err
+	}
+	faces, err := acquireFaces(theme)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseFaces(theme, faces)
+
+	text := chartGlyphText(theme, faces)
+	missing := chain.missingRunes(text)
+	if len(missing) == 0 {
+		log.Printf("字体检查通过: 主题 %s 的字体可以显示卦象图用到的全部 %d 个字符", theme.Name, len([]rune(text)))
+		return nil, nil
+	}
+
+	items := make([]string, 0, len(missing))
+	for _, r := range missing {
+		items = append(items, fmt.Sprintf("%c(U+%04X)", r, r))
+	}
+	const maxListed = 40
+	listed := items
+	if len(listed) > maxListed {
+		listed = listed[:maxListed]
+	}
+	hint := "请在 render.fonts 中补充字体"
+	if !hasEmbeddedCJKFont() {
+		hint = "程序未内嵌中文字体，请按 fonts/README.md 恢复 fonts 目录中的字体后重新编译，或在 render.fonts 中补充字体"
+	}
+	log.Printf("警告: 主题 %s 的字体回退链缺少 %d 个字符的字形，这些字将显示为方框，%s: %s",
+		theme.Name, len(missing), hint, strings.Join(listed, "、"))
+	return missing, nil
+}
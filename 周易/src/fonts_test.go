@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// TestEmbeddedFonts 检查fonts目录内嵌了中文字体，且该字体是可以嵌入PDF报告的TrueType轮廓
+func TestEmbeddedFonts(t *testing.T) {
+	if !hasEmbeddedCJKFont() {
+		t.Fatal("fonts目录没有内嵌中文字体，卦象图和PDF报告中的汉字将依赖系统字体，见 fonts/README.md")
+	}
+	for _, source := range embeddedFontSources() {
+		chain := &fontChain{sources: []fontSource{source}}
+		if len(chain.missingRunes(cjkProbeText)) == 0 && !isTrueTypeOutline(source.Data) {
+			t.Errorf("%s 不是TrueType轮廓，不能嵌入PDF报告，请先用 convert-font 转换", source.Name)
+		}
+	}
+}
+
+// TestConvertFontToTrueType 转换Go Regular后检查字符映射、前进宽度、包围盒和新字体名
+func TestConvertFontToTrueType(t *testing.T) {
+	converted, err := convertFontToTrueType(goregular.TTF, fontConvertOptions{
+		Family:    "Test Sans",
+		Tolerance: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isTrueTypeOutline(converted) {
+		t.Fatal("转换结果不是TrueType轮廓")
+	}
+
+	original, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := sfnt.Parse(converted)
+	if err != nil {
+		t.Fatalf("解析转换结果失败: %v", err)
+	}
+	var buf sfnt.Buffer
+	if name, _ := result.Name(&buf, sfnt.NameIDFamily); name != "Test Sans" {
+		t.Errorf("字体家族名为 %q，期望 Test Sans", name)
+	}
+
+	ppem := fixed.I(int(original.UnitsPerEm()))
+	for _, r := range "AgQ&@0" {
+		want, err := glyphMetrics(original, &buf, r, ppem)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := glyphMetrics(result, &buf, r, ppem)
+		if err != nil {
+			t.Fatalf("%c: %v", r, err)
+		}
+		if got.advance != want.advance {
+			t.Errorf("%c 的前进宽度为 %v，期望 %v", r, got.advance, want.advance)
+		}
+		// 取整到设计单位后包围盒最多偏差1个单位
+		if !nearPoint(got.bounds.Min, want.bounds.Min) || !nearPoint(got.bounds.Max, want.bounds.Max) {
+			t.Errorf("%c 的包围盒为 %v，期望 %v", r, got.bounds, want.bounds)
+		}
+	}
+}
+
+// nearPoint 判断两点在每个方向上的偏差是否不超过1个单位
+func nearPoint(a, b fixed.Point26_6) bool {
+	d := a.Sub(b)
+	return d.X.Round() >= -1 && d.X.Round() <= 1 && d.Y.Round() >= -1 && d.Y.Round() <= 1
+}
+
+// glyphSummary 字形的前进宽度和轮廓包围盒
+type glyphSummary struct {
+	advance fixed.Int26_6
+	bounds  fixed.Rectangle26_6
+}
+
+// glyphMetrics 按ppem取字符的前进宽度和轮廓包围盒
+func glyphMetrics(f *sfnt.Font, buf *sfnt.Buffer, r rune, ppem fixed.Int26_6) (glyphSummary, error) {
+	gid, err := f.GlyphIndex(buf, r)
+	if err != nil {
+		return glyphSummary{}, err
+	}
+	if gid == 0 {
+		return glyphSummary{}, fmt.Errorf("字体中没有字符 %c", r)
+	}
+	advance, err := f.GlyphAdvance(buf, gid, ppem, font.HintingNone)
+	if err != nil {
+		return glyphSummary{}, err
+	}
+	segments, err := f.LoadGlyph(buf, gid, ppem, nil)
+	if err != nil {
+		return glyphSummary{}, err
+	}
+	return glyphSummary{advance, segments.Bounds()}, nil
+}
@@ -0,0 +1,78 @@
+// ganzhi.go 实现干支历法的本地推算
+// 包括根据日干和时辰推算时柱（五鼠遁）等不依赖外部万年历API的计算
+package main
+
+import "time"
+
+// 子时的两种排法，只影响23:00-23:59的日柱和时干的推法，两种排法得出的时柱相同
+const (
+	ZiHourLate  = "late"  // 晚子时：零点换日，23点之后日柱仍取当日，时干按次日日干起（默认）
+	ZiHourEarly = "early" // 子初换日：23点起日柱即换为次日，时干按新的日干起
+)
+
+// indexOf 返回字符串在列表中的索引，找不到时返回-1
+func indexOf(list []string, value string) int {
+	for i, item := range list {
+		if item == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// hourBranchIndex 根据钟点返回时辰地支的索引
+// 23:00-00:59为子时，01:00-02:59为丑时，依此类推
+//
+// 参数：
+//   - hour: 0-23的小时数
+//
+// 返回值：地支列表中的索引（0为子，11为亥）
+func hourBranchIndex(hour int) int {
+	return ((hour + 1) / 2) % 12
+}
+
+// hourPillar 根据日干和钟点推算时柱（五鼠遁）
+// 甲己日起甲子时，乙庚日起丙子时，丙辛日起戊子时，丁壬日起庚子时，戊癸日起壬子时
+// 23点之后按晚子时排时，日干仍是当日的，时干按次日日干起；按子初换日排时，
+// 日干已是次日的（见dayPillarDate），直接按日干起。两种排法得出同一个时柱
+//
+// 参数：
+//   - 日干: 日柱的天干，如"甲"
+//   - hour: 0-23的小时数
+//   - ziHour: 子时排法，ZiHourLate或ZiHourEarly，为空时按晚子时
+//
+// 返回值：时柱，如"甲子时"；日干无法识别时返回空字符串
+func hourPillar(日干 string, hour int, ziHour string) string {
+	日干索引 := indexOf(天干列表, 日干)
+	if 日干索引 < 0 {
+		return ""
+	}
+	if hour >= 23 && ziHour != ZiHourEarly {
+		日干索引 = (日干索引 + 1) % 10
+	}
+
+	地支索引 := hourBranchIndex(hour)
+	时干索引 := ((日干索引%5)*2 + 地支索引) % 10
+	return 天干列表[时干索引] + 地支[地支索引] + "时"
+}
+
+// dayPillarDate 返回排日柱所用的日期
+// 按子初换日排时，23点之后的日柱取次日；晚子时和其他钟点取当日
+func dayPillarDate(moment time.Time, ziHour string) time.Time {
+	if ziHour == ZiHourEarly && moment.Hour() >= 23 {
+		return moment.AddDate(0, 0, 1)
+	}
+	return moment
+}
+
+// ziHourUsed 返回排某一时刻的四柱所用的子时排法
+// 只有23点之后两种排法才有区别，其余钟点返回空字符串
+func ziHourUsed(moment time.Time, ziHour string) string {
+	if moment.Hour() < 23 {
+		return ""
+	}
+	if ziHour == "" {
+		return ZiHourLate
+	}
+	return ziHour
+}
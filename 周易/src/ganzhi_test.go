@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestZiHourPillars 23:30按两种子时排法排出的日柱和时柱，零点之后两种排法一致
+// 2025-01-01为庚午日、2025-01-02为辛未日，子时（23:00-00:59）的时柱都是戊子时
+func TestZiHourPillars(t *testing.T) {
+	// 换用不落盘的缓存并写入两日的万年历数据，不调用外部API
+	calendarCacheOnce.Do(func() {})
+	saved := calendarCache
+	calendarCache = NewCalendarCache("", 0)
+	defer func() { calendarCache = saved }()
+	calendarCache.Put(calendarCacheKey(2025, 1, 1), CalendarAPIResponse{Code: 200, Ganzhinian: "甲辰年", Ganzhiyue: "丙子月", Ganzhiri: "庚午日"})
+	calendarCache.Put(calendarCacheKey(2025, 1, 2), CalendarAPIResponse{Code: 200, Ganzhinian: "甲辰年", Ganzhiyue: "丙子月", Ganzhiri: "辛未日"})
+
+	location := time.FixedZone("CST", 8*3600)
+	tests := []struct {
+		name     string
+		moment   time.Time
+		ziHour   string
+		wantDay  string
+		wantHour string
+		wantUsed string
+	}{
+		{"晚子时", time.Date(2025, 1, 1, 23, 30, 0, 0, location), ZiHourLate, "庚午日", "戊子时", ZiHourLate},
+		{"未配置按晚子时", time.Date(2025, 1, 1, 23, 30, 0, 0, location), "", "庚午日", "戊子时", ZiHourLate},
+		{"子初换日", time.Date(2025, 1, 1, 23, 30, 0, 0, location), ZiHourEarly, "辛未日", "戊子时", ZiHourEarly},
+		{"晚子时零点后", time.Date(2025, 1, 2, 0, 30, 0, 0, location), ZiHourLate, "辛未日", "戊子时", ""},
+		{"子初换日零点后", time.Date(2025, 1, 2, 0, 30, 0, 0, location), ZiHourEarly, "辛未日", "戊子时", ""},
+		{"子初换日亥时", time.Date(2025, 1, 1, 22, 30, 0, 0, location), ZiHourEarly, "庚午日", "丁亥时", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ganzhiri, ganzhinian, ganzhiyue, err := getRiGanAndCalendarInfoAt(tt.moment, tt.ziHour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ganzhinian != "甲辰年" || ganzhiyue != "丙子月" {
+				t.Errorf("年月柱为 %s %s，期望 甲辰年 丙子月", ganzhinian, ganzhiyue)
+			}
+			if ganzhiri != tt.wantDay {
+				t.Errorf("日柱为 %s，期望 %s", ganzhiri, tt.wantDay)
+			}
+			if hour := hourPillar(extractRiGan(ganzhiri), tt.moment.Hour(), tt.ziHour); hour != tt.wantHour {
+				t.Errorf("时柱为 %s，期望 %s", hour, tt.wantHour)
+			}
+			if used := ziHourUsed(tt.moment, tt.ziHour); used != tt.wantUsed {
+				t.Errorf("子时排法为 %q，期望 %q", used, tt.wantUsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,419 @@
+// golden.go 实现卦象图的金图比对
+// 用固定的卦象、固定的四柱和只含内嵌字体的回退链渲染一组盘面，与提交在golden目录中的基准图逐像素比较。
+// 比较在YIQ色彩空间中进行，并容忍抗锯齿造成的1像素偏差，差异像素占比超过容差即判为失败，
+// 失败时生成HTML报告，并排展示基准图、当前渲染和差异图。
+// 修改排版后确认效果无误，用 update-golden 命令重新生成基准图
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// goldenCase 一个金图比对用例
+type goldenCase struct {
+	Name   string           // 用例名称，同时是基准图的文件名
+	Theme  string           // 内置主题名称
+	Layout string           // 版式名称
+	Chart  func() *GuaChart // 盘面数据
+}
+
+// goldenCases 全部金图用例，覆盖四种内置主题、四种版式、无动爻和六爻皆动的盘面，以及英文输出
+var goldenCases = []goldenCase{
+	{Name: "classic-landscape", Theme: ThemeClassic, Layout: LayoutLandscape, Chart: benchmarkChart},
+	{Name: "classic-portrait", Theme: ThemeClassic, Layout: LayoutPortrait, Chart: benchmarkChart},
+	{Name: "classic-square", Theme: ThemeClassic, Layout: LayoutSquare, Chart: benchmarkChart},
+	{Name: "classic-wide", Theme: ThemeClassic, Layout: LayoutWide, Chart: benchmarkChart},
+	{Name: "dark-landscape", Theme: "dark", Layout: LayoutLandscape, Chart: benchmarkChart},
+	{Name: "print-landscape", Theme: "print", Layout: LayoutLandscape, Chart: benchmarkChart},
+	{Name: "minimal-landscape", Theme: "minimal", Layout: LayoutLandscape, Chart: benchmarkChart},
+	{Name: "classic-landscape-static", Theme: ThemeClassic, Layout: LayoutLandscape, Chart: goldenStaticChart},
+	{Name: "classic-portrait-all-moving", Theme: ThemeClassic, Layout: LayoutPortrait, Chart: goldenAllMovingChart},
+	{Name: "classic-landscape-en", Theme: ThemeClassic, Layout: LayoutLandscape, Chart: goldenEnglishChart},
+}
+
+// goldenStaticChart 无动爻的固定盘面，只显示本卦
+func goldenStaticChart() *GuaChart {
+	chart := benchmarkChart()
+	chart.BianGua = append([]int(nil), chart.BenGua...)
+	chart.DongYao = make([]bool, 6)
+	chart.HasDongYao = false
+	chart.BianGuaName = chart.BenGuaName
+	return chart
+}
+
+// goldenAllMovingChart 六爻皆动的固定盘面：乾卦全部老阳，变为坤卦
+func goldenAllMovingChart() *GuaChart {
+	chart := benchmarkChart()
+	chart.BenGua = []int{1, 1, 1, 1, 1, 1}
+	chart.BianGua = []int{0, 0, 0, 0, 0, 0}
+	chart.DongYao = []bool{true, true, true, true, true, true}
+	chart.HasDongYao = true
+	chart.BenGuaName = guaToName(chart.BenGua)
+	chart.BianGuaName = guaToName(chart.BianGua)
+	return chart
+}
+
+// goldenEnglishChart 英文输出的固定盘面，覆盖英文标签和西文字体的排版
+func goldenEnglishChart() *GuaChart {
+	chart := benchmarkChart()
+	chart.Locale = LocaleEn
+	return chart
+}
+
+// goldenTheme 返回用例使用的内置主题
+// 不读取配置和主题目录，背景图替换为主题的渐变色，避免本机的背景图和自定义主题影响结果
+func goldenTheme(name string) (*Theme, error) {
+	builtins := make(map[string]Theme)
+	for _, theme := range builtinThemes() {
+		builtins[theme.Name] = theme
+	}
+	theme, ok := builtins[name]
+	if !ok {
+		return nil, fmt.Errorf("内置主题不存在: %s", name)
+	}
+	if base, ok := builtins[theme.Base]; ok && theme.Base != "" {
+		mergeTheme(&theme, &base)
+	}
+
+	// 改名以免与正常渲染共用背景缓存
+	theme.Name = "golden_" + name
+	theme.Background.Image = ""
+	theme.Font.File = ""
+	if err := theme.resolve(); err != nil {
+		return nil, err
+	}
+	return &theme, nil
+}
+
+// goldenTemplate 返回用例使用的内置版式模板
+// 不读取模板目录和配置，避免同名的自定义模板影响结果
+func goldenTemplate(name string) (*ChartTemplate, error) {
+	builtins := make(map[string]ChartTemplate)
+	for _, tpl := range builtinTemplates() {
+		builtins[tpl.Name] = tpl
+	}
+	tpl, ok := builtins[name]
+	if !ok {
+		return nil, fmt.Errorf("内置版式不存在: %s", name)
+	}
+	if base, ok := builtins[tpl.Base]; ok && tpl.Base != "" {
+		mergeTemplate(&tpl, &base)
+	}
+	return &tpl, nil
+}
+
+// renderGolden 按用例渲染盘面，字体只使用内嵌字体和Go Regular
+func renderGolden(gc goldenCase, chain *fontChain) (*image.NRGBA, error) {
+	theme, err := goldenTheme(gc.Theme)
+	if err != nil {
+		return nil, err
+	}
+	tpl, err := goldenTemplate(gc.Layout)
+	if err != nil {
+		return nil, err
+	}
+	theme = tpl.applyFonts(theme)
+	faces, err := createFontFaces(chain, theme)
+	if err != nil {
+		return nil, err
+	}
+	defer faces.Close()
+
+	chart := gc.Chart()
+	layout := computeLayout(tpl, chart, theme, faces)
+	dst := getBackground(theme, layout.宽度, layout.高度)
+	if err := drawGuaImage(newRasterCanvas(dst, faces, theme), layout, chart); err != nil {
+		return nil, fmt.Errorf("绘制卦象图像失败: %v", err)
+	}
+	return dst, nil
+}
+
+// selectGoldenCases 按名称筛选用例，名称为空时返回全部用例
+func selectGoldenCases(name string) ([]goldenCase, error) {
+	if name == "" {
+		return goldenCases, nil
+	}
+	for _, gc := range goldenCases {
+		if gc.Name == name {
+			return []goldenCase{gc}, nil
+		}
+	}
+	return nil, fmt.Errorf("金图用例不存在: %s", name)
+}
+
+// updateGoldenImages 重新渲染用例并覆盖基准图
+//
+// 返回值：写入的基准图路径
+func updateGoldenImages(dir string, cases []goldenCase) ([]string, error) {
+	chain, err := embeddedFontChain()
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureDir(dir); err != nil {
+		return nil, fmt.Errorf("创建基准图目录失败: %v", err)
+	}
+
+	var written []string
+	for _, gc := range cases {
+		img, err := renderGolden(gc, chain)
+		if err != nil {
+			return written, fmt.Errorf("渲染用例 %s 失败: %v", gc.Name, err)
+		}
+		path := filepath.Join(dir, gc.Name+".png")
+		if err := writePNGFile(path, img); err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// goldenResult 一个用例的比对结果
+type goldenResult struct {
+	Name       string  // 用例名称
+	Passed     bool    // 是否通过
+	Message    string  // 失败原因或差异概况
+	Mismatched int     // 差异像素数
+	Ratio      float64 // 差异像素占比
+	Expected   string  // 报告中基准图的相对路径
+	Actual     string  // 报告中当前渲染的相对路径
+	Diff       string  // 报告中差异图的相对路径
+}
+
+// 默认比对参数，check-golden命令和TestGolden共用
+const (
+	defaultGoldenThreshold = 0.1   // 单个像素的默认色差阈值
+	defaultGoldenTolerance = 0.001 // 默认允许的差异像素占比
+)
+
+// goldenOptions 比对参数
+type goldenOptions struct {
+	Threshold float64 // 单个像素的色差阈值（0-1），超过即视为不同
+	Tolerance float64 // 允许的差异像素占比，超过即判为失败
+	ReportDir string  // HTML报告目录，有失败用例时写入
+}
+
+// checkGoldenImages 渲染用例并与基准图比对，有失败用例时生成HTML报告
+//
+// 返回值：每个用例的比对结果；渲染或读写文件出错时返回错误
+func checkGoldenImages(dir string, cases []goldenCase, opts goldenOptions) ([]goldenResult, error) {
+	chain, err := embeddedFontChain()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]goldenResult, 0, len(cases))
+	failedImages := make(map[string][3]image.Image)
+	for _, gc := range cases {
+		actual, err := renderGolden(gc, chain)
+		if err != nil {
+			return nil, fmt.Errorf("渲染用例 %s 失败: %v", gc.Name, err)
+		}
+
+		result := goldenResult{Name: gc.Name}
+		expected, err := readPNGFile(filepath.Join(dir, gc.Name+".png"))
+		switch {
+		case err != nil:
+			result.Message = fmt.Sprintf("读取基准图失败: %v（可用 update-golden 生成）", err)
+			failedImages[gc.Name] = [3]image.Image{nil, actual, nil}
+		case expected.Bounds().Size() != actual.Bounds().Size():
+			result.Message = fmt.Sprintf("尺寸不同：基准图 %v，当前 %v", expected.Bounds().Size(), actual.Bounds().Size())
+			failedImages[gc.Name] = [3]image.Image{expected, actual, nil}
+		default:
+			mismatched, diff := compareImages(expected, actual, opts.Threshold)
+			result.Mismatched = mismatched
+			result.Ratio = float64(mismatched) / float64(actual.Bounds().Dx()*actual.Bounds().Dy())
+			result.Passed = result.Ratio <= opts.Tolerance
+			result.Message = fmt.Sprintf("差异像素 %d（%.4f%%，容差 %.4f%%）", mismatched, result.Ratio*100, opts.Tolerance*100)
+			if !result.Passed {
+				failedImages[gc.Name] = [3]image.Image{expected, actual, diff}
+			}
+		}
+		results = append(results, result)
+	}
+
+	if len(failedImages) > 0 && opts.ReportDir != "" {
+		if err := writeGoldenReport(opts.ReportDir, results, failedImages); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// compareImages 比较两张同尺寸图像，返回差异像素数和差异图
+// 色差在YIQ空间按亮度和色度加权计算；若某像素在另一张图的3×3邻域内有相近的像素，
+// 视为抗锯齿或亚像素偏移，不计入差异。差异图以淡化的基准图为底，差异像素标红，被容忍的像素标黄
+func compareImages(expected, actual image.Image, threshold float64) (int, *image.NRGBA) {
+	bounds := expected.Bounds()
+	offset := actual.Bounds().Min.Sub(bounds.Min)
+	diff := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+
+	// 35215为YIQ色差的最大值，阈值按其比例换算
+	maxDelta := 35215 * threshold * threshold
+	mismatched := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			e := expected.At(x, y)
+			a := actual.At(x+offset.X, y+offset.Y)
+			dx, dy := x-bounds.Min.X, y-bounds.Min.Y
+
+			if colorDelta(e, a) <= maxDelta {
+				// 相同的像素以淡化的灰度显示
+				gray := color.GrayModel.Convert(e).(color.Gray).Y
+				faded := uint8(255 - (255-int(gray))/10)
+				diff.SetNRGBA(dx, dy, color.NRGBA{faded, faded, faded, 255})
+				continue
+			}
+			if hasSimilarNeighbor(actual, x+offset.X, y+offset.Y, e, maxDelta) &&
+				hasSimilarNeighbor(expected, x, y, a, maxDelta) {
+				diff.SetNRGBA(dx, dy, color.NRGBA{255, 200, 0, 255})
+				continue
+			}
+			mismatched++
+			diff.SetNRGBA(dx, dy, color.NRGBA{255, 0, 0, 255})
+		}
+	}
+	return mismatched, diff
+}
+
+// hasSimilarNeighbor 判断img在(x, y)的3×3邻域内是否有与c相近的像素
+func hasSimilarNeighbor(img image.Image, x, y int, c color.Color, maxDelta float64) bool {
+	bounds := img.Bounds()
+	for ny := y - 1; ny <= y+1; ny++ {
+		for nx := x - 1; nx <= x+1; nx++ {
+			if (nx == x && ny == y) || !image.Pt(nx, ny).In(bounds) {
+				continue
+			}
+			if colorDelta(img.At(nx, ny), c) <= maxDelta {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// colorDelta 计算两种颜色在YIQ空间中的加权色差平方，先与白色背景混合去除透明度
+func colorDelta(c1, c2 color.Color) float64 {
+	r1, g1, b1 := blendWhite(c1)
+	r2, g2, b2 := blendWhite(c2)
+	dr, dg, db := r1-r2, g1-g2, b1-b2
+
+	y := dr*0.29889531 + dg*0.58662247 + db*0.11448223
+	i := dr*0.59597799 - dg*0.27417610 - db*0.32180189
+	q := dr*0.21147017 - dg*0.52261711 + db*0.31114694
+	return 0.5053*y*y + 0.299*i*i + 0.1957*q*q
+}
+
+// blendWhite 将颜色与白色背景混合，返回0-255范围的RGB分量
+// color.Color.RGBA返回预乘透明度的分量，直接加上白色背景透出的部分即可
+func blendWhite(c color.Color) (float64, float64, float64) {
+	r, g, b, a := c.RGBA()
+	white := 255 * (1 - float64(a)/0xffff)
+	return float64(r)/0xffff*255 + white, float64(g)/0xffff*255 + white, float64(b)/0xffff*255 + white
+}
+
+// goldenReportTemplate 金图比对的HTML报告
+var goldenReportTemplate = template.Must(template.New("golden").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>卦象图金图比对报告</title>
+<style>
+body { font-family: sans-serif; margin: 20px; background: #fafafa; }
+table.summary { border-collapse: collapse; margin-bottom: 24px; }
+table.summary td, table.summary th { border: 1px solid #ccc; padding: 4px 10px; }
+.passed { color: #2e7d32; } .failed { color: #c62828; font-weight: bold; }
+.case { margin-bottom: 40px; }
+.images { display: flex; gap: 12px; }
+.images figure { margin: 0; flex: 1; }
+.images img { width: 100%; border: 1px solid #ccc; background: #fff; }
+</style>
+</head>
+<body>
+<h1>卦象图金图比对报告</h1>
+<p>生成时间：{{.Generated}}；红色为差异像素，黄色为被容忍的抗锯齿偏差。</p>
+<table class="summary">
+<tr><th>用例</th><th>结果</th><th>说明</th></tr>
+{{range .Results}}<tr><td>{{.Name}}</td>{{if .Passed}}<td class="passed">通过</td>{{else}}<td class="failed">失败</td>{{end}}<td>{{.Message}}</td></tr>
+{{end}}</table>
+{{range .Results}}{{if not .Passed}}<div class="case">
+<h2 class="failed">{{.Name}}</h2>
+<p>{{.Message}}</p>
+<div class="images">
+{{if .Expected}}<figure><img src="{{.Expected}}"><figcaption>基准图</figcaption></figure>{{end}}
+{{if .Actual}}<figure><img src="{{.Actual}}"><figcaption>当前渲染</figcaption></figure>{{end}}
+{{if .Diff}}<figure><img src="{{.Diff}}"><figcaption>差异</figcaption></figure>{{end}}
+</div>
+</div>
+{{end}}{{end}}
+</body>
+</html>
+`))
+
+// writeGoldenReport 写入失败用例的图片和HTML报告
+func writeGoldenReport(dir string, results []goldenResult, failedImages map[string][3]image.Image) error {
+	if err := ensureDir(dir); err != nil {
+		return fmt.Errorf("创建报告目录失败: %v", err)
+	}
+
+	for i := range results {
+		images, failed := failedImages[results[i].Name]
+		if !failed {
+			continue
+		}
+		targets := []*string{&results[i].Expected, &results[i].Actual, &results[i].Diff}
+		for j, suffix := range []string{"expected", "actual", "diff"} {
+			if images[j] == nil {
+				continue
+			}
+			fileName := results[i].Name + "." + suffix + ".png"
+			if err := writePNGFile(filepath.Join(dir, fileName), images[j]); err != nil {
+				return err
+			}
+			*targets[j] = fileName
+		}
+	}
+
+	var buf bytes.Buffer
+	err := goldenReportTemplate.Execute(&buf, map[string]interface{}{
+		"Generated": time.Now().Format("2006-01-02 15:04:05"),
+		"Results":   results,
+	})
+	if err != nil {
+		return fmt.Errorf("生成比对报告失败: %v", err)
+	}
+	return os.WriteFile(filepath.Join(dir, "index.html"), buf.Bytes(), 0644)
+}
+
+// writePNGFile 将图像编码为PNG写入文件
+func writePNGFile(path string, img image.Image) error {
+	data, err := encodePNG(img)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("写入 %s 失败: %v", path, err)
+	}
+	return nil
+}
+
+// readPNGFile 读取并解码PNG文件
+func readPNGFile(path string) (image.Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解码 %s 失败: %v", path, err)
+	}
+	return img, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGolden 渲染全部金图用例并与golden目录中的基准图比对
+// 失败时在 output/golden_report 生成HTML差异报告；确认变化符合预期后用 update-golden 重新生成基准图
+func TestGolden(t *testing.T) {
+	report := filepath.Join("output", "golden_report")
+	results, err := checkGoldenImages("golden", goldenCases, goldenOptions{
+		Threshold: defaultGoldenThreshold,
+		Tolerance: defaultGoldenTolerance,
+		ReportDir: report,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, result := range results {
+		t.Run(result.Name, func(t *testing.T) {
+			if !result.Passed {
+				t.Errorf("与基准图不一致: %s，差异报告: %s", result.Message, filepath.Join(report, "index.html"))
+			}
+		})
+	}
+}
+
+// TestGoldenFontCoverage 检查内嵌字体能否显示卦象图的全部文字，避免基准图中的汉字渲染成方框
+func TestGoldenFontCoverage(t *testing.T) {
+	if !hasEmbeddedCJKFont() {
+		t.Fatal("fonts目录未内嵌中文字体，基准图中的汉字将渲染成方框，见 fonts/README.md")
+	}
+
+	chain, err := embeddedFontChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	theme, err := goldenTheme(ThemeClassic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	faces, err := createFontFaces(chain, theme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer faces.Close()
+
+	missing := chain.missingRunes(chartGlyphText(theme, faces))
+	if len(missing) > 0 {
+		items := make([]string, 0, len(missing))
+		for _, r := range missing {
+			items = append(items, fmt.Sprintf("%c(U+%04X)", r, r))
+		}
+		t.Errorf("内嵌字体缺少 %d 个字符的字形: %s", len(missing), strings.Join(items, "、"))
+	}
+}
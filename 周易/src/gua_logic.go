@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"math/rand"
 )
 
 func init() {
@@ -14,9 +15,8 @@ func init() {
 }
 
 // 模拟摇一次铜钱，返回阴（0）或阳（1），以及是否为变爻
-func yaoQian() (int, bool) {
-	r := getGlobalRand() // 使用优化后的全局随机数生成器
-
+// r为本次起卦独用的随机数生成器，不需要加锁
+func yaoQian(r *rand.Rand) (int, bool) {
 	// 直接计算正面次数，避免循环
 	正面次数 := r.Intn(4) // 0-3之间的随机数，直接模拟三次投掷的结果
 
@@ -46,7 +46,9 @@ func generateTestGua() ([]int, []int, []bool) {
 }
 
 // 生成卦象，优化版本
-func generateGua() ([]int, []int, []bool) {
+// 六次摇卦由seed决定，同一seed总是得到同一卦，图片中记录了seed，可据此复现起卦过程
+func generateGua(seed int64) ([]int, []int, []bool) {
+	r := rand.New(rand.NewSource(seed))
 	本卦 := make([]int, 6)
 	变卦 := make([]int, 6)
 	变爻标记 := make([]bool, 6) // 记录每一爻是否为变爻
@@ -54,7 +56,7 @@ func generateGua() ([]int, []int, []bool) {
 	// 一次性生成所有爻，避免多次设置种子
 	for i := 0; i < 6; i++ {
 		fmt.Printf("生成第%d爻: ", i+1)
-		爻, 是否变爻 := yaoQian()
+		爻, 是否变爻 := yaoQian(r)
 		本卦[i] = 爻
 		变爻标记[i] = 是否变爻
 		// 计算变卦
@@ -97,6 +99,11 @@ func guaToName(gua []int) string {
 	return "未知卦"
 }
 
+// 取互卦：本卦二、三、四爻为下卦，三、四、五爻为上卦
+func huGua(gua []int) []int {
+	return []int{gua[1], gua[2], gua[3], gua[2], gua[3], gua[4]}
+}
+
 // 定世爻
 func dingShiYao(guaGong string) int {
 	return guaGongShiYao[guaGong]
@@ -0,0 +1,390 @@
+// history_store.go 实现占卜历史的持久化存储
+// 每次起卦的结果连同完整盘面追加写入JSON Lines文件，程序启动时读入内存建立索引，
+// 按ID查询和按日期、卦名、用户、类型、分类筛选的分页列表都在内存中完成；
+// 图片被清理后盘面仍保留在历史中，PDF报告据此重新生成
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// HistoryRecord 一次起卦的历史记录
+// 占卜结果的字段展开在记录顶层，另附请求中的用户、群号、类型和渲染参数以及完整盘面
+type HistoryRecord struct {
+	*DivineResult
+	Type     string    `json:"type,omitempty"`     // 占卜类型，如"today"
+	Category string    `json:"category,omitempty"` // 请求中的问事分类，如"事业"
+	Question string    `json:"question,omitempty"` // 所问之事
+	UserID   string    `json:"user_id,omitempty"`  // 起卦用户
+	GroupID  int64     `json:"group_id,omitempty"` // OneBot群号
+	Theme    string    `json:"theme,omitempty"`    // 渲染主题
+	Layout   string    `json:"layout,omitempty"`   // 渲染版式
+	Brand    string    `json:"brand,omitempty"`    // 叠加的品牌
+	Chart    *GuaChart `json:"chart"`              // 完整盘面，含起卦方法和种子
+}
+
+// chartMetadata 将历史记录还原为盘面元数据，用于图片已被清理后重新生成报告
+func (r *HistoryRecord) chartMetadata() *ChartMetadata {
+	return &ChartMetadata{
+		Version:  chartMetadataVersion,
+		ID:       r.ID,
+		Type:     r.Type,
+		Question: r.Question,
+		Theme:    r.Theme,
+		Layout:   r.Layout,
+		Brand:    r.Brand,
+		Chart:    r.Chart,
+	}
+}
+
+// publicRecord 返回链接改为完整地址的记录副本，存储中的记录保持服务内路径
+// withQuestion为false时去掉所问之事，未持访问令牌的请求不能读到他人问了什么；
+// 图片元数据中也写有所问之事，此时一并去掉图片链接，免得开启签名链接后借列表拿到签过名的地址
+func publicRecord(record *HistoryRecord, base string, withQuestion bool) *HistoryRecord {
+	public := *record
+	if !withQuestion {
+		result := *record.DivineResult
+		result.Question = ""
+		result.ImagePath, result.ImageURL, result.ThumbURL = "", "", ""
+		public.DivineResult = &result
+		public.Question = ""
+		return &public
+	}
+	public.DivineResult = publicResult(record.DivineResult, base)
+	return &public
+}
+
+// HistoryFilter 历史列表的筛选条件，零值表示不限
+type HistoryFilter struct {
+	From     string // 起卦日期下限（含），格式YYYY-MM-DD，按起卦地的当地日期比较
+	To       string // 起卦日期上限（含）
+	Gua      string // 本卦或变卦的卦名，如"乾"，全称需先经normalizeGuaName规范
+	UserID   string // 起卦用户
+	GroupID  int64  // OneBot群号
+	Type     string // 占卜类型
+	Category string // 问事分类
+}
+
+// match 判断记录是否满足筛选条件
+func (f *HistoryFilter) match(record *HistoryRecord) bool {
+	if f.From != "" && record.Date < f.From {
+		return false
+	}
+	if f.To != "" && record.Date > f.To {
+		return false
+	}
+	if f.UserID != "" && record.UserID != f.UserID {
+		return false
+	}
+	if f.GroupID != 0 && record.GroupID != f.GroupID {
+		return false
+	}
+	if f.Type != "" && record.Type != f.Type {
+		return false
+	}
+	if f.Category != "" && record.Category != f.Category {
+		return false
+	}
+	if f.Gua != "" && record.BenGua != f.Gua && record.BianGua != f.Gua {
+		return false
+	}
+	return true
+}
+
+// maxCategoryLength 问事分类的最大字数
+const maxCategoryLength = 20
+
+// validateCategory 检查问事分类的长度
+func validateCategory(category string) error {
+	if n := utf8.RuneCountInString(category); n > maxCategoryLength {
+		return fmt.Errorf("问事分类过长: %d 字（最多 %d 字）", n, maxCategoryLength)
+	}
+	return nil
+}
+
+// normalizeGuaName 将卦名或全称（如"乾为天"）规范为卦名，无法识别时返回false
+func normalizeGuaName(name string) (string, bool) {
+	if _, found := guaXiang[name]; found {
+		return name, true
+	}
+	for short, gua := range guaXiang {
+		if gua.FullName == name {
+			return short, true
+		}
+	}
+	return "", false
+}
+
+// HistoryPage 分页列表的一页
+type HistoryPage struct {
+	Items    []*HistoryRecord `json:"items"`     // 本页记录，按起卦先后倒序
+	Total    int              `json:"total"`     // 满足条件的记录总数
+	Page     int              `json:"page"`      // 页码，从1开始
+	PageSize int              `json:"page_size"` // 每页条数
+}
+
+// HistoryStore 占卜历史存储
+// 记录按写入顺序保存在内存中并追加写入文件，所有方法均可并发调用
+type HistoryStore struct {
+	mu         sync.Mutex
+	records    []*HistoryRecord          // 按写入顺序排列
+	byID       map[string]*HistoryRecord // 按占卜ID索引
+	maxRecords int                       // 最多保留的记录数，<=0 表示不限制
+	filePath   string                    // JSON Lines文件路径，为空时仅保存在内存中
+}
+
+// 占卜历史单例
+var (
+	historyStore     *HistoryStore
+	historyStoreOnce sync.Once
+)
+
+// getHistoryStore 获取全局占卜历史存储
+// 首次调用时按配置创建并从文件加载；未启用历史时返回nil
+func getHistoryStore() *HistoryStore {
+	historyStoreOnce.Do(func() {
+		config := GetConfig().History
+		if !config.Enabled {
+			return
+		}
+		historyStore = NewHistoryStore(config.File, config.MaxRecords)
+		if err := historyStore.Load(); err != nil {
+			log.Printf("加载占卜历史失败，将使用空历史: %v", err)
+		}
+	})
+	return historyStore
+}
+
+// NewHistoryStore 创建占卜历史存储
+//
+// 参数：
+//   - filePath: JSON Lines文件路径，为空则不落盘
+//   - maxRecords: 最多保留的记录数，<=0 表示不限制
+//
+// 返回值：新建的存储实例
+func NewHistoryStore(filePath string, maxRecords int) *HistoryStore {
+	return &HistoryStore{
+		byID:       make(map[string]*HistoryRecord),
+		maxRecords: maxRecords,
+		filePath:   filePath,
+	}
+}
+
+// Add 记录一次起卦并追加写入文件
+// 超出最大记录数一成以上时淘汰最早的记录并重写文件，避免每次写入都重写
+func (s *HistoryStore) Add(record *HistoryRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("序列化占卜历史失败: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, record)
+	s.byID[record.ID] = record
+
+	if s.maxRecords > 0 && len(s.records) > s.maxRecords+s.maxRecords/10 {
+		s.trimLocked()
+		return s.rewriteLocked()
+	}
+	return s.appendLocked(line)
+}
+
+// Get 按占卜ID查询记录
+func (s *HistoryStore) Get(id string) (*HistoryRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, found := s.byID[id]
+	return record, found
+}
+
+// List 按筛选条件分页列出记录，最近的记录在前
+//
+// 参数：
+//   - filter: 筛选条件
+//   - page: 页码，从1开始
+//   - pageSize: 每页条数
+//
+// 返回值：本页记录和满足条件的总数
+func (s *HistoryStore) List(filter HistoryFilter, page, pageSize int) *HistoryPage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := &HistoryPage{Items: []*HistoryRecord{}, Page: page, PageSize: pageSize}
+	skip := (page - 1) * pageSize
+	for i := len(s.records) - 1; i >= 0; i-- {
+		if !filter.match(s.records[i]) {
+			continue
+		}
+		if result.Total >= skip && len(result.Items) < pageSize {
+			result.Items = append(result.Items, s.records[i])
+		}
+		result.Total++
+	}
+	return result
+}
+
+// Since 按起卦先后返回指定时刻之后写入的全部记录
+func (s *HistoryStore) Since(t time.Time) []*HistoryRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	start := sort.Search(len(s.records), func(i int) bool { return s.records[i].CreatedAt >= t.Unix() })
+	return append([]*HistoryRecord(nil), s.records[start:]...)
+}
+
+// trimLocked 淘汰超出容量的最早记录，调用方必须持有锁
+func (s *HistoryStore) trimLocked() {
+	if s.maxRecords <= 0 || len(s.records) <= s.maxRecords {
+		return
+	}
+	overflow := len(s.records) - s.maxRecords
+	for _, record := range s.records[:overflow] {
+		delete(s.byID, record.ID)
+	}
+	s.records = append([]*HistoryRecord(nil), s.records[overflow:]...)
+}
+
+// appendLocked 在文件末尾追加一行记录，调用方必须持有锁
+func (s *HistoryStore) appendLocked(line []byte) error {
+	if s.filePath == "" {
+		return nil
+	}
+	if err := ensureDir(filepath.Dir(s.filePath)); err != nil {
+		return fmt.Errorf("创建历史目录失败: %v", err)
+	}
+	file, err := os.OpenFile(s.filePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("打开历史文件失败: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("写入历史文件失败: %v", err)
+	}
+	return nil
+}
+
+// rewriteLocked 将全部记录重写到文件，调用方必须持有锁
+// 先写临时文件再重命名，避免写入中途崩溃导致历史文件损坏
+func (s *HistoryStore) rewriteLocked() error {
+	if s.filePath == "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, record := range s.records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("序列化占卜历史失败: %v", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if err := ensureDir(filepath.Dir(s.filePath)); err != nil {
+		return fmt.Errorf("创建历史目录失败: %v", err)
+	}
+	tmpPath := s.filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("写入历史临时文件失败: %v", err)
+	}
+	if err := os.Rename(tmpPath, s.filePath); err != nil {
+		return fmt.Errorf("替换历史文件失败: %v", err)
+	}
+	return nil
+}
+
+// Load 从文件加载历史记录，文件不存在时视为空历史
+// 无法解析的行（如写入中途崩溃留下的半行）跳过并记录日志
+func (s *HistoryStore) Load() error {
+	if s.filePath == "" || !fileExists(s.filePath) {
+		return nil
+	}
+	file, err := os.Open(s.filePath)
+	if err != nil {
+		return fmt.Errorf("读取历史文件失败: %v", err)
+	}
+	defer file.Close()
+
+	var records []*HistoryRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNo, skipped := 0, 0
+	for scanner.Scan() {
+		lineNo++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var record HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.DivineResult == nil || record.ID == "" {
+			skipped++
+			log.Printf("跳过无法解析的历史记录: %s 第 %d 行", s.filePath, lineNo)
+			continue
+		}
+		records = append(records, &record)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取历史文件失败: %v", err)
+	}
+	// 文件按写入顺序追加，补录的起卦也按写入时刻排序
+	sort.SliceStable(records, func(i, j int) bool { return records[i].CreatedAt < records[j].CreatedAt })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = records
+	s.byID = make(map[string]*HistoryRecord, len(records))
+	for _, record := range records {
+		s.byID[record.ID] = record
+	}
+	s.trimLocked()
+	if skipped > 0 || len(s.records) < len(records) {
+		if err := s.rewriteLocked(); err != nil {
+			return err
+		}
+	}
+	log.Printf("已从 %s 加载 %d 条占卜历史", s.filePath, len(s.records))
+	return nil
+}
+
+// Stats 返回历史存储的使用情况
+func (s *HistoryStore) Stats() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return map[string]interface{}{
+		"records":     len(s.records),
+		"max_records": s.maxRecords,
+		"file":        s.filePath,
+	}
+}
+
+// recordDivination 将一次起卦写入历史，未启用历史时忽略；写入失败只记录日志，不影响占卜结果
+func recordDivination(req *DivineRequest, result *DivineResult, chart *GuaChart, theme, layout, brand string) {
+	store := getHistoryStore()
+	if store == nil {
+		return
+	}
+	stored := *result
+	stored.ImageData = "" // 图片数据已在图片存储和文件中，历史中不保存
+	record := &HistoryRecord{
+		DivineResult: &stored,
+		Type:         req.Type,
+		Category:     req.Category,
+		Question:     req.Question,
+		UserID:       req.UserID,
+		GroupID:      req.GroupID,
+		Theme:        theme,
+		Layout:       layout,
+		Brand:        brand,
+		Chart:        chart,
+	}
+	if err := store.Add(record); err != nil {
+		log.Printf("保存占卜历史失败: %s: %v", result.ID, err)
+	}
+}
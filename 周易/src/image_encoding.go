@@ -0,0 +1,283 @@
+// image_encoding.go 实现卦象图的输出格式、编码和格式协商
+// 位图可编码为PNG、JPEG；格式由请求的format参数指定，
+// 未指定时按Accept请求头协商。编码结果按格式和像素数检查大小，过小说明渲染未完成
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"mime"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 图片输出格式
+const (
+	ImageFormatPNG  = "png"  // 位图，默认格式
+	ImageFormatJPEG = "jpeg" // 有损位图，文件较小，适合带背景图的主题
+	ImageFormatSVG  = "svg"  // 矢量图，供前端缩放和用CSS调整样式
+	ImageFormatGIF  = "gif"  // 起卦过程动画，兼容性最好
+	ImageFormatAPNG = "apng" // 起卦过程动画，全彩，文件扩展名仍为.png
+)
+
+// PNG压缩级别
+const (
+	PNGCompressionDefault = "default" // 标准库默认级别
+	PNGCompressionSpeed   = "speed"   // 最快，文件较大
+	PNGCompressionBest    = "best"    // 最小，编码较慢
+	PNGCompressionNone    = "none"    // 不压缩
+)
+
+// minBytesPerMegapixel 各位图格式每百万像素编码结果的最小字节数
+// 1200×900的PNG下限约为10KB，与早期的固定阈值一致；有损格式压缩率更高，下限相应更低
+var minBytesPerMegapixel = map[string]int{
+	ImageFormatPNG:  9 * 1024,
+	ImageFormatJPEG: 4 * 1024,
+}
+
+// normalizeImageFormat 规范化请求中的图片格式，为空时返回PNG
+func normalizeImageFormat(format string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", ImageFormatPNG:
+		return ImageFormatPNG, nil
+	case ImageFormatJPEG, "jpg":
+		return ImageFormatJPEG, nil
+	case ImageFormatSVG:
+		return ImageFormatSVG, nil
+	case ImageFormatGIF:
+		return ImageFormatGIF, nil
+	case ImageFormatAPNG:
+		return ImageFormatAPNG, nil
+	default:
+		return "", fmt.Errorf("不支持的图片格式: %s（可选 png、jpeg、svg、gif、apng）", format)
+	}
+}
+
+// validateImageQuality 校验请求中的编码质量，0表示使用配置的默认值
+func validateImageQuality(quality int) error {
+	if quality < 0 || quality > 100 {
+		return fmt.Errorf("图片质量无效: %d（取值1-100，0表示使用默认值）", quality)
+	}
+	return nil
+}
+
+// imageContentType 返回图片格式对应的MIME类型
+func imageContentType(format string) string {
+	switch format {
+	case ImageFormatJPEG:
+		return "image/jpeg"
+	case ImageFormatSVG:
+		return "image/svg+xml"
+	case ImageFormatGIF:
+		return "image/gif"
+	case ImageFormatAPNG:
+		return "image/apng"
+	default:
+		return "image/png"
+	}
+}
+
+// imageFormatFromContentType 由MIME类型反查图片格式，未知类型返回空字符串
+func imageFormatFromContentType(contentType string) string {
+	for _, format := range []string{ImageFormatPNG, ImageFormatJPEG, ImageFormatSVG, ImageFormatGIF, ImageFormatAPNG} {
+		if imageContentType(format) == contentType {
+			return format
+		}
+	}
+	return ""
+}
+
+// isAnimatedFormat 判断图片格式是否为起卦动画
+func isAnimatedFormat(format string) bool {
+	return format == ImageFormatGIF || format == ImageFormatAPNG
+}
+
+// isRasterFormat 判断图片格式是否为可相互转换的静态位图
+func isRasterFormat(format string) bool {
+	_, ok := minBytesPerMegapixel[format]
+	return ok
+}
+
+// imageFileExt 返回图片格式对应的文件扩展名，不含点
+// APNG使用.png扩展名，不支持动画的查看器也能显示其第一帧
+func imageFileExt(format string) string {
+	switch format {
+	case ImageFormatAPNG:
+		return "png"
+	case ImageFormatJPEG:
+		return "jpg"
+	default:
+		return format
+	}
+}
+
+// encodeRaster 将图像编码为指定的位图格式
+//
+// 参数：
+//   - format: ImageFormatPNG或ImageFormatJPEG
+//   - img: 待编码的图像
+//   - quality: JPEG的编码质量（1-100），0表示使用配置 render.quality
+//
+// 返回值：编码后的数据；编码结果小于该格式的下限时返回错误
+func encodeRaster(format string, img image.Image, quality int) ([]byte, error) {
+	if quality <= 0 {
+		quality = GetConfig().Render.Quality
+	}
+
+	var data []byte
+	var err error
+	switch format {
+	case ImageFormatJPEG:
+		data, err = encodeJPEG(img, quality)
+	default:
+		data, err = encodePNG(img)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkEncodedSize(format, len(data), img.Bounds()); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// encodePNG 按配置的压缩级别将图像编码为PNG字节
+func encodePNG(img image.Image) ([]byte, error) {
+	encoder := png.Encoder{CompressionLevel: pngCompressionLevel(GetConfig().Render.PNGCompression)}
+	var buf bytes.Buffer
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("编码PNG失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeJPEG 按质量将图像编码为JPEG字节
+func encodeJPEG(img image.Image, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("编码JPEG失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// pngCompressionLevel 将配置中的压缩级别名称转换为标准库的压缩级别
+func pngCompressionLevel(name string) png.CompressionLevel {
+	switch name {
+	case PNGCompressionSpeed:
+		return png.BestSpeed
+	case PNGCompressionBest:
+		return png.BestCompression
+	case PNGCompressionNone:
+		return png.NoCompression
+	default:
+		return png.DefaultCompression
+	}
+}
+
+// checkEncodedSize 检查编码结果的大小是否合理
+// 下限按格式和像素数计算，缩略图的下限相应降低；过小通常说明画面空白或渲染未完成，
+// 返回错误而不是输出损坏的图片
+func checkEncodedSize(format string, size int, bounds image.Rectangle) error {
+	perMegapixel, ok := minBytesPerMegapixel[format]
+	if !ok {
+		return nil
+	}
+	minimum := int(int64(perMegapixel) * int64(bounds.Dx()) * int64(bounds.Dy()) / 1000000)
+	if size < minimum {
+		return fmt.Errorf("生成的%s图片过小，可能未完全渲染: %d bytes（%d×%d 至少 %d bytes）",
+			strings.ToUpper(format), size, bounds.Dx(), bounds.Dy(), minimum)
+	}
+	return nil
+}
+
+// transcodeImage 将已渲染的静态位图转换为另一种位图格式
+//
+// 返回值：转换后的图片，文件名的扩展名随格式更换；源图片或目标格式不是静态位图时返回错误
+func transcodeImage(src *renderedImage, format string, quality int) (*renderedImage, error) {
+	if !isRasterFormat(imageFormatFromContentType(src.ContentType)) || !isRasterFormat(format) {
+		return nil, fmt.Errorf("%s 图片无法转换为 %s", src.ContentType, format)
+	}
+	img, _, err := image.Decode(bytes.NewReader(src.Data))
+	if err != nil {
+		return nil, fmt.Errorf("解码图片失败: %v", err)
+	}
+	data, err := encodeRaster(format, img, quality)
+	if err != nil {
+		return nil, err
+	}
+	// 盘面元数据随图片一起转换
+	if meta, err := extractChartMetadata(src.Data); err == nil {
+		if data, err = embedChartMetadata(format, data, meta); err != nil {
+			return nil, err
+		}
+	}
+
+	fileName := strings.TrimSuffix(src.FileName, filepath.Ext(src.FileName)) + "." + imageFileExt(format)
+	return &renderedImage{Data: data, ContentType: imageContentType(format), FileName: fileName, CreatedAt: time.Now()}, nil
+}
+
+// negotiateImageFormat 按Accept请求头在候选格式中选出客户端最偏好的一种
+// 按q值从高到低选择，q值相同时取candidates中靠前的格式；
+// 精确的MIME类型优先于image/*，image/*优先于*/*
+//
+// 参数：
+//   - accept: Accept请求头
+//   - candidates: 可输出的格式，按服务端偏好排序
+//
+// 返回值：选中的格式；请求头为空或没有可接受的候选格式时返回false
+func negotiateImageFormat(accept string, candidates []string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return "", false
+	}
+
+	// 解析各媒体类型及其q值
+	type acceptRange struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+
+	best, bestQ := "", 0.0
+	for _, format := range candidates {
+		contentType := imageContentType(format)
+		// 取最具体的匹配项的q值
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			level := -1
+			switch r.mediaType {
+			case contentType:
+				level = 2
+			case "image/*":
+				level = 1
+			case "*/*":
+				level = 0
+			}
+			if level > specificity {
+				q, specificity = r.q, level
+			}
+		}
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	return best, bestQ > 0
+}
@@ -11,20 +11,23 @@ import (
 
 	"github.com/disintegration/imaging"
 	"golang.org/x/image/font"
-	"golang.org/x/image/font/opentype"
 	"golang.org/x/image/math/fixed"
 )
 
 // 生成渐变背景 - 优化版本，直接操作像素数据
-func generateGradientBackground(width, height int) *image.NRGBA {
+// 从top到bottom做竖向线性渐变
+func generateGradientBackground(width, height int, top, bottom color.RGBA) *image.NRGBA {
 	// 直接创建NRGBA图像避免额外的内存分配
 	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	lerp := func(from, to uint8, factor float64) uint8 {
+		return uint8(int(from) + int(float64(int(to)-int(from))*factor))
+	}
 	// 添加渐变效果
 	for y := 0; y < height; y++ {
 		factor := float64(y) / float64(height)
-		r := uint8(248 - int(20*factor))
-		g := uint8(240 - int(15*factor))
-		b := uint8(224 - int(10*factor))
+		r := lerp(top.R, bottom.R, factor)
+		g := lerp(top.G, bottom.G, factor)
+		b := lerp(top.B, bottom.B, factor)
 		// 优化:一次填充一整行
 		for x := 0; x < width; x++ {
 			pos := y*img.Stride + x*4
@@ -37,155 +40,78 @@ func generateGradientBackground(width, height int) *image.NRGBA {
 	return img
 }
 
-// 加载或获取缓存的背景图片
-func getBackground(width, height int, bgPath string) *image.NRGBA {
-	cachedBackgroundOnce.Do(func() {
-		// 打印当前状态信息
-		log.Printf("正在加载背景图片，尺寸: %dx%d", width, height)
-		// 创建默认背景（米色）
-		bg := imaging.New(width, height, color.NRGBA{248, 240, 224, 255})
+// 加载或获取缓存的主题背景图片
+// 每个主题和尺寸的背景只生成一次，返回副本供调用方绘制
+func getBackground(theme *Theme, width, height int) *image.NRGBA {
+	cacheKey := fmt.Sprintf("%s_%dx%d", theme.Name, width, height)
 
-		// 可能的背景图路径列表
-		paths := []string{
-			bgPath,
-			"background.png",
-			"images/background.png",
-			"../images/background.png",
-			filepath.Join(getCurrentDir(), "images", "background.png"),
-		}
+	backgroundCacheMu.Lock()
+	bg, exists := backgroundCache[cacheKey]
+	if !exists {
+		bg = loadThemeBackground(theme, width, height)
+		backgroundCache[cacheKey] = bg
+	}
+	backgroundCacheMu.Unlock()
+
+	// 返回背景副本，避免并发修改
+	return imaging.Clone(bg)
+}
 
-		// 确保images目录存在
+// loadThemeBackground 加载主题的背景图片，未配置或加载失败时生成渐变背景
+func loadThemeBackground(theme *Theme, width, height int) *image.NRGBA {
+	// 打印当前状态信息
+	log.Printf("正在加载主题 %s 的背景图片，尺寸: %dx%d", theme.Name, width, height)
+	gradient := func() *image.NRGBA {
+		return generateGradientBackground(width, height, theme.colors.top, theme.colors.bottom)
+	}
+
+	bgPath := theme.Background.Image
+	if bgPath == "" {
+		return gradient()
+	}
+
+	// 可能的背景图路径列表
+	paths := []string{
+		bgPath,
+		filepath.Join("..", bgPath),
+		filepath.Join(getCurrentDir(), bgPath),
+	}
+
+	// 古典主题的默认背景图不存在时，用渐变背景生成一张
+	if theme.Name == ThemeClassic && bgPath == "images/background.png" {
 		imagesDir := filepath.Join(getCurrentDir(), "images")
 		if err := ensureDir(imagesDir); err == nil {
-			// 如果没有找到背景图，创建一个默认的渐变背景
 			defaultBgPath := filepath.Join(imagesDir, "background.png")
 			if !fileExists(defaultBgPath) {
-				defaultBg := generateGradientBackground(width, height)
-				err := imaging.Save(defaultBg, defaultBgPath)
-				if err == nil {
+				if err := imaging.Save(gradient(), defaultBgPath); err == nil {
 					log.Printf("已创建默认背景图: %s", defaultBgPath)
-					// 添加到搜索路径的开头
-					paths = append([]string{defaultBgPath}, paths...)
 				} else {
 					log.Printf("创建默认背景失败: %v", err)
 				}
 			}
 		}
+	}
 
-		// 尝试加载背景图片
-		var loadedBg bool
-		for _, path := range paths {
-			if fileExists(path) {
-				log.Printf("尝试加载背景图: %s", path)
-				bgImage, err := imaging.Open(path)
-				if err == nil {
-					// 调整大小以适应目标尺寸
-					bgImage = imaging.Resize(bgImage, width, height, imaging.Lanczos)
-					bg = imaging.Paste(bg, bgImage, image.Pt(0, 0))
-					log.Printf("成功加载背景图片: %s", path)
-					loadedBg = true
-					break
-				} else {
-					log.Printf("加载背景图片失败: %v", err)
-				}
-			}
-		}
-
-		if !loadedBg {
-			log.Printf("无法加载任何背景图片，使用生成的渐变背景")
-			bg = generateGradientBackground(width, height)
-		}
-
-		cachedBackground = bg
-	})
-
-	// 返回背景副本，避免并发修改
-	return imaging.Clone(cachedBackground)
-}
-
-// 加载字体文件，带缓存
-func loadFontFile() ([]byte, error) {
-	cachedFontBytesOnce.Do(func() {
-		// 可能的字体路径
-		paths := []string{
-			"simkai.ttf",
-			"ttf/simkai.ttf",
-			"../ttf/simkai.ttf",
-			"./ttf/simkai.ttf",
-			filepath.Join(getCurrentDir(), "ttf", "simkai.ttf"),
-			// Windows系统字体
-			"C:\\Windows\\Fonts\\simkai.ttf", // 楷体
-			"C:\\Windows\\Fonts\\simhei.ttf", // 黑体
-			"C:\\Windows\\Fonts\\simsun.ttc", // 宋体
-			// Linux系统字体
-			"/usr/share/fonts/truetype/arphic/ukai.ttc",
-			"/usr/share/fonts/truetype/wqy/wqy-microhei.ttc",
-			// macOS系统字体
-			"/Library/Fonts/Arial Unicode.ttf",
-			"/System/Library/Fonts/PingFang.ttc",
+	// 尝试加载背景图片
+	for _, path := range paths {
+		if !fileExists(path) {
+			continue
 		}
-
-		// 确保ttf目录存在
-		ttfDir := filepath.Join(getCurrentDir(), "ttf")
-		ensureDir(ttfDir)
-
-		log.Printf("正在寻找字体文件...")
-		for _, path := range paths {
-			if fileExists(path) {
-				log.Printf("尝试加载字体: %s", path)
-				data, err := os.ReadFile(path)
-				if err == nil {
-					log.Printf("成功加载字体: %s", path)
-					// 如果加载的是系统字体，复制到项目目录
-					if !strings.Contains(path, getCurrentDir()) {
-						destPath := filepath.Join(ttfDir, "simkai.ttf")
-						if err := os.WriteFile(destPath, data, 0644); err == nil {
-							log.Printf("已将字体复制到: %s", destPath)
-						}
-					}
-					cachedFontBytes = data
-					return
-				}
-			}
+		log.Printf("尝试加载背景图: %s", path)
+		bgImage, err := imaging.Open(path)
+		if err != nil {
+			log.Printf("加载背景图片失败: %v", err)
+			continue
 		}
-	})
-
-	if cachedFontBytes == nil {
-		return nil, fmt.Errorf("找不到可用的字体文件")
-	}
-
-	return cachedFontBytes, nil
-}
-
-// 预初始化布局常量
-func initLayout(imageWidth int, 有动爻 bool) *Layout {
-	var 左卦中心X int
-	var 右卦中心X int
-	var 六神X int
-
-	if 有动爻 {
-		// 有动爻，显示双卦
-		左卦中心X = 300
-		右卦中心X = 800
-		六神X = 140
-	} else {
-		// 无动爻，只显示单卦并居中
-		左卦中心X = imageWidth / 2 // 居中显示
-		右卦中心X = -1             // 不显示右卦
-		六神X = (imageWidth / 2) - 180
+		// 按比例缩放并居中裁切到目标尺寸，竖版和宽屏版式下图案不变形
+		bg := imaging.New(width, height, color.NRGBA{248, 240, 224, 255})
+		bgImage = imaging.Fill(bgImage, width, height, imaging.Center, imaging.Lanczos)
+		log.Printf("成功加载背景图片: %s", path)
+		return imaging.Paste(bg, bgImage, image.Pt(0, 0))
 	}
 
-	return &Layout{
-		六神X:    六神X,
-		左卦中心X:  左卦中心X,
-		右卦中心X:  右卦中心X,
-		基础Y:    300,
-		爻间距:    40,
-		爻高度:    20,
-		爻宽度:    160,
-		文字基线偏移: 10,
-		爻辞Y:    300 + 6*50 + 20,
-	}
+	log.Printf("无法加载主题 %s 的背景图片，使用生成的渐变背景", theme.Name)
+	return gradient()
 }
 
 // 优化后的绘制阳爻函数 - 一次性填充矩形区域
@@ -211,8 +137,8 @@ func drawYangYao(img *image.NRGBA, x, y, width, height int, color color.RGBA) {
 }
 
 // 优化后的绘制阴爻函数 - 一次性填充矩形区域
-func drawYinYao(img *image.NRGBA, x, y, width, height int, color color.RGBA) {
-	gap := width / 3
+// gap为中间空隙的宽度
+func drawYinYao(img *image.NRGBA, x, y, width, height, gap int, color color.RGBA) {
 	leftWidth := (width - gap) / 2
 	rightWidth := width - gap - leftWidth
 
@@ -253,19 +179,29 @@ func drawYinYao(img *image.NRGBA, x, y, width, height int, color color.RGBA) {
 	}
 }
 
+// drawRectOutline 绘制矩形边框，stroke为边框粗细
+func drawRectOutline(img *image.NRGBA, x, y, width, height, stroke int, color color.RGBA) {
+	stroke = min(stroke, width/2, height/2)
+	drawYangYao(img, x, y, width, stroke, color)               // 上边
+	drawYangYao(img, x, y+height-stroke, width, stroke, color) // 下边
+	drawYangYao(img, x, y, stroke, height, color)              // 左边
+	drawYangYao(img, x+width-stroke, y, stroke, height, color) // 右边
+}
+
 // 优化的文本绘制函数
-func drawCachedText(img *image.NRGBA, text string, x, y int, face font.Face) {
-	// 为每个独特的文本创建缓存键
-	cacheKey := fmt.Sprintf("%s_%p", text, face)
+// texts为当前画布的文本缓存，不同画布互不共享，可并发渲染
+func drawCachedText(texts map[string]*TextCache, img *image.NRGBA, text string, x, y int, face font.Face, textColor color.RGBA) {
+	// 为每个独特的文本和颜色创建缓存键
+	cacheKey := fmt.Sprintf("%s_%p_%v", text, face, textColor)
 
 	// 检查缓存
-	cache, exists := textCacheMap[cacheKey]
+	cache, exists := texts[cacheKey]
 	if !exists {
 		// 如果缓存不存在，创建新的
 		width := font.MeasureString(face, text).Round()
 		drawer := &font.Drawer{
 			Dst:  img,
-			Src:  image.NewUniform(color.RGBA{0, 0, 0, 255}), // 黑色
+			Src:  image.NewUniform(textColor),
 			Face: face,
 		}
 		cache = &TextCache{
@@ -275,7 +211,7 @@ func drawCachedText(img *image.NRGBA, text string, x, y int, face font.Face) {
 			Drawer: drawer,
 		}
 		// 存储到缓存
-		textCacheMap[cacheKey] = cache
+		texts[cacheKey] = cache
 	} else {
 		// 更新目标图像，以防图像已变更
 		cache.Drawer.Dst = img
@@ -290,14 +226,14 @@ func drawCachedText(img *image.NRGBA, text string, x, y int, face font.Face) {
 }
 
 // 优化版的居中绘制文本
-func drawCenteredText(img *image.NRGBA, text string, centerX, y int, face font.Face) {
-	cacheKey := fmt.Sprintf("%s_%p", text, face)
-	cache, exists := textCacheMap[cacheKey]
+func drawCenteredText(texts map[string]*TextCache, img *image.NRGBA, text string, centerX, y int, face font.Face, textColor color.RGBA) {
+	cacheKey := fmt.Sprintf("%s_%p_%v", text, face, textColor)
+	cache, exists := texts[cacheKey]
 	if !exists {
 		width := font.MeasureString(face, text).Round()
 		drawer := &font.Drawer{
 			Dst:  img,
-			Src:  image.NewUniform(color.RGBA{0, 0, 0, 255}),
+			Src:  image.NewUniform(textColor),
 			Face: face,
 		}
 		cache = &TextCache{
@@ -306,7 +242,7 @@ func drawCenteredText(img *image.NRGBA, text string, centerX, y int, face font.F
 			Face:   face,
 			Drawer: drawer,
 		}
-		textCacheMap[cacheKey] = cache
+		texts[cacheKey] = cache
 	} else {
 		// 更新目标图像
 		cache.Drawer.Dst = img
@@ -321,8 +257,9 @@ func drawCenteredText(img *image.NRGBA, text string, centerX, y int, face font.F
 }
 
 // 绘制自动换行的文本
-func drawWrappedText(img *image.NRGBA, text string, x, y, maxWidth, lineHeight int, face font.Face) int {
-	words := []rune(text)
+// 中文逐字换行，拉丁字母的单词整体换行，换行处的空格不带到下一行
+func drawWrappedText(canvas chartCanvas, text string, x, y, maxWidth, lineHeight int, style textStyle) int {
+	words := wrapTokens(text)
 	if len(words) == 0 {
 		return y
 	}
@@ -332,15 +269,15 @@ func drawWrappedText(img *image.NRGBA, text string, x, y, maxWidth, lineHeight i
 	currentY := y
 
 	for i := 0; i < len(words); i++ {
-		// 尝试添加一个字符
-		testLine := currentLine + string(words[i])
+		// 尝试添加一个字或单词
+		testLine := currentLine + words[i]
 		// 测量当前行宽度
-		width := font.MeasureString(face, testLine).Round()
+		width := canvas.MeasureText(testLine, style)
 		// 如果超过最大宽度，绘制当前行并换行
 		if width > maxWidth && len(currentLine) > 0 {
-			drawCachedText(img, currentLine, x, currentY, face)
+			canvas.DrawText(currentLine, x, currentY, style)
 			currentY += lineHeight
-			currentLine = string(words[i])
+			currentLine = strings.TrimLeft(words[i], " ")
 		} else {
 			currentLine = testLine
 		}
@@ -348,7 +285,7 @@ func drawWrappedText(img *image.NRGBA, text string, x, y, maxWidth, lineHeight i
 
 	// 绘制最后一行
 	if len(currentLine) > 0 {
-		drawCachedText(img, currentLine, x, currentY, face)
+		canvas.DrawText(currentLine, x, currentY, style)
 		currentY += lineHeight
 	}
 
@@ -356,9 +293,29 @@ func drawWrappedText(img *image.NRGBA, text string, x, y, maxWidth, lineHeight i
 	return currentY
 }
 
-// 保存图像到指定路径 - 修正版本，确保图片完全写入
-func saveImageToPathFixed(img *image.NRGBA, fileName string) (string, error) {
-	// 优先保存到photos目录
+// wrapTokens 把文本拆成换行的最小单位：每个中文字符、每个空格和每个连续的拉丁字符串各为一个单位
+func wrapTokens(text string) []string {
+	var tokens []string
+	word := ""
+	for _, r := range text {
+		if r != ' ' && r < 0x2E80 {
+			word += string(r)
+			continue
+		}
+		if word != "" {
+			tokens = append(tokens, word)
+			word = ""
+		}
+		tokens = append(tokens, string(r))
+	}
+	if word != "" {
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
+
+// imageOutputDir 返回图片保存目录，优先使用photos，创建失败时退回output
+func imageOutputDir() (string, error) {
 	photosDir := filepath.Join(getCurrentDir(), "photos")
 	if err := ensureDir(photosDir); err != nil {
 		// 如果photos目录创建失败，尝试output目录
@@ -368,84 +325,71 @@ func saveImageToPathFixed(img *image.NRGBA, fileName string) (string, error) {
 		}
 		photosDir = outputDir
 	}
+	return photosDir, nil
+}
+
+// saveImageData 将编码好的图片写入图片目录，确保图片完全写入
+//
+// 参数：
+//   - data: 编码后的图片数据
+//   - fileName: 文件名，含扩展名
+//
+// 返回值：用于HTTP访问的相对路径，如"photos/卜卦_20250101143000_123456789_1a2b3c4d.png"
+func saveImageData(data []byte, fileName string) (string, error) {
+	// 优先保存到photos目录
+	photosDir, err := imageOutputDir()
+	if err != nil {
+		return "", err
+	}
 
 	// 完整的文件系统路径
 	fullPath := filepath.Join(photosDir, fileName)
 
 	// 保存图像
 	log.Printf("正在保存图像到: %s", fullPath)
-	err := imaging.Save(img, fullPath)
-	if err != nil {
+	if err := os.WriteFile(fullPath, data, 0644); err != nil {
 		return "", fmt.Errorf("保存图像失败: %v", err)
 	}
 
-	// 确保文件完全写入磁盘
-	// 检查文件是否存在且大小合理
-	if !fileExists(fullPath) {
-		return "", fmt.Errorf("图片保存后文件不存在: %s", fullPath)
-	}
-
-	// 获取文件信息，确保文件大小合理
+	// 确保文件完全写入磁盘，大小与编码结果一致
 	fileInfo, err := os.Stat(fullPath)
 	if err != nil {
 		return "", fmt.Errorf("无法获取保存文件信息: %v", err)
 	}
-
-	// 检查文件大小是否合理（至少10KB，避免空文件或损坏文件）
-	if fileInfo.Size() < 10*1024 {
+	if fileInfo.Size() != int64(len(data)) {
 		// 删除可能损坏的文件
 		os.Remove(fullPath)
-		return "", fmt.Errorf("生成的图片文件过小，可能未完全渲染: %d bytes", fileInfo.Size())
+		return "", fmt.Errorf("图片文件写入不完整: %d/%d bytes", fileInfo.Size(), len(data))
 	}
 
 	log.Printf("图片保存成功，文件大小: %d bytes", fileInfo.Size())
 
 	// 返回用于HTTP访问的相对路径
 	// 确定是保存在photos还是output目录
-	baseDir := filepath.Base(photosDir)
-	relativePath := baseDir + "/" + fileName
-
-	log.Printf("返回相对路径: %s", relativePath)
-	return relativePath, nil
+	return filepath.Base(photosDir) + "/" + fileName, nil
 }
 
 // 创建字体面
-func createFontFaces(fontBytes []byte) (font.Face, font.Face, font.Face, error) {
-	f, err := opentype.Parse(fontBytes)
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("解析字体文件失败: %v", err)
-	}
-
-	// 创建各种大小的字体
-	titleFace, err := opentype.NewFace(f, &opentype.FaceOptions{
-		Size:    36, // 标题大号字体
-		DPI:     78,
-		Hinting: font.HintingFull,
-	})
+// 字号取自主题，以像素为单位（DPI为72时磅值即像素值）
+// 每种字号的字体面都按回退链逐字选择字体，创建出的字体面只能由一个goroutine使用
+func createFontFaces(chain *fontChain, theme *Theme) (*chartFaces, error) {
+	titleFace, err := chain.newFace(theme.fontSize(textTitle))
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("创建标题字体失败: %v", err)
+		return nil, fmt.Errorf("创建标题字体失败: %v", err)
 	}
 
-	normalFace, err := opentype.NewFace(f, &opentype.FaceOptions{
-		Size:    30, // 增大正常字体确保可见性
-		DPI:     72,
-		Hinting: font.HintingFull,
-	})
+	normalFace, err := chain.newFace(theme.fontSize(textNormal))
 	if err != nil {
 		titleFace.Close()
-		return nil, nil, nil, fmt.Errorf("创建正常字体失败: %v", err)
+		return nil, fmt.Errorf("创建正常字体失败: %v", err)
 	}
 
-	smallFace, err := opentype.NewFace(f, &opentype.FaceOptions{
-		Size:    26, // 增大小号字体
-		DPI:     72,
-		Hinting: font.HintingFull,
-	})
+	smallFace, err := chain.newFace(theme.fontSize(textSmall))
 	if err != nil {
 		titleFace.Close()
 		normalFace.Close()
-		return nil, nil, nil, fmt.Errorf("创建小号字体失败: %v", err)
+		return nil, fmt.Errorf("创建小号字体失败: %v", err)
 	}
 
-	return titleFace, normalFace, smallFace, nil
+	return &chartFaces{Title: titleFace, Normal: normalFace, Small: smallFace}, nil
 }
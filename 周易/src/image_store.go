@@ -0,0 +1,225 @@
+// image_store.go 实现卦象图的内存存储
+// 卦象图渲染后以编码好的字节按占卜ID保存在内存中，由 GET /api/v1/divine/{id}/image 直接输出，
+// 客户端无需经过本机文件路径取图；超出容量或过期的图片被淘汰，
+// 开启落盘时被淘汰的图片仍可按ID从图片目录读取
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// renderedImage 一张编码完成的卦象图
+type renderedImage struct {
+	Data        []byte         // 编码后的图片数据
+	ContentType string         // MIME类型，如"image/png"
+	FileName    string         // 落盘使用的文件名，不含目录
+	CreatedAt   time.Time      // 渲染完成的时间
+	Meta        *ChartMetadata // 盘面元数据，用于生成报告；GIF等图片本身不含元数据，需从此处取得
+}
+
+// ImageStore 按占卜ID保存卦象图的有界内存存储
+// 图片生成后不再修改，按写入顺序淘汰最早的条目，所有方法均可并发调用
+type ImageStore struct {
+	mu         sync.Mutex
+	entries    map[string]*renderedImage
+	order      []string      // 按写入顺序排列的ID，用于淘汰
+	maxEntries int           // 最大图片数，<=0 表示不限制
+	ttl        time.Duration // 图片保留时长，<=0 表示不过期
+
+	hits      atomic.Int64 // 从内存中取到图片的次数
+	misses    atomic.Int64 // 内存中没有该图片的次数
+	evictions atomic.Int64 // 因超出容量或过期被淘汰的图片数
+}
+
+// 卦象图存储单例
+var (
+	imageStore     *ImageStore
+	imageStoreOnce sync.Once
+)
+
+// getImageStore 获取全局卦象图存储，首次调用时按配置创建
+func getImageStore() *ImageStore {
+	imageStoreOnce.Do(func() {
+		config := GetConfig()
+		imageStore = NewImageStore(config.Render.ImageCacheSize, time.Duration(config.Render.ImageCacheMinutes)*time.Minute)
+	})
+	return imageStore
+}
+
+// NewImageStore 创建卦象图存储
+//
+// 参数：
+//   - maxEntries: 最大图片数，<=0 表示不限制
+//   - ttl: 图片保留时长，<=0 表示不过期
+//
+// 返回值：新建的存储实例
+func NewImageStore(maxEntries int, ttl time.Duration) *ImageStore {
+	return &ImageStore{
+		entries:    make(map[string]*renderedImage),
+		maxEntries: maxEntries,
+		ttl:        ttl,
+	}
+}
+
+// Put 保存一张图片，必要时淘汰最早写入或已过期的图片
+func (s *ImageStore) Put(id string, img *renderedImage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.entries[id]; !exists {
+		s.order = append(s.order, id)
+	}
+	s.entries[id] = img
+	s.evictLocked(time.Now())
+}
+
+// Get 按占卜ID取出图片
+//
+// 返回值：
+//   - *renderedImage: 图片数据，调用方不得修改
+//   - bool: 内存中是否存在且未过期
+func (s *ImageStore) Get(id string) (*renderedImage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	img, found := s.entries[id]
+	if !found || s.expired(img, time.Now()) {
+		s.misses.Add(1)
+		return nil, false
+	}
+	s.hits.Add(1)
+	return img, true
+}
+
+// expired 判断图片是否已超过保留时长
+func (s *ImageStore) expired(img *renderedImage, now time.Time) bool {
+	return s.ttl > 0 && now.Sub(img.CreatedAt) > s.ttl
+}
+
+// evictLocked 淘汰过期和超出容量的图片，调用方必须持有锁
+// order按写入时间递增，从队首开始淘汰即可
+func (s *ImageStore) evictLocked(now time.Time) {
+	for len(s.order) > 0 {
+		id := s.order[0]
+		overflow := s.maxEntries > 0 && len(s.order) > s.maxEntries
+		if !overflow && !s.expired(s.entries[id], now) {
+			break
+		}
+		delete(s.entries, id)
+		s.order = s.order[1:]
+		s.evictions.Add(1)
+	}
+}
+
+// Stats 返回存储的使用情况
+func (s *ImageStore) Stats() map[string]interface{} {
+	s.mu.Lock()
+	size := len(s.entries)
+	s.mu.Unlock()
+
+	return map[string]interface{}{
+		"entries":     size,
+		"max_entries": s.maxEntries,
+		"ttl_minutes": int(s.ttl / time.Minute),
+		"hits":        s.hits.Load(),
+		"misses":      s.misses.Load(),
+		"evictions":   s.evictions.Load(),
+	}
+}
+
+// divineIDSuffixBytes 占卜ID随机后缀的字节数，写作十六进制为8位
+const divineIDSuffixBytes = 4
+
+// newDivineID 根据生成时刻创建占卜ID，格式：divine_<Unix纳秒>_<8位十六进制随机数>
+// 时钟精度不足或多个请求恰在同一纳秒时，随机后缀保证ID不重复，也使ID无法由时刻推算
+func newDivineID(t time.Time) string {
+	suffix := make([]byte, divineIDSuffixBytes)
+	if _, err := rand.Read(suffix); err != nil {
+		log.Fatalf("生成占卜ID失败: %v", err)
+	}
+	return fmt.Sprintf("divine_%d_%s", t.UnixNano(), hex.EncodeToString(suffix))
+}
+
+// imageBaseName 返回占卜ID对应的图片文件名（不含扩展名）
+// 文件名由ID中的时刻和随机后缀推出，同一纳秒内的多次起卦也不会互相覆盖；
+// 旧版本不带后缀的ID仍可解析，对应的文件名也不带后缀
+//
+// 返回值：文件名，如"卜卦_20250101143000_123456789_1a2b3c4d"；ID格式无效时返回错误
+func imageBaseName(id string) (string, error) {
+	rest, found := strings.CutPrefix(id, "divine_")
+	nanosText, suffix, hasSuffix := strings.Cut(rest, "_")
+	nanos, err := strconv.ParseInt(nanosText, 10, 64)
+	if !found || err != nil || (hasSuffix && !isDivineIDSuffix(suffix)) {
+		return "", fmt.Errorf("无效的占卜ID: %s", id)
+	}
+	t := time.Unix(0, nanos)
+	baseName := fmt.Sprintf("卜卦_%s_%09d", t.Format("20060102150405"), t.Nanosecond())
+	if hasSuffix {
+		baseName += "_" + suffix
+	}
+	return baseName, nil
+}
+
+// isDivineIDSuffix 判断是否为newDivineID生成的随机后缀：8位小写十六进制
+func isDivineIDSuffix(suffix string) bool {
+	if len(suffix) != hex.EncodedLen(divineIDSuffixBytes) {
+		return false
+	}
+	for _, c := range suffix {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// loadStoredImageFile 从图片目录读取已落盘的卦象图，用于内存中已淘汰的图片
+//
+// 返回值：找到的图片；未落盘或文件已被清理时返回false
+func loadStoredImageFile(id string) (*renderedImage, bool) {
+	baseName, err := imageBaseName(id)
+	if err != nil {
+		return nil, false
+	}
+	return loadStoredImageByName(baseName)
+}
+
+// loadStoredImageByName 按不含扩展名的文件名在图片目录中查找各种格式的图片，缩略图等变体也经此读取
+func loadStoredImageByName(baseName string) (*renderedImage, bool) {
+	for _, dir := range []string{"photos", "output"} {
+		// APNG与PNG同为.png扩展名，由文件内容区分
+		for _, format := range []string{ImageFormatPNG, ImageFormatJPEG, ImageFormatSVG, ImageFormatGIF} {
+			fileName := baseName + "." + imageFileExt(format)
+			fullPath := filepath.Join(getCurrentDir(), dir, fileName)
+			data, err := os.ReadFile(fullPath)
+			if err != nil {
+				continue
+			}
+			if format == ImageFormatPNG && isAPNG(data) {
+				format = ImageFormatAPNG
+			}
+			info, _ := os.Stat(fullPath)
+			createdAt := time.Now()
+			if info != nil {
+				createdAt = info.ModTime()
+			}
+			return &renderedImage{
+				Data:        data,
+				ContentType: imageContentType(format),
+				FileName:    fileName,
+				CreatedAt:   createdAt,
+			}, true
+		}
+	}
+	return nil, false
+}
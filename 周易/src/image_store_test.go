@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestNewDivineIDUnique 同一时刻生成的ID和图片文件名互不相同，旧格式的ID仍可解析
+func TestNewDivineIDUnique(t *testing.T) {
+	now := time.Date(2025, 1, 1, 14, 30, 0, 123456789, time.UTC)
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		baseName, err := imageBaseName(newDivineID(now))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[baseName] {
+			t.Fatalf("同一时刻生成了重复的文件名: %s", baseName)
+		}
+		seen[baseName] = true
+	}
+
+	legacy := "divine_1735741800123456789"
+	baseName, err := imageBaseName(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "卜卦_" + time.Unix(0, 1735741800123456789).Format("20060102150405") + "_123456789"; baseName != want {
+		t.Errorf("旧格式ID的文件名为 %s，期望 %s", baseName, want)
+	}
+
+	for _, id := range []string{"divine_", "divine_123_", "divine_123_ABCDEF12", "divine_123_1a2b3c4d/../x", "other_123"} {
+		if _, err := imageBaseName(id); err == nil {
+			t.Errorf("%q 应为无效ID", id)
+		}
+	}
+}
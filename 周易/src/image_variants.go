@@ -0,0 +1,267 @@
+// image_variants.go 实现卦象图的缩略图和高清图
+// 变体按目标比例重新渲染而不是缩放原图：布局仍按原尺寸计算，画布把坐标、字号和爻线按比例换算，
+// 文字以目标字号直接绘制，缩略图不发虚，高清图可用于打印。
+// 变体保存在单独的变体缓存（见variant_cache.go）和图片目录中，通过 GET /api/v1/divine/{id}/image?size=thumb|hires 获取
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 图片尺寸
+const (
+	ImageSizeOriginal = "original" // 原图
+	ImageSizeThumb    = "thumb"    // 缩略图，宽度见配置render.variants.thumb_width
+	ImageSizeHiRes    = "hires"    // 高清图，分辨率见配置render.variants.hires_dpi
+
+	baseImageDPI = 96 // 原图按屏幕的96 DPI计算物理尺寸
+)
+
+// normalizeImageSize 校验图片尺寸名称
+//
+// 返回值：规范化的尺寸名称，为空时返回ImageSizeOriginal；名称无效时返回错误
+func normalizeImageSize(size string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(size)) {
+	case "", ImageSizeOriginal:
+		return ImageSizeOriginal, nil
+	case ImageSizeThumb, "thumbnail":
+		return ImageSizeThumb, nil
+	case ImageSizeHiRes:
+		return ImageSizeHiRes, nil
+	}
+	return "", fmt.Errorf("图片尺寸无效: %s（可选 original、thumb、hires）", size)
+}
+
+// variantScale 返回变体相对原图的缩放比例
+//
+// 参数：
+//   - size: 图片尺寸
+//   - width: 原图宽度，缩略图按此换算比例
+func variantScale(size string, width int) float64 {
+	config := GetConfig().Render.Variants
+	switch size {
+	case ImageSizeThumb:
+		return float64(config.ThumbWidth) / float64(width)
+	case ImageSizeHiRes:
+		return float64(config.HiResDPI) / baseImageDPI
+	}
+	return 1
+}
+
+// variantFormat 返回变体默认使用的格式：静态位图沿用原图格式，SVG和动画的变体为PNG
+func variantFormat(format string) string {
+	if isRasterFormat(format) {
+		return format
+	}
+	return ImageFormatPNG
+}
+
+// variantKey 变体在图片存储中的键
+func variantKey(id, size, format string, quality int) string {
+	return fmt.Sprintf("%s@%s:%s:%d", id, size, format, quality)
+}
+
+// variantBaseName 变体落盘的文件名（不含扩展名），如"卜卦_20250101143000_123456789_1a2b3c4d_thumb"
+func variantBaseName(id, size string) (string, error) {
+	baseName, err := imageBaseName(id)
+	if err != nil {
+		return "", err
+	}
+	return baseName + "_" + size, nil
+}
+
+// getImageVariant 取得占卜结果指定尺寸的图片
+// 依次查找变体缓存和图片目录，都没有时按原图的盘面元数据重新渲染并保存
+//
+// 参数：
+//   - id: 占卜ID
+//   - original: 原图，用于取得盘面元数据和默认格式
+//   - size: ImageSizeThumb或ImageSizeHiRes
+//   - format: 图片格式，为空时按variantFormat取默认格式；只支持静态位图
+//   - quality: JPEG的编码质量，0表示使用配置的默认值
+//   - client: 请求的客户端地址，需要新渲染高清图时按此限流；为空表示服务端自行生成，不限流
+//
+// 返回值：变体图片；客户端新渲染高清图超出限制时返回*rateLimitError
+func getImageVariant(id string, original *renderedImage, size, format string, quality int, client string) (*renderedImage, error) {
+	defaultFormat := variantFormat(imageFormatFromContentType(original.ContentType))
+	if format == "" {
+		format = defaultFormat
+	}
+	if !isRasterFormat(format) {
+		return nil, fmt.Errorf("缩略图和高清图只支持png、jpeg格式: %s", format)
+	}
+
+	key := variantKey(id, size, format, quality)
+	if img, found := getVariantCache().Get(key); found {
+		return img, nil
+	}
+	// 只有默认格式和质量的变体会落盘
+	if format == defaultFormat && quality == 0 {
+		if baseName, err := variantBaseName(id, size); err == nil {
+			if img, found := loadStoredImageByName(baseName); found {
+				getVariantCache().Put(key, img)
+				return img, nil
+			}
+		}
+	}
+	if size == ImageSizeHiRes && client != "" {
+		if ok, wait := getHiResLimiter().allow(client, time.Now()); !ok {
+			return nil, &rateLimitError{retryAfter: wait}
+		}
+	}
+
+	meta := original.Meta
+	if meta == nil {
+		var err error
+		if meta, err = extractChartMetadata(original.Data); err != nil {
+			return nil, fmt.Errorf("无法取得该占卜的盘面: %v", err)
+		}
+	}
+	img, err := renderImageVariant(meta, size, format, quality)
+	if err != nil {
+		return nil, err
+	}
+	getVariantCache().Put(key, img)
+	if format == defaultFormat && quality == 0 && GetConfig().Render.SaveToDisk {
+		if _, err := saveImageData(img.Data, img.FileName); err != nil {
+			log.Printf("保存%s图片失败: %v", size, err)
+		}
+	}
+	return img, nil
+}
+
+// pregenerateImageVariants 按配置在起卦后立即生成变体，失败只记录日志，不影响原图
+func pregenerateImageVariants(id string, original *renderedImage) {
+	for _, size := range GetConfig().Render.Variants.Pregenerate {
+		if _, err := getImageVariant(id, original, size, "", 0, ""); err != nil {
+			log.Printf("生成%s图片失败: %s: %v", size, id, err)
+		}
+	}
+}
+
+// renderImageVariant 按盘面元数据以目标比例渲染一张变体，写入盘面元数据和分辨率信息
+// 原图的主题和品牌已从配置中删除时改用默认主题、不叠加品牌
+func renderImageVariant(meta *ChartMetadata, size, format string, quality int) (*renderedImage, error) {
+	theme, err := resolveTheme(meta.Theme, 0)
+	if err != nil {
+		if theme, err = resolveTheme("", 0); err != nil {
+			return nil, err
+		}
+	}
+	layoutName, err := normalizeLayoutName(meta.Layout)
+	if err != nil {
+		return nil, err
+	}
+	tpl, err := getChartTemplate(layoutName)
+	if err != nil {
+		return nil, err
+	}
+	branding, err := resolveBranding(meta.Brand, 0)
+	if err != nil || meta.Brand == "" {
+		branding = nil
+	}
+
+	scale := variantScale(size, tpl.Canvas.Width)
+	rendered, err := renderChartScaled(format, quality, tpl, meta.Chart, theme, newBrandOverlay(branding, meta.ID), scale)
+	if err != nil {
+		return nil, err
+	}
+	if rendered.Data, err = embedChartMetadata(format, rendered.Data, meta); err != nil {
+		return nil, err
+	}
+	if rendered.Data, err = embedImageDPI(format, rendered.Data, int(math.Round(baseImageDPI*scale))); err != nil {
+		return nil, err
+	}
+
+	baseName, err := variantBaseName(meta.ID, size)
+	if err != nil {
+		return nil, err
+	}
+	rendered.FileName = baseName + "." + imageFileExt(format)
+	rendered.Meta = meta
+	log.Printf("%s图片生成完成: %s（%d bytes）", size, filepath.Base(rendered.FileName), len(rendered.Data))
+	return rendered, nil
+}
+
+// renderChartScaled 按版式模板以指定比例渲染静态位图
+// 布局用原尺寸的字体计算，保证换行和画布高度与原图一致；绘制时改用按比例放大的字体和主题
+func renderChartScaled(format string, quality int, tpl *ChartTemplate, chart *GuaChart, theme *Theme, overlay *brandOverlay, scale float64) (*renderedImage, error) {
+	release := acquireRenderSlot()
+	defer release()
+
+	theme = tpl.applyFonts(theme)
+	faces, err := acquireFaces(theme)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseFaces(theme, faces)
+	layout := computeLayout(tpl, chart, theme, faces)
+
+	scaledTheme := theme.scaled(scale)
+	scaledFaces, err := acquireFaces(scaledTheme)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseFaces(scaledTheme, scaledFaces)
+
+	width := max(int(math.Round(float64(layout.宽度)*scale)), 1)
+	height := max(int(math.Round(float64(layout.高度)*scale)), 1)
+	dst := getBackground(scaledTheme, width, height)
+	if err := drawGuaImage(newScaledRasterCanvas(dst, scaledFaces, scaledTheme, scale), layout, chart); err != nil {
+		return nil, fmt.Errorf("绘制卦象图像失败: %v", err)
+	}
+	if overlay != nil {
+		overlay.scaled(scale).drawRaster(dst, scaledFaces, scaledTheme)
+	}
+
+	data, err := encodeRaster(format, dst, quality)
+	if err != nil {
+		return nil, err
+	}
+	return &renderedImage{Data: data, ContentType: imageContentType(format), CreatedAt: time.Now()}, nil
+}
+
+// embedImageDPI 写入图片的分辨率，打印和排版软件按此换算物理尺寸
+// PNG写入pHYs块，JPEG在SOI之后插入JFIF段
+func embedImageDPI(format string, data []byte, dpi int) ([]byte, error) {
+	switch format {
+	case ImageFormatPNG:
+		const ihdrEnd = 8 + 25
+		if len(data) < ihdrEnd || !bytes.HasPrefix(data, pngSignature) {
+			return nil, fmt.Errorf("写入分辨率失败: PNG数据无效")
+		}
+		// pHYs: 每米像素数X、Y，单位1表示米
+		ppm := uint32(math.Round(float64(dpi) / 0.0254))
+		phys := make([]byte, 9)
+		binary.BigEndian.PutUint32(phys[0:], ppm)
+		binary.BigEndian.PutUint32(phys[4:], ppm)
+		phys[8] = 1
+
+		var buf bytes.Buffer
+		buf.Write(data[:ihdrEnd])
+		writePNGChunk(&buf, "pHYs", phys)
+		buf.Write(data[ihdrEnd:])
+		return buf.Bytes(), nil
+	case ImageFormatJPEG:
+		if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+			return nil, fmt.Errorf("写入分辨率失败: JPEG数据无效")
+		}
+		// APP0: "JFIF\0"、版本1.01、密度单位1表示每英寸、X和Y密度、无缩略图
+		app0 := []byte{0xFF, 0xE0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0x00, 0x01, 0x01, 0x01,
+			byte(dpi >> 8), byte(dpi), byte(dpi >> 8), byte(dpi), 0x00, 0x00}
+
+		var buf bytes.Buffer
+		buf.Write(data[:2])
+		buf.Write(app0)
+		buf.Write(data[2:])
+		return buf.Bytes(), nil
+	}
+	return data, nil
+}
@@ -0,0 +1,99 @@
+// layout.go 实现卦象图的自适应布局
+// 每种版式由一个模板（见chart_template.go）定义画布宽度、最小高度、卦象和爻辞区域的摆放方式以及显示的栏目，
+// 计算布局时先用与实际渲染相同的字体测量爻辞换行后的高度，内容超出时加高画布而不是裁切
+package main
+
+// 内置版式名称
+const (
+	LayoutLandscape = "landscape" // 横版1200×900，默认版式
+	LayoutPortrait  = "portrait"  // 竖版，适合手机浏览
+	LayoutSquare    = "square"    // 方形，适合社交平台
+	LayoutWide      = "wide"      // 宽屏，爻辞排在卦象右侧
+)
+
+// computeLayout 计算盘面在指定版式下的布局
+// 先按版式模板放置各区域，再在测量画布上排一遍爻辞得到实际高度，必要时加高画布
+//
+// 参数：
+//   - tpl: 版式模板
+//   - chart: 盘面数据
+//   - theme: 渲染主题，决定爻的尺寸
+//   - faces: 渲染用字体，用于测量文字宽度
+//
+// 返回值：
+//   - *Layout: 计算好的布局
+func computeLayout(tpl *ChartTemplate, chart *GuaChart, theme *Theme, faces *chartFaces) *Layout {
+	layout := &Layout{
+		名称:      tpl.Name,
+		宽度:      tpl.Canvas.Width,
+		高度:      tpl.Canvas.MinHeight,
+		标题Y:     tpl.Header.TitleY,
+		副标题Y:    tpl.Header.SubtitleY,
+		卦名Y:     tpl.Chart.NameY,
+		卦宫Y:     tpl.Chart.GongY,
+		基础Y:     tpl.Chart.BaseY,
+		爻间距:     tpl.Chart.LineSpacing,
+		爻高度:     theme.Line.Height,
+		爻宽度:     theme.Line.Width,
+		文字基线偏移:  10,
+		六亲偏移:    tpl.Chart.NaJiaOffset,
+		动爻偏移:    tpl.Chart.MarkerOffset,
+		伏神偏移:    tpl.Chart.FuShenOffset,
+		爻辞Y:     tpl.YaoCi.Y,
+		本卦爻辞X:   tpl.YaoCi.X,
+		变卦爻辞X:   tpl.YaoCi.X2,
+		爻辞宽度:    tpl.YaoCi.Width,
+		爻辞堆叠:    tpl.YaoCi.Stacked != nil && *tpl.YaoCi.Stacked,
+		单卦爻辞中心X: tpl.YaoCi.SingleCenterX,
+		单卦爻辞宽度:  tpl.YaoCi.SingleWidth,
+		爻辞行间距:   tpl.YaoCi.LineHeight,
+		互卦X:     tpl.HuGua.X,
+		互卦Y:     tpl.HuGua.Y,
+		栏目: layoutPanels{
+			标题:  show(tpl.Panels.Title),
+			副标题: show(tpl.Panels.Subtitle),
+			卦名:  show(tpl.Panels.Names),
+			六神:  show(tpl.Panels.LiuShen),
+			六亲:  show(tpl.Panels.LiuQin),
+			动爻:  show(tpl.Panels.Moving),
+			标签:  show(tpl.Panels.Labels),
+			伏神:  show(tpl.Panels.FuShen),
+			爻辞:  show(tpl.Panels.YaoCi),
+			互卦:  show(tpl.Panels.HuGua),
+		},
+	}
+
+	if chart.HasDongYao {
+		// 有动爻，显示双卦
+		layout.左卦中心X = tpl.Chart.LeftCenterX
+		layout.右卦中心X = tpl.Chart.RightCenterX
+		layout.六神X = tpl.Chart.LeftCenterX - tpl.Chart.LiuShenOffset
+	} else {
+		// 无动爻，只显示单卦并居中
+		layout.左卦中心X = tpl.Chart.SingleCenterX
+		layout.右卦中心X = -1 // 不显示右卦
+		layout.六神X = tpl.Chart.SingleCenterX - tpl.Chart.SingleLiuShenOffset
+	}
+
+	// 测量爻辞排版后的底部位置，超出最小高度时加高画布
+	if layout.栏目.爻辞 {
+		bottom := drawYaoCi(&measureCanvas{faces: faces}, layout, newLocalizer(chart.Locale), chart.BenGuaName, chart.BianGuaName, chart.BenGua, chart.BianGua, chart.HasDongYao)
+		if need := bottom + tpl.Canvas.BottomMargin; need > layout.高度 {
+			layout.高度 = need
+		}
+	}
+	return layout
+}
+
+// measureCanvas 只测量不绘制的画布，用于在渲染前计算内容尺寸
+type measureCanvas struct {
+	faces *chartFaces
+}
+
+func (c *measureCanvas) DrawText(text string, x, y int, style textStyle)               {}
+func (c *measureCanvas) DrawCenteredText(text string, centerX, y int, style textStyle) {}
+func (c *measureCanvas) DrawYao(x, y, width, height int, yang bool)                    {}
+
+func (c *measureCanvas) MeasureText(text string, style textStyle) int {
+	return (&rasterCanvas{faces: c.faces}).MeasureText(text, style)
+}
@@ -0,0 +1,311 @@
+// locale.go 实现卦象图和占卜结果的多语言输出
+// 盘面数据始终以简体中文保存（卦名、干支、六神等同时是排盘的键），只在绘制和输出结果时经localizer转换：
+// 繁体中文先按词组、再逐字转换标签和经文；英文使用卦名拼音、习用英文卦名、
+// 理雅各（James Legge）译本的卦辞以及英文的爻位名称，爻辞保留原文
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 支持的语言
+const (
+	LocaleZhHans = "zh-Hans" // 简体中文，默认
+	LocaleZhHant = "zh-Hant" // 繁体中文
+	LocaleEn     = "en"      // 英文
+)
+
+// localeAliases 语言标签到支持语言的映射，键为小写
+// 未列出的zh-*按简体处理，en-*按英文处理
+var localeAliases = map[string]string{
+	"zh":      LocaleZhHans,
+	"zh-hans": LocaleZhHans,
+	"zh-cn":   LocaleZhHans,
+	"zh-sg":   LocaleZhHans,
+	"zh-hant": LocaleZhHant,
+	"zh-tw":   LocaleZhHant,
+	"zh-hk":   LocaleZhHant,
+	"zh-mo":   LocaleZhHant,
+	"en":      LocaleEn,
+}
+
+// localeNames 返回所有支持的语言
+func localeNames() []string {
+	return []string{LocaleZhHans, LocaleZhHant, LocaleEn}
+}
+
+// matchLocale 把语言标签（如"zh-TW"、"zh-Hant-HK"、"en-US"）对应到支持的语言
+func matchLocale(tag string) (string, bool) {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if locale, ok := localeAliases[tag]; ok {
+		return locale, true
+	}
+	// 带地区或文字的标签逐段截短后再匹配，如zh-hant-hk → zh-hant
+	for i := strings.LastIndex(tag, "-"); i > 0; i = strings.LastIndex(tag, "-") {
+		tag = tag[:i]
+		if locale, ok := localeAliases[tag]; ok {
+			return locale, true
+		}
+	}
+	return "", false
+}
+
+// normalizeLocale 规范化请求中的语言，为空时使用配置的默认语言
+func normalizeLocale(name string) (string, error) {
+	if strings.TrimSpace(name) == "" {
+		name = GetConfig().Render.DefaultLocale
+	}
+	locale, ok := matchLocale(name)
+	if !ok {
+		return "", fmt.Errorf("不支持的语言: %s（可选 %s）", name, strings.Join(localeNames(), "、"))
+	}
+	return locale, nil
+}
+
+// negotiateLocale 按Accept-Language请求头选择语言，取q值最高的受支持语言
+// 只在配置开启render.negotiate_locale时使用
+//
+// 返回值：协商出的语言；请求头为空或没有受支持的语言时返回false
+func negotiateLocale(acceptLanguage string) (string, bool) {
+	type languageRange struct {
+		locale string
+		q      float64
+	}
+	var ranges []languageRange
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		locale, ok := matchLocale(tag)
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, languageRange{locale: locale, q: q})
+		}
+	}
+	if len(ranges) == 0 {
+		return "", false
+	}
+	// q值相同时保持请求头中的先后顺序
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	return ranges[0].locale, true
+}
+
+// localizer 按语言转换图中和结果中的文字
+type localizer struct {
+	locale string
+}
+
+// newLocalizer 返回指定语言的转换器，空或未知的语言按简体中文
+func newLocalizer(locale string) *localizer {
+	switch locale {
+	case LocaleZhHant, LocaleEn:
+		return &localizer{locale: locale}
+	default:
+		return &localizer{locale: LocaleZhHans}
+	}
+}
+
+// latin 是否为拼音文字，这类语言的文字较宽，图中的卦名和标签以卦象为中心居中排列
+func (l *localizer) latin() bool {
+	return l.locale == LocaleEn
+}
+
+// text 转换固定标签或中文文本
+// 繁体逐字转换；英文查标签表，表中没有的原样返回
+func (l *localizer) text(s string) string {
+	switch l.locale {
+	case LocaleZhHant:
+		return hantReplacer.Replace(s)
+	case LocaleEn:
+		if en, ok := enLabels[s]; ok {
+			return en
+		}
+	}
+	return s
+}
+
+// guaTitle 图中卦名一行，如"火天大有"，英文为"14 Da You (Qian palace)"
+func (l *localizer) guaTitle(卦名 string) string {
+	gua := guaXiang[卦名]
+	if l.locale != LocaleEn {
+		return l.text(gua.FullName)
+	}
+	en := guaEnglishData[卦名]
+	return fmt.Sprintf("%d %s (%s)", en.Number, en.Pinyin, l.guaGong(gua.GuaGong))
+}
+
+// guaSubtitle 图中卦名下方的一行，中文为卦宫如"(乾宫)"，英文为英文卦名
+func (l *localizer) guaSubtitle(卦名 string) string {
+	if l.locale == LocaleEn {
+		return guaEnglishData[卦名].Title
+	}
+	return l.text("(" + guaXiang[卦名].GuaGong + ")")
+}
+
+// guaDesc 结果中的卦的完整描述，中文为全名如"火天大有"，英文如"14 Da You · Possession in Great Measure"
+func (l *localizer) guaDesc(卦名 string) string {
+	if l.locale != LocaleEn {
+		return l.text(guaXiang[卦名].FullName)
+	}
+	en := guaEnglishData[卦名]
+	return fmt.Sprintf("%d %s · %s", en.Number, en.Pinyin, en.Title)
+}
+
+// judgment 卦辞，目前只有英文
+func (l *localizer) judgment(卦名 string) string {
+	if l.locale != LocaleEn {
+		return ""
+	}
+	return guaEnglishData[卦名].Judgment
+}
+
+// guaGong 卦宫名称，英文如"Qian palace"
+func (l *localizer) guaGong(gong string) string {
+	if l.locale != LocaleEn {
+		return l.text(gong)
+	}
+	return trigramPinyin[strings.TrimSuffix(gong, "宫")] + " palace"
+}
+
+// liuShen 六神名称
+func (l *localizer) liuShen(name string) string {
+	if l.locale == LocaleEn {
+		return liuShenEnglish[name]
+	}
+	return l.text(name)
+}
+
+// naJiaText 六亲纳甲，如"父母壬戌土"，英文如"Parents Renxu Earth"
+func (l *localizer) naJiaText(六亲, 干支, 五行 string) string {
+	if l.locale != LocaleEn {
+		return l.text(六亲 + 干支 + 五行)
+	}
+	return liuQinEnglish[六亲] + " " + pinyinGanZhi(干支) + " " + wuXingEnglish[五行]
+}
+
+// pillars 图中标题的四柱，如"甲辰年 丙寅月 乙巳日 丁亥时"，英文如"Jiachen · Bingyin · Yisi · Dinghai"
+func (l *localizer) pillars(chart *GuaChart) string {
+	values := []string{chart.Ganzhinian, chart.Ganzhiyue, chart.Ganzhiri, chart.Ganzhishi}
+	if l.locale != LocaleEn {
+		return l.text(strings.Join(values, " "))
+	}
+	for i, value := range values {
+		values[i] = pinyinGanZhi(value)
+	}
+	return strings.Join(values, " · ")
+}
+
+// divineTime 图中副标题的起卦时间
+func (l *localizer) divineTime(chart *GuaChart) string {
+	if l.locale != LocaleEn {
+		return l.text(formatDivineTime(chart))
+	}
+	text := fmt.Sprintf("%s %s", chart.DivineTime.Format("2006-01-02 15:04"), chart.Timezone)
+	if chart.SolarTime != nil {
+		text += "  true solar time " + chart.SolarTime.Format("15:04")
+	}
+	return text
+}
+
+// huGuaText 互卦一栏，如"互卦：水火既济"，英文如"Nuclear: 63 Ji Ji"
+func (l *localizer) huGuaText(卦名 string) string {
+	if l.locale != LocaleEn {
+		return l.text("互卦：" + guaXiang[卦名].FullName)
+	}
+	en := guaEnglishData[卦名]
+	return fmt.Sprintf("Nuclear: %d %s", en.Number, en.Pinyin)
+}
+
+// fuShenText 伏神一栏，如"伏 妻财甲寅木"，英文如"Hidden: Wealth Jiayin Wood"
+func (l *localizer) fuShenText(fu FuShen) string {
+	if l.locale != LocaleEn {
+		return l.text("伏 " + fu.LiuQin + fu.GanZhi + fu.WuXing)
+	}
+	return "Hidden: " + l.naJiaText(fu.LiuQin, fu.GanZhi, fu.WuXing)
+}
+
+// yaoName 爻位名称，如"初九"，英文如"Nine at the beginning"
+func (l *localizer) yaoName(爻位, 爻 int) string {
+	if l.locale != LocaleEn {
+		return l.text(getYaoWeiName(爻位, 爻))
+	}
+	number := "Nine"
+	if 爻 == 0 {
+		number = "Six"
+	}
+	return number + " " + yaoPlaceEnglish[爻位-1]
+}
+
+// yaoShortName 与爻并排的简短爻位名称，中文同yaoName，英文如"Line 1"
+func (l *localizer) yaoShortName(爻位, 爻 int) string {
+	if l.locale == LocaleEn {
+		return fmt.Sprintf("Line %d", 爻位)
+	}
+	return l.yaoName(爻位, 爻)
+}
+
+// yaoCiLine 一爻的爻位名称和爻辞，英文只翻译爻位名称
+func (l *localizer) yaoCiLine(卦名 string, 卦 []int, 爻位 int) string {
+	if l.locale != LocaleEn {
+		return l.text(yaoCiLine(卦名, 卦, 爻位))
+	}
+	return l.yaoName(爻位, 卦[爻位-1]) + ": " + getYaoCi(卦名, 爻位-1)
+}
+
+// yaoKind 四象名称，如"老阳"，英文如"old yang"
+func (l *localizer) yaoKind(yang, moving bool) string {
+	if l.locale != LocaleEn {
+		return l.text(yaoKindName(yang, moving))
+	}
+	kind := "young "
+	if moving {
+		kind = "old "
+	}
+	if yang {
+		return kind + "yang"
+	}
+	return kind + "yin"
+}
+
+// castCaption 起卦动画中每次摇卦的说明，如"第1次 初九 少阳"
+func (l *localizer) castCaption(爻位, 爻 int, yang, moving bool) string {
+	if l.locale != LocaleEn {
+		caption := fmt.Sprintf("第%d次 %s %s", 爻位, getYaoWeiName(爻位, 爻), yaoKindName(yang, moving))
+		if moving {
+			caption += " 动"
+		}
+		return l.text(caption)
+	}
+	caption := fmt.Sprintf("Toss %d: %s, %s", 爻位, l.yaoName(爻位, 爻), l.yaoKind(yang, moving))
+	if moving {
+		caption += ", moving"
+	}
+	return caption
+}
+
+// pinyinGanZhi 把干支转为拼音，如"甲辰年"转为"Jiachen"，末尾的年月日时略去，不认识的字原样保留
+func pinyinGanZhi(ganZhi string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimRight(ganZhi, "年月日时") {
+		if pinyin, ok := ganZhiPinyin[r]; ok {
+			b.WriteString(pinyin)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	runes := []rune(b.String())
+	if len(runes) > 0 {
+		runes[0] = unicode.ToUpper(runes[0])
+	}
+	return string(runes)
+}
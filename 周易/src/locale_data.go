@@ -0,0 +1,306 @@
+// locale_data.go 多语言输出所需的数据
+// 包括简体到繁体的转换表，以及64卦的拼音、英文卦名和英文卦辞
+package main
+
+import "strings"
+
+// 简转繁时需要按词组处理的一简对多繁的字，先于逐字转换匹配
+// 如"干父之蛊"的干作幹，"噬干肉"的干作乾，"鸿渐于干"的干不变；"系"在随、遁、坎诸卦作係，其余作繫
+var hantPhrases = []string{
+	"干父", "幹父",
+	"干母", "幹母",
+	"噬干", "噬乾",
+	"系小子", "係小子",
+	"系丈夫", "係丈夫",
+	"拘系之", "拘係之",
+	"系遁", "係遯",
+	"系用", "係用",
+	"奔其机", "奔其机", // 机为几案，不作機
+	"几不如舍", "幾不如舍",
+	"月几望", "月幾望",
+	"公历", "公曆",
+	"以御天", "以御天", // 御为驾驭，不作禦
+	"后以", "后以", // 后为君主，不作後
+	"后不省方", "后不省方",
+}
+
+// 简体到繁体的逐字转换表，覆盖卦名、卦辞、彖传、象传、爻辞、图中标签和PDF报告用到的字
+// 丑（地支）、斗（北斗）、谷、于、尸、腊、干（水岸）、征（征伐）、里（百里）、咸（皆）等繁体同形的字不在表中
+var hantChars = map[rune]rune{
+	'与': '與', '丛': '叢', '东': '東', '丧': '喪', '丰': '豐', '临': '臨', '为': '為', '习': '習',
+	'乱': '亂', '亏': '虧', '云': '雲', '亿': '億', '仅': '僅', '仆': '僕', '从': '從', '仪': '儀',
+	'众': '眾', '倾': '傾', '兑': '兌', '兴': '興', '内': '內', '冯': '馮', '击': '擊', '则': '則',
+	'剥': '剝', '动': '動', '劳': '勞', '华': '華', '厉': '厲', '发': '發', '变': '變', '号': '號',
+	'后': '後', '哑': '啞', '园': '園', '国': '國', '坚': '堅', '壮': '壯', '处': '處', '复': '復',
+	'妇': '婦', '宁': '寧', '实': '實', '宠': '寵', '宫': '宮', '宾': '賓', '尔': '爾', '屦': '屨',
+	'岁': '歲', '巩': '鞏', '帅': '帥', '师': '師', '带': '帶', '并': '並', '庆': '慶', '庐': '廬',
+	'开': '開', '弃': '棄', '张': '張', '归': '歸', '御': '禦', '忧': '憂', '怀': '懷', '恒': '恆',
+	'恻': '惻', '戋': '戔', '战': '戰', '户': '戶', '执': '執', '挛': '攣', '损': '損', '据': '據',
+	'敌': '敵', '无': '無', '旧': '舊', '时': '時', '显': '顯', '晋': '晉', '机': '機', '杀': '殺',
+	'来': '來', '杨': '楊', '栋': '棟', '桡': '橈', '汇': '彙', '泽': '澤', '济': '濟', '涂': '塗',
+	'涟': '漣', '涣': '渙', '渊': '淵', '渐': '漸', '潜': '潛', '灭': '滅', '灵': '靈', '灾': '災',
+	'牵': '牽', '独': '獨', '琐': '瑣', '瓮': '甕', '畴': '疇', '硕': '碩', '离': '離', '窥': '窺',
+	'简': '簡', '系': '繫', '约': '約', '纳': '納', '纷': '紛', '绂': '紱', '终': '終', '经': '經',
+	'维': '維', '罢': '罷', '肤': '膚', '胜': '勝', '舆': '輿', '艰': '艱', '节': '節', '苋': '莧',
+	'苏': '蘇', '药': '藥', '获': '獲', '虚': '虛', '虽': '雖', '蛊': '蠱', '见': '見', '观': '觀',
+	'视': '視', '觌': '覿', '触': '觸', '誉': '譽', '讼': '訟', '诫': '誡', '说': '說', '谓': '謂',
+	'谦': '謙', '豮': '豶', '贝': '貝', '贞': '貞', '负': '負', '败': '敗', '贯': '貫', '贰': '貳',
+	'贲': '賁', '资': '資', '赏': '賞', '跃': '躍', '跻': '躋', '车': '車', '轮': '輪', '载': '載',
+	'辅': '輔', '辐': '輻', '过': '過', '进': '進', '远': '遠', '违': '違', '连': '連', '迟': '遲',
+	'遁': '遯', '遗': '遺', '邻': '鄰', '铉': '鉉', '错': '錯', '锡': '錫', '长': '長', '门': '門',
+	'问': '問', '闲': '閑', '阒': '闃', '阴': '陰', '阶': '階', '陆': '陸', '陨': '隕', '险': '險',
+	'随': '隨', '顶': '頂', '须': '須', '颊': '頰', '颐': '頤', '频': '頻', '颠': '顛', '风': '風',
+	'飞': '飛', '饮': '飲', '馈': '饋', '马': '馬', '驱': '驅', '鱼': '魚', '鲋': '鮒', '鸟': '鳥',
+	'鸣': '鳴', '鸿': '鴻', '鹤': '鶴', '黄': '黃', '龙': '龍', '龟': '龜',
+	// 图中标签用到的字
+	'阳': '陽', '历': '曆', '孙': '孫', '财': '財', '陈': '陳', '亲': '親', '辞': '辭', '个': '個',
+	// 卦辞、彖传、象传和PDF报告用到的字
+	'万': '萬', '两': '兩', '严': '嚴', '丽': '麗', '义': '義', '乐': '樂', '伤': '傷', '俭': '儉',
+	'关': '關', '养': '養', '决': '決', '况': '況', '刚': '剛', '劝': '勸', '势': '勢', '参': '參',
+	'图': '圖', '圣': '聖', '备': '備', '头': '頭', '奋': '奮', '对': '對', '属': '屬', '应': '應',
+	'庙': '廟', '异': '異', '强': '強', '当': '當', '录': '錄', '恶': '惡', '惊': '驚', '惧': '懼',
+	'惩': '懲', '扬': '揚', '报': '報', '摇': '搖', '数': '數', '断': '斷', '昼': '晝', '气': '氣',
+	'汤': '湯', '渎': '瀆', '满': '滿', '狱': '獄', '电': '電', '盘': '盤', '码': '碼', '礼': '禮',
+	'禄': '祿', '种': '種', '积': '積', '称': '稱', '穷': '窮', '笃': '篤', '类': '類', '纶': '綸',
+	'统': '統', '继': '繼', '续': '續', '缓': '緩', '编': '編', '罚': '罰', '聪': '聰', '荐': '薦',
+	'荣': '榮', '莅': '蒞', '议': '議', '记': '記', '讲': '講', '设': '設', '识': '識', '语': '語',
+	'误': '誤', '诰': '誥', '请': '請', '诸': '諸', '谋': '謀', '贤': '賢', '辉': '輝', '迁': '遷',
+	'迩': '邇', '钱': '錢', '铜': '銅', '闭': '閉', '间': '間', '闷': '悶', '难': '難', '静': '靜',
+	'页': '頁', '顺': '順', '预': '預', '颙': '顒', '饪': '飪',
+}
+
+// hantReplacer 先按词组、再逐字把简体转为繁体
+var hantReplacer = func() *strings.Replacer {
+	pairs := append([]string(nil), hantPhrases...)
+	for from, to := range hantChars {
+		pairs = append(pairs, string(from), string(to))
+	}
+	return strings.NewReplacer(pairs...)
+}()
+
+// guaEnglish 一卦的英文信息
+type guaEnglish struct {
+	Number   int    // 文王卦序
+	Pinyin   string // 卦名拼音
+	Title    string // 习用的英文卦名
+	Judgment string // 卦辞，据理雅各（James Legge）1882年译本，已属公有领域，卦名改为拼音
+}
+
+// guaEnglishData 64卦的英文信息，按guaXiang中的卦名索引
+var guaEnglishData = map[string]guaEnglish{
+	"乾": {1, "Qian", "The Creative",
+		"Qian represents what is great and originating, penetrating, advantageous, correct and firm."},
+	"坤": {2, "Kun", "The Receptive",
+		"Kun represents what is great and originating, penetrating, advantageous, correct and having the firmness of a mare. When the superior man has to make any movement, if he take the initiative, he will go astray; if he follow, he will find his proper lord. The advantageousness will be seen in his getting friends in the south-west, and losing friends in the north-east. If he rest in correctness and firmness, there will be good fortune."},
+	"屯": {3, "Zhun", "Difficulty at the Beginning",
+		"Zhun indicates that in the case which it presupposes there will be great progress and success, and the advantage will come from being correct and firm. Any movement in advance should not be lightly undertaken. There will be advantage in appointing feudal princes."},
+	"蒙": {4, "Meng", "Youthful Folly",
+		"Meng indicates that in the case which it presupposes there will be progress and success. I do not go and seek the youthful and inexperienced, but he comes and seeks me. When he shows the sincerity that marks the first recourse to divination, I instruct him. If he apply a second and third time, that is troublesome; and I do not instruct the troublesome. There will be advantage in being firm and correct."},
+	"需": {5, "Xu", "Waiting",
+		"Xu intimates that, with the sincerity which is declared in it, there will be brilliant success. With firmness there will be good fortune; and it will be advantageous to cross the great stream."},
+	"讼": {6, "Song", "Conflict",
+		"Song intimates how, though there is sincerity in one's contention, he will yet meet with opposition and obstruction; but if he cherish an apprehensive caution, there will be good fortune, while, if he must prosecute it to the bitter end, there will be evil. It will be advantageous to see the great man; it will not be advantageous to cross the great stream."},
+	"师": {7, "Shi", "The Army",
+		"Shi indicates how, in the case which it supposes, with firmness and correctness, and a leader of age and experience, there will be good fortune and no error."},
+	"比": {8, "Bi", "Holding Together",
+		"Bi indicates that under the conditions which it supposes there is good fortune. But let the principal party intended in it re-examine himself, as if by divination, whether his virtue be great, unintermitting, and firm. If it be so, there will be no error. Those who have not rest will then come to him; and with those who are too late in coming it will be ill."},
+	"小畜": {9, "Xiao Xu", "The Taming Power of the Small",
+		"Xiao Xu indicates that under its conditions there will be progress and success. We see dense clouds, but no rain coming from our borders in the west."},
+	"履": {10, "Lü", "Treading",
+		"Lü suggests the idea of one treading on the tail of a tiger, which does not bite him. There will be progress and success."},
+	"泰": {11, "Tai", "Peace",
+		"In Tai we see the little gone and the great come. It indicates that there will be good fortune, with progress and success."},
+	"否": {12, "Pi", "Standstill",
+		"In Pi there is the want of good understanding between the different classes of men, and its indication is unfavourable to the firm and correct course of the superior man. We see in it the great gone and the little come."},
+	"同人": {13, "Tong Ren", "Fellowship with Men",
+		"Tong Ren, or union of men, appears here as we find it in the remote districts of the country, indicating progress and success. It will be advantageous to cross the great stream. It will be advantageous to maintain the firm correctness of the superior man."},
+	"大有": {14, "Da You", "Possession in Great Measure",
+		"Da You indicates that, under the circumstances which it implies, there will be great progress and success."},
+	"谦": {15, "Qian", "Modesty",
+		"Qian indicates progress and success. The superior man, being humble as it implies, will have a good issue to his undertakings."},
+	"豫": {16, "Yu", "Enthusiasm",
+		"Yu indicates that, in the state which it implies, feudal princes may be set up, and the hosts put in motion, with advantage."},
+	"随": {17, "Sui", "Following",
+		"Sui indicates that under its conditions there will be great progress and success. But it will be advantageous to be firm and correct. There will then be no error."},
+	"蛊": {18, "Gu", "Work on What Has Been Spoiled",
+		"Gu indicates great progress and success to him who deals properly with the condition represented by it. There will be advantage in efforts like that of crossing the great stream. He should weigh well, however, the events of three days before the turning point, and those to be done three days after it."},
+	"临": {19, "Lin", "Approach",
+		"Lin indicates that under the conditions supposed in it there will be great progress and success, while it will be advantageous to be firmly correct. In the eighth month there will be evil."},
+	"观": {20, "Guan", "Contemplation",
+		"Guan shows how he whom it represents should be like the worshipper who has washed his hands, but not yet presented his offerings; with sincerity and an appearance of dignity commanding reverent regard."},
+	"噬嗑": {21, "Shi He", "Biting Through",
+		"Shi He indicates successful progress in the condition of things which it supposes. It will be advantageous to use legal constraints."},
+	"贲": {22, "Bi", "Grace",
+		"Bi indicates that there should be free course in what it denotes. There will be little advantage, however, if it be allowed to advance and take the lead."},
+	"剥": {23, "Bo", "Splitting Apart",
+		"Bo indicates that in the state which it symbolises it will not be advantageous to make a movement in any direction whatever."},
+	"复": {24, "Fu", "Return",
+		"Fu indicates that there will be free course and progress in what it denotes. The subject of it finds no one to distress him in his exits and entrances; friends come to him, and no error is committed. He will return and repeat his proper course. In seven days comes his return. There will be advantage in whatever direction movement is made."},
+	"无妄": {25, "Wu Wang", "Innocence",
+		"Wu Wang indicates great progress and success, while there will be advantage in being firm and correct. If its subject and his action be not correct, he will fall into errors, and it will not be advantageous for him to move in any direction."},
+	"大畜": {26, "Da Xu", "The Taming Power of the Great",
+		"Under the conditions of Da Xu it will be advantageous to be firm and correct. If its subject do not seek to enjoy his revenues in his own family, without taking service at court, there will be good fortune. It will be advantageous for him to cross the great stream."},
+	"颐": {27, "Yi", "The Corners of the Mouth",
+		"Yi indicates that with firm correctness there will be good fortune in what is denoted by it. We must look at what we are seeking to nourish, and by the exercise of our thoughts seek for the proper aliment."},
+	"大过": {28, "Da Guo", "Preponderance of the Great",
+		"Da Guo suggests to us a beam that is weak. There will be advantage in moving under its conditions in any direction whatever; there will be success."},
+	"坎": {29, "Kan", "The Abysmal",
+		"Kan, here repeated, shows the possession of sincerity, through which the mind is penetrating. Action in accordance with this will be of high value."},
+	"离": {30, "Li", "The Clinging",
+		"Li indicates that, in regard to what it denotes, it will be advantageous to be firm and correct, and that thus there will be free course and success. Let its subject also nourish a docility like that of the cow, and there will be good fortune."},
+	"咸": {31, "Xian", "Influence",
+		"Xian indicates that, on the fulfilment of the conditions implied in it, there will be free course and success. Its advantageousness will depend on the being firm and correct, as in marrying a young lady. There will be good fortune."},
+	"恒": {32, "Heng", "Duration",
+		"Heng indicates successful progress and no error in what it denotes. But the advantage will come from being firm and correct; and movement in any direction whatever will be advantageous."},
+	"遁": {33, "Dun", "Retreat",
+		"Dun indicates that in its circumstances there should be withdrawal, and that thus there will be success. To a small extent it will still be advantageous to be firm and correct."},
+	"大壮": {34, "Da Zhuang", "The Power of the Great",
+		"Da Zhuang indicates that under the conditions which it symbolises it will be advantageous to be firm and correct."},
+	"晋": {35, "Jin", "Progress",
+		"In Jin we see a prince who secures the tranquillity of the people presented on that account with numerous horses by the king, and three times in a day received at interviews."},
+	"明夷": {36, "Ming Yi", "Darkening of the Light",
+		"Ming Yi indicates that in the circumstances which it denotes it will be advantageous to realise the difficulty of the position, and maintain firm correctness."},
+	"家人": {37, "Jia Ren", "The Family",
+		"For the realisation of what is taught in Jia Ren, or for the regulation of the family, what is most advantageous is that the wife be firm and correct."},
+	"睽": {38, "Kui", "Opposition",
+		"Kui indicates that, notwithstanding the condition of things which it denotes, in small matters there will still be good success."},
+	"蹇": {39, "Jian", "Obstruction",
+		"In the state indicated by Jian advantage will be found in the south-west, and the contrary in the north-east. It will be advantageous also to meet with the great man. In these circumstances, with firmness and correctness, there will be good fortune."},
+	"解": {40, "Xie", "Deliverance",
+		"In the state indicated by Xie advantage will be found in the south-west. If no further operations be called for, there will be good fortune in coming back to the old conditions. If some operations be called for, there will be good fortune in the early conducting of them."},
+	"损": {41, "Sun", "Decrease",
+		"In what is denoted by Sun, if there be sincerity in him who employs it, there will be great good fortune: freedom from error; firmness and correctness that can be maintained; and advantage in every movement that shall be made. In what shall this sincerity be employed? Even in sacrifice two baskets of grain, though there be nothing else, may be presented."},
+	"益": {42, "Yi", "Increase",
+		"Yi indicates that in the state which it denotes there will be advantage in every movement which shall be undertaken, that it will be advantageous even to cross the great stream."},
+	"夬": {43, "Guai", "Breakthrough",
+		"Guai requires that one should openly proclaim the guilt of the criminal in the royal court, and with sincerity and earnestness appeal for sympathy and support, with a consciousness of the peril involved in cutting off the criminal. He should also make announcement in his own city, and show that it will not be well to have recourse at once to arms. In this way there will be advantage in whatever he shall go forward to."},
+	"姤": {44, "Gou", "Coming to Meet",
+		"Gou shows a female who is bold and strong. It will not be good to marry such a female."},
+	"萃": {45, "Cui", "Gathering Together",
+		"In the state denoted by Cui, the king will repair to his ancestral temple. It will be advantageous also to meet with the great man; and then there will be progress and success, though the advantage must come through firm correctness. The use of great victims will conduce to good fortune; and in whatsoever direction movement is made, it will be advantageous."},
+	"升": {46, "Sheng", "Pushing Upward",
+		"Sheng indicates that under its conditions there will be great progress and success. Seeking by the qualities implied in it to meet with the great man, its subject need have no anxiety. Advance to the south will be fortunate."},
+	"困": {47, "Kun", "Oppression",
+		"In the condition denoted by Kun there may yet be progress and success. For the firm and correct, the really great man, there will be good fortune. He will fall into no error. If he make speeches, his words cannot be made good."},
+	"井": {48, "Jing", "The Well",
+		"Looking at Jing, we think of how the site of a town may be changed, while the fashion of its wells undergoes no change. The water of a well never disappears and never receives any great increase, and those who come and those who go draw and enjoy the benefit. If the drawing have nearly been accomplished, but, before the rope has quite reached the water, the bucket is broken, this is evil."},
+	"革": {49, "Ge", "Revolution",
+		"What takes place as indicated by Ge is believed in only after it has been accomplished. There will be great progress and success. Advantage will come from being firm and correct. In that case occasion for repentance will disappear."},
+	"鼎": {50, "Ding", "The Cauldron",
+		"Ding gives the intimation of great progress and success."},
+	"震": {51, "Zhen", "The Arousing",
+		"Zhen gives the intimation of ease and development. When the time of movement which it indicates comes, the subject of the hexagram will be found looking out with apprehension, and yet smiling and talking cheerfully. When the movement like a crash of thunder terrifies all within a hundred li, he will be like the sincere worshipper who is not startled into letting go his ladle and cup of sacrificial spirits."},
+	"艮": {52, "Gen", "Keeping Still",
+		"When one's resting is like that of the back, and he loses all consciousness of self; when he walks in his courtyard, and does not see any of the persons in it, there will be no error."},
+	"渐": {53, "Jian", "Development",
+		"Jian suggests to us the marriage of a young lady, and the good fortune attending it. There will be advantage in being firm and correct."},
+	"归妹": {54, "Gui Mei", "The Marrying Maiden",
+		"Gui Mei indicates that under the conditions which it denotes action will be evil, and in no wise advantageous."},
+	"丰": {55, "Feng", "Abundance",
+		"Feng intimates progress and development. When a king has reached the point which the name denotes, there is no occasion to be anxious through fear of a change. Let him be as the sun at noon."},
+	"旅": {56, "Lü", "The Wanderer",
+		"Lü intimates that in the condition which it denotes there may be some little attainment and progress. If the stranger or traveller be firm and correct as he ought to be, there will be good fortune."},
+	"巽": {57, "Xun", "The Gentle",
+		"Xun intimates that under the conditions which it denotes there will be some little attainment and progress. There will be advantage in movement onward in whatever direction. It will be advantageous also to see the great man."},
+	"兑": {58, "Dui", "The Joyous",
+		"Dui intimates that under its conditions there will be progress and attainment. But it will be advantageous to be firm and correct."},
+	"涣": {59, "Huan", "Dispersion",
+		"Huan intimates that under its conditions there will be progress and success. The king goes to his ancestral temple; and it will be advantageous to cross the great stream. It will be advantageous to be firm and correct."},
+	"节": {60, "Jie", "Limitation",
+		"Jie intimates that under its conditions there will be progress and attainment. But if the regulations which it prescribes be severe and difficult, they cannot be permanently maintained."},
+	"中孚": {61, "Zhong Fu", "Inner Truth",
+		"Zhong Fu moves even pigs and fish, and leads to good fortune. There will be advantage in crossing the great stream. There will be advantage in being firm and correct."},
+	"小过": {62, "Xiao Guo", "Preponderance of the Small",
+		"Xiao Guo indicates that in the circumstances which it implies there will be progress and attainment. But it will be advantageous to be firm and correct. What the name denotes may be done in small affairs, but not in great affairs. It is like the notes that come down from a bird on the wing; to descend is better than to ascend. There will in this way be great good fortune."},
+	"既济": {63, "Ji Ji", "After Completion",
+		"Ji Ji intimates progress and success in small matters. There will be advantage in being firm and correct. There has been good fortune in the beginning; there may be disorder in the end."},
+	"未济": {64, "Wei Ji", "Before Completion",
+		"Wei Ji intimates progress and success in the circumstances which it implies. We see a young fox that has nearly crossed the stream, when its tail gets immersed. There will be no advantage in any way."},
+}
+
+// 八卦（卦宫）的拼音
+var trigramPinyin = map[string]string{
+	"乾": "Qian", "坤": "Kun", "震": "Zhen", "巽": "Xun",
+	"坎": "Kan", "离": "Li", "艮": "Gen", "兑": "Dui",
+}
+
+// 天干地支的拼音，用于英文的四柱和纳甲
+var ganZhiPinyin = map[rune]string{
+	'甲': "jia", '乙': "yi", '丙': "bing", '丁': "ding", '戊': "wu",
+	'己': "ji", '庚': "geng", '辛': "xin", '壬': "ren", '癸': "gui",
+	'子': "zi", '丑': "chou", '寅': "yin", '卯': "mao", '辰': "chen", '巳': "si",
+	'午': "wu", '未': "wei", '申': "shen", '酉': "you", '戌': "xu", '亥': "hai",
+}
+
+// 六神、六亲和五行的英文
+// 六神在图中与爻并排，取简短的名称
+var (
+	liuShenEnglish = map[string]string{
+		"青龙": "Dragon", "朱雀": "Bird", "勾陈": "Hook",
+		"螣蛇": "Serpent", "白虎": "Tiger", "玄武": "Tortoise",
+	}
+	liuQinEnglish = map[string]string{
+		"父母": "Parents", "兄弟": "Siblings", "子孙": "Offspring", "妻财": "Wealth", "官鬼": "Officials",
+	}
+	wuXingEnglish = map[string]string{
+		"木": "Wood", "火": "Fire", "土": "Earth", "金": "Metal", "水": "Water",
+	}
+)
+
+// 爻位的英文名称，按传统译法，如"Nine at the beginning"、"Six in the second place"
+var yaoPlaceEnglish = []string{"at the beginning", "in the second place", "in the third place", "in the fourth place", "in the fifth place", "at the top"}
+
+// 动爻化出之爻对本爻作用的英文，用于PDF报告的排盘表和分析要点
+var transformEnglish = map[string]string{
+	"回头生": "returns nourishment", "回头克": "returns control",
+	"化泄": "drains", "化耗": "exhausts", "化比和": "same element",
+}
+
+// enLabels 图中固定标签、PDF报告标签和接口消息的英文，以简体标签为键
+var enLabels = map[string]string{
+	"主卦":    "Primary",
+	"变卦":    "Changed",
+	"爻辞":    "Line texts",
+	"爻辞：":   "Texts:",
+	"本卦爻辞：": "Primary hexagram:",
+	"变卦爻辞：": "Changed hexagram:",
+	"● 动爻":  "●",
+	"字":     "H",
+	"背":     "T",
+	"无爻辞":   "(no text)",
+	// PDF报告
+	"周易占卜报告": "Zhouyi Divination Report",
+	"占卜编号 ":  "Divination ",
+	"所问之事：":  "Question: ",
+	"起卦信息":   "Casting",
+	"起卦时间：":  "Cast at: ",
+	"起卦方法：":  "Method: ",
+	"三枚铜钱摇六次（随机种子 %d）": "three coins tossed six times (random seed %d)",
+	"未记录":         "not recorded",
+	"年柱":          "Year",
+	"月柱":          "Month",
+	"日柱":          "Day",
+	"时柱":          "Hour",
+	"卦象":          "Hexagrams",
+	"本卦":          "Primary",
+	"卦辞：":         "Judgment: ",
+	"彖曰：":         "Commentary on the Judgment: ",
+	"象曰：":         "Image: ",
+	"　（动）":        " (moving)",
+	"动爻":          "Moving lines",
+	"六爻安静，无动爻。":   "All six lines are at rest.",
+	"变为 ":         "Becomes ",
+	"排盘分析":        "Analysis",
+	"爻位":          "Line",
+	"六神":          "Spirit",
+	"六亲纳甲":        "Relation",
+	"世应":          "Shi/Ying",
+	"动变":          "Change",
+	"世":           "Shi",
+	"应":           "Ying",
+	"备注":          "Notes",
+	"第 %d / %d 页": "Page %d of %d",
+	"成功":          "success",
+	"生成卦象失败":      "failed to cast the hexagram",
+	"请求参数错误":      "invalid request",
+}
@@ -15,7 +15,10 @@ package main
 
 import (
 	"log"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 )
 
 // main 是程序的入口函数，负责系统初始化和服务启动
@@ -26,6 +29,8 @@ import (
 // 3. 预热系统缓存，包括字体文件和背景图片的预加载
 // 4. 启动HTTP服务器和WebSocket服务
 //
+// 如果命令行带有子命令（如prewarm），则执行对应的管理命令后退出
+//
 // 使用并发方式预加载资源以提高启动速度
 func main() {
 	// 初始化日志系统
@@ -39,28 +44,50 @@ func main() {
 		log.Fatalf("配置初始化失败: %v", err)
 	}
 
+	// 执行管理命令，完成后直接退出，不启动服务
+	if len(os.Args) > 1 {
+		err := runAdminCommand(os.Args[1], os.Args[2:])
+		flushCalendarCache()
+		if err != nil {
+			log.Fatalf("管理命令执行失败: %v", err)
+		}
+		return
+	}
+
 	// 预热系统缓存，提高首次请求的响应速度
 	// 使用WaitGroup确保所有预加载任务完成后再启动服务器
 	var wg sync.WaitGroup
 	wg.Add(2)
 
+	// 默认主题和版式，预加载其字体和背景
+	theme, _ := resolveTheme("", 0)
+	layoutName, _ := normalizeLayoutName("")
+	tpl, _ := getChartTemplate(layoutName)
+	theme = tpl.applyFonts(theme)
+
 	// 并行预加载字体文件
-	// 尝试加载项目字体文件或系统字体，为卦象图片生成做准备
+	// 加载并解析默认主题的字体，创建一组字体面放入对象池，为卦象图片生成做准备
 	go func() {
 		defer wg.Done()
-		_, err := loadFontFile()
+		faces, err := acquireFaces(theme)
 		if err != nil {
 			log.Printf("预加载字体失败: %v", err)
-		} else {
-			log.Printf("字体文件预加载完成")
+			return
+		}
+		releaseFaces(theme, faces)
+		log.Printf("字体文件预加载完成")
+
+		// 检查字体能否显示卦象图的全部文字，缺字时在日志中列出
+		if _, err := reportMissingGlyphs(theme); err != nil {
+			log.Printf("字体检查失败: %v", err)
 		}
 	}()
 
 	// 并行预加载背景图片
-	// 加载或生成默认的渐变背景图片，用于卦象图片的背景
+	// 加载默认主题和版式尺寸的背景图片，用于卦象图片的背景
 	go func() {
 		defer wg.Done()
-		getBackground(ImageWidth, ImageHeight, "images/background.png")
+		getBackground(theme, tpl.Canvas.Width, tpl.Canvas.MinHeight)
 		log.Printf("背景图片预加载完成")
 	}()
 
@@ -83,7 +110,30 @@ func main() {
 	// 启动定期清理任务
 	startPeriodicCleanup()
 
+	// 加载持久化的万年历缓存，并按配置在后台预热
+	getCalendarCache()
+	startCalendarPrewarm()
+
+	// 加载占卜历史
+	getHistoryStore()
+
+	// 收到退出信号时先将未落盘的缓存写入磁盘
+	handleShutdownSignals()
+
 	// 启动HTTP服务器
 	// 包括API路由设置、WebSocket服务初始化、静态文件服务等
 	startServer()
 }
+
+// handleShutdownSignals 在后台等待中断和终止信号，收到后保存万年历缓存再退出
+// 占卜历史逐条追加写入，无需在退出时处理
+func handleShutdownSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("收到信号 %v，保存缓存后退出", sig)
+		flushCalendarCache()
+		os.Exit(0)
+	}()
+}
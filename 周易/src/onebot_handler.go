@@ -3,6 +3,7 @@
 package main
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -366,13 +367,28 @@ func (c *OneBotClient) SendHeartbeatEvent() error {
 	return c.SendEvent(event)
 }
 
+// divineImageFile 返回图片消息段的file字段
+// 优先使用落盘图片的完整链接，未落盘时从内存存储取出图片以base64://形式发送
+func divineImageFile(result *DivineResult) string {
+	if result.ImagePath != "" {
+		return publicLink(publicBaseURL(nil), result.ImagePath)
+	}
+	if result.ImageData != "" {
+		return "base64://" + result.ImageData
+	}
+	if img, found := getImageStore().Get(result.ID); found {
+		return "base64://" + base64.StdEncoding.EncodeToString(img.Data)
+	}
+	return ""
+}
+
 // 发送占卜结果作为群消息事件
 func (c *OneBotClient) SendDivineResultAsGroupMessage(groupId int64, result *DivineResult) error {
 	// 创建消息段
 	var message Message
 	message = append(message, NewTextSegment(fmt.Sprintf("今日卦象：%s", result.BenGua)))
-	if result.ImagePath != "" {
-		message = append(message, NewImageSegment(result.ImagePath))
+	if file := divineImageFile(result); file != "" {
+		message = append(message, NewImageSegment(file))
 	}
 	message = append(message, NewTextSegment(fmt.Sprintf("日期：%s", result.Date)))
 
@@ -407,8 +423,8 @@ func (c *OneBotClient) SendDivineResultAsPrivateMessage(userId int64, result *Di
 	// 创建消息段
 	var message Message
 	message = append(message, NewTextSegment(fmt.Sprintf("今日卦象：%s", result.BenGua)))
-	if result.ImagePath != "" {
-		message = append(message, NewImageSegment(result.ImagePath))
+	if file := divineImageFile(result); file != "" {
+		message = append(message, NewImageSegment(file))
 	}
 	message = append(message, NewTextSegment(fmt.Sprintf("日期：%s", result.Date)))
 
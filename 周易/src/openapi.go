@@ -0,0 +1,835 @@
+// openapi.go 生成描述全部HTTP接口和WebSocket/OneBot消息的OpenAPI 3文档
+// 文档与代码同源：请求和响应的结构按Go类型的json标签反射得到，主题、版式、品牌等枚举取自注册表，
+// 每个经handleAPI注册的路由都必须在文档中有对应的操作，否则启动失败。
+// 文档在 /api/v1/openapi.json 提供，/api/v1/docs 为内嵌的交互式查看页面；
+// 请求进入处理器前按文档中的参数和请求体结构校验，见request_validation.go
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// openAPIVersion 文档遵循的OpenAPI版本
+const openAPIVersion = "3.0.3"
+
+// apiDocumentVersion 接口文档的版本，与路径中的/api/v1对应
+const apiDocumentVersion = "1.0.0"
+
+// openAPIDocument OpenAPI文档
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Servers    []openAPIServer                         `json:"servers,omitempty"`
+	Tags       []openAPITag                            `json:"tags"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+
+	operations map[string]*openAPIOperation // 按"方法 路径"索引的操作，路径为完整路径
+	registered map[string]bool              // 已由handleAPI注册路由的操作
+}
+
+// openAPIInfo 文档的基本信息
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+// openAPIServer 接口的访问地址
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+// openAPITag 接口分组
+type openAPITag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// openAPIComponents 可复用的结构定义，键为Go类型名
+type openAPIComponents struct {
+	Schemas map[string]*openAPISchema `json:"schemas"`
+}
+
+// openAPIOperation 一个接口操作
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	WebSocket   *openAPIWebSocket           `json:"x-websocket,omitempty"` // WebSocket端点的消息结构，OpenAPI本身无法描述
+}
+
+// openAPIParameter 路径或查询参数
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"` // path或query
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+// openAPIRequestBody 请求体
+type openAPIRequestBody struct {
+	Description string                       `json:"description,omitempty"`
+	Required    bool                         `json:"required,omitempty"`
+	Content     map[string]*openAPIMediaType `json:"content"`
+
+	maxBytes int64 // 校验时读取的请求体上限
+}
+
+// openAPIResponse 响应
+type openAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*openAPIMediaType `json:"content,omitempty"`
+}
+
+// openAPIMediaType 请求体或响应的一种内容类型
+type openAPIMediaType struct {
+	Schema  *openAPISchema `json:"schema"`
+	Example interface{}    `json:"example,omitempty"`
+}
+
+// openAPIWebSocket WebSocket端点收发的消息，键为消息类型，值为消息结构
+type openAPIWebSocket struct {
+	Client map[string]*openAPISchema `json:"client"` // 客户端发送的消息
+	Server map[string]*openAPISchema `json:"server"` // 服务端推送的消息
+}
+
+// openAPISchema 数据结构，取JSON Schema中本服务用到的部分
+// 字符串枚举不区分大小写，与各参数的规范化函数一致
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Enum                 []string                  `json:"enum,omitempty"`
+	Default              interface{}               `json:"default,omitempty"`
+	Minimum              *float64                  `json:"minimum,omitempty"`
+	Maximum              *float64                  `json:"maximum,omitempty"`
+	MaxLength            *int                      `json:"maxLength,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+	OneOf                []*openAPISchema          `json:"oneOf,omitempty"`
+	Example              interface{}               `json:"example,omitempty"`
+}
+
+// schemaRefPrefix 引用components中结构的前缀
+const schemaRefPrefix = "#/components/schemas/"
+
+// 文档单例，首次使用时生成，主题等注册表在此之前已加载完毕
+var (
+	openAPIDoc     *openAPIDocument
+	openAPIDocOnce sync.Once
+)
+
+// getOpenAPIDocument 返回接口文档
+func getOpenAPIDocument() *openAPIDocument {
+	openAPIDocOnce.Do(func() {
+		openAPIDoc = buildOpenAPIDocument()
+	})
+	return openAPIDoc
+}
+
+// operation 按路由pattern查找操作，pattern形如"GET /api/v1/divine/{id}"
+func (d *openAPIDocument) operation(pattern string) (*openAPIOperation, bool) {
+	op, ok := d.operations[pattern]
+	return op, ok
+}
+
+// markRegistered 记录操作已注册路由，文档中没有该路由时启动失败
+func (d *openAPIDocument) markRegistered(pattern string) *openAPIOperation {
+	op, ok := d.operation(pattern)
+	if !ok {
+		log.Fatalf("接口 %s 未在OpenAPI文档中描述，请在openapi.go中补充", pattern)
+	}
+	d.registered[pattern] = true
+	return op
+}
+
+// checkRegistered 检查文档中/api下的操作都已注册路由，避免文档描述了不存在的接口
+func (d *openAPIDocument) checkRegistered() {
+	for pattern := range d.operations {
+		_, path, _ := strings.Cut(pattern, " ")
+		if strings.HasPrefix(path, apiV1Prefix+"/") && !d.registered[pattern] {
+			log.Fatalf("OpenAPI文档中的接口 %s 没有注册路由", pattern)
+		}
+	}
+}
+
+// add 添加操作，path为完整路径
+func (d *openAPIDocument) add(method, path string, op *openAPIOperation) {
+	if d.Paths[path] == nil {
+		d.Paths[path] = make(map[string]*openAPIOperation)
+	}
+	d.Paths[path][strings.ToLower(method)] = op
+	d.operations[method+" "+path] = op
+}
+
+// resolve 取得引用指向的结构，非引用原样返回
+func (d *openAPIDocument) resolve(schema *openAPISchema) *openAPISchema {
+	for schema != nil && schema.Ref != "" {
+		schema = d.Components.Schemas[strings.TrimPrefix(schema.Ref, schemaRefPrefix)]
+	}
+	return schema
+}
+
+// schemaGenerator 按Go类型生成结构，具名结构体放入components并以引用返回
+type schemaGenerator struct {
+	schemas map[string]*openAPISchema
+}
+
+// schemaOf 返回值的类型对应的结构
+func (g *schemaGenerator) schemaOf(value interface{}) *openAPISchema {
+	return g.schema(reflect.TypeOf(value))
+}
+
+// component 取得已生成的具名结构，用于补充说明和约束
+func (g *schemaGenerator) component(value interface{}) *openAPISchema {
+	t := reflect.TypeOf(value)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	g.schema(t)
+	return g.schemas[t.Name()]
+}
+
+// schema 返回类型对应的结构
+func (g *schemaGenerator) schema(t reflect.Type) *openAPISchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	case reflect.TypeOf(json.RawMessage{}):
+		return &openAPISchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &openAPISchema{Type: "string", Format: "byte"}
+		}
+		return &openAPISchema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		if _, exists := g.schemas[t.Name()]; !exists {
+			// 先占位，结构体引用自身时不会无限递归
+			g.schemas[t.Name()] = &openAPISchema{}
+			*g.schemas[t.Name()] = *g.object(t)
+		}
+		return &openAPISchema{Ref: schemaRefPrefix + t.Name()}
+	}
+	// interface{}等任意值
+	return &openAPISchema{}
+}
+
+// object 按结构体字段的json标签生成对象结构，未标omitempty的字段为必有字段
+// 匿名嵌入的结构体字段提升到外层，与外层字段同名时以外层为准
+func (g *schemaGenerator) object(t reflect.Type) *openAPISchema {
+	schema := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if field.Anonymous && tag == "" {
+			embedded = append(embedded, field.Type)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = g.schema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+
+	for _, inner := range embedded {
+		for inner.Kind() == reflect.Pointer {
+			inner = inner.Elem()
+		}
+		g.schema(inner)
+		promoted := g.object(inner)
+		for name, property := range promoted.Properties {
+			if _, exists := schema.Properties[name]; exists {
+				continue
+			}
+			schema.Properties[name] = property
+			for _, required := range promoted.Required {
+				if required == name {
+					schema.Required = append(schema.Required, name)
+				}
+			}
+		}
+	}
+	sort.Strings(schema.Required)
+	return schema
+}
+
+// floatPtr 返回浮点数的指针，用于结构中的取值范围
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+// intPtr 返回整数的指针，用于结构中的长度上限
+func intPtr(v int) *int {
+	return &v
+}
+
+// describe 为对象结构的各属性补充说明，键为属性名
+func describe(schema *openAPISchema, descriptions map[string]string) {
+	for name, description := range descriptions {
+		if property, ok := schema.Properties[name]; ok {
+			property.Description = description
+		}
+	}
+}
+
+// apiEnvelope 成功响应的结构，data为接口返回的数据
+func apiEnvelope(data *openAPISchema) *openAPISchema {
+	return &openAPISchema{
+		Type: "object",
+		Properties: map[string]*openAPISchema{
+			"code":    {Type: "integer", Description: "与HTTP状态码一致"},
+			"message": {Type: "string"},
+			"data":    data,
+		},
+		Required: []string{"code", "data", "message"},
+	}
+}
+
+// jsonResponse 返回数据以ApiResponse包装的JSON响应
+func jsonResponse(description string, data *openAPISchema) *openAPIResponse {
+	return &openAPIResponse{
+		Description: description,
+		Content:     map[string]*openAPIMediaType{"application/json": {Schema: apiEnvelope(data)}},
+	}
+}
+
+// binaryResponse 返回二进制内容的响应，如图片和PDF
+func binaryResponse(description string, contentTypes ...string) *openAPIResponse {
+	content := make(map[string]*openAPIMediaType, len(contentTypes))
+	for _, contentType := range contentTypes {
+		content[contentType] = &openAPIMediaType{Schema: &openAPISchema{Type: "string", Format: "binary"}}
+	}
+	return &openAPIResponse{Description: description, Content: content}
+}
+
+// withErrors 为操作补充错误响应，错误响应统一为带error字段的ApiResponse
+func withErrors(responses map[string]*openAPIResponse, statuses ...int) map[string]*openAPIResponse {
+	for _, status := range statuses {
+		responses[fmt.Sprint(status)] = &openAPIResponse{
+			Description: http.StatusText(status),
+			Content: map[string]*openAPIMediaType{
+				"application/json": {Schema: &openAPISchema{Ref: schemaRefPrefix + "ApiResponse"}},
+			},
+		}
+	}
+	return responses
+}
+
+// queryParam 返回查询参数
+func queryParam(name, description string, schema *openAPISchema) *openAPIParameter {
+	return &openAPIParameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+// pathIDParam 路径中的占卜ID
+var pathIDParam = &openAPIParameter{
+	Name: "id", In: "path", Required: true, Description: "占卜ID，如divine_1640995200000000000_3f9a1c2e",
+	Schema: &openAPISchema{Type: "string"},
+}
+
+// imageFormatNames 返回请求中可用的图片格式，jpg为jpeg的别名
+func imageFormatNames() []string {
+	return []string{ImageFormatPNG, ImageFormatJPEG, "jpg", ImageFormatSVG, ImageFormatGIF, ImageFormatAPNG}
+}
+
+// oneBotActionNames 返回OneBot客户端支持的动作名称，按字母排序
+func oneBotActionNames() []string {
+	client := &OneBotClient{Handlers: make(map[string]func(*OneBotClient, *OneBotAction) *OneBotActionResponse)}
+	client.registerActionHandlers()
+	names := make([]string, 0, len(client.Handlers))
+	for name := range client.Handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// enumSchema 返回字符串枚举
+func enumSchema(values []string, description string) *openAPISchema {
+	return &openAPISchema{Type: "string", Enum: values, Description: description}
+}
+
+// buildOpenAPIDocument 生成接口文档
+func buildOpenAPIDocument() *openAPIDocument {
+	g := &schemaGenerator{schemas: make(map[string]*openAPISchema)}
+	doc := &openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info: openAPIInfo{
+			Title: "周易占卜系统 API",
+			Description: "所有接口位于/api/v1下，旧的/api路径作为别名继续可用。" +
+				"出错时返回带error字段的ApiResponse，error.code为机器可读的错误码，error.field为出错的参数。" +
+				"字符串枚举不区分大小写。",
+			Version: apiDocumentVersion,
+		},
+		Tags: []openAPITag{
+			{Name: "占卜", Description: "起卦、图片、报告和占卜历史"},
+			{Name: "历法", Description: "四柱、农历和节气查询"},
+			{Name: "系统", Description: "主题列表、状态查询和接口文档"},
+			{Name: "WebSocket", Description: "实时推送和OneBot协议，消息结构见x-websocket"},
+		},
+		Paths:      make(map[string]map[string]*openAPIOperation),
+		operations: make(map[string]*openAPIOperation),
+		registered: make(map[string]bool),
+	}
+
+	// 统一响应和错误
+	g.schemaOf(ApiResponse{})
+	errorDetail := g.component(ErrorDetail{})
+	errorDetail.Properties["code"].Enum = apiErrorCodes
+	describe(errorDetail, map[string]string{
+		"code":  "机器可读的错误码",
+		"field": "出错的参数名，JSON字段或查询参数，与参数无关时省略",
+	})
+
+	themeNameSchema := func() *openAPISchema { return enumSchema(themeNames(), "图片主题名称") }
+	layoutNameSchema := func() *openAPISchema { return enumSchema(layoutNames(), "版式名称") }
+	formatSchema := func() *openAPISchema { return enumSchema(imageFormatNames(), "图片格式") }
+	qualitySchema := func() *openAPISchema {
+		return &openAPISchema{Type: "integer", Minimum: floatPtr(0), Maximum: floatPtr(100), Description: "JPEG的编码质量，0表示使用配置的默认值"}
+	}
+	localeSchema := func() *openAPISchema {
+		return &openAPISchema{Type: "string", Description: "语言：" + strings.Join(localeNames(), "、") + "，也接受zh-TW、en-US等语言标签"}
+	}
+	longitudeSchema := func() *openAPISchema {
+		return &openAPISchema{Type: "number", Minimum: floatPtr(-180), Maximum: floatPtr(180), Description: "经度，东经为正，指定后按真太阳时排时柱"}
+	}
+	divineTypeSchema := func() *openAPISchema {
+		return enumSchema([]string{DivineTypeToday, DivineTypeCast}, "占卜类型：today为今日卦象，每个用户每天一卦，未带user_id时按X-User-ID请求头或客户端地址区分用户；cast为临时起卦。省略时带user_id按today，否则按cast")
+	}
+
+	// 占卜请求：user_id可为字符串或数字，所有字段都可省略
+	divineRequest := g.component(DivineRequest{})
+	divineRequest.Description = "占卜请求，所有字段都可省略"
+	divineRequest.Required = nil
+	divineRequest.Properties["type"] = divineTypeSchema()
+	divineRequest.Properties["format"] = formatSchema()
+	divineRequest.Properties["quality"] = qualitySchema()
+	divineRequest.Properties["theme"] = themeNameSchema()
+	divineRequest.Properties["layout"] = layoutNameSchema()
+	divineRequest.Properties["brand"] = enumSchema(brandingNames(), "品牌叠加配置名称，none表示不叠加")
+	divineRequest.Properties["locale"] = localeSchema()
+	divineRequest.Properties["longitude"] = longitudeSchema()
+	divineRequest.Properties["question"].MaxLength = intPtr(maxQuestionLength)
+	divineRequest.Properties["category"].MaxLength = intPtr(maxCategoryLength)
+	divineRequest.Properties["user_id"] = &openAPISchema{
+		Description: "起卦用户，今日卦象按此每天一卦，OneBot的QQ号可直接填数字",
+		OneOf:       []*openAPISchema{{Type: "string"}, {Type: "integer", Format: "int64"}},
+	}
+	describe(divineRequest, map[string]string{
+		"datetime": "起卦时间，如\"2025-01-01 14:30\"或RFC3339，为空表示当前时间",
+		"timezone": "起卦地的IANA时区，如\"America/New_York\"，为空表示北京时间",
+		"group_id": "OneBot群号，用于选择该群配置的主题和品牌",
+		"inline":   "是否在结果中直接返回Base64编码的图片",
+		"question": "所问之事，写入图片元数据，不绘制在图中",
+		"category": "问事分类，如\"事业\"、\"感情\"，记入占卜历史，可按分类查询",
+	})
+
+	divineResult := g.schemaOf(DivineResult{})
+	historyRecord := g.schemaOf(HistoryRecord{})
+	errorStatuses := []int{http.StatusBadRequest, http.StatusInternalServerError}
+
+	doc.add(http.MethodPost, apiPath("/divine"), &openAPIOperation{
+		OperationID: "createDivination",
+		Summary:     "起卦",
+		Description: "生成卦象和卦象图，未指定格式时按Accept请求头协商，未指定语言时使用默认语言（开启render.negotiate_locale时按Accept-Language协商）。结果同时推送给WebSocket客户端。",
+		Tags:        []string{"占卜"},
+		Parameters: []*openAPIParameter{
+			queryParam("inline", "与请求体中的inline等效", &openAPISchema{Type: "boolean"}),
+			queryParam("format", "请求体未指定格式时使用", formatSchema()),
+			queryParam("quality", "请求体未指定质量时使用", qualitySchema()),
+			queryParam("locale", "请求体未指定语言时使用", localeSchema()),
+			{Name: userIDHeader, In: "header", Description: "请求体未带user_id的今日卦象以此区分用户，未提供时按客户端地址", Schema: &openAPISchema{Type: "string"}},
+		},
+		RequestBody: &openAPIRequestBody{
+			Required: true,
+			Content: map[string]*openAPIMediaType{"application/json": {
+				Schema:  &openAPISchema{Ref: schemaRefPrefix + "DivineRequest"},
+				Example: map[string]interface{}{"type": DivineTypeCast, "question": "近期运势如何", "format": ImageFormatPNG},
+			}},
+			maxBytes: maxDivineRequestBytes,
+		},
+		Responses: withErrors(map[string]*openAPIResponse{"200": jsonResponse("占卜结果", divineResult)},
+			append(errorStatuses, http.StatusRequestEntityTooLarge)...),
+	})
+
+	// 配置了history.access_token时查询历史须带令牌，未配置时结果中不含所问之事
+	historyAuthParam := &openAPIParameter{
+		Name: "Authorization", In: "header", Description: "Bearer <history.access_token>，配置了访问令牌时必填，带正确令牌时结果中包含所问之事",
+		Schema: &openAPISchema{Type: "string"},
+	}
+	doc.add(http.MethodGet, apiPath("/divine"), &openAPIOperation{
+		OperationID: "listDivinations",
+		Summary:     "占卜历史分页列表",
+		Description: "最近的记录在前，未启用占卜历史时返回404。配置了访问令牌时须带令牌，否则返回401；未配置令牌时不返回所问之事。",
+		Tags:        []string{"占卜"},
+		Parameters: []*openAPIParameter{
+			queryParam("page", "页码，从1开始", &openAPISchema{Type: "integer", Minimum: floatPtr(1), Default: 1}),
+			queryParam("page_size", "每页条数", &openAPISchema{Type: "integer", Minimum: floatPtr(1), Maximum: floatPtr(maxHistoryPageSize), Default: defaultHistoryPageSize}),
+			queryParam("from", "起卦日期下限（含），按起卦地的当地日期比较", &openAPISchema{Type: "string", Format: "date"}),
+			queryParam("to", "起卦日期上限（含）", &openAPISchema{Type: "string", Format: "date"}),
+			queryParam("gua", "本卦或变卦的卦名，简称或全称，如乾、天风姤", &openAPISchema{Type: "string"}),
+			queryParam("user_id", "起卦用户", &openAPISchema{Type: "string"}),
+			queryParam("group_id", "OneBot群号", &openAPISchema{Type: "integer", Format: "int64"}),
+			queryParam("type", "占卜类型", divineTypeSchema()),
+			queryParam("category", "问事分类，与起卦请求中的category完全相同", &openAPISchema{Type: "string"}),
+			historyAuthParam,
+		},
+		Responses: withErrors(map[string]*openAPIResponse{"200": jsonResponse("一页占卜记录", g.schemaOf(HistoryPage{}))},
+			http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound),
+	})
+
+	doc.add(http.MethodGet, apiPath("/divine/{id}"), &openAPIOperation{
+		OperationID: "getDivination",
+		Summary:     "按ID查询占卜记录",
+		Description: "返回起卦时的完整结果和盘面，图片被清理后记录仍然保留。访问令牌的要求同历史列表。",
+		Tags:        []string{"占卜"},
+		Parameters:  []*openAPIParameter{pathIDParam, historyAuthParam},
+		Responses: withErrors(map[string]*openAPIResponse{"200": jsonResponse("占卜记录", historyRecord)},
+			http.StatusUnauthorized, http.StatusNotFound),
+	})
+
+	signedParams := []*openAPIParameter{
+		queryParam(linkExpiresParam, "签名链接的过期时刻，Unix秒，开启签名链接时必填", &openAPISchema{Type: "integer", Format: "int64"}),
+		queryParam(linkSignatureParam, "签名链接的HMAC-SHA256签名，开启签名链接时必填", &openAPISchema{Type: "string"}),
+	}
+	doc.add(http.MethodGet, apiPath("/divine/{id}/image"), &openAPIOperation{
+		OperationID: "getDivinationImage",
+		Summary:     "卦象图片",
+		Description: "静态位图可按format参数或Accept请求头转换格式，size为thumb或hires时输出缩略图或高清图。同一客户端新渲染高清图过于频繁时返回429，响应头Retry-After给出等待秒数。",
+		Tags:        []string{"占卜"},
+		Parameters: append([]*openAPIParameter{
+			pathIDParam,
+			queryParam("format", "转换后的图片格式，只对静态位图有效", formatSchema()),
+			queryParam("quality", "转换为JPEG时的编码质量", qualitySchema()),
+			queryParam("size", "图片尺寸，thumbnail为thumb的别名", enumSchema([]string{ImageSizeOriginal, ImageSizeThumb, "thumbnail", ImageSizeHiRes}, "图片尺寸")),
+		}, signedParams...),
+		Responses: withErrors(map[string]*openAPIResponse{
+			"200": binaryResponse("卦象图片", "image/png", "image/jpeg", "image/svg+xml", "image/gif", "image/apng"),
+		}, http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusTooManyRequests, http.StatusInternalServerError),
+	})
+
+	doc.add(http.MethodGet, apiPath("/divine/{id}/report.pdf"), &openAPIOperation{
+		OperationID: "getDivinationReport",
+		Summary:     "PDF报告",
+		Description: "卦象图的主题默认沿用原图，版式默认为横版。",
+		Tags:        []string{"占卜"},
+		Parameters: append([]*openAPIParameter{
+			pathIDParam,
+			queryParam("theme", "卦象图的主题", themeNameSchema()),
+			queryParam("layout", "卦象图的版式", layoutNameSchema()),
+		}, signedParams...),
+		Responses: withErrors(map[string]*openAPIResponse{"200": binaryResponse("PDF报告", "application/pdf")},
+			http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError),
+	})
+
+	doc.add(http.MethodPost, apiPath("/divine/extract"), &openAPIOperation{
+		OperationID: "extractChart",
+		Summary:     "从图片取回盘面",
+		Description: "上传本服务生成的PNG、JPEG或SVG原图，取回其中的盘面元数据；render=true时按盘面重新渲染一张新图。",
+		Tags:        []string{"占卜"},
+		Parameters: []*openAPIParameter{
+			queryParam("render", "是否按盘面重新渲染", &openAPISchema{Type: "boolean"}),
+			queryParam("format", "重新渲染的图片格式", formatSchema()),
+			queryParam("quality", "重新渲染的编码质量", qualitySchema()),
+			queryParam("theme", "重新渲染的主题，默认沿用原图", themeNameSchema()),
+			queryParam("layout", "重新渲染的版式，默认沿用原图", layoutNameSchema()),
+			queryParam("brand", "重新渲染的品牌，默认沿用原图", enumSchema(brandingNames(), "品牌叠加配置名称")),
+			queryParam("locale", "重新渲染的语言，盘面本身不变", localeSchema()),
+		},
+		RequestBody: &openAPIRequestBody{
+			Description: "图片以multipart表单的image字段上传，也可直接作为请求体",
+			Required:    true,
+			Content: map[string]*openAPIMediaType{
+				"multipart/form-data": {Schema: &openAPISchema{
+					Type:       "object",
+					Properties: map[string]*openAPISchema{"image": {Type: "string", Format: "binary"}},
+					Required:   []string{"image"},
+				}},
+				"image/png":     {Schema: &openAPISchema{Type: "string", Format: "binary"}},
+				"image/jpeg":    {Schema: &openAPISchema{Type: "string", Format: "binary"}},
+				"image/svg+xml": {Schema: &openAPISchema{Type: "string", Format: "binary"}},
+			},
+		},
+		Responses: withErrors(map[string]*openAPIResponse{"200": jsonResponse("盘面元数据", g.schemaOf(ChartExtractResult{}))},
+			http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity, http.StatusInternalServerError),
+	})
+
+	doc.add(http.MethodGet, apiPath("/calendar"), &openAPIOperation{
+		OperationID: "getCalendar",
+		Summary:     "历法查询",
+		Description: "返回四柱、纳音、空亡、农历和节气，指定time后才排时柱。",
+		Tags:        []string{"历法"},
+		Parameters: []*openAPIParameter{
+			{Name: "date", In: "query", Required: true, Description: "公历日期", Schema: &openAPISchema{Type: "string", Format: "date"}},
+			queryParam("time", "钟点，HH:MM", &openAPISchema{Type: "string", Example: "23:30"}),
+			queryParam("tz", "IANA时区，默认北京时间", &openAPISchema{Type: "string", Example: defaultTimezone}),
+			queryParam("longitude", "经度，指定后按真太阳时排时柱", longitudeSchema()),
+		},
+		Responses: withErrors(map[string]*openAPIResponse{"200": jsonResponse("历法信息", g.schemaOf(CalendarInfo{}))},
+			http.StatusBadRequest, http.StatusBadGateway),
+	})
+
+	doc.add(http.MethodGet, apiPath("/themes"), &openAPIOperation{
+		OperationID: "listThemes",
+		Summary:     "主题和版式列表",
+		Tags:        []string{"系统"},
+		Responses: map[string]*openAPIResponse{"200": jsonResponse("可用的主题和版式模板", &openAPISchema{
+			Type: "object",
+			Properties: map[string]*openAPISchema{
+				"default":        {Type: "string", Description: "默认主题"},
+				"themes":         {Type: "array", Items: g.schemaOf(Theme{})},
+				"default_layout": {Type: "string", Description: "默认版式"},
+				"layouts":        {Type: "array", Items: &openAPISchema{Type: "string"}},
+				"templates":      {Type: "array", Items: g.schemaOf(ChartTemplate{})},
+			},
+		})},
+	})
+
+	statusCommon := map[string]*openAPISchema{
+		"connected_clients": {Type: "integer", Description: "当前连接数"},
+		"server_time":       {Type: "integer", Format: "int64", Description: "服务器时间，Unix秒"},
+		"calendar_cache":    {Type: "object", Description: "万年历缓存的条目数和命中率"},
+		"calendar_verify":   {Type: "object", Description: "万年历API与本地历法的比对统计"},
+	}
+	wsStatus := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{"websocket_enabled": {Type: "boolean"}}}
+	oneBotStatus := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{
+		"onebot_enabled": {Type: "boolean"},
+		"onebot_version": {Type: "string"},
+		"implementation": {Type: "string"},
+		"image_store":    {Type: "object", Description: "卦象图内存存储的统计"},
+	}}
+	for name, property := range statusCommon {
+		wsStatus.Properties[name] = property
+		oneBotStatus.Properties[name] = property
+	}
+	doc.add(http.MethodGet, apiPath("/ws/status"), &openAPIOperation{
+		OperationID: "getWebSocketStatus",
+		Summary:     "WebSocket状态",
+		Tags:        []string{"系统"},
+		Responses:   map[string]*openAPIResponse{"200": jsonResponse("WebSocket服务状态", wsStatus)},
+	})
+	doc.add(http.MethodGet, apiPath("/onebot/status"), &openAPIOperation{
+		OperationID: "getOneBotStatus",
+		Summary:     "OneBot状态",
+		Tags:        []string{"系统"},
+		Responses:   map[string]*openAPIResponse{"200": jsonResponse("OneBot服务状态", oneBotStatus)},
+	})
+
+	doc.add(http.MethodGet, apiPath("/openapi.json"), &openAPIOperation{
+		OperationID: "getOpenAPIDocument",
+		Summary:     "OpenAPI文档",
+		Description: "即本文档，servers按请求的访问地址生成。",
+		Tags:        []string{"系统"},
+		Responses: map[string]*openAPIResponse{"200": {
+			Description: "OpenAPI 3文档",
+			Content:     map[string]*openAPIMediaType{"application/json": {Schema: &openAPISchema{Type: "object"}}},
+		}},
+	})
+	doc.add(http.MethodGet, apiPath("/docs"), &openAPIOperation{
+		OperationID: "getAPIDocsViewer",
+		Summary:     "交互式接口文档",
+		Description: "浏览本文档并直接发送请求的页面，不依赖外部资源。",
+		Tags:        []string{"系统"},
+		Responses: map[string]*openAPIResponse{"200": {
+			Description: "HTML页面",
+			Content:     map[string]*openAPIMediaType{"text/html": {Schema: &openAPISchema{Type: "string"}}},
+		}},
+	})
+
+	// WebSocket端点：消息为{"type": 类型, "data": 数据}，下面按类型给出data的结构
+	switchingProtocols := map[string]*openAPIResponse{"101": {Description: "升级为WebSocket连接"}}
+	doc.add(http.MethodGet, "/ws", &openAPIOperation{
+		OperationID: "connectWebSocket",
+		Summary:     "WebSocket实时推送",
+		Description: "消息格式为WSMessage，x-websocket按type列出data的结构。客户端发送divine消息起卦，data与占卜请求相同，" +
+			"未填写user_id时使用连接时的name，也没有name的今日卦象按连接时的X-User-ID请求头或客户端地址区分用户。HTTP接口起卦的结果同样推送给所有连接。",
+		Tags:       []string{"WebSocket"},
+		Parameters: []*openAPIParameter{queryParam("name", "客户端名称，起卦请求未带user_id时作为今日卦象的用户标识", &openAPISchema{Type: "string"})},
+		Responses:  switchingProtocols,
+		WebSocket: &openAPIWebSocket{
+			Client: map[string]*openAPISchema{
+				WSEventDivine:    {Ref: schemaRefPrefix + "DivineRequest"},
+				WSEventHeartbeat: {Type: "object"},
+			},
+			Server: map[string]*openAPISchema{
+				WSEventConnect: g.schemaOf(WSConnection{}),
+				WSEventDivine:  divineResult,
+				WSEventHeartbeat: {Type: "object", Properties: map[string]*openAPISchema{
+					"timestamp": {Type: "integer", Format: "int64"},
+					"server":    {Type: "string"},
+					"clients":   {Type: "integer", Description: "定时心跳时的连接数"},
+					"client_id": {Type: "string", Description: "回应客户端心跳时的连接ID"},
+				}},
+				WSEventError: {Type: "object", Properties: map[string]*openAPISchema{
+					"error":   {Type: "string", Description: "错误详情"},
+					"message": {Type: "string", Description: "按请求语言给出的提示"},
+					"field":   {Type: "string", Description: "请求参数不符合文档时出错的字段"},
+				}},
+			},
+		},
+	})
+	g.schemaOf(WSMessage{})
+
+	oneBotAction := g.component(OneBotAction{})
+	oneBotAction.Properties["action"].Enum = oneBotActionNames()
+	doc.add(http.MethodGet, "/onebot/ws", &openAPIOperation{
+		OperationID: "connectOneBot",
+		Summary:     "OneBot v11正向WebSocket",
+		Description: "客户端发送OneBotAction调用动作，服务端以OneBotActionResponse回应，并推送生命周期、心跳和消息事件。",
+		Tags:        []string{"WebSocket"},
+		Responses:   switchingProtocols,
+		WebSocket: &openAPIWebSocket{
+			Client: map[string]*openAPISchema{"action": {Ref: schemaRefPrefix + "OneBotAction"}},
+			Server: map[string]*openAPISchema{
+				"response":  g.schemaOf(OneBotActionResponse{}),
+				"lifecycle": g.schemaOf(LifecycleEvent{}),
+				"heartbeat": g.schemaOf(HeartbeatEvent{}),
+				"private":   g.schemaOf(PrivateMessageEvent{}),
+				"group":     g.schemaOf(GroupMessageEvent{}),
+			},
+		},
+	})
+	g.schemaOf(MessageSegment{})
+
+	doc.Components.Schemas = g.schemas
+	return doc
+}
+
+// handleOpenAPISpec 输出OpenAPI文档
+// GET /api/v1/openapi.json
+func handleOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	doc := *getOpenAPIDocument()
+	doc.Servers = []openAPIServer{{URL: publicBaseURL(r)}}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(&doc)
+}
+
+// openAPIViewerPage 交互式文档页面，读取同目录下的openapi.json渲染
+//
+//go:embed openapi_viewer.html
+var openAPIViewerPage []byte
+
+// handleOpenAPIViewer 输出交互式文档页面
+// GET /api/v1/docs
+func handleOpenAPIViewer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(openAPIViewerPage)
+}
+
+// missingFromAPIDocs 返回接口文档中未提及的路径、参数、请求体字段和错误码
+// 路径需原样出现，参数、字段和错误码需以`名称`或表格单元格的形式出现，
+// 用于检查手写的API接口文档.md与代码是否一致
+func (d *openAPIDocument) missingFromAPIDocs(text string) []string {
+	mentioned := func(name string) bool {
+		return strings.Contains(text, "`"+name+"`") || strings.Contains(text, "| "+name+" ")
+	}
+
+	var missing []string
+	seen := make(map[string]bool)
+	report := func(item string) {
+		if !seen[item] {
+			seen[item] = true
+			missing = append(missing, item)
+		}
+	}
+
+	paths := make([]string, 0, len(d.Paths))
+	for path := range d.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if !strings.HasPrefix(path, apiV1Prefix+"/") {
+			continue
+		}
+		if !strings.Contains(text, path) {
+			report("接口 " + path)
+		}
+		for _, method := range []string{"get", "post"} {
+			op, ok := d.Paths[path][method]
+			if !ok {
+				continue
+			}
+			for _, param := range op.Parameters {
+				if param.In == "query" && !mentioned(param.Name) {
+					report(fmt.Sprintf("%s %s 的参数 %s", strings.ToUpper(method), path, param.Name))
+				}
+			}
+			if op.RequestBody == nil {
+				continue
+			}
+			if media, ok := op.RequestBody.Content["application/json"]; ok {
+				body := d.resolve(media.Schema)
+				names := make([]string, 0, len(body.Properties))
+				for name := range body.Properties {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					if !mentioned(name) {
+						report(fmt.Sprintf("%s %s 的请求体字段 %s", strings.ToUpper(method), path, name))
+					}
+				}
+			}
+		}
+	}
+	for _, code := range apiErrorCodes {
+		if !mentioned(code) {
+			report("错误码 " + code)
+		}
+	}
+	return missing
+}
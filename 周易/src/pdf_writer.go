@@ -0,0 +1,403 @@
+// pdf_writer.go 实现生成PDF报告所需的最小PDF写出器
+// 支持多页、文字、线框和位图。文字使用与卦象图相同的字体回退链逐字选择字体，
+// 每个用到的TrueType字体以子集形式嵌入（CIDFontType2 + Identity-H），并附带ToUnicode映射，
+// 报告中的中文可以被复制和搜索，在没有安装中文字体的机器上也能正确显示
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"fmt"
+	"image"
+	"image/draw"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// A4纸张尺寸，单位为点（1/72英寸）
+const (
+	pdfPageWidth  = 595.28
+	pdfPageHeight = 841.89
+)
+
+// pdfColor PDF中的RGB颜色，各分量取值0-1
+type pdfColor struct{ R, G, B float64 }
+
+// pdfFont 报告中用到的一个嵌入字体
+type pdfFont struct {
+	resource string          // 页面资源中的名称，如"F1"
+	source   fontSource      // 回退链中的字体
+	unitsEm  int             // 每em的字体单位数
+	glyphs   map[uint16]rune // 用到的字形编号及其对应的字符，用于ToUnicode
+	advances map[uint16]int  // 字形的前进宽度，字体单位
+}
+
+// pdfImage 报告中的一张位图
+type pdfImage struct {
+	resource string
+	img      image.Image
+}
+
+// pdfPage 一页的内容流
+type pdfPage struct {
+	content bytes.Buffer
+}
+
+// pdfDocument 正在生成的PDF文档
+// 坐标以页面左上角为原点、向下为正，写出内容流时换算为PDF的左下角坐标系
+type pdfDocument struct {
+	title   string
+	pages   []*pdfPage
+	fonts   []*pdfFont // 按回退链顺序排列的可嵌入字体
+	images  []*pdfImage
+	missing map[rune]bool // 整条回退链都没有字形、以.notdef绘制的字符
+	created time.Time
+	buf     sfnt.Buffer
+}
+
+// newPDFDocument 创建PDF文档，文字使用字体回退链中的TrueType字体
+// CFF轮廓的字体无法子集化，跳过后由链中后面的字体补齐
+func newPDFDocument(title string, chain *fontChain) (*pdfDocument, error) {
+	doc := &pdfDocument{title: title, missing: make(map[rune]bool), created: time.Now()}
+	for _, source := range chain.sources {
+		if len(source.Data) == 0 || !isTrueTypeOutline(source.Data) {
+			continue
+		}
+		doc.fonts = append(doc.fonts, &pdfFont{
+			resource: fmt.Sprintf("F%d", len(doc.fonts)+1),
+			source:   source,
+			unitsEm:  int(source.Font.UnitsPerEm()),
+			glyphs:   make(map[uint16]rune),
+			advances: make(map[uint16]int),
+		})
+	}
+	if len(doc.fonts) == 0 {
+		return nil, fmt.Errorf("字体回退链中没有可嵌入PDF的TrueType字体")
+	}
+	return doc, nil
+}
+
+// addPage 添加新页面并返回
+func (d *pdfDocument) addPage() *pdfPage {
+	page := &pdfPage{}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// glyphFor 为字符选择字体和字形，整条链都没有该字时使用第一个字体的.notdef
+func (d *pdfDocument) glyphFor(r rune) (*pdfFont, uint16) {
+	for _, f := range d.fonts {
+		if index, err := f.source.Font.GlyphIndex(&d.buf, r); err == nil && index != 0 {
+			return f, uint16(index)
+		}
+	}
+	return d.fonts[0], 0
+}
+
+// glyphAdvance 返回字形的前进宽度，字体单位
+func (d *pdfDocument) glyphAdvance(f *pdfFont, gid uint16) int {
+	if advance, ok := f.advances[gid]; ok {
+		return advance
+	}
+	advance, err := f.source.Font.GlyphAdvance(&d.buf, sfnt.GlyphIndex(gid), fixed.I(f.unitsEm), font.HintingNone)
+	units := 0
+	if err == nil {
+		units = advance.Round()
+	}
+	f.advances[gid] = units
+	return units
+}
+
+// measureText 返回文字在指定字号下的宽度，单位为点
+func (d *pdfDocument) measureText(text string, size float64) float64 {
+	width := 0.0
+	for _, r := range text {
+		f, gid := d.glyphFor(r)
+		width += float64(d.glyphAdvance(f, gid)) * size / float64(f.unitsEm)
+	}
+	return width
+}
+
+// drawText 在页面上绘制一行文字，(x, y)为基线起点
+// 相邻的同一字体的字符合并为一段，按字形编号以十六进制写出
+func (d *pdfDocument) drawText(page *pdfPage, text string, x, y, size float64, color pdfColor) {
+	if text == "" {
+		return
+	}
+	fmt.Fprintf(&page.content, "BT %.3f %.3f %.3f rg 1 0 0 1 %.2f %.2f Tm\n", color.R, color.G, color.B, x, pdfPageHeight-y)
+
+	var current *pdfFont
+	var run strings.Builder
+	flush := func() {
+		if run.Len() > 0 {
+			fmt.Fprintf(&page.content, "<%s> Tj\n", run.String())
+			run.Reset()
+		}
+	}
+	for _, r := range text {
+		f, gid := d.glyphFor(r)
+		if _, seen := f.glyphs[gid]; !seen {
+			f.glyphs[gid] = r
+		}
+		if gid == 0 {
+			// 缺字的.notdef不对应任何字符
+			f.glyphs[gid] = 0
+			if !unicode.IsSpace(r) {
+				d.missing[r] = true
+			}
+		}
+		d.glyphAdvance(f, gid)
+		if f != current {
+			flush()
+			fmt.Fprintf(&page.content, "/%s %.2f Tf\n", f.resource, size)
+			current = f
+		}
+		fmt.Fprintf(&run, "%04X", gid)
+	}
+	flush()
+	page.content.WriteString("ET\n")
+}
+
+// drawLine 绘制直线
+func (d *pdfDocument) drawLine(page *pdfPage, x1, y1, x2, y2, width float64, color pdfColor) {
+	fmt.Fprintf(&page.content, "%.3f %.3f %.3f RG %.2f w %.2f %.2f m %.2f %.2f l S\n",
+		color.R, color.G, color.B, width, x1, pdfPageHeight-y1, x2, pdfPageHeight-y2)
+}
+
+// fillRect 填充矩形，(x, y)为左上角
+func (d *pdfDocument) fillRect(page *pdfPage, x, y, w, h float64, color pdfColor) {
+	fmt.Fprintf(&page.content, "%.3f %.3f %.3f rg %.2f %.2f %.2f %.2f re f\n",
+		color.R, color.G, color.B, x, pdfPageHeight-y-h, w, h)
+}
+
+// drawImage 在页面上绘制位图，(x, y)为左上角，w、h为显示尺寸
+func (d *pdfDocument) drawImage(page *pdfPage, img image.Image, x, y, w, h float64) {
+	resource := fmt.Sprintf("Im%d", len(d.images)+1)
+	d.images = append(d.images, &pdfImage{resource: resource, img: img})
+	fmt.Fprintf(&page.content, "q %.2f 0 0 %.2f %.2f %.2f cm /%s Do Q\n", w, h, x, pdfPageHeight-y-h, resource)
+}
+
+// pdfObjects 按编号收集PDF对象，写出时记录各对象的字节偏移
+type pdfObjects struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+// reserve 预留一个对象编号
+func (o *pdfObjects) reserve() int {
+	o.offsets = append(o.offsets, 0)
+	return len(o.offsets)
+}
+
+// write 写出一个对象
+func (o *pdfObjects) write(num int, body string) {
+	o.offsets[num-1] = o.buf.Len()
+	fmt.Fprintf(&o.buf, "%d 0 obj\n%s\nendobj\n", num, body)
+}
+
+// writeStream 以zlib压缩写出一个流对象，dict为流字典中除Length和Filter外的条目
+func (o *pdfObjects) writeStream(num int, dict string, data []byte) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(data)
+	zw.Close()
+
+	o.offsets[num-1] = o.buf.Len()
+	fmt.Fprintf(&o.buf, "%d 0 obj\n<< %s /Length %d /Filter /FlateDecode >>\nstream\n", num, dict, compressed.Len())
+	o.buf.Write(compressed.Bytes())
+	o.buf.WriteString("\nendstream\nendobj\n")
+}
+
+// bytes 写出交叉引用表和文件尾，返回完整的PDF文件
+func (o *pdfObjects) bytes(root, info int) []byte {
+	xref := o.buf.Len()
+	fmt.Fprintf(&o.buf, "xref\n0 %d\n0000000000 65535 f \n", len(o.offsets)+1)
+	for _, offset := range o.offsets {
+		fmt.Fprintf(&o.buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&o.buf, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(o.offsets)+1, root, info, xref)
+	return o.buf.Bytes()
+}
+
+// bytes 生成PDF文件
+//
+// 返回值：PDF文件数据；字体子集化失败时返回错误
+func (d *pdfDocument) bytes() ([]byte, error) {
+	var objects pdfObjects
+	objects.buf.WriteString("%PDF-1.7\n%\xE2\xE3\xCF\xD3\n")
+
+	catalog := objects.reserve()
+	pagesNum := objects.reserve()
+	info := objects.reserve()
+
+	// 字体：只写出实际用到的字体
+	var fontRefs []string
+	for _, f := range d.fonts {
+		if len(f.glyphs) == 0 {
+			continue
+		}
+		num, err := d.writeFont(&objects, f)
+		if err != nil {
+			return nil, err
+		}
+		fontRefs = append(fontRefs, fmt.Sprintf("/%s %d 0 R", f.resource, num))
+	}
+
+	// 位图以RGB原始数据压缩后写出
+	var imageRefs []string
+	for _, im := range d.images {
+		num := objects.reserve()
+		bounds := im.img.Bounds()
+		rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), im.img, bounds.Min, draw.Src)
+		rgb := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+		for i := 0; i < len(rgba.Pix); i += 4 {
+			rgb = append(rgb, rgba.Pix[i], rgba.Pix[i+1], rgba.Pix[i+2])
+		}
+		objects.writeStream(num, fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8",
+			bounds.Dx(), bounds.Dy()), rgb)
+		imageRefs = append(imageRefs, fmt.Sprintf("/%s %d 0 R", im.resource, num))
+	}
+
+	resources := fmt.Sprintf("<< /Font << %s >> /XObject << %s >> >>", strings.Join(fontRefs, " "), strings.Join(imageRefs, " "))
+	var kids []string
+	for _, page := range d.pages {
+		pageNum := objects.reserve()
+		contentNum := objects.reserve()
+		objects.writeStream(contentNum, "", page.content.Bytes())
+		objects.write(pageNum, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources %s /Contents %d 0 R >>",
+			pagesNum, pdfPageWidth, pdfPageHeight, resources, contentNum))
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageNum))
+	}
+
+	objects.write(pagesNum, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+	objects.write(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesNum))
+	_, offset := d.created.Zone()
+	objects.write(info, fmt.Sprintf("<< /Title %s /Producer %s /CreationDate (D:%s%+03d'%02d') >>",
+		pdfTextString(d.title), pdfTextString(softwareName), d.created.Format("20060102150405"), offset/3600, abs(offset%3600/60)))
+	return objects.bytes(catalog, info), nil
+}
+
+// writeFont 写出一个字体子集及其描述，返回Type0字体的对象编号
+func (d *pdfDocument) writeFont(objects *pdfObjects, f *pdfFont) (int, error) {
+	glyphs := make(map[uint16]bool, len(f.glyphs))
+	gids := make([]int, 0, len(f.glyphs))
+	for gid := range f.glyphs {
+		glyphs[gid] = true
+		gids = append(gids, int(gid))
+	}
+	sort.Ints(gids)
+
+	subset, err := subsetTrueType(f.source.Data, glyphs)
+	if err != nil {
+		return 0, fmt.Errorf("子集化字体 %s 失败: %v", f.source.Name, err)
+	}
+	baseName := pdfSubsetName(d, f, gids)
+	scale := func(v int) int { return v * 1000 / f.unitsEm }
+
+	tables, _ := readSFNTTables(subset)
+	head, hhea := tables["head"], tables["hhea"]
+	bbox := [4]int{}
+	for i := range bbox {
+		bbox[i] = scale(int(int16(uint16(head[36+2*i])<<8 | uint16(head[37+2*i]))))
+	}
+	ascent, descent := bbox[3], bbox[1]
+	if len(hhea) >= 8 {
+		ascent = scale(int(int16(uint16(hhea[4])<<8 | uint16(hhea[5]))))
+		descent = scale(int(int16(uint16(hhea[6])<<8 | uint16(hhea[7]))))
+	}
+
+	fontFile := objects.reserve()
+	objects.writeStream(fontFile, fmt.Sprintf("/Length1 %d", len(subset)), subset)
+
+	descriptor := objects.reserve()
+	objects.write(descriptor, fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 4 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		baseName, bbox[0], bbox[1], bbox[2], bbox[3], ascent, descent, ascent, fontFile))
+
+	// 字宽数组，格式为 [gid [w] gid [w] ...]
+	var widths strings.Builder
+	for _, gid := range gids {
+		fmt.Fprintf(&widths, "%d [%d] ", gid, scale(f.advances[uint16(gid)]))
+	}
+	cidFont := objects.reserve()
+	objects.write(cidFont, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /DW 1000 /W [%s] /CIDToGIDMap /Identity >>",
+		baseName, descriptor, strings.TrimSpace(widths.String())))
+
+	toUnicode := objects.reserve()
+	objects.writeStream(toUnicode, "", pdfToUnicodeCMap(f.glyphs, gids))
+
+	type0 := objects.reserve()
+	objects.write(type0, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		baseName, cidFont, toUnicode))
+	return type0, nil
+}
+
+// pdfSubsetName 生成子集字体名，如"ABCDEF+ZhouyiSans-Regular"
+// 六个大写字母的前缀由字形集合决定，同一字体的不同子集不会重名
+func pdfSubsetName(d *pdfDocument, f *pdfFont, gids []int) string {
+	name, err := f.source.Font.Name(&d.buf, sfnt.NameIDPostScript)
+	if err != nil || name == "" {
+		name = "Font" + f.resource
+	}
+	name = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || strings.ContainsRune("()<>[]{}/%#", r) {
+			return -1
+		}
+		return r
+	}, name)
+
+	hash := sha1.New()
+	fmt.Fprint(hash, name, gids)
+	sum := hash.Sum(nil)
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = 'A' + sum[i]%26
+	}
+	return string(tag) + "+" + name
+}
+
+// pdfToUnicodeCMap 生成字形编号到Unicode的映射，使PDF中的文字可复制和搜索
+func pdfToUnicodeCMap(glyphs map[uint16]rune, gids []int) []byte {
+	var cmap bytes.Buffer
+	cmap.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	// .notdef不写入映射；每个bfchar段最多100条
+	if len(gids) > 0 && gids[0] == 0 {
+		gids = gids[1:]
+	}
+	for start := 0; start < len(gids); start += 100 {
+		end := min(start+100, len(gids))
+		fmt.Fprintf(&cmap, "%d beginbfchar\n", end-start)
+		for _, gid := range gids[start:end] {
+			fmt.Fprintf(&cmap, "<%04X> <", gid)
+			for _, unit := range utf16.Encode([]rune{glyphs[uint16(gid)]}) {
+				fmt.Fprintf(&cmap, "%04X", unit)
+			}
+			cmap.WriteString(">\n")
+		}
+		cmap.WriteString("endbfchar\n")
+	}
+	cmap.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return cmap.Bytes()
+}
+
+// pdfTextString 将文字编码为带BOM的UTF-16BE十六进制字符串，用于文档信息等非页面文字
+func pdfTextString(text string) string {
+	var s strings.Builder
+	s.WriteString("<FEFF")
+	for _, unit := range utf16.Encode([]rune(text)) {
+		fmt.Fprintf(&s, "%04X", unit)
+	}
+	s.WriteString(">")
+	return s.String()
+}
@@ -0,0 +1,210 @@
+// public_url.go 生成对外的链接地址
+// 占卜结果中的图片链接、品牌二维码、OneBot图片消息和启动日志中的接口地址都经此生成：
+// 配置了server.public_base_url时使用该地址，否则按反向代理传来的X-Forwarded-Proto/Host或请求的Host推断，
+// 都没有时退回http://localhost:端口。
+// 开启签名链接后，图片和报告链接附带过期时间和HMAC签名，服务端校验通过才返回内容
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 签名链接的查询参数
+const (
+	linkExpiresParam   = "expires" // 过期时刻，Unix秒
+	linkSignatureParam = "sig"     // HMAC-SHA256签名，十六进制
+)
+
+// publicBaseURL 返回对外访问的基础地址，不以/结尾
+// 依次取配置的public_base_url、反向代理传来的X-Forwarded-Proto/Host、请求的Host，
+// r为nil（如渲染二维码、OneBot推送时没有请求）时只取配置，未配置时返回http://localhost:端口
+func publicBaseURL(r *http.Request) string {
+	config := GetConfig().Server
+	if config.PublicBaseURL != "" {
+		return strings.TrimRight(config.PublicBaseURL, "/")
+	}
+	if r == nil {
+		return "http://localhost:" + config.Port
+	}
+
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if config.TrustForwardedHeaders {
+		// 经过多级代理时取最前面的一个，即客户端访问的地址
+		if proto := firstHeaderValue(r, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwarded := firstHeaderValue(r, "X-Forwarded-Host"); forwarded != "" {
+			host = forwarded
+		}
+	}
+	if host == "" {
+		host = "localhost:" + config.Port
+	}
+	return scheme + "://" + host
+}
+
+// clientAddress 返回客户端的IP地址
+// 信任反向代理的请求头时取X-Forwarded-For中最前面的地址，否则取连接的对端地址
+func clientAddress(r *http.Request) string {
+	if GetConfig().Server.TrustForwardedHeaders {
+		if forwarded := firstHeaderValue(r, "X-Forwarded-For"); forwarded != "" {
+			return forwarded
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// firstHeaderValue 返回逗号分隔的请求头中的第一个值
+func firstHeaderValue(r *http.Request, name string) string {
+	value, _, _ := strings.Cut(r.Header.Get(name), ",")
+	return strings.TrimSpace(value)
+}
+
+// buildPublicURL 拼出对外的完整链接
+// 开启签名链接且路径为图片或报告时附带过期时间和签名
+//
+// 参数：
+//   - base: publicBaseURL返回的基础地址
+//   - path: 服务内的路径，如"/api/v1/divine/{id}/image"，按路径规则转义
+//   - query: 附加的查询参数，可为nil
+//
+// 返回值：完整链接
+func buildPublicURL(base, path string, query url.Values) string {
+	if query == nil {
+		query = url.Values{}
+	}
+	if GetConfig().Server.SignedLinks.Enabled && isSignedPath(path) {
+		signLink(path, query, time.Now())
+	}
+	link := base + (&url.URL{Path: path}).EscapedPath()
+	if len(query) > 0 {
+		link += "?" + query.Encode()
+	}
+	return link
+}
+
+// isSignedPath 判断路径是否需要签名访问：卦象图片、PDF报告和落盘的图片文件
+func isSignedPath(path string) bool {
+	if strings.HasPrefix(path, "/photos/") || strings.HasPrefix(path, "/output/") {
+		return true
+	}
+	if !strings.HasPrefix(path, apiPath("/divine/")) && !strings.HasPrefix(path, apiLegacyPrefix+"/divine/") {
+		return false
+	}
+	return strings.HasSuffix(path, "/image") || strings.HasSuffix(path, "/report.pdf")
+}
+
+// 签名密钥，未配置时启动后随机生成
+var (
+	linkSecret     []byte
+	linkSecretOnce sync.Once
+)
+
+// getLinkSecret 返回签名密钥
+func getLinkSecret() []byte {
+	linkSecretOnce.Do(func() {
+		if secret := GetConfig().Server.SignedLinks.Secret; secret != "" {
+			linkSecret = []byte(secret)
+			return
+		}
+		linkSecret = make([]byte, 32)
+		if _, err := rand.Read(linkSecret); err != nil {
+			log.Fatalf("生成签名密钥失败: %v", err)
+		}
+		log.Printf("未配置server.signed_links.secret，已随机生成签名密钥，重启后之前的链接将失效")
+	})
+	return linkSecret
+}
+
+// linkSignature 计算路径和过期时刻的签名
+// 签名只覆盖路径和过期时刻，同一链接可另加format、size等参数
+func linkSignature(path string, expires int64) string {
+	mac := hmac.New(sha256.New, getLinkSecret())
+	fmt.Fprintf(mac, "%s\n%d", path, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signLink 为路径签名，将过期时刻和签名写入查询参数
+func signLink(path string, query url.Values, now time.Time) {
+	ttl := time.Duration(GetConfig().Server.SignedLinks.TTLMinutes) * time.Minute
+	expires := now.Add(ttl).Unix()
+	query.Set(linkExpiresParam, strconv.FormatInt(expires, 10))
+	query.Set(linkSignatureParam, linkSignature(path, expires))
+}
+
+// errLinkExpired 签名链接已过期
+var errLinkExpired = errors.New("链接已过期")
+
+// verifyLinkSignature 校验请求中的签名和过期时刻
+func verifyLinkSignature(r *http.Request) error {
+	query := r.URL.Query()
+	expires, err := strconv.ParseInt(query.Get(linkExpiresParam), 10, 64)
+	if err != nil || query.Get(linkSignatureParam) == "" {
+		return fmt.Errorf("链接缺少签名")
+	}
+	expected := linkSignature(r.URL.Path, expires)
+	if !hmac.Equal([]byte(expected), []byte(query.Get(linkSignatureParam))) {
+		return fmt.Errorf("链接签名无效")
+	}
+	if time.Now().Unix() > expires {
+		return errLinkExpired
+	}
+	return nil
+}
+
+// requireSignedLink 开启签名链接时校验请求的签名，未开启时直接交给下一个处理器
+func requireSignedLink(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if GetConfig().Server.SignedLinks.Enabled {
+			if err := verifyLinkSignature(r); err != nil {
+				code := ErrCodeInvalidSignature
+				if errors.Is(err, errLinkExpired) {
+					code = ErrCodeLinkExpired
+				}
+				writeErrorResponse(w, http.StatusForbidden, err.Error(), &ErrorDetail{Code: code})
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// publicResult 返回链接改为完整地址的占卜结果副本
+// 结果中保存的是服务内的路径，发给客户端前经此转换；已是完整地址的链接保持不变
+func publicResult(result *DivineResult, base string) *DivineResult {
+	public := *result
+	public.ImagePath = publicLink(base, result.ImagePath)
+	public.ImageURL = publicLink(base, result.ImageURL)
+	public.ThumbURL = publicLink(base, result.ThumbURL)
+	return &public
+}
+
+// publicLink 将服务内的路径（可带查询参数）转换为完整链接，空字符串和完整地址原样返回
+func publicLink(base, link string) string {
+	if link == "" || strings.Contains(link, "://") {
+		return link
+	}
+	path, rawQuery, _ := strings.Cut(link, "?")
+	query, _ := url.ParseQuery(rawQuery)
+	return buildPublicURL(base, "/"+strings.TrimPrefix(path, "/"), query)
+}
@@ -0,0 +1,443 @@
+// qrcode.go 实现二维码编码
+// 只实现品牌叠加层需要的部分：字节模式、纠错等级M、版本1到10（最多213字节，足够放下结果页地址），
+// 按ISO/IEC 18004生成模块矩阵，并按规范的四条罚分规则选择掩码
+package main
+
+import "fmt"
+
+// qrBlockSpec 一个版本在纠错等级M下的分块方式
+type qrBlockSpec struct {
+	ECPerBlock int // 每块的纠错码字数
+	Blocks1    int // 第一组块数
+	Data1      int // 第一组每块的数据码字数
+	Blocks2    int // 第二组块数，每块比第一组多一个数据码字
+}
+
+// qrBlockSpecsM 版本1到10在纠错等级M下的分块表
+var qrBlockSpecsM = [...]qrBlockSpec{
+	1:  {10, 1, 16, 0},
+	2:  {16, 1, 28, 0},
+	3:  {26, 1, 44, 0},
+	4:  {18, 2, 32, 0},
+	5:  {24, 2, 43, 0},
+	6:  {16, 4, 27, 0},
+	7:  {18, 4, 31, 0},
+	8:  {22, 2, 38, 2},
+	9:  {22, 3, 36, 2},
+	10: {26, 4, 43, 1},
+}
+
+// qrAlignment 版本1到10的校正图形中心坐标
+var qrAlignment = [...][]int{
+	1:  nil,
+	2:  {6, 18},
+	3:  {6, 22},
+	4:  {6, 26},
+	5:  {6, 30},
+	6:  {6, 34},
+	7:  {6, 22, 38},
+	8:  {6, 24, 42},
+	9:  {6, 26, 46},
+	10: {6, 28, 50},
+}
+
+// qrMaxVersion 支持的最高版本
+const qrMaxVersion = 10
+
+// qrCode 编码好的二维码
+type qrCode struct {
+	Size    int      // 每边的模块数
+	Modules [][]bool // 模块矩阵，[y][x]，true为深色
+
+	function [][]bool // 功能图形占用的模块，填充数据和掩码时跳过
+}
+
+// dataCodewords 版本的数据码字总数
+func (s qrBlockSpec) dataCodewords() int {
+	return s.Blocks1*s.Data1 + s.Blocks2*(s.Data1+1)
+}
+
+// encodeQRCode 以字节模式、纠错等级M编码数据，自动选择能容纳数据的最小版本
+func encodeQRCode(data []byte) (*qrCode, error) {
+	version := 0
+	for v := 1; v <= qrMaxVersion; v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+len(data)*8 <= qrBlockSpecsM[v].dataCodewords()*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("二维码内容过长: %d字节，最多%d字节", len(data), qrBlockSpecsM[qrMaxVersion].dataCodewords()-3)
+	}
+
+	codewords := qrAddErrorCorrection(qrDataCodewords(data, version), qrBlockSpecsM[version])
+
+	qr := newQRCode(version)
+	qr.placeCodewords(codewords)
+
+	// 依次试用八种掩码，取罚分最低的
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		qr.applyMask(mask)
+		qr.drawFormatBits(mask)
+		if penalty := qr.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		qr.applyMask(mask) // 掩码为异或，再做一次即还原
+	}
+	qr.applyMask(best)
+	qr.drawFormatBits(best)
+	return qr, nil
+}
+
+// qrBitBuffer 按位追加的缓冲区
+type qrBitBuffer []bool
+
+func (b *qrBitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*b = append(*b, value>>i&1 == 1)
+	}
+}
+
+// qrDataCodewords 生成模式指示、字符数、数据、终止符和填充码字
+func qrDataCodewords(data []byte, version int) []byte {
+	capacity := qrBlockSpecsM[version].dataCodewords()
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+
+	var bits qrBitBuffer
+	bits.append(0x4, 4) // 字节模式
+	bits.append(len(data), countBits)
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	bits.append(0, min(4, capacity*8-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+
+	codewords := make([]byte, 0, capacity)
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for j := 0; j < 8; j++ {
+			if bits[i+j] {
+				b |= 1 << (7 - j)
+			}
+		}
+		codewords = append(codewords, b)
+	}
+	for pad := byte(0xEC); len(codewords) < capacity; pad ^= 0xEC ^ 0x11 {
+		codewords = append(codewords, pad)
+	}
+	return codewords
+}
+
+// qrAddErrorCorrection 按分块计算纠错码字，再将各块的数据码字和纠错码字分别交错排列
+func qrAddErrorCorrection(data []byte, spec qrBlockSpec) []byte {
+	divisor := qrReedSolomonDivisor(spec.ECPerBlock)
+	var blocks, ecBlocks [][]byte
+	offset := 0
+	for i := 0; i < spec.Blocks1+spec.Blocks2; i++ {
+		length := spec.Data1
+		if i >= spec.Blocks1 {
+			length++
+		}
+		block := data[offset : offset+length]
+		offset += length
+		blocks = append(blocks, block)
+		ecBlocks = append(ecBlocks, qrReedSolomonRemainder(block, divisor))
+	}
+
+	result := make([]byte, 0, len(data)+len(blocks)*spec.ECPerBlock)
+	for i := 0; i <= spec.Data1; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < spec.ECPerBlock; i++ {
+		for _, ec := range ecBlocks {
+			result = append(result, ec[i])
+		}
+	}
+	return result
+}
+
+// qrGFMultiply GF(256)上的乘法，本原多项式x^8+x^4+x^3+x^2+1
+func qrGFMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+// qrReedSolomonDivisor 生成多项式(x-α^0)(x-α^1)…(x-α^(degree-1))的系数，省略最高次项
+func qrReedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = qrGFMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = qrGFMultiply(root, 0x02)
+	}
+	return result
+}
+
+// qrReedSolomonRemainder 数据多项式除以生成多项式的余式，即纠错码字
+func qrReedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= qrGFMultiply(divisor[i], factor)
+		}
+	}
+	return result
+}
+
+// newQRCode 创建指定版本的二维码并画好功能图形：定位、分隔、定时、校正图形和版本信息，格式信息的位置先预留
+func newQRCode(version int) *qrCode {
+	size := version*4 + 17
+	qr := &qrCode{Size: size, Modules: make([][]bool, size), function: make([][]bool, size)}
+	for i := range qr.Modules {
+		qr.Modules[i] = make([]bool, size)
+		qr.function[i] = make([]bool, size)
+	}
+
+	// 定时图形
+	for i := 0; i < size; i++ {
+		qr.setFunction(6, i, i%2 == 0)
+		qr.setFunction(i, 6, i%2 == 0)
+	}
+
+	// 三个角上的定位图形，连同外圈的分隔符
+	qr.drawFinder(3, 3)
+	qr.drawFinder(size-4, 3)
+	qr.drawFinder(3, size-4)
+
+	// 校正图形，与定位图形重叠的三个位置除外
+	positions := qrAlignment[version]
+	last := len(positions) - 1
+	for i, y := range positions {
+		for j, x := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			qr.drawAlignment(x, y)
+		}
+	}
+
+	// 预留格式信息的位置，版本7及以上画版本信息
+	qr.drawFormatBits(0)
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := bits>>i&1 == 1
+			a, b := size-11+i%3, i/3
+			qr.setFunction(a, b, dark)
+			qr.setFunction(b, a, dark)
+		}
+	}
+	return qr
+}
+
+// setFunction 设置功能图形模块
+func (qr *qrCode) setFunction(x, y int, dark bool) {
+	qr.Modules[y][x] = dark
+	qr.function[y][x] = true
+}
+
+// drawFinder 以(x, y)为中心画定位图形及其分隔符
+func (qr *qrCode) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= qr.Size || yy < 0 || yy >= qr.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			qr.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// drawAlignment 以(x, y)为中心画5×5的校正图形
+func (qr *qrCode) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			qr.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormatBits 画纠错等级M和指定掩码的格式信息，两份副本以及固定的深色模块
+func (qr *qrCode) drawFormatBits(mask int) {
+	data := 0<<3 | mask // 纠错等级M的指示位为00
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+
+	// 左上角
+	for i := 0; i <= 5; i++ {
+		qr.setFunction(8, i, bit(i))
+	}
+	qr.setFunction(8, 7, bit(6))
+	qr.setFunction(8, 8, bit(7))
+	qr.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		qr.setFunction(14-i, 8, bit(i))
+	}
+
+	// 右上角和左下角
+	for i := 0; i < 8; i++ {
+		qr.setFunction(qr.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.setFunction(8, qr.Size-15+i, bit(i))
+	}
+	qr.setFunction(8, qr.Size-8, true)
+}
+
+// placeCodewords 从右下角起按两列一组、上下折返的顺序填入码字，跳过功能图形
+func (qr *qrCode) placeCodewords(codewords []byte) {
+	i := 0
+	for right := qr.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // 跳过竖直的定时图形
+		}
+		for vert := 0; vert < qr.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = qr.Size - 1 - vert // 向上填充
+				}
+				if !qr.function[y][x] && i < len(codewords)*8 {
+					qr.Modules[y][x] = codewords[i>>3]>>(7-i&7)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask 对数据区域异或指定的掩码
+func (qr *qrCode) applyMask(mask int) {
+	for y := 0; y < qr.Size; y++ {
+		for x := 0; x < qr.Size; x++ {
+			if qr.function[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				qr.Modules[y][x] = !qr.Modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty 按规范的四条规则计算罚分：同色连续、2×2同色块、类定位图形、深浅比例
+func (qr *qrCode) penalty() int {
+	size := qr.Size
+	result := 0
+	at := func(x, y int, vertical bool) bool {
+		if vertical {
+			return qr.Modules[x][y]
+		}
+		return qr.Modules[y][x]
+	}
+
+	// 规则1和规则3，逐行和逐列扫描
+	finderLike := [2][11]bool{
+		{true, false, true, true, true, false, true, false, false, false, false},
+		{false, false, false, false, true, false, true, true, true, false, true},
+	}
+	for _, vertical := range []bool{false, true} {
+		for y := 0; y < size; y++ {
+			run := 1
+			for x := 1; x <= size; x++ {
+				if x < size && at(x, y, vertical) == at(x-1, y, vertical) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					result += 3 + run - 5
+				}
+				run = 1
+			}
+			for x := 0; x+11 <= size; x++ {
+				for _, pattern := range finderLike {
+					matched := true
+					for k := 0; k < 11 && matched; k++ {
+						matched = at(x+k, y, vertical) == pattern[k]
+					}
+					if matched {
+						result += 40
+					}
+				}
+			}
+		}
+	}
+
+	// 规则2
+	for y := 0; y+1 < size; y++ {
+		for x := 0; x+1 < size; x++ {
+			c := qr.Modules[y][x]
+			if c == qr.Modules[y][x+1] && c == qr.Modules[y+1][x] && c == qr.Modules[y+1][x+1] {
+				result += 3
+			}
+		}
+	}
+
+	// 规则4，深色比例偏离50%每5%罚10分
+	dark := 0
+	for _, row := range qr.Modules {
+		for _, m := range row {
+			if m {
+				dark++
+			}
+		}
+	}
+	total := size * size
+	result += (abs(dark*20-total*10)+total-1)/total*10 - 10
+	return result
+}
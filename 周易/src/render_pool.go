@@ -0,0 +1,118 @@
+// render_pool.go 管理并发渲染共用的资源
+// 字体回退链（见fonts.go）中解析后的字体只读，可被所有请求共享；font.Face内部带有缓冲区，同一时刻只能由一个goroutine使用，
+// 因此按主题的字体和字号建立字体面对象池，渲染时借出、结束后归还。
+// 文字缓存随画布创建，背景图每次取副本，渲染过程不再需要全局锁，
+// 并发数只由渲染槽位限制，默认等于CPU核数
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 并发渲染资源
+var (
+	facePools sync.Map // 字体面对象池，键为faceKey，值为*sync.Pool
+
+	renderSlots     chan struct{} // 渲染槽位，限制同时进行的渲染数量
+	renderSlotsOnce sync.Once
+)
+
+// faceKey 字体面对象池的键，字体文件和三种字号都相同的主题共用一个池
+func faceKey(theme *Theme) string {
+	return fmt.Sprintf("%s|%g|%g|%g", theme.Font.File,
+		theme.fontSize(textTitle), theme.fontSize(textNormal), theme.fontSize(textSmall))
+}
+
+// acquireFaces 借出一组主题字号的字体面，用完后必须调用releaseFaces归还
+func acquireFaces(theme *Theme) (*chartFaces, error) {
+	pool, _ := facePools.LoadOrStore(faceKey(theme), &sync.Pool{})
+	if faces, ok := pool.(*sync.Pool).Get().(*chartFaces); ok {
+		return faces, nil
+	}
+
+	chain, err := loadFontChain(theme)
+	if err != nil {
+		return nil, err
+	}
+	faces, err := createFontFaces(chain, theme)
+	if err != nil {
+		return nil, fmt.Errorf("创建字体失败: %v", err)
+	}
+	return faces, nil
+}
+
+// releaseFaces 归还字体面，供后续渲染复用
+func releaseFaces(theme *Theme, faces *chartFaces) {
+	pool, _ := facePools.LoadOrStore(faceKey(theme), &sync.Pool{})
+	pool.(*sync.Pool).Put(faces)
+}
+
+// renderConcurrency 返回同时渲染的最大数量，未配置时等于CPU核数
+func renderConcurrency() int {
+	if n := GetConfig().Render.MaxConcurrency; n > 0 {
+		return n
+	}
+	return runtime.NumCPU()
+}
+
+// acquireRenderSlot 占用一个渲染槽位，槽位用尽时等待，返回释放函数
+func acquireRenderSlot() func() {
+	renderSlotsOnce.Do(func() {
+		renderSlots = make(chan struct{}, renderConcurrency())
+	})
+	renderSlots <- struct{}{}
+	return func() { <-renderSlots }
+}
+
+// benchmarkChart 构造固定的测试盘面，用于渲染基准测试，不调用万年历API
+func benchmarkChart() *GuaChart {
+	本卦, 变卦, 变爻标记 := generateTestGua()
+	divineTime := time.Date(2025, 1, 1, 12, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	chart := &GuaChart{
+		DivineTime: divineTime,
+		Timezone:   "Asia/Shanghai",
+		Ganzhinian: "甲辰年",
+		Ganzhiyue:  "丙子月",
+		Ganzhiri:   "庚午日",
+		RiGan:      "庚",
+		BenGua:     本卦,
+		BianGua:    变卦,
+		DongYao:    变爻标记,
+		HasDongYao: hasChangingYao(变爻标记),
+	}
+	chart.Ganzhishi = hourPillar(chart.RiGan, divineTime.Hour(), ZiHourLate)
+	chart.BenGuaName = guaToName(本卦)
+	chart.BianGuaName = guaToName(变卦)
+	return chart
+}
+
+// benchmarkRender 用workers个goroutine并发渲染total张图，返回总耗时
+// 渲染走与占卜请求相同的渲染槽位、字体面对象池和编码流程，但不写磁盘
+func benchmarkRender(chart *GuaChart, theme *Theme, format string, quality int, layoutName string, workers, total int) (time.Duration, error) {
+	var (
+		next     atomic.Int64
+		wg       sync.WaitGroup
+		firstErr error
+		errOnce  sync.Once
+	)
+
+	start := time.Now()
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for next.Add(1) <= int64(total) {
+				if _, err := renderChartWithLayout(format, quality, layoutName, chart, theme, nil); err != nil {
+					errOnce.Do(func() { firstErr = err })
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return time.Since(start), firstErr
+}
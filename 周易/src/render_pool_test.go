@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+// BenchmarkRenderParallel 多个goroutine同时渲染同一盘面，衡量去掉全局渲染锁后的并发吞吐
+// 与bench-render命令走相同的渲染流程：渲染槽位、字体面对象池和PNG编码，不写磁盘。
+// 用 -cpu 1,4,8 比较不同并发数下的每张耗时
+func BenchmarkRenderParallel(b *testing.B) {
+	chart := benchmarkChart()
+	theme, err := resolveTheme("", 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	layoutName, err := normalizeLayoutName("")
+	if err != nil {
+		b.Fatal(err)
+	}
+	// 预热字体回退链、字体面对象池和背景缓存，不计入耗时
+	if _, err := renderChartWithLayout(ImageFormatPNG, 0, layoutName, chart, theme, nil); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := renderChartWithLayout(ImageFormatPNG, 0, layoutName, chart, theme, nil); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,278 @@
+// request_validation.go 按OpenAPI文档校验请求
+// 查询参数和JSON请求体在进入处理器前按文档中的类型、枚举、取值范围和长度校验，
+// 不符合时返回带error.code和error.field的400错误。文档与校验使用同一份结构，
+// 接口参数改动时只需修改openapi.go，文档和校验同时生效。
+// 时间能否解析、时区是否存在等语义上的检查仍由各处理器完成
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// validateRequest 包装处理器，请求不符合文档时直接返回错误
+func validateRequest(op *openAPIOperation, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		doc := getOpenAPIDocument()
+		if apiErr := doc.validateQuery(op, r); apiErr != nil {
+			writeError(w, apiErr)
+			return
+		}
+		if apiErr := doc.validateJSONBody(op, w, r); apiErr != nil {
+			writeError(w, apiErr)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// validateQuery 校验查询参数，空值视为未填写
+func (d *openAPIDocument) validateQuery(op *openAPIOperation, r *http.Request) *apiError {
+	query := r.URL.Query()
+	for _, param := range op.Parameters {
+		if param.In != "query" {
+			continue
+		}
+		raw := query.Get(param.Name)
+		if raw == "" {
+			if param.Required {
+				return invalidParameter(param.Name, fmt.Errorf("缺少参数 %s", param.Name))
+			}
+			continue
+		}
+		value, err := parseQueryValue(d.resolve(param.Schema), raw)
+		if err != nil {
+			return invalidParameter(param.Name, fmt.Errorf("参数 %s 应为%s: %s", param.Name, err, raw))
+		}
+		if apiErr := d.validateValue(param.Schema, value, param.Name); apiErr != nil {
+			// 查询参数总是字符串，类型不符也按取值无效处理
+			apiErr.Code = ErrCodeInvalidParameter
+			return apiErr
+		}
+	}
+	return nil
+}
+
+// parseQueryValue 按结构的类型解析查询参数
+//
+// 返回值：解析后的值；无法解析时返回期望类型的说明
+func parseQueryValue(schema *openAPISchema, raw string) (interface{}, error) {
+	switch schema.Type {
+	case "integer":
+		if _, err := strconv.ParseInt(raw, 10, 64); err != nil {
+			return nil, errors.New("整数")
+		}
+		return json.Number(raw), nil
+	case "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return nil, errors.New("数字")
+		}
+		return json.Number(raw), nil
+	case "boolean":
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, errors.New("true或false")
+		}
+		return value, nil
+	}
+	return raw, nil
+}
+
+// validateJSONBody 校验JSON请求体，校验后放回请求供处理器读取
+// 请求体为空或不是合法的JSON时交给处理器，由处理器返回具体的解析错误
+func (d *openAPIDocument) validateJSONBody(op *openAPIOperation, w http.ResponseWriter, r *http.Request) *apiError {
+	if op.RequestBody == nil {
+		return nil
+	}
+	media, ok := op.RequestBody.Content["application/json"]
+	if !ok {
+		return nil
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, op.RequestBody.maxBytes))
+	r.Body.Close()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return &apiError{Status: http.StatusRequestEntityTooLarge, Code: ErrCodePayloadTooLarge,
+				Message: fmt.Sprintf("请求体超过 %d 字节", op.RequestBody.maxBytes)}
+		}
+		return &apiError{Status: http.StatusBadRequest, Code: ErrCodeInvalidRequest, Message: "无法读取请求体"}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil
+	}
+	return d.validateValue(media.Schema, value, "")
+}
+
+// validateValue 按结构校验解码后的JSON值
+// 数字可以是json.Number（HTTP请求体）或float64（WebSocket消息）；null视为未填写
+//
+// 参数：
+//   - schema: 值的结构
+//   - value: 解码后的值
+//   - field: 值所在的字段名，嵌套字段以.连接，顶层为空
+//
+// 返回值：不符合时返回带字段名的错误
+func (d *openAPIDocument) validateValue(schema *openAPISchema, value interface{}, field string) *apiError {
+	schema = d.resolve(schema)
+	if schema == nil || value == nil {
+		return nil
+	}
+
+	if len(schema.OneOf) > 0 {
+		types := make([]string, 0, len(schema.OneOf))
+		for _, candidate := range schema.OneOf {
+			if d.validateValue(candidate, value, field) == nil {
+				return nil
+			}
+			types = append(types, d.resolve(candidate).Type)
+		}
+		return typeMismatch(field, strings.Join(types, "或"))
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return typeMismatch(field, "object")
+		}
+		for _, name := range schema.Required {
+			if _, found := object[name]; !found {
+				return invalidParameter(joinField(field, name), fmt.Errorf("缺少字段 %s", name))
+			}
+		}
+		for name, property := range schema.Properties {
+			if item, found := object[name]; found {
+				if apiErr := d.validateValue(property, item, joinField(field, name)); apiErr != nil {
+					return apiErr
+				}
+			}
+		}
+
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return typeMismatch(field, "array")
+		}
+		for i, item := range items {
+			if apiErr := d.validateValue(schema.Items, item, fmt.Sprintf("%s[%d]", field, i)); apiErr != nil {
+				return apiErr
+			}
+		}
+
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return typeMismatch(field, "string")
+		}
+		return validateString(schema, text, field)
+
+	case "integer", "number":
+		number, ok := numericValue(value, schema.Type == "integer")
+		if !ok {
+			return typeMismatch(field, schema.Type)
+		}
+		if (schema.Minimum != nil && number < *schema.Minimum) || (schema.Maximum != nil && number > *schema.Maximum) {
+			return invalidParameter(field, fmt.Errorf("%s 超出范围: %v（%s）", field, number, rangeText(schema)))
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return typeMismatch(field, "boolean")
+		}
+	}
+	return nil
+}
+
+// validateString 校验字符串的枚举、长度和格式，枚举不区分大小写
+func validateString(schema *openAPISchema, text, field string) *apiError {
+	if len(schema.Enum) > 0 {
+		matched := false
+		for _, option := range schema.Enum {
+			if strings.EqualFold(strings.TrimSpace(text), option) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return invalidParameter(field, fmt.Errorf("%s 取值无效: %s（可选 %s）", field, text, strings.Join(schema.Enum, "、")))
+		}
+	}
+	if schema.MaxLength != nil {
+		if n := utf8.RuneCountInString(text); n > *schema.MaxLength {
+			return invalidParameter(field, fmt.Errorf("%s 过长: %d 字（最多 %d 字）", field, n, *schema.MaxLength))
+		}
+	}
+	if schema.Format == "date" {
+		if _, err := time.Parse("2006-01-02", text); err != nil {
+			return invalidParameter(field, fmt.Errorf("%s 日期格式错误，应为YYYY-MM-DD: %s", field, text))
+		}
+	}
+	return nil
+}
+
+// numericValue 取得JSON数字的值，integer要求为整数
+func numericValue(value interface{}, integer bool) (float64, bool) {
+	var number float64
+	switch v := value.(type) {
+	case json.Number:
+		if integer {
+			n, err := v.Int64()
+			return float64(n), err == nil
+		}
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		number = v
+	default:
+		return 0, false
+	}
+	if integer && number != float64(int64(number)) {
+		return 0, false
+	}
+	return number, true
+}
+
+// rangeText 取值范围的说明，如"-180~180"
+func rangeText(schema *openAPISchema) string {
+	switch {
+	case schema.Minimum != nil && schema.Maximum != nil:
+		return fmt.Sprintf("%v~%v", *schema.Minimum, *schema.Maximum)
+	case schema.Minimum != nil:
+		return fmt.Sprintf("不小于%v", *schema.Minimum)
+	case schema.Maximum != nil:
+		return fmt.Sprintf("不大于%v", *schema.Maximum)
+	}
+	return ""
+}
+
+// typeMismatch 返回字段类型不符的错误
+func typeMismatch(field, expected string) *apiError {
+	message := fmt.Sprintf("请求体应为 %s", expected)
+	if field != "" {
+		message = fmt.Sprintf("字段 %s 的类型应为 %s", field, expected)
+	}
+	return &apiError{Status: http.StatusBadRequest, Code: ErrCodeTypeMismatch, Field: field, Message: message}
+}
+
+// joinField 拼接嵌套字段名
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
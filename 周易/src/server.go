@@ -2,21 +2,36 @@ package main
 
 import (
 	"bytes"
+	"crypto/hmac"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// maxDivineRequestBytes 占卜请求体的大小上限
+const maxDivineRequestBytes = 1 << 20
+
 // API处理函数 - 处理"今日卦象"请求
+// POST /api/v1/divine
 func handleDivineRequest(w http.ResponseWriter, r *http.Request) {
 	// 1. 打印请求体 (可选，但有助于调试)
-	bodyBytes, err := io.ReadAll(r.Body)
+	bodyBytes, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDivineRequestBytes))
 	if err != nil {
-		http.Error(w, "无法读取请求体", http.StatusBadRequest)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeAPIError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("请求体超过 %d 字节", maxDivineRequestBytes))
+			return
+		}
+		writeAPIError(w, http.StatusBadRequest, "无法读取请求体")
 		return
 	}
 	defer r.Body.Close() // 确保关闭请求体，避免资源泄漏
@@ -28,51 +43,86 @@ func handleDivineRequest(w http.ResponseWriter, r *http.Request) {
 	// 2. JSON 解码和错误处理
 	err = decoder.Decode(&req)
 	if err != nil {
-		// 更细致的错误处理，根据错误类型返回不同的 HTTP 状态码
+		// 按错误类型返回不同的错误码，解码失败都是请求本身的问题
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
 		switch {
-		case err == io.EOF:
-			http.Error(w, "请求体为空", http.StatusBadRequest)
-		case err.(*json.SyntaxError) != nil:
-			http.Error(w, fmt.Sprintf("JSON 语法错误: %s", err), http.StatusBadRequest)
-		case err.(*json.UnmarshalTypeError) != nil:
-			http.Error(w, fmt.Sprintf("类型不匹配: %s", err), http.StatusBadRequest)
+		case errors.Is(err, io.EOF):
+			writeError(w, &apiError{Status: http.StatusBadRequest, Code: ErrCodeEmptyBody, Message: "请求体为空"})
+		case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+			writeError(w, &apiError{Status: http.StatusBadRequest, Code: ErrCodeInvalidJSON, Message: fmt.Sprintf("JSON 语法错误: %s", err)})
+		case errors.As(err, &typeErr):
+			writeError(w, &apiError{Status: http.StatusBadRequest, Code: ErrCodeTypeMismatch, Field: typeErr.Field, Message: fmt.Sprintf("类型不匹配: %s", err)})
 		default:
 			log.Printf("JSON 解码错误: %v", err) // 记录错误到日志
-			http.Error(w, "服务器内部错误", http.StatusInternalServerError)
+			writeError(w, &apiError{Status: http.StatusBadRequest, Code: ErrCodeInvalidJSON, Message: fmt.Sprintf("无法解析请求体: %s", err)})
 		}
 		return
 	}
 	fmt.Printf("Received request body: %+v\n", req) // 打印解码后的数据
 
+	// 查询参数inline=true与请求体中的inline等效
+	if inline := r.URL.Query().Get("inline"); inline != "" {
+		if req.Inline, err = strconv.ParseBool(inline); err != nil {
+			writeFieldError(w, "inline", "inline参数无效: "+inline)
+			return
+		}
+	}
+
+	// 图片格式和质量也可由查询参数指定；都未指定格式时按Accept请求头协商
+	if format := r.URL.Query().Get("format"); format != "" && req.Format == "" {
+		req.Format = format
+	}
+	if req.Format == "" {
+		if format, ok := negotiateImageFormat(r.Header.Get("Accept"), divineAcceptFormats()); ok {
+			req.Format = format
+		}
+	}
+	if quality := r.URL.Query().Get("quality"); quality != "" && req.Quality == 0 {
+		if req.Quality, err = strconv.Atoi(quality); err != nil {
+			writeFieldError(w, "quality", "图片质量无效: "+quality)
+			return
+		}
+	}
+
+	// 语言可由查询参数指定；都未指定时使用默认语言，开启了negotiate_locale时先按Accept-Language请求头协商
+	if locale := r.URL.Query().Get("locale"); locale != "" && req.Locale == "" {
+		req.Locale = locale
+	}
+	if req.Locale == "" && GetConfig().Render.NegotiateLocale {
+		if locale, ok := negotiateLocale(r.Header.Get("Accept-Language")); ok {
+			req.Locale = locale
+		}
+	}
+
+	// 未带user_id的今日卦象按X-User-ID请求头或客户端地址区分用户
+	if req.UserID == "" && isTodayType(req.Type) {
+		req.UserID = requestUserID(r)
+	}
+
+	if apiErr := validateDivineRequest(&req); apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+
 	// 生成卦象图片
-	imagePath, err := generateTodayGua()
+	result, err := generateDivination(&req)
 	if err != nil {
-		http.Error(w, "生成卦象失败: "+err.Error(), http.StatusInternalServerError)
+		writeAPIError(w, http.StatusInternalServerError, "生成卦象失败: "+err.Error())
 		return
 	}
 
-	// 构建完整的图片URL
-	config := GetConfig()
-	port := config.Server.Port
-	fullImageURL := fmt.Sprintf("http://localhost:%s/%s", port, imagePath)
-
-	// 创建响应
-	now := time.Now()
-	divineResult := DivineResult{
-		ID:        fmt.Sprintf("divine_%d", now.UnixNano()),
-		Date:      now.Format("2006-01-02"),
-		ImagePath: fullImageURL, // 返回完整的图片URL
-		CreatedAt: now.Unix(),
-	}
+	// 图片链接改为完整地址，经反向代理访问时使用代理的地址
+	divineResult := publicResult(result, publicBaseURL(r))
 
 	response := ApiResponse{
 		Code:    200,
-		Message: "成功",
+		Message: newLocalizer(divineResult.Locale).text("成功"),
 		Data:    divineResult,
 	}
 
-	// 广播到WebSocket客户端
-	BroadcastMessage(WSEventDivine, divineResult)
+	// 广播到WebSocket客户端，只附带盘面和链接，见broadcastResult
+	BroadcastMessage(WSEventDivine, broadcastResult(result))
 
 	// 设置响应头
 	w.Header().Set("Content-Type", "application/json")
@@ -80,15 +130,588 @@ func handleDivineRequest(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// broadcastResult 返回推送给全部WebSocket客户端的占卜结果副本
+// 去掉较大的Base64图片和可能涉及隐私的所问之事，所问之事只返回给发起请求的一方；
+// 结果中没有用户标识，广播不会暴露是谁起的卦。链接保留服务内的路径，由各客户端按自己连接时的地址转换，
+// 请求方的Host不会影响其他客户端
+func broadcastResult(result *DivineResult) *DivineResult {
+	broadcast := *result
+	broadcast.ImageData = ""
+	broadcast.Question = ""
+	return &broadcast
+}
+
+// validateDivineRequest 校验占卜请求，出错时返回带参数名的错误
+// 校验起卦类型、时间、时区、经度、图片格式和质量、主题、版式、品牌、所问之事和语言
+func validateDivineRequest(req *DivineRequest) *apiError {
+	if _, err := normalizeDivineType(req.Type, req.UserID); err != nil {
+		if errors.Is(err, errTodayWithoutUser) {
+			return invalidParameter("user_id", err)
+		}
+		return invalidParameter("type", err)
+	}
+	if req.Timezone != "" {
+		if _, err := resolveDivineTime("", req.Timezone); err != nil {
+			return invalidParameter("timezone", err)
+		}
+	}
+	if _, err := resolveDivineTime(req.DateTime, req.Timezone); err != nil {
+		return invalidParameter("datetime", err)
+	}
+	if req.Longitude != nil {
+		if err := validateLongitude(*req.Longitude); err != nil {
+			return invalidParameter("longitude", err)
+		}
+	}
+	if _, err := normalizeImageFormat(req.Format); err != nil {
+		return invalidParameter("format", err)
+	}
+	if err := validateImageQuality(req.Quality); err != nil {
+		return invalidParameter("quality", err)
+	}
+	if _, err := resolveTheme(req.Theme, req.GroupID); err != nil {
+		return invalidParameter("theme", err)
+	}
+	if _, err := normalizeLayoutName(req.Layout); err != nil {
+		return invalidParameter("layout", err)
+	}
+	if _, err := resolveBranding(req.Brand, req.GroupID); err != nil {
+		return invalidParameter("brand", err)
+	}
+	if err := validateQuestion(req.Question); err != nil {
+		return invalidParameter("question", err)
+	}
+	if err := validateCategory(req.Category); err != nil {
+		return invalidParameter("category", err)
+	}
+	if _, err := normalizeLocale(req.Locale); err != nil {
+		return invalidParameter("locale", err)
+	}
+	return nil
+}
+
 // 新增API路由处理
 func setupAPIRoutes() {
-	http.HandleFunc("/api/divine", handleDivineRequest)
-	http.HandleFunc("/ws", handleWSConnection)                // WebSocket连接端点
-	http.HandleFunc("/onebot/ws", handleOneBotWSConnection)   // OneBot WebSocket连接端点
-	http.HandleFunc("/api/ws/status", handleWSStatus)         // WebSocket状态查询
-	http.HandleFunc("/api/onebot/status", handleOneBotStatus) // OneBot状态查询
-	http.HandleFunc("/test", serveWebSocketTestPage)          // WebSocket测试页面
-	http.HandleFunc("/onebot/test", serveOneBotTestPage)      // OneBot测试页面
+	// 接口注册在/api/v1下，旧的/api路径作为别名
+	handleAPI("POST /divine", handleDivineRequest)                                  // 起卦
+	handleAPI("GET /divine", handleDivineList)                                      // 占卜历史列表
+	handleAPI("GET /divine/{id}", handleDivineGet)                                  // 按ID查询占卜历史
+	handleAPI("GET /divine/{id}/image", requireSignedLink(handleDivineImage))       // 直接输出卦象图片
+	handleAPI("POST /divine/extract", handleChartExtract)                           // 从卦象图中取回盘面
+	handleAPI("GET /divine/{id}/report.pdf", requireSignedLink(handleDivineReport)) // PDF占卜报告
+	handleAPI("GET /calendar", handleCalendarQuery)                                 // 历法查询
+	handleAPI("GET /themes", handleThemeList)                                       // 图片主题列表
+	handleAPI("GET /ws/status", handleWSStatus)                                     // WebSocket状态查询
+	handleAPI("GET /onebot/status", handleOneBotStatus)                             // OneBot状态查询
+	handleAPI("GET /openapi.json", handleOpenAPISpec)                               // OpenAPI文档
+	handleAPI("GET /docs", handleOpenAPIViewer)                                     // 交互式接口文档
+	http.HandleFunc("/ws", handleWSConnection)                                      // WebSocket连接端点
+	http.HandleFunc("/onebot/ws", handleOneBotWSConnection)                         // OneBot WebSocket连接端点
+	http.HandleFunc("/test", serveWebSocketTestPage)                                // WebSocket测试页面
+	http.HandleFunc("/onebot/test", serveOneBotTestPage)                            // OneBot测试页面
+
+	// 文档中描述的接口都应已注册
+	getOpenAPIDocument().checkRegistered()
+}
+
+// 历法查询API
+// GET /api/v1/calendar?date=YYYY-MM-DD[&time=HH:MM&tz=Asia/Shanghai]
+func handleCalendarQuery(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	moment, withHour, err := parseCalendarQuery(query.Get("date"), query.Get("time"), query.Get("tz"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	longitude, err := parseLongitude(query.Get("longitude"))
+	if err != nil {
+		writeFieldError(w, "longitude", err.Error())
+		return
+	}
+
+	info, err := buildCalendarInfo(moment, withHour, longitude)
+	if err != nil {
+		log.Printf("历法查询失败: %v", err)
+		writeAPIError(w, http.StatusBadGateway, "获取万年历数据失败: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ApiResponse{
+		Code:    200,
+		Message: "成功",
+		Data:    info,
+	})
+}
+
+// divineAcceptFormats 占卜接口按Accept请求头协商时的候选格式，按偏好排序
+// 动画只能通过format参数显式请求，浏览器的Accept中常带有image/apng，不能据此返回动画
+func divineAcceptFormats() []string {
+	return []string{ImageFormatPNG, ImageFormatJPEG, ImageFormatSVG}
+}
+
+// handleDivineImage 输出占卜结果的卦象图片
+// GET /api/v1/divine/{id}/image[?format=jpeg&quality=80&size=thumb]
+// 优先从内存存储读取，已淘汰且开启了落盘时从图片目录读取。
+// 静态位图可按format参数或Accept请求头转换为PNG或JPEG，转换结果同样缓存在内存中；
+// size为thumb或hires时输出按比例重新渲染的缩略图或高清图
+func handleDivineImage(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	query := r.URL.Query()
+
+	quality := 0
+	if value := query.Get("quality"); value != "" {
+		var err error
+		if quality, err = strconv.Atoi(value); err != nil || validateImageQuality(quality) != nil {
+			writeFieldError(w, "quality", "图片质量无效: "+value)
+			return
+		}
+	}
+	format := ""
+	if value := query.Get("format"); value != "" {
+		var err error
+		if format, err = normalizeImageFormat(value); err != nil {
+			writeFieldError(w, "format", err.Error())
+			return
+		}
+	}
+	size, err := normalizeImageSize(query.Get("size"))
+	if err != nil {
+		writeFieldError(w, "size", err.Error())
+		return
+	}
+
+	img, found := getImageStore().Get(id)
+	if !found {
+		img, found = loadStoredImageFile(id)
+	}
+	if !found {
+		writeAPIError(w, http.StatusNotFound, "图片不存在或已过期: "+id)
+		return
+	}
+
+	// 未指定format时按Accept协商，只在静态位图之间转换，q值相同时保持原格式；变体总是静态位图
+	stored := imageFormatFromContentType(img.ContentType)
+	if size != ImageSizeOriginal {
+		stored = variantFormat(stored)
+	}
+	if format == "" && isRasterFormat(stored) {
+		format, _ = negotiateImageFormat(r.Header.Get("Accept"), []string{stored, ImageFormatPNG, ImageFormatJPEG})
+		w.Header().Set("Vary", "Accept")
+	}
+	if size != ImageSizeOriginal {
+		// 缩略图和高清图按请求的格式直接渲染，不经转码，保留写入的分辨率
+		if format != "" && !isRasterFormat(format) {
+			writeFieldError(w, "format", "缩略图和高清图只支持png、jpeg格式: "+format)
+			return
+		}
+		if format == stored {
+			format = ""
+		}
+		variant, err := getImageVariant(id, img, size, format, quality, clientAddress(r))
+		var limited *rateLimitError
+		if errors.As(err, &limited) {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(limited.retryAfter)))
+			writeErrorResponse(w, http.StatusTooManyRequests, err.Error(), &ErrorDetail{Code: ErrCodeRateLimited})
+			return
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, "生成"+size+"图片失败: "+err.Error())
+			return
+		}
+		img = variant
+	} else if format != "" && (format != stored || (quality > 0 && format != ImageFormatPNG)) {
+		converted, err := convertDivineImage(id, img, format, quality)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		img = converted
+	}
+
+	// 同一ID的图片不会改变，允许客户端长期缓存
+	w.Header().Set("Content-Type", img.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(img.Data)))
+	w.Header().Set("Cache-Control", "public, max-age=86400, immutable")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename*=UTF-8''%s", url.PathEscape(img.FileName)))
+	w.Write(img.Data)
+}
+
+// convertDivineImage 将卦象图转换为指定的格式和质量，结果按ID、格式和质量缓存在内存存储中
+func convertDivineImage(id string, img *renderedImage, format string, quality int) (*renderedImage, error) {
+	key := fmt.Sprintf("%s@%s:%d", id, format, quality)
+	if converted, found := getImageStore().Get(key); found {
+		return converted, nil
+	}
+	converted, err := transcodeImage(img, format, quality)
+	if err != nil {
+		return nil, err
+	}
+	getImageStore().Put(key, converted)
+	return converted, nil
+}
+
+// handleDivineReport 输出占卜结果的PDF报告
+// GET /api/v1/divine/{id}/report.pdf[?theme=print&layout=landscape]
+// 盘面取自图片存储，已淘汰时从落盘图片的元数据中还原，图片已被清理时取自占卜历史；
+// 卦象图的主题默认沿用原图，版式默认为横版。
+// 生成的报告按ID、主题和版式缓存在图片存储中
+func handleDivineReport(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	query := r.URL.Query()
+
+	var meta *ChartMetadata
+	img, found := getImageStore().Get(id)
+	if !found {
+		img, found = loadStoredImageFile(id)
+	}
+	if found {
+		if meta = img.Meta; meta == nil {
+			var err error
+			if meta, err = extractChartMetadata(img.Data); err != nil {
+				writeAPIError(w, http.StatusNotFound, "无法取得该占卜的盘面: "+err.Error())
+				return
+			}
+		}
+	} else if store := getHistoryStore(); store != nil {
+		if record, ok := store.Get(id); ok && record.Chart != nil {
+			meta = record.chartMetadata()
+		}
+	}
+	if meta == nil {
+		writeAPIError(w, http.StatusNotFound, "占卜结果不存在或已过期: "+id)
+		return
+	}
+
+	// 原图的主题可能已被删除，未指定主题时改用默认主题
+	theme, err := resolveTheme(query.Get("theme"), 0)
+	if query.Get("theme") == "" {
+		if theme, err = resolveTheme(meta.Theme, 0); err != nil {
+			theme, err = resolveTheme("", 0)
+		}
+	}
+	if err != nil {
+		writeFieldError(w, "theme", err.Error())
+		return
+	}
+	layoutName, err := normalizeLayoutName(query.Get("layout"))
+	if err != nil {
+		writeFieldError(w, "layout", err.Error())
+		return
+	}
+
+	key := fmt.Sprintf("%s@report:%s:%s", id, theme.Name, layoutName)
+	report, found := getImageStore().Get(key)
+	if !found {
+		data, err := generateDivineReport(meta, theme, layoutName)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, "生成报告失败: "+err.Error())
+			return
+		}
+		baseName, _ := imageBaseName(id)
+		report = &renderedImage{Data: data, ContentType: "application/pdf", FileName: baseName + ".pdf", CreatedAt: time.Now(), Meta: meta}
+		getImageStore().Put(key, report)
+	}
+
+	w.Header().Set("Content-Type", report.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(report.Data)))
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename*=UTF-8''%s", url.PathEscape(report.FileName)))
+	w.Write(report.Data)
+}
+
+// maxExtractUploadBytes 取回盘面时上传图片的大小上限
+const maxExtractUploadBytes = 20 << 20
+
+// handleChartExtract 从上传的卦象图中取回盘面元数据
+// POST /api/v1/divine/extract[?render=true&format=png&theme=dark&layout=portrait]
+// 图片可以multipart表单的image字段上传，也可直接作为请求体。
+// 指定render=true时按元数据中的盘面重新渲染出一张新图，主题和版式默认沿用原图
+func handleChartExtract(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxExtractUploadBytes)
+
+	var data []byte
+	var err error
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		file, _, formErr := r.FormFile("image")
+		if formErr != nil {
+			writeAPIError(w, http.StatusBadRequest, "缺少图片字段 image: "+formErr.Error())
+			return
+		}
+		defer file.Close()
+		data, err = io.ReadAll(file)
+	} else {
+		data, err = io.ReadAll(r.Body)
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "读取图片失败: "+err.Error())
+		return
+	}
+	if len(data) == 0 {
+		writeAPIError(w, http.StatusBadRequest, "请求中没有图片")
+		return
+	}
+
+	meta, err := extractChartMetadata(data)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	result := &ChartExtractResult{Metadata: meta, Verified: verifyChartSeed(meta.Chart)}
+	if _, found := getImageStore().Get(meta.ID); found {
+		result.ImageURL = buildPublicURL(publicBaseURL(r), divineImagePath(meta.ID), nil)
+	}
+
+	query := r.URL.Query()
+	if render, _ := strconv.ParseBool(query.Get("render")); render {
+		req := &DivineRequest{
+			Type:     meta.Type,
+			Format:   query.Get("format"),
+			Theme:    query.Get("theme"),
+			Layout:   query.Get("layout"),
+			Brand:    query.Get("brand"),
+			Question: meta.Question,
+		}
+		if req.Theme == "" {
+			req.Theme = meta.Theme
+		}
+		if req.Layout == "" {
+			req.Layout = meta.Layout
+		}
+		// 原图的品牌可能已从配置中删除，此时改用默认品牌
+		if req.Brand == "" {
+			if _, err := resolveBranding(meta.Brand, 0); err == nil {
+				req.Brand = meta.Brand
+			}
+		} else if _, err := resolveBranding(req.Brand, 0); err != nil {
+			writeFieldError(w, "brand", err.Error())
+			return
+		}
+		if quality := query.Get("quality"); quality != "" {
+			if req.Quality, err = strconv.Atoi(quality); err != nil || validateImageQuality(req.Quality) != nil {
+				writeFieldError(w, "quality", "图片质量无效: "+quality)
+				return
+			}
+		}
+
+		format, err := normalizeImageFormat(req.Format)
+		if err != nil {
+			writeFieldError(w, "format", err.Error())
+			return
+		}
+		// 原图的主题可能已被删除，此时改用默认主题
+		theme, err := resolveTheme(req.Theme, 0)
+		if err != nil && query.Get("theme") == "" {
+			theme, err = resolveTheme("", 0)
+		}
+		if err != nil {
+			writeFieldError(w, "theme", err.Error())
+			return
+		}
+		layoutName, err := normalizeLayoutName(req.Layout)
+		if err != nil {
+			writeFieldError(w, "layout", err.Error())
+			return
+		}
+
+		// 可改用其他语言重新渲染，盘面本身不变
+		chart := meta.Chart
+		if locale := query.Get("locale"); locale != "" {
+			if locale, err = normalizeLocale(locale); err != nil {
+				writeFieldError(w, "locale", err.Error())
+				return
+			}
+			localized := *meta.Chart
+			localized.Locale = locale
+			chart = &localized
+		}
+
+		rerendered, err := renderDivination(req, chart, format, theme, layoutName)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, "重新渲染失败: "+err.Error())
+			return
+		}
+		result.Rerendered = publicResult(rerendered, publicBaseURL(r))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ApiResponse{
+		Code:    200,
+		Message: "成功",
+		Data:    result,
+	})
+}
+
+// handleDivineGet 按ID查询占卜历史
+// GET /api/v1/divine/{id}
+// 返回起卦时的完整结果和盘面，图片被清理后记录仍然保留
+func handleDivineGet(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	store := getHistoryStore()
+	if store == nil {
+		writeAPIError(w, http.StatusNotFound, "未启用占卜历史")
+		return
+	}
+	withQuestion, ok := authorizeHistory(w, r)
+	if !ok {
+		return
+	}
+	record, found := store.Get(id)
+	if !found {
+		writeAPIError(w, http.StatusNotFound, "占卜记录不存在: "+id)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ApiResponse{
+		Code:    200,
+		Message: "成功",
+		Data:    publicRecord(record, publicBaseURL(r), withQuestion),
+	})
+}
+
+// authorizeHistory 校验查询占卜历史的访问令牌
+// 配置了history.access_token时请求须带Authorization: Bearer <令牌>，否则返回401；
+// 未配置令牌时任何人都可查询，但不返回所问之事
+//
+// 返回值：
+//   - withQuestion: 是否在结果中返回所问之事
+//   - ok: 是否放行，为false时已写入错误响应
+func authorizeHistory(w http.ResponseWriter, r *http.Request) (withQuestion, ok bool) {
+	token := GetConfig().History.AccessToken
+	if token == "" {
+		return false, true
+	}
+	given, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || !hmac.Equal([]byte(strings.TrimSpace(given)), []byte(token)) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeErrorResponse(w, http.StatusUnauthorized, "查询占卜历史需要访问令牌", &ErrorDetail{Code: ErrCodeUnauthorized})
+		return false, false
+	}
+	return true, true
+}
+
+// 占卜历史分页参数
+const (
+	defaultHistoryPageSize = 20
+	maxHistoryPageSize     = 100
+)
+
+// handleDivineList 分页列出占卜历史，最近的记录在前
+// GET /api/v1/divine[?page=1&page_size=20&from=2025-01-01&to=2025-01-31&gua=乾&user_id=u1&group_id=123&type=today&category=事业]
+func handleDivineList(w http.ResponseWriter, r *http.Request) {
+	store := getHistoryStore()
+	if store == nil {
+		writeAPIError(w, http.StatusNotFound, "未启用占卜历史")
+		return
+	}
+	withQuestion, ok := authorizeHistory(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+
+	page := 1
+	if value := query.Get("page"); value != "" {
+		var err error
+		if page, err = strconv.Atoi(value); err != nil || page < 1 {
+			writeFieldError(w, "page", "页码无效: "+value)
+			return
+		}
+	}
+	pageSize := defaultHistoryPageSize
+	if value := query.Get("page_size"); value != "" {
+		var err error
+		if pageSize, err = strconv.Atoi(value); err != nil || pageSize < 1 || pageSize > maxHistoryPageSize {
+			writeFieldError(w, "page_size", fmt.Sprintf("每页条数无效: %s（1-%d）", value, maxHistoryPageSize))
+			return
+		}
+	}
+
+	filter := HistoryFilter{
+		From:     query.Get("from"),
+		To:       query.Get("to"),
+		UserID:   query.Get("user_id"),
+		Type:     query.Get("type"),
+		Category: query.Get("category"),
+	}
+	for field, date := range map[string]string{"from": filter.From, "to": filter.To} {
+		if _, err := time.Parse("2006-01-02", date); date != "" && err != nil {
+			writeFieldError(w, field, "日期格式错误，应为YYYY-MM-DD: "+date)
+			return
+		}
+	}
+	if value := query.Get("gua"); value != "" {
+		name, ok := normalizeGuaName(value)
+		if !ok {
+			writeFieldError(w, "gua", "卦名无效: "+value)
+			return
+		}
+		filter.Gua = name
+	}
+	if value := query.Get("group_id"); value != "" {
+		var err error
+		if filter.GroupID, err = strconv.ParseInt(value, 10, 64); err != nil {
+			writeFieldError(w, "group_id", "群号无效: "+value)
+			return
+		}
+	}
+
+	result := store.List(filter, page, pageSize)
+	base := publicBaseURL(r)
+	for i, record := range result.Items {
+		result.Items[i] = publicRecord(record, base, withQuestion)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ApiResponse{
+		Code:    200,
+		Message: "成功",
+		Data:    result,
+	})
+}
+
+// handleThemeList 返回可用的图片主题和版式模板
+func handleThemeList(w http.ResponseWriter, r *http.Request) {
+	themes := make([]*Theme, 0, len(getThemes()))
+	for _, name := range themeNames() {
+		themes = append(themes, getThemes()[name])
+	}
+	templates := make([]*ChartTemplate, 0, len(getChartTemplates()))
+	for _, name := range layoutNames() {
+		templates = append(templates, getChartTemplates()[name])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ApiResponse{
+		Code:    200,
+		Message: "成功",
+		Data: map[string]interface{}{
+			"default":        GetConfig().Render.DefaultTheme,
+			"themes":         themes,
+			"default_layout": GetConfig().Render.DefaultLayout,
+			"layouts":        layoutNames(),
+			"templates":      templates,
+		},
+	})
+}
+
+// writeAPIError 以统一的ApiResponse格式返回错误，错误码按HTTP状态码取默认值
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeErrorResponse(w, status, message, &ErrorDetail{Code: errorCodeForStatus(status)})
+}
+
+// writeErrorResponse 以统一的ApiResponse格式返回错误
+func writeErrorResponse(w http.ResponseWriter, status int, message string, detail *ErrorDetail) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ApiResponse{
+		Code:    status,
+		Message: message,
+		Data:    nil,
+		Error:   detail,
+	})
 }
 
 // 提供WebSocket测试页面
@@ -109,6 +732,10 @@ func handleOneBotStatus(w http.ResponseWriter, r *http.Request) {
 		"onebot_enabled":    true,
 		"onebot_version":    OneBotVersion,
 		"implementation":    OneBotImpl,
+		"calendar_cache":    getCalendarCache().Stats(),
+		"calendar_verify":   calendarVerifyStats(),
+		"image_store":       getImageStore().Stats(),
+		"variant_cache":     getVariantCache().Stats(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -124,13 +751,13 @@ func serveImageFiles() {
 	photosDir := filepath.Join(getCurrentDir(), "photos")
 	if err := ensureDir(photosDir); err == nil {
 		fs := http.FileServer(http.Dir(photosDir))
-		http.Handle("/photos/", http.StripPrefix("/photos/", fs))
+		http.Handle("/photos/", requireSignedLink(http.StripPrefix("/photos/", fs).ServeHTTP))
 	}
 
 	outputDir := filepath.Join(getCurrentDir(), "output")
 	if err := ensureDir(outputDir); err == nil {
 		fs := http.FileServer(http.Dir(outputDir))
-		http.Handle("/output/", http.StripPrefix("/output/", fs))
+		http.Handle("/output/", requireSignedLink(http.StripPrefix("/output/", fs).ServeHTTP))
 	}
 }
 
@@ -148,20 +775,25 @@ func startServer() {
 	// 提供图像文件访问
 	serveImageFiles()
 
-	// 从配置文件获取端口
+	// 从配置文件获取端口，日志中的地址使用对外访问的基础地址
 	config := GetConfig()
 	port := config.Server.Port
+	base := publicBaseURL(nil)
+	wsBase := "ws" + strings.TrimPrefix(base, "http")
 	log.Printf("启动HTTP服务器，监听端口 %s...", port)
-	log.Printf("API接口路径: http://localhost:%s/api/divine", port)
-	log.Printf("WebSocket接口路径: ws://localhost:%s/ws", port)
-	log.Printf("OneBot WebSocket接口路径: ws://localhost:%s/onebot/ws", port)
-	log.Printf("WebSocket状态查询: http://localhost:%s/api/ws/status", port)
-	log.Printf("OneBot状态查询: http://localhost:%s/api/onebot/status", port)
-	log.Printf("WebSocket测试页面: http://localhost:%s/test", port)
-	log.Printf("OneBot测试页面: http://localhost:%s/onebot/test", port)
+	log.Printf("API接口路径: %s/api/v1/divine（旧路径/api/divine仍可用）", base)
+	log.Printf("卦象图片接口路径: %s/api/v1/divine/{id}/image", base)
+	log.Printf("历法查询接口路径: %s/api/v1/calendar?date=YYYY-MM-DD", base)
+	log.Printf("图片主题列表: %s/api/v1/themes", base)
+	log.Printf("WebSocket接口路径: %s/ws", wsBase)
+	log.Printf("OneBot WebSocket接口路径: %s/onebot/ws", wsBase)
+	log.Printf("WebSocket状态查询: %s/api/v1/ws/status", base)
+	log.Printf("OneBot状态查询: %s/api/v1/onebot/status", base)
+	log.Printf("WebSocket测试页面: %s/test", base)
+	log.Printf("OneBot测试页面: %s/onebot/test", base)
 
 	// 启动HTTP服务器
-	err := http.ListenAndServe(":"+port, nil)
+	err := http.ListenAndServe(":"+port, newAPIRouter(http.DefaultServeMux))
 	if err != nil {
 		log.Fatalf("启动HTTP服务器失败: %v", err)
 	}
@@ -0,0 +1,101 @@
+// solar_time.go 实现真太阳时换算
+// 钟表时间以时区中央经线为准，而时辰应以起卦地的太阳位置为准：
+// 真太阳时 = 钟表时间 + (当地经度 - 时区中央经线) × 4分钟 + 均时差
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// zoneMeridian 返回时刻所在时区偏移对应的中央经线（东经为正）
+// 如北京时间UTC+8对应东经120度，夏令时期间按实际偏移计算
+func zoneMeridian(moment time.Time) float64 {
+	_, offset := moment.Zone()
+	return float64(offset) / 3600 * 15
+}
+
+// equationOfTime 计算指定时刻的均时差（分钟）
+// 采用Spencer傅里叶级数近似，全年误差在半分钟以内，对划分时辰足够精确
+//
+// 参数：
+//   - moment: 计算时刻
+//
+// 返回值：真太阳时减平太阳时的分钟数，约在-14至+16分钟之间
+func equationOfTime(moment time.Time) float64 {
+	utc := moment.UTC()
+	gamma := 2 * math.Pi / 365 * (float64(utc.YearDay()-1) + (float64(utc.Hour())-12)/24)
+	return 229.18 * (0.000075 +
+		0.001868*math.Cos(gamma) - 0.032077*math.Sin(gamma) -
+		0.014615*math.Cos(2*gamma) - 0.040849*math.Sin(2*gamma))
+}
+
+// trueSolarTime 将钟表时间换算为起卦地的真太阳时
+//
+// 参数：
+//   - clock: 钟表时间
+//   - longitude: 起卦地经度，东经为正、西经为负
+//   - withEquation: 是否加上均时差，为false时得到的是地方平太阳时
+//
+// 返回值：与clock位于同一时区、钟点为真太阳时的时刻
+func trueSolarTime(clock time.Time, longitude float64, withEquation bool) time.Time {
+	minutes := (longitude - zoneMeridian(clock)) * 4
+	if withEquation {
+		minutes += equationOfTime(clock)
+	}
+	return clock.Add(time.Duration(minutes * float64(time.Minute)))
+}
+
+// validateLongitude 检查经度是否在-180到180度之间
+func validateLongitude(longitude float64) error {
+	if math.IsNaN(longitude) || longitude < -180 || longitude > 180 {
+		return fmt.Errorf("无效的经度: %v（应在-180到180之间）", longitude)
+	}
+	return nil
+}
+
+// parseLongitude 解析查询参数中的经度，为空时返回nil
+func parseLongitude(value string) (*float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	longitude, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("无效的经度: %s", value)
+	}
+	if err := validateLongitude(longitude); err != nil {
+		return nil, err
+	}
+	return &longitude, nil
+}
+
+// resolvePillarTime 确定用于排时柱的时刻
+// 请求指定了经度时按该经度换算真太阳时；
+// 未指定经度但配置开启了真太阳时，则使用配置的默认经度；否则直接使用钟表时间
+//
+// 参数：
+//   - clock: 钟表时间
+//   - longitude: 请求中的经度，可为nil
+//
+// 返回值：
+//   - time.Time: 排盘所用时刻
+//   - *float64: 实际采用的经度，未换算时为nil
+//   - error: 经度无效时返回错误
+func resolvePillarTime(clock time.Time, longitude *float64) (time.Time, *float64, error) {
+	config := GetConfig().Calendar
+	if longitude == nil {
+		if !config.TrueSolarTime {
+			return clock, nil, nil
+		}
+		defaultLongitude := config.DefaultLongitude
+		longitude = &defaultLongitude
+	}
+	if err := validateLongitude(*longitude); err != nil {
+		return time.Time{}, nil, err
+	}
+	return trueSolarTime(clock, *longitude, config.EquationOfTime), longitude, nil
+}
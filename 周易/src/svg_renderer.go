@@ -0,0 +1,106 @@
+// svg_renderer.go 实现卦象图的SVG矢量渲染
+// 与位图渲染共用drawGuaImage的排版逻辑，文字宽度用同一套字体测量，
+// 换行和位置与PNG一致；元素带有class，前端可直接用CSS调整样式并无损缩放
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+)
+
+// svgTextClass 各文字样式对应的CSS类名
+var svgTextClass = [...]string{
+	textTitle:  "gua-text gua-title",
+	textNormal: "gua-text gua-normal",
+	textSmall:  "gua-text gua-small",
+	textAccent: "gua-text gua-accent",
+}
+
+// svgCanvas 输出SVG元素的画布
+type svgCanvas struct {
+	buf   bytes.Buffer
+	faces *chartFaces // 仅用于测量文字宽度
+	theme *Theme
+}
+
+func (c *svgCanvas) DrawText(text string, x, y int, style textStyle) {
+	fmt.Fprintf(&c.buf, `<text x="%d" y="%d" class="%s">`, x, y, svgTextClass[style])
+	xml.EscapeText(&c.buf, []byte(text))
+	c.buf.WriteString("</text>\n")
+}
+
+func (c *svgCanvas) DrawCenteredText(text string, centerX, y int, style textStyle) {
+	fmt.Fprintf(&c.buf, `<text x="%d" y="%d" text-anchor="middle" class="%s">`, centerX, y, svgTextClass[style])
+	xml.EscapeText(&c.buf, []byte(text))
+	c.buf.WriteString("</text>\n")
+}
+
+func (c *svgCanvas) DrawYao(x, y, width, height int, yang bool) {
+	line := c.theme.Line
+	if yang {
+		fmt.Fprintf(&c.buf, `<rect class="gua-yao gua-yang" x="%d" y="%d" width="%d" height="%d"/>`+"\n",
+			x, y, width, height)
+		return
+	}
+
+	// 阴爻与位图渲染一致：中间按主题比例留出空隙
+	gap := int(float64(width) * line.Gap)
+	leftWidth := (width - gap) / 2
+	rightWidth := width - gap - leftWidth
+	fmt.Fprintf(&c.buf, `<g class="gua-yao gua-yin"><rect x="%d" y="%d" width="%d" height="%d"/><rect x="%d" y="%d" width="%d" height="%d"/></g>`+"\n",
+		x, y, leftWidth, height, x+leftWidth+gap, y, rightWidth, height)
+}
+
+func (c *svgCanvas) MeasureText(text string, style textStyle) int {
+	return (&rasterCanvas{faces: c.faces, theme: c.theme}).MeasureText(text, style)
+}
+
+// renderChartSVG 将卦象盘面渲染为SVG文档
+//
+// 参数：
+//   - layout: 布局参数
+//   - chart: 盘面数据
+//   - faces: 用于测量文字宽度的字体
+//   - theme: 渲染主题，写入文档的默认样式
+//   - overlay: 品牌叠加层，nil表示不叠加
+//
+// 返回值：UTF-8编码的SVG文档
+func renderChartSVG(layout *Layout, chart *GuaChart, faces *chartFaces, theme *Theme, overlay *brandOverlay) ([]byte, error) {
+	canvas := &svgCanvas{faces: faces, theme: theme}
+	if err := drawGuaImage(canvas, layout, chart); err != nil {
+		return nil, err
+	}
+
+	var doc bytes.Buffer
+	fmt.Fprintf(&doc, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(&doc, `<svg xmlns="http://www.w3.org/2000/svg" class="gua-chart gua-theme-%s" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		theme.Name, layout.宽度, layout.高度, layout.宽度, layout.高度)
+
+	// 默认样式取自主题，字号与位图渲染的像素字号一致
+	doc.WriteString("<style>\n")
+	fmt.Fprintf(&doc, ".gua-text{font-family:%s;fill:%s}\n", theme.Font.Family, theme.Palette.Text)
+	fmt.Fprintf(&doc, ".gua-title{font-size:%gpx}\n", theme.fontSize(textTitle))
+	fmt.Fprintf(&doc, ".gua-normal{font-size:%gpx}\n", theme.fontSize(textNormal))
+	fmt.Fprintf(&doc, ".gua-small{font-size:%gpx;fill:%s}\n", theme.fontSize(textSmall), theme.Palette.SubText)
+	fmt.Fprintf(&doc, ".gua-accent{font-size:%gpx;fill:%s}\n", theme.fontSize(textAccent), theme.Palette.Accent)
+	if theme.Line.Style == YaoLineStroke {
+		fmt.Fprintf(&doc, ".gua-yao{fill:none;stroke:%s;stroke-width:%d}\n", theme.Palette.Yao, theme.Line.Stroke)
+	} else {
+		fmt.Fprintf(&doc, ".gua-yao{fill:%s}\n", theme.Palette.Yao)
+	}
+	doc.WriteString("</style>\n")
+
+	// 背景使用主题的渐变色
+	fmt.Fprintf(&doc, `<defs><linearGradient id="gua-bg" x1="0" y1="0" x2="0" y2="1">`+
+		`<stop offset="0" stop-color="%s"/><stop offset="1" stop-color="%s"/></linearGradient></defs>`+"\n",
+		theme.Background.GradientTop, theme.Background.GradientBottom)
+	fmt.Fprintf(&doc, `<rect class="gua-background" width="%d" height="%d" fill="url(#gua-bg)"/>`+"\n", layout.宽度, layout.高度)
+
+	doc.Write(canvas.buf.Bytes())
+	if overlay != nil {
+		overlay.writeSVG(&doc, faces, theme, layout.宽度, layout.高度)
+	}
+	doc.WriteString("</svg>\n")
+	return doc.Bytes(), nil
+}
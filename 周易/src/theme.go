@@ -0,0 +1,395 @@
+// theme.go 实现卦象图的主题系统
+// 主题定义配色、背景、字体和爻线样式，内置古典、深色、高对比打印和极简四套，
+// 也可以通过主题目录中的JSON文件或配置文件自定义，并按请求或OneBot群选择
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 内置主题名称
+const (
+	ThemeClassic = "classic" // 古典羊皮纸，默认主题
+	ThemeDark    = "dark"    // 深色模式
+	ThemePrint   = "print"   // 高对比度，适合打印
+	ThemeMinimal = "minimal" // 极简
+)
+
+// 爻线样式
+const (
+	YaoLineSolid  = "solid"   // 实心填充
+	YaoLineStroke = "outline" // 仅描边
+)
+
+// ThemePalette 主题配色，颜色均为"#rrggbb"格式
+type ThemePalette struct {
+	Text    string `json:"text"`     // 标题和正文文字
+	SubText string `json:"sub_text"` // 小字：公历时间、六亲纳甲、爻辞
+	Yao     string `json:"yao"`      // 爻线
+	Accent  string `json:"accent"`   // 动爻标记
+}
+
+// ThemeBackground 主题背景
+// 指定图片时位图渲染使用该图片，否则使用上下两色的竖向渐变；SVG始终使用渐变
+type ThemeBackground struct {
+	Image          string `json:"image,omitempty"` // 背景图片路径
+	GradientTop    string `json:"gradient_top"`    // 渐变顶部颜色
+	GradientBottom string `json:"gradient_bottom"` // 渐变底部颜色
+}
+
+// ThemeFont 主题字体
+type ThemeFont struct {
+	File       string  `json:"file,omitempty"` // 字体文件路径，为空使用系统默认字体
+	Family     string  `json:"family"`         // SVG输出使用的CSS字体族
+	TitleSize  float64 `json:"title_size"`     // 标题字号（像素）
+	NormalSize float64 `json:"normal_size"`    // 正文字号（像素）
+	SmallSize  float64 `json:"small_size"`     // 小字字号（像素）
+}
+
+// ThemeLine 爻线样式
+type ThemeLine struct {
+	Style  string  `json:"style"`  // solid或outline
+	Width  int     `json:"width"`  // 爻线宽度（像素）
+	Height int     `json:"height"` // 爻线高度（像素）
+	Gap    float64 `json:"gap"`    // 阴爻中间空隙占爻宽的比例
+	Stroke int     `json:"stroke"` // 描边粗细，仅outline样式使用
+}
+
+// Theme 一套完整的卦象图主题
+type Theme struct {
+	Name       string          `json:"name"`           // 主题名称，用于请求和配置中引用
+	Base       string          `json:"base,omitempty"` // 继承的主题，未填写的字段取自该主题
+	Title      string          `json:"title"`          // 主题显示名称
+	Palette    ThemePalette    `json:"palette"`
+	Background ThemeBackground `json:"background"`
+	Font       ThemeFont       `json:"font"`
+	Line       ThemeLine       `json:"line"`
+
+	colors themeColors // 解析后的颜色
+}
+
+// themeColors 主题中解析后的颜色
+type themeColors struct {
+	text, subText, yao, accent color.RGBA
+	top, bottom                color.RGBA
+}
+
+// builtinThemes 返回内置主题
+// 古典主题与引入主题系统前的渲染效果完全一致
+func builtinThemes() []Theme {
+	return []Theme{
+		{
+			Name:  ThemeClassic,
+			Title: "古典",
+			Palette: ThemePalette{
+				Text: "#000000", SubText: "#000000", Yao: "#8b4513", Accent: "#000000",
+			},
+			Background: ThemeBackground{
+				Image: "images/background.png", GradientTop: "#f8f0e0", GradientBottom: "#e4e1d6",
+			},
+			Font: ThemeFont{
+				Family: `"KaiTi","STKaiti","AR PL UKai CN",serif`, TitleSize: 39, NormalSize: 30, SmallSize: 26,
+			},
+			Line: ThemeLine{Style: YaoLineSolid, Width: 160, Height: 20, Gap: 1.0 / 3, Stroke: 2},
+		},
+		{
+			Name:  ThemeDark,
+			Base:  ThemeClassic,
+			Title: "深色",
+			Palette: ThemePalette{
+				Text: "#e8e0d0", SubText: "#b8b0a0", Yao: "#d4a050", Accent: "#ff8a65",
+			},
+			Background: ThemeBackground{GradientTop: "#1e1e24", GradientBottom: "#121216"},
+		},
+		{
+			Name:  ThemePrint,
+			Base:  ThemeClassic,
+			Title: "高对比打印",
+			Palette: ThemePalette{
+				Text: "#000000", SubText: "#000000", Yao: "#000000", Accent: "#000000",
+			},
+			Background: ThemeBackground{GradientTop: "#ffffff", GradientBottom: "#ffffff"},
+			Line:       ThemeLine{Height: 24},
+		},
+		{
+			Name:  ThemeMinimal,
+			Base:  ThemeClassic,
+			Title: "极简",
+			Palette: ThemePalette{
+				Text: "#333333", SubText: "#777777", Yao: "#333333", Accent: "#c0392b",
+			},
+			Background: ThemeBackground{GradientTop: "#fafafa", GradientBottom: "#f0f0f0"},
+			Font:       ThemeFont{Family: `"PingFang SC","Microsoft YaHei","Noto Sans CJK SC",sans-serif`},
+			Line:       ThemeLine{Style: YaoLineStroke, Height: 16, Gap: 0.2, Stroke: 2},
+		},
+	}
+}
+
+// themeRegistry 已加载的主题
+var (
+	themeRegistry     map[string]*Theme
+	themeRegistryOnce sync.Once
+)
+
+// getThemes 获取主题表，首次调用时加载内置主题、主题目录和配置中的主题
+// 同名主题后加载的覆盖先加载的
+func getThemes() map[string]*Theme {
+	themeRegistryOnce.Do(func() {
+		themeRegistry = make(map[string]*Theme)
+		for _, theme := range builtinThemes() {
+			registerTheme(theme, "内置")
+		}
+
+		config := GetConfig().Render
+		if config.ThemeDir != "" {
+			files, _ := filepath.Glob(filepath.Join(config.ThemeDir, "*.json"))
+			sort.Strings(files)
+			for _, file := range files {
+				data, err := os.ReadFile(file)
+				if err != nil {
+					log.Printf("读取主题文件失败 %s: %v", file, err)
+					continue
+				}
+				if err := registerThemeJSON(data, file); err != nil {
+					log.Printf("加载主题文件失败 %s: %v", file, err)
+				}
+			}
+		}
+		for i, raw := range config.Themes {
+			if err := registerThemeJSON(raw, fmt.Sprintf("配置themes[%d]", i)); err != nil {
+				log.Printf("加载配置中的主题失败: %v", err)
+			}
+		}
+
+		if _, ok := themeRegistry[config.DefaultTheme]; !ok && config.DefaultTheme != "" {
+			log.Printf("默认主题 %s 不存在，使用 %s", config.DefaultTheme, ThemeClassic)
+		}
+		log.Printf("已加载 %d 个卦象图主题", len(themeRegistry))
+	})
+	return themeRegistry
+}
+
+// registerThemeJSON 解析JSON主题定义并注册
+// 定义中未出现的字段取自base指定的主题，未指定base时取自古典主题
+func registerThemeJSON(data []byte, source string) error {
+	var theme Theme
+	if err := json.Unmarshal(data, &theme); err != nil {
+		return fmt.Errorf("解析主题失败: %v", err)
+	}
+	if theme.Name == "" {
+		return fmt.Errorf("主题缺少name字段")
+	}
+	if theme.Base == "" {
+		theme.Base = ThemeClassic
+	}
+	if _, ok := themeRegistry[theme.Base]; !ok {
+		return fmt.Errorf("主题 %s 继承的主题 %s 不存在", theme.Name, theme.Base)
+	}
+	return registerTheme(theme, source)
+}
+
+// registerTheme 补全继承字段、校验并注册主题
+func registerTheme(theme Theme, source string) error {
+	if base, ok := themeRegistry[theme.Base]; ok && theme.Base != "" {
+		mergeTheme(&theme, base)
+	}
+	if theme.Title == "" {
+		theme.Title = theme.Name
+	}
+	if err := theme.resolve(); err != nil {
+		return fmt.Errorf("主题 %s 无效: %v", theme.Name, err)
+	}
+	themeRegistry[theme.Name] = &theme
+	log.Printf("已注册主题 %s（%s，来源：%s）", theme.Name, theme.Title, source)
+	return nil
+}
+
+// mergeTheme 用base的字段补全theme中的零值字段
+func mergeTheme(theme *Theme, base *Theme) {
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	fillFloat := func(dst *float64, src float64) {
+		if *dst == 0 {
+			*dst = src
+		}
+	}
+	fillInt := func(dst *int, src int) {
+		if *dst == 0 {
+			*dst = src
+		}
+	}
+
+	fill(&theme.Palette.Text, base.Palette.Text)
+	fill(&theme.Palette.SubText, base.Palette.SubText)
+	fill(&theme.Palette.Yao, base.Palette.Yao)
+	fill(&theme.Palette.Accent, base.Palette.Accent)
+	// 背景图片只在两种渐变色都未指定时继承，自定义了渐变色即表示不用图片
+	if theme.Background.GradientTop == "" && theme.Background.GradientBottom == "" {
+		fill(&theme.Background.Image, base.Background.Image)
+	}
+	fill(&theme.Background.GradientTop, base.Background.GradientTop)
+	fill(&theme.Background.GradientBottom, base.Background.GradientBottom)
+	fill(&theme.Font.File, base.Font.File)
+	fill(&theme.Font.Family, base.Font.Family)
+	fillFloat(&theme.Font.TitleSize, base.Font.TitleSize)
+	fillFloat(&theme.Font.NormalSize, base.Font.NormalSize)
+	fillFloat(&theme.Font.SmallSize, base.Font.SmallSize)
+	fill(&theme.Line.Style, base.Line.Style)
+	fillInt(&theme.Line.Width, base.Line.Width)
+	fillInt(&theme.Line.Height, base.Line.Height)
+	fillFloat(&theme.Line.Gap, base.Line.Gap)
+	fillInt(&theme.Line.Stroke, base.Line.Stroke)
+}
+
+// resolve 校验主题并解析颜色
+func (t *Theme) resolve() error {
+	if t.Name == "" {
+		return fmt.Errorf("主题缺少name字段")
+	}
+
+	var err error
+	parse := func(value, field string) color.RGBA {
+		c, e := parseHexColor(value)
+		if e != nil && err == nil {
+			err = fmt.Errorf("%s: %v", field, e)
+		}
+		return c
+	}
+	t.colors = themeColors{
+		text:    parse(t.Palette.Text, "palette.text"),
+		subText: parse(t.Palette.SubText, "palette.sub_text"),
+		yao:     parse(t.Palette.Yao, "palette.yao"),
+		accent:  parse(t.Palette.Accent, "palette.accent"),
+		top:     parse(t.Background.GradientTop, "background.gradient_top"),
+		bottom:  parse(t.Background.GradientBottom, "background.gradient_bottom"),
+	}
+	if err != nil {
+		return err
+	}
+
+	if t.Font.TitleSize <= 0 || t.Font.NormalSize <= 0 || t.Font.SmallSize <= 0 {
+		return fmt.Errorf("字号必须大于0")
+	}
+	if t.Line.Style != YaoLineSolid && t.Line.Style != YaoLineStroke {
+		return fmt.Errorf("爻线样式无效: %s（可选 solid、outline）", t.Line.Style)
+	}
+	if t.Line.Width <= 0 || t.Line.Height <= 0 {
+		return fmt.Errorf("爻线宽度和高度必须大于0")
+	}
+	if t.Line.Gap <= 0 || t.Line.Gap >= 1 {
+		return fmt.Errorf("阴爻空隙比例应在0到1之间")
+	}
+	return nil
+}
+
+// parseHexColor 解析"#rrggbb"格式的颜色
+func parseHexColor(value string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(value), "#")
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("颜色格式应为#rrggbb: %q", value)
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("颜色格式应为#rrggbb: %q", value)
+	}
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255}, nil
+}
+
+// textColor 返回文字样式对应的颜色
+func (t *Theme) textColor(style textStyle) color.RGBA {
+	switch style {
+	case textSmall:
+		return t.colors.subText
+	case textAccent:
+		return t.colors.accent
+	default:
+		return t.colors.text
+	}
+}
+
+// fontSize 返回文字样式对应的像素字号
+func (t *Theme) fontSize(style textStyle) float64 {
+	switch style {
+	case textTitle:
+		return t.Font.TitleSize
+	case textSmall:
+		return t.Font.SmallSize
+	default:
+		return t.Font.NormalSize
+	}
+}
+
+// scaled 返回字号、爻线尺寸和描边按比例缩放的主题副本，供缩放画布以目标尺寸绘制
+func (t *Theme) scaled(scale float64) *Theme {
+	if scale == 1 {
+		return t
+	}
+	scaledTheme := *t
+	scaledTheme.Font.TitleSize = t.Font.TitleSize * scale
+	scaledTheme.Font.NormalSize = t.Font.NormalSize * scale
+	scaledTheme.Font.SmallSize = t.Font.SmallSize * scale
+	scaledTheme.Line.Width = max(int(math.Round(float64(t.Line.Width)*scale)), 1)
+	scaledTheme.Line.Height = max(int(math.Round(float64(t.Line.Height)*scale)), 1)
+	scaledTheme.Line.Stroke = max(int(math.Round(float64(t.Line.Stroke)*scale)), 1)
+	return &scaledTheme
+}
+
+// resolveTheme 确定一次起卦使用的主题
+// 优先级：请求指定的主题 > OneBot群配置的主题 > 默认主题
+//
+// 参数：
+//   - name: 请求中的主题名称，可为空
+//   - groupID: OneBot群号，非群聊时为0
+//
+// 返回值：
+//   - *Theme: 使用的主题
+//   - error: 请求指定的主题不存在时返回错误
+func resolveTheme(name string, groupID int64) (*Theme, error) {
+	themes := getThemes()
+	config := GetConfig().Render
+
+	if name != "" {
+		theme, ok := themes[name]
+		if !ok {
+			return nil, fmt.Errorf("主题不存在: %s（可选 %s）", name, strings.Join(themeNames(), "、"))
+		}
+		return theme, nil
+	}
+
+	if groupID != 0 {
+		if groupTheme, ok := config.GroupThemes[strconv.FormatInt(groupID, 10)]; ok {
+			if theme, ok := themes[groupTheme]; ok {
+				return theme, nil
+			}
+			log.Printf("群 %d 配置的主题 %s 不存在，使用默认主题", groupID, groupTheme)
+		}
+	}
+
+	if theme, ok := themes[config.DefaultTheme]; ok {
+		return theme, nil
+	}
+	return themes[ThemeClassic], nil
+}
+
+// themeNames 返回所有主题名称，按字母排序
+func themeNames() []string {
+	names := make([]string, 0, len(getThemes()))
+	for name := range getThemes() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -3,6 +3,10 @@
 package main
 
 import (
+	"encoding/json"
+	"reflect"
+	"time"
+
 	"golang.org/x/image/font"
 )
 
@@ -28,32 +32,124 @@ type TextCache struct {
 // DivineResult 占卜结果数据结构体
 // 存储一次完整占卜的所有信息，用于API响应和数据存储
 type DivineResult struct {
-	ID          string `json:"id"`          // 占卜结果的唯一标识符
-	Date        string `json:"date"`        // 占卜日期，格式：YYYY-MM-DD
-	Ganzhinian  string `json:"ganzhinian"`  // 干支纪年，如"甲辰年"
-	Ganzhiyue   string `json:"ganzhiyue"`   // 干支纪月，如"丙寅月"
-	Ganzhiri    string `json:"ganzhiri"`    // 干支纪日，如"乙巳日"
-	BenGua      string `json:"bengua"`      // 本卦名称
-	BenGuaDesc  string `json:"benguadesc"`  // 本卦完整描述
-	BianGua     string `json:"biangua"`     // 变卦名称（如果有动爻）
-	BianGuaDesc string `json:"bianguadesc"` // 变卦完整描述（如果有动爻）
-	HasDongYao  bool   `json:"hasdonyao"`   // 是否存在动爻（变爻）
-	ImagePath   string `json:"imagepath"`   // 生成的卦象图片完整URL路径
-	CreatedAt   int64  `json:"created_at"`  // 创建时间戳（Unix时间戳）
+	ID           string   `json:"id"`                      // 占卜结果的唯一标识符
+	Date         string   `json:"date"`                    // 占卜日期，格式：YYYY-MM-DD
+	Ganzhinian   string   `json:"ganzhinian"`              // 干支纪年，如"甲辰年"
+	Ganzhiyue    string   `json:"ganzhiyue"`               // 干支纪月，如"丙寅月"
+	Ganzhiri     string   `json:"ganzhiri"`                // 干支纪日，如"乙巳日"
+	Ganzhishi    string   `json:"ganzhishi"`               // 干支纪时，如"丁亥时"
+	ZiHour       string   `json:"zi_hour,omitempty"`       // 23点之后起卦时排日柱所用的子时排法：late或early，其余钟点为空
+	DivineTime   string   `json:"divine_time"`             // 起卦时刻，RFC3339格式，带请求时区的偏移
+	Timezone     string   `json:"timezone"`                // 起卦时刻所用的IANA时区，如"Asia/Shanghai"
+	SolarTime    string   `json:"solar_time,omitempty"`    // 真太阳时，格式：YYYY-MM-DD HH:MM:SS，未换算时为空
+	Longitude    *float64 `json:"longitude,omitempty"`     // 换算真太阳时所用的经度
+	BenGua       string   `json:"bengua"`                  // 本卦名称
+	BenGuaDesc   string   `json:"benguadesc"`              // 本卦完整描述
+	BianGua      string   `json:"biangua"`                 // 变卦名称（如果有动爻）
+	BianGuaDesc  string   `json:"bianguadesc"`             // 变卦完整描述（如果有动爻）
+	Judgment     string   `json:"judgment,omitempty"`      // 本卦卦辞，目前只在英文时返回
+	BianJudgment string   `json:"bian_judgment,omitempty"` // 变卦卦辞，目前只在英文时返回
+	HasDongYao   bool     `json:"hasdonyao"`               // 是否存在动爻（变爻）
+	ImagePath    string   `json:"imagepath"`               // 落盘图片的完整URL路径，未开启落盘时为空
+	ImageURL     string   `json:"image_url"`               // 图片接口路径，如"/api/v1/divine/{id}/image"
+	ThumbURL     string   `json:"thumb_url"`               // 缩略图接口路径，如"/api/v1/divine/{id}/image?size=thumb"
+	ImageType    string   `json:"image_type"`              // 图片的MIME类型，如"image/png"
+	ImageData    string   `json:"image_data,omitempty"`    // Base64编码的图片数据，仅在请求inline时返回
+	Question     string   `json:"question,omitempty"`      // 所问之事
+	Locale       string   `json:"locale"`                  // 图片文字和卦名描述的语言：zh-Hans、zh-Hant、en
+	CreatedAt    int64    `json:"created_at"`              // 创建时间戳（Unix时间戳）
+}
+
+// ChartExtractResult 从图片中取回盘面的结果
+type ChartExtractResult struct {
+	Metadata   *ChartMetadata `json:"metadata"`             // 图片中的盘面元数据
+	Verified   bool           `json:"verified"`             // 按种子重新摇卦与盘面一致
+	ImageURL   string         `json:"image_url,omitempty"`  // 原图仍可访问时的图片接口路径
+	Rerendered *DivineResult  `json:"rerendered,omitempty"` // 请求重新渲染时的新占卜结果
 }
 
 // DivineRequest 占卜请求参数结构体
 // 客户端发送占卜请求时使用的参数格式
 type DivineRequest struct {
-	Type string `json:"type"` // 占卜类型，目前支持"today"（今日卦象）等
+	Type      string   `json:"type"`                // 占卜类型："today"（今日卦象，每个用户每天一卦）或"cast"（临时起卦），省略时按有无user_id选择
+	DateTime  string   `json:"datetime,omitempty"`  // 起卦时间，如"2025-01-01 14:30"或RFC3339，为空表示当前时间
+	Timezone  string   `json:"timezone,omitempty"`  // 起卦地的IANA时区，如"America/New_York"，为空表示北京时间
+	Longitude *float64 `json:"longitude,omitempty"` // 起卦地经度，东经为正，指定后按真太阳时排时柱
+	Format    string   `json:"format,omitempty"`    // 图片格式：png（默认）、jpeg、svg、gif、apng
+	Quality   int      `json:"quality,omitempty"`   // JPEG的编码质量（1-100），为0时使用配置的默认值
+	Theme     string   `json:"theme,omitempty"`     // 图片主题名称，为空时按群配置或默认主题
+	GroupID   int64    `json:"group_id,omitempty"`  // OneBot群号，用于选择该群配置的主题
+	Layout    string   `json:"layout,omitempty"`    // 版式：landscape（默认）、portrait、square、wide
+	Inline    bool     `json:"inline,omitempty"`    // 是否在结果中直接返回Base64编码的图片
+	Question  string   `json:"question,omitempty"`  // 所问之事，写入图片元数据，不绘制在图中
+	Category  string   `json:"category,omitempty"`  // 问事分类，如"事业"、"感情"，记入占卜历史，可按分类查询
+	Locale    string   `json:"locale,omitempty"`    // 语言：zh-Hans（默认）、zh-Hant、en
+	Brand     string   `json:"brand,omitempty"`     // 品牌叠加配置名称，为空时按群配置或默认品牌，none表示不叠加
+	UserID    string   `json:"user_id,omitempty"`   // 起卦用户，今日卦象按此每天一卦，记入占卜历史，可按用户查询
+}
+
+// UnmarshalJSON 解析占卜请求，user_id可写作字符串或数字（OneBot的user_id为数字）
+func (r *DivineRequest) UnmarshalJSON(data []byte) error {
+	type plainRequest DivineRequest
+	aux := struct {
+		*plainRequest
+		UserID json.RawMessage `json:"user_id,omitempty"`
+	}{plainRequest: (*plainRequest)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	r.UserID = ""
+	if len(aux.UserID) == 0 || string(aux.UserID) == "null" {
+		return nil
+	}
+	if aux.UserID[0] == '"' {
+		return json.Unmarshal(aux.UserID, &r.UserID)
+	}
+	var number json.Number
+	if err := json.Unmarshal(aux.UserID, &number); err != nil {
+		return &json.UnmarshalTypeError{Value: string(aux.UserID), Type: reflect.TypeOf(""), Field: "user_id"}
+	}
+	r.UserID = number.String()
+	return nil
+}
+
+// GuaChart 一次起卦的完整盘面数据
+// 由起卦时刻、四柱干支和卦象组成，是图片渲染和结果输出的共同数据来源
+type GuaChart struct {
+	DivineTime  time.Time  `json:"divine_time"`          // 起卦时刻，位于请求时区
+	Timezone    string     `json:"timezone"`             // 起卦时区名称
+	SolarTime   *time.Time `json:"solar_time,omitempty"` // 真太阳时，四柱按此时刻排定，未换算时为nil
+	Longitude   *float64   `json:"longitude,omitempty"`  // 换算真太阳时所用的经度
+	Ganzhinian  string     `json:"ganzhinian"`           // 干支纪年
+	Ganzhiyue   string     `json:"ganzhiyue"`            // 干支纪月
+	Ganzhiri    string     `json:"ganzhiri"`             // 干支纪日
+	Ganzhishi   string     `json:"ganzhishi"`            // 干支纪时
+	ZiHour      string     `json:"zi_hour,omitempty"`    // 23点之后起卦时排日柱所用的子时排法：late或early，其余钟点为空
+	RiGan       string     `json:"rigan"`                // 日干，用于安六神
+	BenGuaName  string     `json:"bengua"`               // 本卦名称
+	BianGuaName string     `json:"biangua"`              // 变卦名称（无动爻时与本卦相同）
+	BenGua      []int      `json:"bengua_yao"`           // 本卦六爻，从初爻到上爻，1为阳0为阴
+	BianGua     []int      `json:"biangua_yao"`          // 变卦六爻
+	DongYao     []bool     `json:"dongyao"`              // 动爻标记
+	HasDongYao  bool       `json:"hasdonyao"`            // 是否存在动爻
+	Method      string     `json:"method"`               // 起卦方法，目前为"coins"（三枚铜钱摇六次）
+	Seed        int64      `json:"seed"`                 // 六次摇卦的随机种子，generateGua(seed)可复现本卦和动爻
+	Locale      string     `json:"locale,omitempty"`     // 图中文字的语言，为空表示简体中文
 }
 
 // ApiResponse 统一API响应格式结构体
 // 所有HTTP API接口都使用此格式返回数据，确保响应格式的统一性
 type ApiResponse struct {
-	Code    int         `json:"code"`    // 响应状态码，200表示成功
-	Message string      `json:"message"` // 响应消息，成功时为"成功"，失败时为错误描述
-	Data    interface{} `json:"data"`    // 响应数据，具体内容根据接口而定
+	Code    int          `json:"code"`            // 响应状态码，200表示成功
+	Message string       `json:"message"`         // 响应消息，成功时为"成功"，失败时为错误描述
+	Data    interface{}  `json:"data"`            // 响应数据，具体内容根据接口而定
+	Error   *ErrorDetail `json:"error,omitempty"` // 出错时的机器可读信息，成功时省略
+}
+
+// ErrorDetail 错误响应中机器可读的部分
+type ErrorDetail struct {
+	Code  string `json:"code"`            // 错误码，如"invalid_parameter"，取值见api_router.go
+	Field string `json:"field,omitempty"` // 出错的参数名，与参数无关时省略
 }
 
 // CalendarAPIResponse 万年历API响应结构体
@@ -65,9 +161,65 @@ type CalendarAPIResponse struct {
 	Ganzhiri   string `json:"ganzhiri"`   // 干支纪日，如"乙巳日"
 }
 
+// CalendarPillar 单柱历法信息
+type CalendarPillar struct {
+	GanZhi  string `json:"ganzhi"`  // 干支，如"甲辰"
+	NaYin   string `json:"nayin"`   // 纳音五行，如"覆灯火"
+	XunKong string `json:"xunkong"` // 所在旬的空亡地支，如"寅卯"
+}
+
+// LunarDate 农历日期
+type LunarDate struct {
+	Year   int    `json:"year"`    // 农历年（公历纪年数字）
+	Month  int    `json:"month"`   // 农历月，1-12
+	Day    int    `json:"day"`     // 农历日，1-30
+	IsLeap bool   `json:"is_leap"` // 是否闰月
+	Text   string `json:"text"`    // 中文表示，如"二〇二四年腊月初二"
+}
+
+// SolarTermPoint 节气交接时刻
+type SolarTermPoint struct {
+	Name string `json:"name"` // 节气名称，如"冬至"
+	Time string `json:"time"` // 交接时刻，格式YYYY-MM-DD HH:MM:SS（北京时间）
+}
+
+// SolarTermInfo 查询时刻前后的节气信息
+type SolarTermInfo struct {
+	Current string          `json:"current"`        // 当天交接的节气，无则为空
+	Prev    *SolarTermPoint `json:"prev,omitempty"` // 之前最近的一个节气
+	Next    *SolarTermPoint `json:"next,omitempty"` // 之后最近的一个节气
+}
+
+// CalendarInfo 历法查询结果结构体
+// 用于 /api/v1/calendar 接口，包含四柱、纳音、空亡、农历和节气
+type CalendarInfo struct {
+	Date      string          `json:"date"`                 // 公历日期，YYYY-MM-DD
+	Time      string          `json:"time,omitempty"`       // 钟点，HH:MM，未指定时为空
+	Timezone  string          `json:"timezone"`             // 查询使用的时区
+	SolarTime string          `json:"solar_time,omitempty"` // 真太阳时，HH:MM，仅在换算时返回
+	Longitude *float64        `json:"longitude,omitempty"`  // 换算真太阳时所用的经度
+	Year      CalendarPillar  `json:"year"`                 // 年柱
+	Month     CalendarPillar  `json:"month"`                // 月柱
+	Day       CalendarPillar  `json:"day"`                  // 日柱
+	Hour      *CalendarPillar `json:"hour,omitempty"`       // 时柱，未指定钟点时为空
+	ZiHour    string          `json:"zi_hour,omitempty"`    // 23点之后排日柱所用的子时排法：late或early，其余钟点为空
+	KongWang  string          `json:"kongwang"`             // 日空亡地支，六爻断卦所用的旬空
+	Lunar     LunarDate       `json:"lunar"`                // 农历日期
+	SolarTerm SolarTermInfo   `json:"solar_term"`           // 节气信息
+}
+
 // Layout 卦象图片布局参数结构体
 // 定义卦象图片各个元素的位置和尺寸参数
 type Layout struct {
+	名称 string // 版式名称，如"landscape"
+	宽度 int    // 画布宽度
+	高度 int    // 画布高度，爻辞较长时大于版式的最小高度
+
+	标题Y  int // 四柱标题的基线Y坐标
+	副标题Y int // 公历时间的基线Y坐标
+	卦名Y  int // 卦名的基线Y坐标
+	卦宫Y  int // 卦宫的基线Y坐标
+
 	六神X    int // 六神文字的X坐标位置
 	左卦中心X  int // 左侧卦象（本卦）中心X坐标
 	右卦中心X  int // 右侧卦象（变卦）中心X坐标，-1表示不显示
@@ -76,7 +228,30 @@ type Layout struct {
 	爻高度    int // 单个爻的高度
 	爻宽度    int // 单个爻的宽度
 	文字基线偏移 int // 文字相对于爻中心的基线偏移量
-	爻辞Y    int // 爻辞文字的Y坐标位置
+	六亲偏移   int // 六亲纳甲在爻右端之后的距离
+	动爻偏移   int // 动爻标记在爻右端之后的距离
+	伏神偏移   int // 伏神在爻右端之后的距离
+
+	爻辞Y     int  // 爻辞文字的Y坐标位置
+	本卦爻辞X   int  // 有动爻时本卦爻辞的X坐标
+	变卦爻辞X   int  // 有动爻时变卦爻辞的X坐标
+	爻辞宽度    int  // 有动爻时每段爻辞的换行宽度
+	爻辞堆叠    bool // 本卦和变卦爻辞上下排列，为false时左右并排
+	单卦爻辞中心X int  // 无动爻时爻辞区域的中心X坐标
+	单卦爻辞宽度  int  // 无动爻时爻辞的换行宽度
+	爻辞行间距   int  // 爻辞行高
+
+	互卦X int // 互卦文字的中心X坐标，0表示自动放置
+	互卦Y int // 互卦文字的基线Y坐标，0表示自动放置
+
+	栏目 layoutPanels // 显示的栏目，来自版式模板
+}
+
+// layoutPanels 卦象图中各栏目是否显示
+type layoutPanels struct {
+	标题, 副标题, 卦名        bool // 四柱、公历时间、卦名和卦宫
+	六神, 六亲, 动爻, 标签, 伏神 bool // 卦象两侧的文字和卦象下方的标签
+	爻辞, 互卦             bool // 卦辞爻辞和互卦
 }
 
 // WSMessage WebSocket消息结构体
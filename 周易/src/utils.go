@@ -161,7 +161,7 @@ func getYaoWeiName(yaoWei int, 爻 int) string {
 }
 
 // saveImageToPath 保存图像到指定路径（废弃函数，仅保留接口兼容性）
-// 该函数已被saveImageToPathFixed替代，仅用于向后兼容
+// 该函数已被saveImageData替代，仅用于向后兼容
 // 实际的图像保存逻辑现在在image_generator.go中实现
 //
 // 参数：
@@ -1,5 +1,6 @@
 // variables.go 定义了系统运行时使用的全局变量和缓存
-// 包括文本渲染缓存、图片缓存、随机数生成器、万年历缓存等
+// 包括文本渲染缓存、图片缓存、随机数生成器等
+// 万年历缓存见 calendar_cache.go
 package main
 
 import (
@@ -9,49 +10,24 @@ import (
 	"time"
 )
 
-// textCacheMap 文本渲染缓存映射表
-// 缓存已计算的文本宽度和渲染器，避免重复计算提高图片生成性能
-// 键为文本内容，值为TextCache结构体，包含文本宽度、字体面等信息
-var textCacheMap = make(map[string]*TextCache)
-
-// cachedBackground 背景图片缓存
-// 存储预加载的背景图片，避免每次生成卦象时重复读取磁盘文件
-var cachedBackground *image.NRGBA
+// 背景图片缓存
+// 按主题和尺寸存储预加载的背景图片，避免每次生成卦象时重复读取磁盘文件
+var (
+	backgroundCache   = make(map[string]*image.NRGBA) // 键为"主题_宽x高"
+	backgroundCacheMu sync.Mutex                      // 保护backgroundCache
+)
 
 // 全局随机数生成器相关变量
 // 使用单例模式确保整个程序使用同一个随机数生成器
 var (
 	globalRand     *rand.Rand // 全局随机数生成器实例
 	globalRandOnce sync.Once  // 确保随机数生成器只初始化一次
+	globalRandMu   sync.Mutex // rand.Rand不能并发使用，并发起卦时由此串行取种子
 )
 
-// 背景图片缓存控制变量
-// 使用sync.Once确保背景图片只加载一次，提高性能
-var (
-	cachedBackgroundOnce sync.Once // 确保背景图片只加载一次
-)
+// 字体回退链缓存见 fonts.go
 
-// 字体文件缓存变量
-// 字体文件较大，缓存可以显著提高图片生成速度
-var (
-	cachedFontBytes     []byte    // 缓存的字体文件二进制数据
-	cachedFontBytesOnce sync.Once // 确保字体文件只加载一次
-)
-
-// 万年历API结果缓存
-// 由于万年历数据按日期变化，使用日期作为键进行缓存
-// 避免重复调用外部API，提高响应速度和降低网络开销
-var (
-	calendarCache      map[string]CalendarAPIResponse = make(map[string]CalendarAPIResponse) // 万年历数据缓存映射表
-	calendarCacheMutex sync.RWMutex                                                          // 万年历缓存读写锁，保证并发安全
-)
-
-// 图片生成并发控制变量
-// 防止高并发情况下图片生成冲突和资源竞争，确保图片渲染完整性
-var (
-	imageGenerationMutex sync.Mutex                             // 图片生成互斥锁，确保图片生成过程串行化
-	imageGenerationSem   chan struct{} = make(chan struct{}, 3) // 图片生成信号量，限制最大并发数为3
-)
+// 渲染并发控制见 render_pool.go
 
 // getGlobalRand 获取全局随机数生成器
 // 使用单例模式，确保整个程序使用同一个随机数生成器
@@ -65,3 +41,12 @@ func getGlobalRand() *rand.Rand {
 	})
 	return globalRand
 }
+
+// newCastSeed 从全局随机数生成器取一个起卦种子，可并发调用
+// 每次起卦用种子创建独立的随机数生成器，全局生成器只在取种子时加锁
+func newCastSeed() int64 {
+	r := getGlobalRand()
+	globalRandMu.Lock()
+	defer globalRandMu.Unlock()
+	return r.Int63()
+}
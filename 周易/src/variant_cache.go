@@ -0,0 +1,214 @@
+// variant_cache.go 实现缩略图和高清图的内存缓存和高清图的渲染限流
+// 变体与原图分开缓存：高清图单张可达数MB，与原图共用按张数计的存储会挤掉原图并占用大量内存，
+// 因此变体另用按张数和总字节数双重限制的LRU缓存。
+// 高清图渲染耗时且占用内存，每个客户端每分钟新渲染的高清图数受配置限制，已缓存的高清图不受限
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// VariantCache 缩略图和高清图的LRU缓存，同时限制张数和总字节数，所有方法均可并发调用
+type VariantCache struct {
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List // 最近使用的在队首，元素为*variantEntry
+	bytes      int64      // 缓存中图片数据的总字节数
+	maxEntries int        // 最大张数，<=0 表示不限制
+	maxBytes   int64      // 最大总字节数，<=0 表示不限制
+
+	hits      atomic.Int64 // 从缓存中取到变体的次数
+	misses    atomic.Int64 // 缓存中没有该变体的次数
+	evictions atomic.Int64 // 因超出张数或字节数被淘汰的变体数
+}
+
+// variantEntry 缓存中的一张变体
+type variantEntry struct {
+	key string
+	img *renderedImage
+}
+
+// 变体缓存单例
+var (
+	variantCache     *VariantCache
+	variantCacheOnce sync.Once
+)
+
+// getVariantCache 获取全局变体缓存，首次调用时按配置创建
+func getVariantCache() *VariantCache {
+	variantCacheOnce.Do(func() {
+		config := GetConfig().Render.Variants
+		variantCache = NewVariantCache(config.CacheSize, int64(config.CacheMB)<<20)
+	})
+	return variantCache
+}
+
+// NewVariantCache 创建变体缓存
+//
+// 参数：
+//   - maxEntries: 最大张数，<=0 表示不限制
+//   - maxBytes: 最大总字节数，<=0 表示不限制
+//
+// 返回值：新建的缓存实例
+func NewVariantCache(maxEntries int, maxBytes int64) *VariantCache {
+	return &VariantCache{
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+	}
+}
+
+// Get 按键取出变体并标记为最近使用
+//
+// 返回值：
+//   - *renderedImage: 变体图片，调用方不得修改
+//   - bool: 缓存中是否存在
+func (c *VariantCache) Get(key string) (*renderedImage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, found := c.entries[key]
+	if !found {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	c.hits.Add(1)
+	return element.Value.(*variantEntry).img, true
+}
+
+// Put 保存一张变体，超出张数或字节数时淘汰最久未使用的变体
+// 单张超过字节上限的变体不缓存
+func (c *VariantCache) Put(key string, img *renderedImage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, found := c.entries[key]; found {
+		c.removeLocked(element)
+	}
+	if c.maxBytes > 0 && int64(len(img.Data)) > c.maxBytes {
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&variantEntry{key: key, img: img})
+	c.bytes += int64(len(img.Data))
+
+	for c.lru.Len() > 0 && (c.maxEntries > 0 && c.lru.Len() > c.maxEntries || c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.removeLocked(c.lru.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// removeLocked 从缓存中删除一个元素，调用方必须持有锁
+func (c *VariantCache) removeLocked(element *list.Element) {
+	entry := c.lru.Remove(element).(*variantEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= int64(len(entry.img.Data))
+}
+
+// Stats 返回缓存的使用情况
+func (c *VariantCache) Stats() map[string]interface{} {
+	c.mu.Lock()
+	size, bytes := c.lru.Len(), c.bytes
+	c.mu.Unlock()
+
+	return map[string]interface{}{
+		"entries":     size,
+		"max_entries": c.maxEntries,
+		"bytes":       bytes,
+		"max_bytes":   c.maxBytes,
+		"hits":        c.hits.Load(),
+		"misses":      c.misses.Load(),
+		"evictions":   c.evictions.Load(),
+	}
+}
+
+// rateLimitError 客户端新渲染高清图过于频繁
+type rateLimitError struct {
+	retryAfter time.Duration // 距下一个令牌的时长
+}
+
+func (e *rateLimitError) Error() string {
+	return fmt.Sprintf("高清图请求过于频繁，请%d秒后再试", retryAfterSeconds(e.retryAfter))
+}
+
+// retryAfterSeconds 把等待时长换算为Retry-After响应头的秒数，至少1秒
+func retryAfterSeconds(d time.Duration) int {
+	return max(int(math.Ceil(d.Seconds())), 1)
+}
+
+// maxRateLimitClients 限流器记录的客户端数上限，超出时清理令牌已回满的客户端
+const maxRateLimitClients = 4096
+
+// rateLimiter 按客户端的令牌桶限流器，每分钟补充perMinute个令牌，最多积攒perMinute个
+type rateLimiter struct {
+	mu        sync.Mutex
+	perMinute int
+	buckets   map[string]*rateBucket
+}
+
+// rateBucket 一个客户端的令牌桶
+type rateBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// 高清图限流器单例
+var (
+	hiResLimiter     *rateLimiter
+	hiResLimiterOnce sync.Once
+)
+
+// getHiResLimiter 获取高清图的渲染限流器，首次调用时按配置创建
+func getHiResLimiter() *rateLimiter {
+	hiResLimiterOnce.Do(func() {
+		hiResLimiter = newRateLimiter(GetConfig().Render.Variants.HiResPerMinute)
+	})
+	return hiResLimiter
+}
+
+// newRateLimiter 创建限流器，perMinute<=0 表示不限制
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{perMinute: perMinute, buckets: make(map[string]*rateBucket)}
+}
+
+// allow 为客户端取一个令牌
+//
+// 返回值：
+//   - bool: 是否允许
+//   - time.Duration: 不允许时距下一个令牌的时长
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	if l.perMinute <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	capacity := float64(l.perMinute)
+	rate := capacity / time.Minute.Seconds() // 每秒补充的令牌数
+	if len(l.buckets) >= maxRateLimitClients {
+		for key, bucket := range l.buckets {
+			if bucket.tokens+now.Sub(bucket.updated).Seconds()*rate >= capacity {
+				delete(l.buckets, key)
+			}
+		}
+	}
+
+	bucket, found := l.buckets[client]
+	if !found {
+		bucket = &rateBucket{tokens: capacity, updated: now}
+		l.buckets[client] = bucket
+	}
+	bucket.tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.updated).Seconds()*rate)
+	bucket.updated = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
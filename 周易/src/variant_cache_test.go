@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// testVariant 生成指定大小的变体图片，内容只用于计算字节数
+func testVariant(size int) *renderedImage {
+	return &renderedImage{Data: make([]byte, size), ContentType: "image/png"}
+}
+
+// TestVariantCacheLRU 超出张数或总字节数时淘汰最久未用的变体，过大的变体不缓存
+func TestVariantCacheLRU(t *testing.T) {
+	cache := NewVariantCache(3, 1000)
+	for i := 1; i <= 3; i++ {
+		cache.Put(fmt.Sprintf("v%d", i), testVariant(100))
+	}
+	// 读取v1后v2最久未用，写入v4时淘汰v2
+	if _, found := cache.Get("v1"); !found {
+		t.Fatal("v1 应命中")
+	}
+	cache.Put("v4", testVariant(100))
+	if _, found := cache.Get("v2"); found {
+		t.Error("超出张数时应淘汰最久未用的v2")
+	}
+	for _, key := range []string{"v1", "v3", "v4"} {
+		if _, found := cache.Get(key); !found {
+			t.Errorf("%s 不应被淘汰", key)
+		}
+	}
+
+	// 总字节数超限时从最久未用的开始淘汰，直到不超限
+	cache.Put("big", testVariant(850))
+	stats := cache.Stats()
+	if stats["bytes"].(int64) > 1000 || stats["entries"] != 2 {
+		t.Errorf("写入850字节后统计为 %v", stats)
+	}
+	if _, found := cache.Get("big"); !found {
+		t.Error("刚写入的变体不应被淘汰")
+	}
+
+	// 单张超过上限的不缓存，也不挤掉已有的变体
+	cache.Put("huge", testVariant(1001))
+	if _, found := cache.Get("huge"); found {
+		t.Error("超过字节上限的变体不应缓存")
+	}
+	if _, found := cache.Get("big"); !found {
+		t.Error("过大的变体不应挤掉已有的变体")
+	}
+
+	// 同一键重复写入按新数据计算字节数
+	cache.Put("big", testVariant(10))
+	if stats := cache.Stats(); stats["bytes"].(int64) != 110 {
+		t.Errorf("覆盖写入后字节数为 %v，期望 110", stats["bytes"])
+	}
+}
+
+// TestRateLimiter 每个客户端可连续取perMinute个令牌，之后按速率补充，客户端之间互不影响
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(6)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		if ok, _ := limiter.allow("1.2.3.4", now); !ok {
+			t.Fatalf("第%d次请求应被允许", i+1)
+		}
+	}
+	ok, wait := limiter.allow("1.2.3.4", now)
+	if ok || wait != 10*time.Second {
+		t.Fatalf("超出后应拒绝并等待10秒，实际 %v, %v", ok, wait)
+	}
+	if ok, _ := limiter.allow("5.6.7.8", now); !ok {
+		t.Error("其他客户端不受影响")
+	}
+	if ok, _ := limiter.allow("1.2.3.4", now.Add(10*time.Second)); !ok {
+		t.Error("10秒后应补充一个令牌")
+	}
+	if ok, _ := limiter.allow("1.2.3.4", now.Add(10*time.Second)); ok {
+		t.Error("补充的令牌已用完")
+	}
+
+	if ok, _ := newRateLimiter(0).allow("1.2.3.4", now); !ok {
+		t.Error("perMinute为0时不限制")
+	}
+}
@@ -30,9 +30,12 @@ type WSManager struct {
 
 // WebSocket客户端
 type WSClient struct {
-	ID   string
-	Conn *websocket.Conn
-	Send chan WSMessage
+	ID      string
+	Name    string // 连接时通过?name=指定的客户端名称，请求未带user_id时作为今日卦象的用户标识
+	UserID  string // 连接时按X-User-ID请求头或客户端地址得到的标识，未带user_id和name的今日卦象使用
+	BaseURL string // 连接时按请求推断的对外访问地址，用于生成图片链接
+	Conn    *websocket.Conn
+	Send    chan WSMessage
 }
 
 var wsManager *WSManager
@@ -89,8 +92,13 @@ func (m *WSManager) run() {
 			clientsToRemove := make([]string, 0)
 
 			for id, client := range m.clients {
+				clientMessage := message
+				if result, ok := message.Data.(*DivineResult); ok {
+					// 占卜结果中的链接按接收方连接时的地址转换
+					clientMessage.Data = publicResult(result, client.BaseURL)
+				}
 				select {
-				case client.Send <- message:
+				case client.Send <- clientMessage:
 					// 消息发送成功
 				default:
 					// 客户端发送通道已满或已关闭，标记为需要移除
@@ -148,9 +156,12 @@ func handleWSConnection(w http.ResponseWriter, r *http.Request) {
 	clientID := fmt.Sprintf("client_%d", time.Now().UnixNano())
 
 	client := &WSClient{
-		ID:   clientID,
-		Conn: conn,
-		Send: make(chan WSMessage, 256),
+		ID:      clientID,
+		Name:    r.URL.Query().Get("name"),
+		UserID:  requestUserID(r),
+		BaseURL: publicBaseURL(r),
+		Conn:    conn,
+		Send:    make(chan WSMessage, 256),
 	}
 
 	// 注册客户端
@@ -287,42 +298,77 @@ func (c *WSClient) handleMessage(msg WSMessage) {
 
 // 处理占卜请求
 func (c *WSClient) handleDivineRequest(msg WSMessage) {
+	// 按接口文档校验请求参数，data可携带datetime、timezone等字段
+	request := &openAPISchema{Ref: schemaRefPrefix + "DivineRequest"}
+	if apiErr := getOpenAPIDocument().validateValue(request, msg.Data, ""); apiErr != nil {
+		c.sendRequestError(msg.Data, apiErr)
+		return
+	}
+
+	var req DivineRequest
+	if msg.Data != nil {
+		if raw, err := json.Marshal(msg.Data); err == nil {
+			json.Unmarshal(raw, &req)
+		}
+	}
+	if req.UserID == "" {
+		req.UserID = c.Name
+	}
+	if req.UserID == "" && isTodayType(req.Type) {
+		req.UserID = c.UserID
+	}
+	// 参数错误与HTTP接口一样按字段返回
+	if apiErr := validateDivineRequest(&req); apiErr != nil {
+		c.sendRequestError(msg.Data, apiErr)
+		return
+	}
+
 	// 生成卦象图片
-	imagePath, err := generateTodayGua()
+	result, err := generateDivination(&req)
 	if err != nil {
+		locale, _ := matchLocale(req.Locale)
 		errorMsg := WSMessage{
 			Type: WSEventError,
 			Data: map[string]interface{}{
 				"error":   err.Error(),
-				"message": "生成卦象失败",
+				"message": newLocalizer(locale).text("生成卦象失败"),
 			},
 		}
 		c.Send <- errorMsg
 		return
 	}
 
-	// 构建完整的图片URL
-	config := GetConfig()
-	port := config.Server.Port
-	fullImageURL := fmt.Sprintf("http://localhost:%s/%s", port, imagePath)
-
-	// 创建响应
-	now := time.Now()
-	result := DivineResult{
-		ID:        fmt.Sprintf("divine_%d", now.UnixNano()),
-		Date:      now.Format("2006-01-02"),
-		ImagePath: fullImageURL, // 返回完整的图片URL
-		CreatedAt: now.Unix(),
-	}
-
 	response := WSMessage{
 		Type: WSEventDivine,
-		Data: result,
+		Data: publicResult(result, c.BaseURL),
 	}
 
 	c.Send <- response
 }
 
+// sendRequestError 向客户端发送请求参数错误，附带出错的字段
+func (c *WSClient) sendRequestError(data interface{}, apiErr *apiError) {
+	locale, _ := matchLocale(localeOf(data))
+	c.Send <- WSMessage{
+		Type: WSEventError,
+		Data: map[string]interface{}{
+			"error":   apiErr.Message,
+			"message": newLocalizer(locale).text("请求参数错误"),
+			"field":   apiErr.Field,
+		},
+	}
+}
+
+// localeOf 取出消息数据中的locale字段，用于在解析请求之前选择错误提示的语言
+func localeOf(data interface{}) string {
+	if object, ok := data.(map[string]interface{}); ok {
+		if locale, ok := object["locale"].(string); ok {
+			return locale
+		}
+	}
+	return ""
+}
+
 // 广播消息到所有连接的客户端
 func BroadcastMessage(msgType string, data interface{}) {
 	if wsManager != nil {
@@ -350,6 +396,8 @@ func handleWSStatus(w http.ResponseWriter, r *http.Request) {
 		"connected_clients": GetConnectedClientsCount(),
 		"server_time":       time.Now().Unix(),
 		"websocket_enabled": true,
+		"calendar_cache":    getCalendarCache().Stats(),
+		"calendar_verify":   calendarVerifyStats(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")